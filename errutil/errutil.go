@@ -0,0 +1,197 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+/*
+Package errutil - A structured error type that carries key/value fields and a class alongside a
+message and a wrapped cause, which the logger prints as fields and stats can count by class. Errors
+are compatible with errors.Is and errors.As of the standard library.
+*/
+package errutil
+
+import (
+	"errors"
+	"fmt"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Error - An error with a message, an optional class such as "timeout" that groups errors for
+counting, key/value fields that describe it and an optional cause that it wraps. Errors are
+immutable, where With, WithFields and WithClass return copies, and errors.Is matches a copy against
+the error it was made from.
+*/
+type Error struct {
+	message string
+	class   string
+	fields  map[string]interface{}
+	cause   error
+	origin  *Error
+}
+
+// New - Creates an error with a message.
+func New(message string) *Error {
+	e := &Error{message: message}
+	e.origin = e
+	return e
+}
+
+// Newf - Creates an error with a formatted message.
+func Newf(format string, args ...interface{}) *Error {
+	return New(fmt.Sprintf(format, args...))
+}
+
+// Wrap - Creates an error with a message that wraps a cause, or returns nil when the cause is nil.
+func Wrap(cause error, message string) *Error {
+	if cause == nil {
+		return nil
+	}
+	e := New(message)
+	e.cause = cause
+	return e
+}
+
+// Wrapf - Creates an error with a formatted message that wraps a cause, or returns nil when the
+// cause is nil.
+func Wrapf(cause error, format string, args ...interface{}) *Error {
+	return Wrap(cause, fmt.Sprintf(format, args...))
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// With - Returns a copy of the error with a field added.
+func (e *Error) With(key string, value interface{}) *Error {
+	return e.WithFields(map[string]interface{}{key: value})
+}
+
+// WithFields - Returns a copy of the error with fields added, where fields of the same key replace
+// those of the error.
+func (e *Error) WithFields(fields map[string]interface{}) *Error {
+	c := *e
+	c.fields = make(map[string]interface{}, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		c.fields[k] = v
+	}
+	for k, v := range fields {
+		c.fields[k] = v
+	}
+	return &c
+}
+
+// WithClass - Returns a copy of the error with a class.
+func (e *Error) WithClass(class string) *Error {
+	c := *e
+	c.class = class
+	return &c
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Error - Returns the message of the error, followed by the message of its cause.
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.message
+	}
+	return e.message + ": " + e.cause.Error()
+}
+
+// Unwrap - Returns the cause of the error, which allows errors.Is and errors.As to inspect it.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is - Returns whether a target is the error that this error was made from, or a copy of it.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.origin == e.origin
+}
+
+// Fields - Returns the fields of the error and of every error it wraps, where the fields of outer
+// errors replace those of the same key of their causes.
+func (e *Error) Fields() map[string]interface{} {
+	return Fields(e)
+}
+
+// Class - Returns the class of the error, or of the outermost error it wraps that has one.
+func (e *Error) Class() string {
+	return Class(e)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Fields - Returns the fields of every Error within the chain of an error, where the fields of outer
+// errors replace those of the same key of their causes. Returns nil when there are none.
+func Fields(err error) map[string]interface{} {
+	var chain []*Error
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			chain = append(chain, e)
+		}
+		err = errors.Unwrap(err)
+	}
+
+	var fields map[string]interface{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i].fields {
+			if fields == nil {
+				fields = map[string]interface{}{}
+			}
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// Class - Returns the class of the outermost Error within the chain of an error that has one, or an
+// empty string when there is none.
+func Class(err error) string {
+	for err != nil {
+		if e, ok := err.(*Error); ok && e.class != "" {
+			return e.class
+		}
+		err = errors.Unwrap(err)
+	}
+	return ""
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Counter - Increments counters of stats, which is satisfied by the Type of the metrics package.
+type Counter interface {
+	Incr(path string, count int64) error
+}
+
+// Count - Increments the stat "<path>.<class>" of the class of an error, such as
+// "errors.timeout", where errors without a class are counted as "unclassified". Nil errors are not
+// counted.
+func Count(stats Counter, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	class := Class(err)
+	if class == "" {
+		class = "unclassified"
+	}
+	return stats.Incr(path+"."+class, 1)
+}
+
+//--------------------------------------------------------------------------------------------------
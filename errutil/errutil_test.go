@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package errutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+var errNotFound = New("not found").WithClass("not_found")
+
+func TestError(t *testing.T) {
+	err := Wrap(errNotFound.With("id", 1), "loading user").With("user", "bob")
+
+	if exp := "loading user: not found"; exp != err.Error() {
+		t.Errorf("Wrong message: %v != %v", err.Error(), exp)
+	}
+	if !errors.Is(err, errNotFound) {
+		t.Error("Expected wrapped copy to match the original error")
+	}
+	if errors.Is(err, New("not found")) {
+		t.Error("Expected distinct errors not to match")
+	}
+	if exp := map[string]interface{}{"id": 1, "user": "bob"}; !reflect.DeepEqual(exp, err.Fields()) {
+		t.Errorf("Wrong fields: %v != %v", err.Fields(), exp)
+	}
+	if exp := "not_found"; exp != err.Class() {
+		t.Errorf("Wrong class: %v != %v", err.Class(), exp)
+	}
+
+	var target *Error
+	if !errors.As(fmt.Errorf("handler: %w", err), &target) || target.Fields()["user"] != "bob" {
+		t.Error("Expected errors.As to find the error")
+	}
+	if cause := Wrap(io.EOF, "reading"); !errors.Is(cause, io.EOF) || Class(cause) != "" {
+		t.Error("Expected cause to be unwrapped")
+	}
+	if Wrap(nil, "nothing") != nil {
+		t.Error("Expected nil error for nil cause")
+	}
+}
+
+type counts map[string]int64
+
+func (c counts) Incr(path string, count int64) error {
+	c[path] += count
+	return nil
+}
+
+func TestCount(t *testing.T) {
+	stats := counts{}
+	Count(stats, "errors", Wrap(errNotFound, "loading user"))
+	Count(stats, "errors", io.EOF)
+	Count(stats, "errors", nil)
+
+	if exp := (counts{"errors.not_found": 1, "errors.unclassified": 1}); !reflect.DeepEqual(exp, stats) {
+		t.Errorf("Wrong counts: %v != %v", stats, exp)
+	}
+}
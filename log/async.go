@@ -0,0 +1,248 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// ErrFlushTimeout - Returned when queued messages were not written within the flush timeout.
+var ErrFlushTimeout = errors.New("timed out whilst flushing queued log messages")
+
+// fatalFlushTimeout - How long a fatal message waits for queued messages to be written.
+const fatalFlushTimeout = 5 * time.Second
+
+//--------------------------------------------------------------------------------------------------
+
+// logJob - A message queued to be written by a logger, which is either an entry or a raw line
+// given to Output. A job with a flushed channel writes nothing and closes the channel instead.
+type logJob struct {
+	logger  *Logger
+	entry   Entry
+	raw     string
+	flushed chan struct{}
+}
+
+// run - Writes the message of a job.
+func (j *logJob) run() {
+	if j.flushed != nil {
+		close(j.flushed)
+		return
+	}
+	if j.raw != "" {
+		io.WriteString(j.logger.stream, j.raw)
+		return
+	}
+	j.logger.write(&j.entry)
+}
+
+/*
+asyncQueue - A bounded queue of messages that are written in order by a background loop, shared by
+every module and child of an asynchronous logger. Messages pushed after the queue is closed are
+written immediately instead. Whilst the queue is full messages of the drop level or higher are
+dropped and counted, and messages of lower levels block until there is room.
+*/
+type asyncQueue struct {
+	sync.RWMutex
+
+	jobs   chan logJob
+	closed bool
+	quit   chan struct{}
+	done   chan struct{}
+
+	dropLevel int
+	dropped   int64
+	dropStats atomic.Value
+}
+
+// dropCounter - Where dropped messages are counted in stats.
+type dropCounter struct {
+	stats StatCounter
+	path  string
+}
+
+// newAsyncQueue - Creates a queue of a size and starts its background loop. A drop level of LogOff
+// or lower never drops messages.
+func newAsyncQueue(size, dropLevel int) *asyncQueue {
+	if size < 0 {
+		size = 0
+	}
+	q := &asyncQueue{
+		jobs:      make(chan logJob, size),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+		dropLevel: dropLevel,
+	}
+	go q.loop()
+	return q
+}
+
+// push - Queues a job of a level, blocking whilst the queue is full unless the job is dropped.
+func (q *asyncQueue) push(j logJob, level int) {
+	q.RLock()
+	defer q.RUnlock()
+
+	if q.closed {
+		j.run()
+		return
+	}
+	if q.dropLevel <= LogOff || level < q.dropLevel {
+		q.jobs <- j
+		return
+	}
+	select {
+	case q.jobs <- j:
+	default:
+		q.drop()
+	}
+}
+
+// drop - Counts a dropped message.
+func (q *asyncQueue) drop() {
+	atomic.AddInt64(&q.dropped, 1)
+	if c, ok := q.dropStats.Load().(dropCounter); ok {
+		c.stats.Incr(c.path, 1)
+	}
+}
+
+// loop - Runs queued jobs until the queue is closed, after which the jobs left are run.
+func (q *asyncQueue) loop() {
+	defer close(q.done)
+	for {
+		select {
+		case j := <-q.jobs:
+			j.run()
+		case <-q.quit:
+			for {
+				select {
+				case j := <-q.jobs:
+					j.run()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush - Blocks until every job queued beforehand has been run, or until the timeout, which also
+// covers waiting for room in a full queue.
+func (q *asyncQueue) flush(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	flushed := make(chan struct{})
+	if !q.pushUntil(logJob{flushed: flushed}, timer.C) {
+		return ErrFlushTimeout
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-timer.C:
+		return ErrFlushTimeout
+	}
+}
+
+// pushUntil - Queues a job, blocking whilst the queue is full until a channel fires, and returns
+// whether the job was queued.
+func (q *asyncQueue) pushUntil(j logJob, stop <-chan time.Time) bool {
+	q.RLock()
+	defer q.RUnlock()
+
+	if q.closed {
+		j.run()
+		return true
+	}
+	select {
+	case q.jobs <- j:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// close - Stops the background loop once the jobs left are run.
+func (q *asyncQueue) close() {
+	q.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.quit)
+	}
+	q.Unlock()
+	<-q.done
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Flush - Blocks until every message printed beforehand has been written, or returns
+// ErrFlushTimeout once the timeout has passed. Any summary of held back duplicate messages is
+// written first.
+func (l *Logger) Flush(timeout time.Duration) error {
+	if l.dedupe != nil {
+		l.dedupe.expire()
+	}
+	if l.async == nil {
+		return nil
+	}
+	return l.async.flush(timeout)
+}
+
+// Dropped - Returns the number of messages that were dropped because the queue of an asynchronous
+// logger was full, counted across every module and child of the logger.
+func (l *Logger) Dropped() int64 {
+	if l.async == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&l.async.dropped)
+}
+
+// SetDropStats - Increments a counter of stats with the path for every message that is dropped
+// because the queue of an asynchronous logger was full, where an empty path is treated as
+// "log.dropped". This applies to every module and child of the logger.
+func (l *Logger) SetDropStats(stats StatCounter, path string) {
+	if l.async == nil {
+		return
+	}
+	if path == "" {
+		path = "log.dropped"
+	}
+	l.async.dropStats.Store(dropCounter{stats: stats, path: path})
+}
+
+// Close - Writes every queued message and stops the background loop of an asynchronous logger, after
+// which messages are written synchronously. This applies to every module and child of the logger.
+func (l *Logger) Close() error {
+	if l.async != nil {
+		l.async.close()
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
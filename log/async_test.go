@@ -0,0 +1,201 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingBuffer - A log buffer that blocks each write until it is released.
+type blockingBuffer struct {
+	sync.Mutex
+	data    string
+	release chan struct{}
+}
+
+func (b *blockingBuffer) Write(p []byte) (int, error) {
+	<-b.release
+	b.Lock()
+	b.data += string(p)
+	b.Unlock()
+	return len(p), nil
+}
+
+func (b *blockingBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.data
+}
+
+func TestAsyncLogging(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Async = true
+	loggerConfig.AsyncBufferSize = 10
+
+	buf := &blockingBuffer{release: make(chan struct{})}
+
+	logger := NewLogger(buf, loggerConfig).(*Logger)
+	defer logger.Close()
+
+	module := logger.NewModule(".foo")
+
+	// Printing returns whilst the stream is blocked.
+	logger.Infoln("First")
+	module.Warnf("Second\n")
+	logger.Output(0, "Raw\n")
+
+	if err := logger.Flush(10 * time.Millisecond); err != ErrFlushTimeout {
+		t.Errorf("Wrong error: %v != %v", err, ErrFlushTimeout)
+	}
+
+	close(buf.release)
+	if err := module.(*Logger).Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "INFO | root | First\nWARN | root.foo | Second\nRaw\n"
+	if act := buf.String(); expected != act {
+		t.Errorf("Async logging does not match: %v != %v", act, expected)
+	}
+}
+
+func TestAsyncFatalAndClose(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Async = true
+
+	buf := &blockingBuffer{release: make(chan struct{})}
+	close(buf.release)
+
+	logger := NewLogger(buf, loggerConfig).(*Logger)
+	logger.Infoln("First")
+	logger.Fatalln("Second")
+
+	// Fatal messages are flushed before returning.
+	expected := "INFO | root | First\nFATAL | root | Second\n"
+	if act := buf.String(); expected != act {
+		t.Errorf("Fatal was not flushed: %v != %v", act, expected)
+	}
+
+	logger.Errorln("Third")
+	logger.Close()
+	logger.Errorln("Fourth")
+
+	expected += "ERROR | root | Third\nERROR | root | Fourth\n"
+	if act := buf.String(); expected != act {
+		t.Errorf("Close did not write queued messages: %v != %v", act, expected)
+	}
+}
+
+func TestAsyncFlushStalled(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Async = true
+	loggerConfig.AsyncBufferSize = 1
+
+	buf := &blockingBuffer{release: make(chan struct{})}
+
+	logger := NewLogger(buf, loggerConfig).(*Logger)
+	defer logger.Close()
+	defer close(buf.release)
+
+	// The first message blocks the background loop on the stream and the second fills the queue.
+	logger.Infoln("First")
+	logger.Infoln("Second")
+	go logger.Infoln("Third")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- logger.Flush(50 * time.Millisecond)
+	}()
+
+	select {
+	case err := <-done:
+		if err != ErrFlushTimeout {
+			t.Errorf("Wrong error: %v != %v", err, ErrFlushTimeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush did not time out whilst the queue was full")
+	}
+}
+
+func TestAsyncDrop(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "DEBUG"
+	loggerConfig.Async = true
+	loggerConfig.AsyncBufferSize = 0
+	loggerConfig.AsyncDropLevel = "INFO"
+
+	buf := &blockingBuffer{release: make(chan struct{})}
+	stats := &statCounts{counts: map[string]int64{}}
+
+	logger := NewLogger(buf, loggerConfig).(*Logger)
+	defer logger.Close()
+	logger.SetDropStats(stats, "")
+
+	module := logger.NewModule(".foo")
+
+	// The warning is taken by the background loop, which then blocks on the stream, and so the
+	// following messages of the drop level or higher are dropped.
+	logger.Warnln("First")
+	logger.Infoln("Dropped")
+	module.Debugf("Dropped\n")
+	logger.Output(0, "Dropped\n")
+
+	close(buf.release)
+	logger.Errorln("Second")
+	if err := logger.Flush(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "WARN | root | First\nERROR | root | Second\n"
+	if act := buf.String(); expected != act {
+		t.Errorf("Async logging does not match: %v != %v", act, expected)
+	}
+	if exp, act := int64(3), module.(*Logger).Dropped(); exp != act {
+		t.Errorf("Wrong dropped count: %v != %v", act, exp)
+	}
+	if exp, act := int64(3), stats.counts["log.dropped"]; exp != act {
+		t.Errorf("Wrong dropped stat: %v != %v", act, exp)
+	}
+}
+
+func TestSyncFlush(t *testing.T) {
+	logger := NewLogger(&LogCounter{}, NewLoggerConfig()).(*Logger)
+	if err := logger.Flush(0); err != nil {
+		t.Error(err)
+	}
+	if logger.Dropped() != 0 {
+		t.Error("Synchronous logger dropped messages")
+	}
+	if err := logger.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the audit log.
+var (
+	ErrAuditDisabled = errors.New("no audit log was set for the logger")
+	ErrAuditTampered = errors.New("audit log record does not match its hash chain")
+)
+
+// auditHashSuffix - The length of the hash field that ends each record, which is a quoted hex
+// SHA-256 sum followed by the closing brace.
+const auditHashSuffix = len(`,"hash":""}`) + sha256.Size*2
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+AuditConfig - Config for an audit log, an append-only record of compliance events kept apart from
+the levelled log so that it is never subject to levels, sampling or filters. Records are written to
+the file at Path, which is only ever appended to, or to a stream such as a NetworkWriter. Each
+record carries a sequence number and the hash of the previous record so that removed, reordered or
+modified records can be detected with VerifyAudit. When Key is set the hashes are HMAC-SHA256 sums
+with the key, so that records cannot be forged without it, otherwise they are SHA-256 sums.
+*/
+type AuditConfig struct {
+	Path string `json:"path" yaml:"path"`
+	Key  string `json:"key" yaml:"key"`
+}
+
+// NewAuditConfig - Returns an audit configuration with the default values for each field.
+func NewAuditConfig() AuditConfig {
+	return AuditConfig{
+		Path: "audit.log",
+		Key:  "",
+	}
+}
+
+// auditRecord - The fields of an audit record that are checked by verification.
+type auditRecord struct {
+	Seq  uint64 `json:"seq"`
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+AuditLog - Writes audit records as single line JSON objects with the keys seq, time, service,
+event, fields, prev and hash. Records are written synchronously and the stream is synced after each
+record when it supports it, such that Record only returns once the event is persisted.
+*/
+type AuditLog struct {
+	sync.Mutex
+	stream io.Writer
+	key    []byte
+	seq    uint64
+	prev   string
+}
+
+// NewAuditLog - Opens the audit file of a config for appending, continuing the sequence and hash
+// chain of any records already within it.
+func NewAuditLog(config AuditConfig) (*AuditLog, error) {
+	a := &AuditLog{key: []byte(config.Key)}
+	if f, err := os.Open(config.Path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1024*1024)
+		for scanner.Scan() {
+			var r auditRecord
+			if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				break
+			}
+			a.seq, a.prev = r.Seq, r.Hash
+		}
+		if err == nil {
+			err = scanner.Err()
+		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	a.stream = f
+	return a, nil
+}
+
+// NewAuditLogWriter - Creates an audit log that writes records to a stream, such as a remote
+// target, starting a new sequence and hash chain. The path of the config is ignored.
+func NewAuditLogWriter(stream io.Writer, config AuditConfig) *AuditLog {
+	return &AuditLog{stream: stream, key: []byte(config.Key)}
+}
+
+// newAuditHash - Returns the hash of records, which is keyed when a key is set.
+func newAuditHash(key []byte) hash.Hash {
+	if len(key) > 0 {
+		return hmac.New(sha256.New, key)
+	}
+	return sha256.New()
+}
+
+// Record - Writes an audit record of an event of a service along with its fields, returning an
+// error when the record could not be written or persisted.
+func (a *AuditLog) Record(service, event string, fields map[string]interface{}) error {
+	a.Lock()
+	defer a.Unlock()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"seq":%v,"time":`, a.seq+1)
+	writeJSONString(&buf, time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"service":`)
+	writeJSONString(&buf, service)
+	buf.WriteString(`,"event":`)
+	writeJSONString(&buf, event)
+	buf.WriteString(`,"fields":`)
+	writeJSONFields(&buf, fields)
+	buf.WriteString(`,"prev":`)
+	writeJSONString(&buf, a.prev)
+	buf.WriteByte('}')
+
+	h := newAuditHash(a.key)
+	h.Write(buf.Bytes())
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	buf.Truncate(buf.Len() - 1)
+	buf.WriteString(`,"hash":"` + sum + "\"}\n")
+	if _, err := a.stream.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if s, ok := a.stream.(syncer); ok {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+	a.seq, a.prev = a.seq+1, sum
+	return nil
+}
+
+// Close - Closes the stream of the audit log when it supports it.
+func (a *AuditLog) Close() error {
+	if c, ok := a.stream.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+VerifyAudit - Reads the records of an audit log and checks that each is unmodified, that sequence
+numbers increase by one from the first record, and that each record carries the hash of the one
+before it. Returns the number of records that were verified, along with ErrAuditTampered or a read
+error when verification stops early.
+*/
+func VerifyAudit(r io.Reader, key string) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	var n int
+	var prev auditRecord
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var record auditRecord
+		if len(line) <= auditHashSuffix || json.Unmarshal(line, &record) != nil {
+			return n, ErrAuditTampered
+		}
+
+		body := append(append([]byte{}, line[:len(line)-auditHashSuffix]...), '}')
+		h := newAuditHash([]byte(key))
+		h.Write(body)
+		if hex.EncodeToString(h.Sum(nil)) != record.Hash {
+			return n, ErrAuditTampered
+		}
+		if n > 0 && (record.Seq != prev.Seq+1 || record.Prev != prev.Hash) {
+			return n, ErrAuditTampered
+		}
+		prev = record
+		n++
+	}
+	return n, scanner.Err()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// newAuditHolder - Creates the shared holder of the audit log of a logger.
+func newAuditHolder() *atomic.Value {
+	v := &atomic.Value{}
+	v.Store((*AuditLog)(nil))
+	return v
+}
+
+// SetAudit - Sets the audit log written to by Audit, which applies to every module and child of the
+// logger.
+func (l *Logger) SetAudit(a *AuditLog) {
+	l.audit.Store(a)
+}
+
+/*
+Audit - Writes an audit record of an event, where the service is the prefix of the logger and the
+fields of the logger are added to those of the event. Audit records are written regardless of the
+level of the logger and are not subject to sampling, filters or hooks. Returns ErrAuditDisabled when
+no audit log is set, otherwise any error writing the record, which callers should treat as fatal
+to the operation being audited.
+*/
+func (l *Logger) Audit(event string, fields map[string]interface{}) error {
+	a := l.audit.Load().(*AuditLog)
+	if a == nil {
+		return ErrAuditDisabled
+	}
+	if len(l.fields) > 0 {
+		merged := make(map[string]interface{}, len(l.fields)+len(fields))
+		for k, v := range l.fields {
+			merged[k] = v
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		fields = merged
+	}
+	return a.Record(l.config.Prefix, event, fields)
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,119 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewAuditConfig()
+	conf.Path = filepath.Join(dir, "audit.log")
+	conf.Key = "secret"
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "OFF"
+
+	logger := NewLogger(ioutil.Discard, loggerConfig).(*Logger)
+	if err = logger.Audit("user.created", nil); err != ErrAuditDisabled {
+		t.Errorf("Wrong error: %v != %v", err, ErrAuditDisabled)
+	}
+
+	a, err := NewAuditLog(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.SetAudit(a)
+	module := logger.NewModule(".users").(*Logger).WithFields(map[string]interface{}{"admin": "bob"})
+	if err = module.Audit("user.created", map[string]interface{}{"user": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = logger.Audit("user.deleted", nil); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	// Reopening continues the chain.
+	if a, err = NewAuditLog(conf); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Record("root", "user.restored", nil); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	data, err := ioutil.ReadFile(conf.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Wrong count of records: %v", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], `{"seq":1,`) ||
+		!strings.Contains(lines[0], `"service":"root.users","event":"user.created","fields":{"admin":"bob","user":"alice"},"prev":""`) {
+		t.Errorf("Wrong record: %v", lines[0])
+	}
+	if !strings.HasPrefix(lines[2], `{"seq":3,`) {
+		t.Errorf("Wrong record: %v", lines[2])
+	}
+
+	if n, err := VerifyAudit(bytes.NewReader(data), conf.Key); err != nil || n != 3 {
+		t.Errorf("Verification failed: %v %v", n, err)
+	}
+	if n, err := VerifyAudit(bytes.NewReader(data), "wrong"); err != ErrAuditTampered || n != 0 {
+		t.Errorf("Expected wrong key to fail: %v %v", n, err)
+	}
+
+	tampered := strings.Replace(string(data), "alice", "mallory", 1)
+	if n, err := VerifyAudit(strings.NewReader(tampered), conf.Key); err != ErrAuditTampered || n != 0 {
+		t.Errorf("Expected modified record to fail: %v %v", n, err)
+	}
+	removed := lines[0] + "\n" + lines[2] + "\n"
+	if n, err := VerifyAudit(strings.NewReader(removed), conf.Key); err != ErrAuditTampered || n != 1 {
+		t.Errorf("Expected removed record to fail: %v %v", n, err)
+	}
+}
+
+func TestAuditLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditLogWriter(&buf, NewAuditConfig())
+	a.Record("root", "started", map[string]interface{}{"version": 2})
+	a.Record("root", "stopped", nil)
+
+	if n, err := VerifyAudit(&buf, ""); err != nil || n != 2 {
+		t.Errorf("Verification failed: %v %v", n, err)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// callerDepth - The number of frames between print and the call site of a printing method, which
+// are print, printf or printLine, and the printing method itself.
+const callerDepth = 3
+
+// callerLevelMask - Returns a mask of the levels that capture caller information, where levels that
+// are not recognised are ignored.
+func callerLevelMask(levels []string) uint32 {
+	var mask uint32
+	for _, level := range levels {
+		if i := logLevelToInt(level); i >= 0 {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// callerOf - Returns the file, line and function of a frame above the caller of callerOf, where a
+// skip of zero is the caller itself.
+func callerOf(skip int) (string, int, string) {
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(skip+2, pcs) == 0 {
+		return "", 0, ""
+	}
+	frame, _ := runtime.CallersFrames(pcs).Next()
+	return frame.File, frame.Line, frame.Function
+}
+
+// maxStackDepth - The maximum number of frames of a captured stack trace.
+const maxStackDepth = 64
+
+// stackOf - Returns the stack trace from a frame above the caller of stackOf, where a skip of zero
+// is the caller itself. Each frame is written as its function followed by an indented file and line.
+func stackOf(skip int) string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	if n == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		buf.WriteString(frame.Function)
+		buf.WriteString("\n\t")
+		buf.WriteString(frame.File)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.Itoa(frame.Line))
+		buf.WriteByte('\n')
+		if !more {
+			break
+		}
+	}
+	return buf.String()
+}
+
+// shortCaller - Returns the file of a caller trimmed to its directory and name, followed by its line.
+func shortCaller(file string, line int) string {
+	if i := strings.LastIndex(file, "/"); i >= 0 {
+		if j := strings.LastIndex(file[:i], "/"); j >= 0 {
+			file = file[j+1:]
+		}
+	}
+	return file + ":" + strconv.Itoa(line)
+}
+
+// shortFunction - Returns a function name without its package path.
+func shortFunction(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+captureCaller - Sets the caller of an entry to the call site of a printing method when caller
+information is enabled for the level of the entry, and sets the stack trace from the call site when
+stack traces are enabled for the level. Must be called directly by print.
+*/
+func (l *Logger) captureCaller(e *Entry, level int) {
+	if level < 0 {
+		return
+	}
+	// Skip captureCaller itself as well as the frames up to print.
+	if l.callerLevels&(1<<uint(level)) != 0 {
+		e.File, e.Line, e.Function = callerOf(callerDepth + 1 + l.callerSkip)
+	}
+	if l.stackLevels&(1<<uint(level)) != 0 {
+		e.Stack = stackOf(callerDepth + 1 + l.callerSkip)
+	}
+}
+
+/*
+WithCallerSkip - Creates a new logger object from the previous that skips an additional number of
+frames when capturing caller information and stack traces. This allows helpers that wrap the
+printing methods to report the call site of the helper rather than of the printing method within it.
+*/
+func (l *Logger) WithCallerSkip(skip int) *Logger {
+	child := *l
+	child.callerSkip += skip
+	return &child
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+// logHelper - A wrapper of a logger used to check the caller skip.
+func logHelper(l *Logger, message string) {
+	l.WithCallerSkip(1).Errorln(message)
+}
+
+func TestCaller(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.CallerLevels = []string{"ERROR", "nope"}
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.Infoln("No caller")
+	logger.Errorf("With caller\n")
+	logHelper(logger, "Through helper")
+
+	lines := strings.Split(buf.data, "\n")
+	if exp := "INFO | root | No caller"; exp != lines[0] {
+		t.Errorf("Unexpected caller: %v != %v", lines[0], exp)
+	}
+	if exp := "ERROR | root | log/caller_test.go:45 log.TestCaller | With caller"; exp != lines[1] {
+		t.Errorf("Wrong caller: %v != %v", lines[1], exp)
+	}
+	if exp := "ERROR | root | log/caller_test.go:46 log.TestCaller | Through helper"; exp != lines[2] {
+		t.Errorf("Wrong helper caller: %v != %v", lines[2], exp)
+	}
+}
+
+func TestCallerFormats(t *testing.T) {
+	e := Entry{
+		Level:    "WARN",
+		Prefix:   "root",
+		Message:  "msg",
+		File:     "/src/github.com/jeffail/util/log/file.go",
+		Line:     12,
+		Function: "github.com/jeffail/util/log.Foo",
+	}
+
+	buf := LogBuffer{data: ""}
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Format = "json"
+	NewLogger(&buf, loggerConfig).(*Logger).write(&e)
+
+	loggerConfig.Format = "logfmt"
+	NewLogger(&buf, loggerConfig).(*Logger).write(&e)
+
+	expected := `{"level":"WARN","service":"root","caller":"log/file.go:12",` +
+		`"function":"github.com/jeffail/util/log.Foo","message":"msg"}` + "\n" +
+		`level=warn service=root caller=log/file.go:12 func=github.com/jeffail/util/log.Foo msg=msg` + "\n"
+	if expected != buf.data {
+		t.Errorf("Wrong caller formats: %v != %v", buf.data, expected)
+	}
+
+	if exp, act := "file.go:1", shortCaller("file.go", 1); exp != act {
+		t.Errorf("Wrong short caller: %v != %v", act, exp)
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.StackLevels = []string{"ERROR"}
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.Warnln("No stack")
+	logger.Errorf("With stack")
+
+	lines := strings.Split(buf.data, "\n")
+	if exp := "WARN | root | No stack"; exp != lines[0] {
+		t.Errorf("Unexpected stack: %v != %v", lines[0], exp)
+	}
+	if exp := "ERROR | root | With stack"; exp != lines[1] {
+		t.Errorf("Wrong line: %v != %v", lines[1], exp)
+	}
+	if exp := "\tgithub.com/jeffail/util/log.TestStackTrace"; exp != lines[2] {
+		t.Errorf("Stack does not begin at the call site: %v != %v", lines[2], exp)
+	}
+	if !strings.HasPrefix(lines[3], "\t\t") || !strings.Contains(lines[3], "caller_test.go:") {
+		t.Errorf("Wrong stack file line: %v", lines[3])
+	}
+	if strings.Contains(buf.data, "log.(*Logger)") {
+		t.Errorf("Stack contains logger frames: %v", buf.data)
+	}
+	if !strings.HasSuffix(buf.data, "\n") || strings.HasSuffix(buf.data, "\n\n") {
+		t.Errorf("Wrong stack line endings: %q", buf.data)
+	}
+
+	e := Entry{Level: "ERROR", Prefix: "root", Message: "msg\n", Stack: "a\n\tb:1\n"}
+	buf = LogBuffer{data: ""}
+	loggerConfig.Format = "json"
+	NewLogger(&buf, loggerConfig).(*Logger).write(&e)
+
+	if exp := `{"level":"ERROR","service":"root","message":"msg","stack":"a\n\tb:1\n"}` + "\n"; exp != buf.data {
+		t.Errorf("Wrong json stack: %v != %v", buf.data, exp)
+	}
+}
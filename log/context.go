@@ -0,0 +1,117 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// ContextExtractor - Returns the fields of a context that identify the work it belongs to, such as
+// trace, span and request IDs.
+type ContextExtractor func(ctx context.Context) map[string]interface{}
+
+// contextKey - The type of the context keys of this package, which cannot collide with those of
+// other packages.
+type contextKey int
+
+// Context keys of the IDs extracted by DefaultContextExtractor.
+const (
+	traceIDKey contextKey = iota
+	spanIDKey
+	requestIDKey
+)
+
+// ContextWithTraceID - Returns a copy of a context carrying a trace ID.
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey, id)
+}
+
+// ContextWithSpanID - Returns a copy of a context carrying a span ID.
+func ContextWithSpanID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, spanIDKey, id)
+}
+
+// ContextWithRequestID - Returns a copy of a context carrying a request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// DefaultContextExtractor - Returns the trace, span and request IDs carried by a context as the
+// fields "trace_id", "span_id" and "request_id", where IDs that are not carried are omitted.
+func DefaultContextExtractor(ctx context.Context) map[string]interface{} {
+	fields := map[string]interface{}{}
+	for key, name := range map[contextKey]string{
+		traceIDKey:   "trace_id",
+		spanIDKey:    "span_id",
+		requestIDKey: "request_id",
+	} {
+		if id, ok := ctx.Value(key).(string); ok && id != "" {
+			fields[name] = id
+		}
+	}
+	return fields
+}
+
+// newContextExtractor - Creates the shared holder of the context extractor of a logger, which starts
+// as the default extractor.
+func newContextExtractor() *atomic.Value {
+	v := &atomic.Value{}
+	v.Store(ContextExtractor(DefaultContextExtractor))
+	return v
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+SetContextExtractor - Replaces the extractor used by WithContext, such as with one that reads the
+IDs of a tracing library from a context. This applies to every module and child of the logger, and a
+nil extractor restores the default.
+*/
+func (l *Logger) SetContextExtractor(extractor ContextExtractor) {
+	if extractor == nil {
+		extractor = DefaultContextExtractor
+	}
+	l.context.Store(extractor)
+}
+
+/*
+WithContext - Creates a new logger object from the previous that attaches the fields extracted from
+a context to every message it prints, such as trace, span and request IDs, so that the messages of a
+single request can be correlated across services. The previous logger is returned when the context
+carries no fields.
+*/
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return l
+	}
+	fields := l.context.Load().(ContextExtractor)(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.WithFields(fields)
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"context"
+	"testing"
+)
+
+type tenantKey struct{}
+
+func TestWithContext(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "INFO"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	module := logger.NewModule(".http").(*Logger)
+
+	ctx := ContextWithTraceID(context.Background(), "abc")
+	ctx = ContextWithRequestID(ctx, "req 1")
+
+	logger.WithContext(context.Background()).Infoln("No context")
+	module.WithContext(ctx).Infoln("Default extractor")
+
+	logger.SetContextExtractor(func(ctx context.Context) map[string]interface{} {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return map[string]interface{}{"tenant": tenant}
+	})
+	module.WithContext(context.WithValue(ctx, tenantKey{}, "x")).Infoln("Custom extractor")
+
+	expected := "INFO | root | No context\n" +
+		"INFO | root.http | Default extractor request_id=\"req 1\" trace_id=abc\n" +
+		"INFO | root.http | Custom extractor tenant=x\n"
+	if expected != buf.data {
+		t.Errorf("Context output does not match: %v != %v", buf.data, expected)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+deduper - Holds back messages that are identical to the previous message, shared by every module
+and child of a logger. Messages are identical when their level, prefix and message match, and the
+fields of messages are not compared. Held back repeats are summarised once a different message is
+printed, or once the window of the first message has passed.
+*/
+type deduper struct {
+	sync.Mutex
+
+	window time.Duration
+
+	last    *Logger
+	entry   Entry
+	started time.Time
+	repeats int
+	timer   *time.Timer
+
+	now func() time.Time
+}
+
+// newDeduper - Creates a deduper of a window, or returns nil when the window is empty or invalid.
+func newDeduper(window string) *deduper {
+	if window == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return nil
+	}
+	return &deduper{
+		window: d,
+		now:    time.Now,
+	}
+}
+
+// take - Returns the logger and summary entry of held back repeats, or nil if there are none. Must
+// be called whilst holding the lock.
+func (d *deduper) take() (*Logger, *Entry) {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.repeats == 0 {
+		return nil, nil
+	}
+
+	summary := Entry{
+		Level:   d.entry.Level,
+		Prefix:  d.entry.Prefix,
+		Message: fmt.Sprintf("last message repeated %v times\n", d.repeats),
+		Fields:  d.entry.Fields,
+	}
+	if !d.entry.Time.IsZero() {
+		summary.Time = d.now().In(d.entry.Time.Location())
+		summary.layout = d.entry.layout
+	}
+	d.repeats = 0
+	return d.last, &summary
+}
+
+// check - Returns whether an entry of a logger is emitted, where repeats of the previous entry
+// within the window are held back. A summary of held back repeats is emitted before any entry that
+// ends them.
+func (d *deduper) check(l *Logger, e *Entry) bool {
+	d.Lock()
+	now := d.now()
+	if d.last != nil && e.Level == d.entry.Level && e.Prefix == d.entry.Prefix &&
+		e.Message == d.entry.Message && now.Sub(d.started) < d.window {
+		d.repeats++
+		if d.timer == nil {
+			d.timer = time.AfterFunc(d.window-now.Sub(d.started), d.expire)
+		}
+		d.Unlock()
+		return false
+	}
+
+	logger, summary := d.take()
+	d.last, d.entry, d.started = l, *e, now
+	d.Unlock()
+
+	if summary != nil {
+		logger.emit(summary)
+	}
+	return true
+}
+
+// expire - Emits a summary of any held back repeats.
+func (d *deduper) expire() {
+	d.Lock()
+	logger, summary := d.take()
+	d.Unlock()
+
+	if summary != nil {
+		logger.emit(summary)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupe(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.DedupeWindow = "1h"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	module := logger.NewModule(".foo")
+
+	for i := 0; i < 4; i++ {
+		logger.Errorln("Failed")
+	}
+	module.Errorln("Failed")
+	module.Errorln("Failed")
+	logger.Warnln("Other")
+	logger.Warnln("Other")
+	logger.Flush(time.Second)
+
+	expected := "ERROR | root | Failed\n" +
+		"ERROR | root | last message repeated 3 times\n" +
+		"ERROR | root.foo | Failed\n" +
+		"ERROR | root.foo | last message repeated 1 times\n" +
+		"WARN | root | Other\n" +
+		"WARN | root | last message repeated 1 times\n"
+	if expected != buf.data {
+		t.Errorf("Dedupe does not match: %v != %v", buf.data, expected)
+	}
+}
+
+func TestDedupeWindow(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.DedupeWindow = "1m"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+
+	now := time.Unix(0, 0)
+	logger.dedupe.now = func() time.Time { return now }
+
+	logger.Infoln("Loop")
+	logger.Infoln("Loop")
+	now = now.Add(time.Minute)
+	logger.Infoln("Loop")
+	logger.Infoln("Loop")
+	logger.Infoln("Done")
+
+	expected := "INFO | root | Loop\n" +
+		"INFO | root | last message repeated 1 times\n" +
+		"INFO | root | Loop\n" +
+		"INFO | root | last message repeated 1 times\n" +
+		"INFO | root | Done\n"
+	if expected != buf.data {
+		t.Errorf("Dedupe does not match: %v != %v", buf.data, expected)
+	}
+}
+
+func TestDedupeTimer(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Async = true
+	loggerConfig.DedupeWindow = "10ms"
+
+	buf := &blockingBuffer{release: make(chan struct{})}
+	close(buf.release)
+
+	logger := NewLogger(buf, loggerConfig).(*Logger)
+	defer logger.Close()
+
+	logger.Infoln("Quiet")
+	logger.Infoln("Quiet")
+
+	expected := "INFO | root | Quiet\nINFO | root | last message repeated 1 times\n"
+	for i := 0; i < 100 && buf.String() != expected; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if act := buf.String(); expected != act {
+		t.Errorf("Dedupe timer did not summarise: %v != %v", act, expected)
+	}
+
+	if newDeduper("nope") != nil || newDeduper("") != nil {
+		t.Error("Expected nil deduper")
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// ErrEventLogUnsupported - Returned when creating an event log writer on a platform other than
+// Windows.
+var ErrEventLogUnsupported = errors.New("the windows event log is not supported on this platform")
+
+// The event types of the Windows Event Log.
+const (
+	eventLogError   = 0x0001
+	eventLogWarning = 0x0002
+	eventLogInfo    = 0x0004
+)
+
+// maxEventLogMessage - The maximum number of characters of a single event message.
+const maxEventLogMessage = 31839
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+EventLogConfig - Config for a Windows Event Log target. The source defaults to the name of the
+program, and should be registered with the Application log beforehand (such as with the
+New-EventLog PowerShell command) for the Event Viewer to display messages without a warning. The
+event ID is given to every event, where the default of 1 matches the message file of EventCreate.
+*/
+type EventLogConfig struct {
+	Source  string `json:"source" yaml:"source"`
+	EventID uint32 `json:"event_id" yaml:"event_id"`
+}
+
+// NewEventLogConfig - Returns an event log target configuration with the default values for each
+// field.
+func NewEventLogConfig() EventLogConfig {
+	return EventLogConfig{
+		Source:  "",
+		EventID: 1,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// eventSource - A registered source of the event log, implemented for each platform.
+type eventSource interface {
+	report(eventType uint16, eventID uint32, message string) error
+	close() error
+}
+
+/*
+EventLogWriter - An EntryWriter that reports each log message as an event of the Windows Event Log,
+which can be used as the stream of a logger. Fatal and error messages are reported as error events,
+warnings as warning events and all other levels as information events. The message of an event is
+the prefix and message of the entry followed by its fields and stack trace.
+*/
+type EventLogWriter struct {
+	sync.Mutex
+
+	eventID uint32
+	source  eventSource
+}
+
+// NewEventLogWriter - Creates an event log writer from a config, or returns ErrEventLogUnsupported
+// when not running on Windows.
+func NewEventLogWriter(conf EventLogConfig) (*EventLogWriter, error) {
+	name := conf.Source
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	}
+	source, err := openEventSource(name)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLogWriter{
+		eventID: conf.EventID,
+		source:  source,
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// eventLogType - Returns the event type of a log level.
+func eventLogType(level int) uint16 {
+	switch level {
+	case LogFatal, LogError:
+		return eventLogError
+	case LogWarn:
+		return eventLogWarning
+	}
+	return eventLogInfo
+}
+
+// eventLogMessage - Returns an entry as the message of an event, which is truncated to the maximum
+// length of a message.
+func eventLogMessage(e *Entry) string {
+	var buf bytes.Buffer
+	if e.Prefix != "" {
+		buf.WriteString(e.Prefix)
+		buf.WriteString(" | ")
+	}
+	buf.WriteString(strings.TrimSuffix(e.Message, "\n"))
+	if len(e.Fields) > 0 {
+		buf.WriteByte(' ')
+		var pairs bytes.Buffer
+		writeLogfmtFields(&pairs, e.Fields)
+		buf.Write(pairs.Bytes())
+	}
+	if e.File != "" {
+		buf.WriteString("\n")
+		buf.WriteString(shortCaller(e.File, e.Line))
+		buf.WriteByte(' ')
+		buf.WriteString(e.Function)
+	}
+	writeStack(&buf, e.Stack)
+
+	msg := strings.Replace(strings.TrimSuffix(buf.String(), "\n"), "\x00", "", -1)
+	if r := []rune(msg); len(r) > maxEventLogMessage {
+		msg = string(r[:maxEventLogMessage])
+	}
+	return msg
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WriteEntry - Reports a log message as an event.
+func (w *EventLogWriter) WriteEntry(e *Entry) error {
+	msg := eventLogMessage(e)
+
+	w.Lock()
+	defer w.Unlock()
+
+	return w.source.report(eventLogType(logLevelToInt(e.Level)), w.eventID, msg)
+}
+
+// Write - Reports a log line as an information event.
+func (w *EventLogWriter) Write(p []byte) (int, error) {
+	if err := w.WriteEntry(&Entry{Level: "INFO", Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close - Deregisters the event source.
+func (w *EventLogWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.source.close()
+}
+
+//--------------------------------------------------------------------------------------------------
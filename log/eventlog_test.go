@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeEventSource - An eventSource that records the events reported to it.
+type fakeEventSource struct {
+	events []fakeEvent
+	closed bool
+}
+
+type fakeEvent struct {
+	eventType uint16
+	eventID   uint32
+	message   string
+}
+
+func (f *fakeEventSource) report(eventType uint16, eventID uint32, message string) error {
+	f.events = append(f.events, fakeEvent{eventType, eventID, message})
+	return nil
+}
+
+func (f *fakeEventSource) close() error {
+	f.closed = true
+	return nil
+}
+
+func TestEventLogWriter(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		if _, err := NewEventLogWriter(NewEventLogConfig()); err != ErrEventLogUnsupported {
+			t.Errorf("Wrong error: %v != %v", err, ErrEventLogUnsupported)
+		}
+	}
+
+	source := &fakeEventSource{}
+	w := &EventLogWriter{eventID: 1, source: source}
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "DEBUG"
+	logger := NewLogger(w, loggerConfig).(*Logger)
+
+	logger.Errorf("Error message\n")
+	logger.WithFields(map[string]interface{}{"a": 1}).Warnln("Warning message")
+	logger.Debugln("Debug message")
+	w.Write([]byte("Raw line\n"))
+
+	expected := []fakeEvent{
+		{eventLogError, 1, "root | Error message"},
+		{eventLogWarning, 1, "root | Warning message a=1"},
+		{eventLogInfo, 1, "root | Debug message"},
+		{eventLogInfo, 1, "Raw line"},
+	}
+	if !reflect.DeepEqual(expected, source.events) {
+		t.Errorf("Wrong events: %v != %v", source.events, expected)
+	}
+
+	if err := w.Close(); err != nil || !source.closed {
+		t.Errorf("Source was not closed: %v", err)
+	}
+}
+
+func TestEventLogMessage(t *testing.T) {
+	e := &Entry{
+		Level:   "ERROR",
+		Message: "Broken\x00 message\n",
+		Stack:   "main.main()\n\tmain.go:10\n",
+	}
+	if exp, act := "Broken message\n\tmain.main()\n\t\tmain.go:10", eventLogMessage(e); exp != act {
+		t.Errorf("Wrong message: %q != %q", act, exp)
+	}
+
+	e = &Entry{Message: strings.Repeat("x", maxEventLogMessage+10)}
+	if exp, act := maxEventLogMessage, len(eventLogMessage(e)); exp != act {
+		t.Errorf("Wrong message length: %v != %v", act, exp)
+	}
+}
@@ -0,0 +1,83 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+)
+
+// windowsEventSource - An event source registered with the event log of the local machine.
+type windowsEventSource struct {
+	handle uintptr
+}
+
+// openEventSource - Registers an event source with the event log of the local machine.
+func openEventSource(name string) (eventSource, error) {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	handle, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(namePtr)))
+	if handle == 0 {
+		return nil, err
+	}
+	return &windowsEventSource{handle: handle}, nil
+}
+
+func (w *windowsEventSource) report(eventType uint16, eventID uint32, message string) error {
+	msgPtr, err := syscall.UTF16PtrFromString(message)
+	if err != nil {
+		return err
+	}
+	strs := [1]*uint16{msgPtr}
+	ok, _, err := procReportEventW.Call(
+		w.handle, uintptr(eventType), 0, uintptr(eventID), 0, 1, 0,
+		uintptr(unsafe.Pointer(&strs[0])), 0,
+	)
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+func (w *windowsEventSource) close() error {
+	if ok, _, err := procDeregisterEventSource.Call(w.handle); ok == 0 {
+		return err
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
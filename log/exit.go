@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// osExit - Exits the process, which is replaced within tests.
+var osExit = os.Exit
+
+// syncer - A stream that can commit written data to stable storage, such as an *os.File.
+type syncer interface {
+	Sync() error
+}
+
+// exitHooks - The functions run before a logger exits or panics, shared by every module and child
+// of a logger.
+type exitHooks struct {
+	sync.Mutex
+	hooks []func()
+}
+
+// AddExitHook - Registers a function that is run before the process exits or panics due to a fatal
+// or panic message, such as a final push of stats. Hooks are run in the order they were added, and
+// a hook that panics does not prevent the others from running.
+func (l *Logger) AddExitHook(hook func()) {
+	l.exits.Lock()
+	l.exits.hooks = append(l.exits.hooks, hook)
+	l.exits.Unlock()
+}
+
+// runExitHook - Runs an exit hook, recovering from any panic within it.
+func runExitHook(hook func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "log exit hook panicked: %v\n", r)
+		}
+	}()
+	hook()
+}
+
+// shutdown - Flushes queued messages and syncs the stream, and then runs the exit hooks.
+func (l *Logger) shutdown() {
+	l.Flush(fatalFlushTimeout)
+	if s, ok := l.stream.(syncer); ok {
+		s.Sync()
+	}
+
+	l.exits.Lock()
+	hooks := make([]func(), len(l.exits.hooks))
+	copy(hooks, l.exits.hooks)
+	l.exits.Unlock()
+
+	for _, hook := range hooks {
+		runExitHook(hook)
+	}
+}
+
+// exit - Shuts the logger down and exits the process with a status of one.
+func (l *Logger) exit() {
+	l.shutdown()
+	osExit(1)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Panicf - Print a fatal message to the console regardless of the level, flush all queued messages,
+// run the exit hooks and then panic with the message.
+func (l *Logger) Panicf(message string, other ...interface{}) {
+	l.forcef(message, "FATAL", other...)
+	l.shutdown()
+	panic(fmt.Sprintf(message, other...))
+}
+
+// Panicln - Print a fatal message to the console regardless of the level, flush all queued
+// messages, run the exit hooks and then panic with the message.
+func (l *Logger) Panicln(message string) {
+	l.forceLine(message, "FATAL")
+	l.shutdown()
+	panic(message)
+}
+
+//--------------------------------------------------------------------------------------------------
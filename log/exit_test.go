@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExitOnFatal(t *testing.T) {
+	var code int
+	osExit = func(c int) { code = c }
+	defer func() { osExit = os.Exit }()
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Async = true
+	loggerConfig.ExitOnFatal = true
+
+	buf := &blockingBuffer{release: make(chan struct{})}
+	close(buf.release)
+
+	logger := NewLogger(buf, loggerConfig).(*Logger)
+	defer logger.Close()
+
+	var order []string
+	logger.AddExitHook(func() {
+		order = append(order, "first:"+buf.String())
+		panic("ignored")
+	})
+	logger.NewModule(".foo").(*Logger).AddExitHook(func() {
+		order = append(order, "second")
+	})
+
+	logger.Infoln("Info")
+	logger.Fatalf("Fatal %v", "message")
+
+	if code != 1 {
+		t.Errorf("Wrong exit code: %v != %v", code, 1)
+	}
+	exp := []string{"first:INFO | root | Info\nFATAL | root | Fatal message", "second"}
+	if !reflect.DeepEqual(exp, order) {
+		t.Errorf("Wrong exit hook order: %v != %v", order, exp)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "OFF"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+
+	hooked := false
+	logger.AddExitHook(func() { hooked = true })
+
+	defer func() {
+		r := recover()
+		if exp := "Panic message"; exp != r {
+			t.Errorf("Wrong panic value: %v != %v", r, exp)
+		}
+		if !hooked {
+			t.Error("Exit hook was not run")
+		}
+		if exp := "FATAL | root | Panic message"; exp != buf.data {
+			t.Errorf("Wrong output: %v != %v", buf.data, exp)
+		}
+	}()
+	logger.Panicf("Panic %v", "message")
+}
+
+func TestPanicWithRingBuffer(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "OFF"
+	loggerConfig.RingBufferSize = 10
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.Debugln("Ring only")
+
+	defer func() {
+		if r := recover(); r != "Panic message" {
+			t.Errorf("Wrong panic value: %v", r)
+		}
+		if exp := "FATAL | root | Panic message\n"; exp != buf.data {
+			t.Errorf("Wrong output: %v != %v", buf.data, exp)
+		}
+		if exp, act := 2, len(logger.Tail(0)); exp != act {
+			t.Errorf("Wrong count of ring entries: %v != %v", act, exp)
+		}
+	}()
+	logger.Panicln("Panic message")
+}
@@ -0,0 +1,309 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+FileConfig - Config for a log file target. Once the file would exceed MaxSize bytes, or once it has
+been open for longer than MaxAge, it is rotated by renaming it with the suffix '.1' and shifting
+older backups up by one, where no more than MaxBackups backups are kept. When Compress is set each
+rotated file is gzipped in the background with the additional suffix '.gz'. Backups last modified
+longer than MaxBackupAge ago are deleted when the file is opened or rotated. A MaxSize of zero or an
+empty MaxAge disables the respective rotation, and an empty MaxBackupAge keeps backups regardless of
+their age.
+*/
+type FileConfig struct {
+	Path       string `json:"path" yaml:"path"`
+	MaxSize    int64  `json:"max_size_bytes" yaml:"max_size_bytes"`
+	MaxAge     string `json:"max_age" yaml:"max_age"`
+	MaxBackups int    `json:"max_backups" yaml:"max_backups"`
+	Compress   bool   `json:"compress" yaml:"compress"`
+
+	MaxBackupAge string `json:"max_backup_age" yaml:"max_backup_age"`
+}
+
+// NewFileConfig - Returns a file target configuration with the default values for each field.
+func NewFileConfig() FileConfig {
+	return FileConfig{
+		Path:       "service.log",
+		MaxSize:    100 * 1024 * 1024,
+		MaxAge:     "",
+		MaxBackups: 5,
+		Compress:   false,
+
+		MaxBackupAge: "",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// FileWriter - An io.WriteCloser that appends log lines to a rotating file, which can be used as the
+// stream of a logger.
+type FileWriter struct {
+	sync.Mutex
+
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	maxBackupAge time.Duration
+
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	compressing sync.WaitGroup
+	compressErr error
+
+	now func() time.Time
+}
+
+// NewFileWriter - Creates a file writer from a config and opens the file for appending.
+func NewFileWriter(conf FileConfig) (*FileWriter, error) {
+	f := &FileWriter{
+		path:       conf.Path,
+		maxSize:    conf.MaxSize,
+		maxBackups: conf.MaxBackups,
+		compress:   conf.Compress,
+		now:        time.Now,
+	}
+	if conf.MaxAge != "" {
+		var err error
+		if f.maxAge, err = time.ParseDuration(conf.MaxAge); err != nil {
+			return nil, fmt.Errorf("failed to parse max_age: %v", err)
+		}
+	}
+	if conf.MaxBackupAge != "" {
+		var err error
+		if f.maxBackupAge, err = time.ParseDuration(conf.MaxBackupAge); err != nil {
+			return nil, fmt.Errorf("failed to parse max_backup_age: %v", err)
+		}
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	f.removeExpired()
+	return f, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// open - Opens the file for appending. Must be called whilst holding the lock.
+func (f *FileWriter) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), f.now()
+	return nil
+}
+
+// backupName - Returns the name of the backup of an index, which has the '.gz' suffix if it was
+// compressed.
+func (f *FileWriter) backupName(i int) string {
+	name := f.path + "." + strconv.Itoa(i)
+	if _, err := os.Stat(name + ".gz"); err == nil {
+		return name + ".gz"
+	}
+	return name
+}
+
+// removeExpired - Removes the backups that were last modified longer than the maximum backup age
+// ago. Must be called whilst holding the lock.
+func (f *FileWriter) removeExpired() {
+	if f.maxBackupAge <= 0 {
+		return
+	}
+	now := f.now()
+	for i := 1; i <= f.maxBackups; i++ {
+		name := f.backupName(i)
+		if info, err := os.Stat(name); err == nil && now.Sub(info.ModTime()) > f.maxBackupAge {
+			os.Remove(name)
+		}
+	}
+}
+
+// compressFile - Writes a gzipped copy of a file with the suffix '.gz' and removes the original. The
+// copy keeps the modification time of the original so that its age is preserved.
+func compressFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	os.Chtimes(name+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
+
+/*
+rotate - Closes the file and shifts it and the backups before it up by one suffix, removing the
+oldest, and then opens a new file. Must be called whilst holding the lock.
+
+The new backup is compressed in the background so that writers are not blocked for the duration.
+Any previous compression is waited on before backups are shifted or removed, as its input and output
+are both named by index, and an error from it is returned instead of rotating.
+*/
+func (f *FileWriter) rotate() error {
+	f.file.Close()
+	f.file = nil
+
+	f.compressing.Wait()
+	if err := f.compressErr; err != nil {
+		f.compressErr = nil
+		return err
+	}
+
+	if f.maxBackups <= 0 {
+		if err := os.Remove(f.path); err != nil {
+			return err
+		}
+		return f.open()
+	}
+
+	os.Remove(f.backupName(f.maxBackups))
+	for i := f.maxBackups - 1; i > 0; i-- {
+		from := f.backupName(i)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		to := f.path + "." + strconv.Itoa(i+1)
+		if strings.HasSuffix(from, ".gz") {
+			to += ".gz"
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+	f.removeExpired()
+	if err := f.open(); err != nil {
+		return err
+	}
+	if f.compress {
+		f.compressing.Add(1)
+		go func(name string) {
+			defer f.compressing.Done()
+			f.compressErr = compressFile(name)
+		}(f.path + ".1")
+	}
+	return nil
+}
+
+// Write - Appends a log line to the file, rotating it first if it would exceed the maximum size or
+// has exceeded the maximum age.
+func (f *FileWriter) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size > 0 {
+		oversize := f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize
+		expired := f.maxAge > 0 && f.now().Sub(f.opened) >= f.maxAge
+		if oversize || expired {
+			if err := f.rotate(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Sync - Commits the contents of the file to stable storage.
+func (f *FileWriter) Sync() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close - Closes the file and waits for the compression of any backup, returning its error.
+func (f *FileWriter) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.compressing.Wait()
+	err := f.compressErr
+	f.compressErr = nil
+
+	if f.file == nil {
+		return err
+	}
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	f.file = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,235 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileWriterSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewFileConfig()
+	conf.Path = filepath.Join(dir, "test.log")
+	conf.MaxSize = 10
+	conf.MaxBackups = 2
+
+	f, err := NewFileWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if exp, act := "fourth\n", readFile(t, conf.Path); exp != act {
+		t.Errorf("Wrong file contents: %v != %v", act, exp)
+	}
+	if exp, act := "third\n", readFile(t, conf.Path+".1"); exp != act {
+		t.Errorf("Wrong backup contents: %v != %v", act, exp)
+	}
+	if exp, act := "second\n", readFile(t, conf.Path+".2"); exp != act {
+		t.Errorf("Wrong backup contents: %v != %v", act, exp)
+	}
+	if _, err = os.Stat(conf.Path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected oldest backup to be removed: %v", err)
+	}
+}
+
+func TestFileWriterAgeCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewFileConfig()
+	conf.Path = filepath.Join(dir, "test.log")
+	conf.MaxAge = "1h"
+	conf.MaxBackups = 2
+	conf.Compress = true
+
+	if _, err = NewFileWriter(FileConfig{Path: conf.Path, MaxAge: "nope"}); err == nil {
+		t.Error("Expected error from bad max age")
+	}
+
+	f, err := NewFileWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	now := time.Unix(0, 0)
+	f.now = func() time.Time { return now }
+	f.opened = now
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	if exp, act := "third\n", readFile(t, conf.Path); exp != act {
+		t.Errorf("Wrong file contents: %v != %v", act, exp)
+	}
+	f.compressing.Wait()
+	for i, exp := range []string{"second\n", "first\n"} {
+		name := f.backupName(i + 1)
+		if filepath.Ext(name) != ".gz" {
+			t.Fatalf("Expected compressed backup: %v", name)
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(zr)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := string(data); exp != act {
+			t.Errorf("Wrong backup contents: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestFileWriterBackupAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewFileConfig()
+	conf.Path = filepath.Join(dir, "test.log")
+	conf.MaxSize = 10
+	conf.MaxBackups = 3
+	conf.Compress = true
+	conf.MaxBackupAge = "24h"
+
+	if _, err = NewFileWriter(FileConfig{Path: conf.Path, MaxBackupAge: "nope"}); err == nil {
+		t.Error("Expected error from bad max backup age")
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{conf.Path + ".1", conf.Path + ".2.gz"} {
+		if err = ioutil.WriteFile(name, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Chtimes(conf.Path+".2.gz", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFileWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err = os.Stat(conf.Path + ".2.gz"); !os.IsNotExist(err) {
+		t.Errorf("Expected expired backup to be removed on open: %v", err)
+	}
+	if _, err = os.Stat(conf.Path + ".1"); err != nil {
+		t.Errorf("Expected recent backup to be kept: %v", err)
+	}
+
+	if err = os.Chtimes(conf.Path+".1", old, old); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err = os.Stat(conf.Path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("Expected expired backup to be removed on rotation: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(conf.Path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("Expected compression to finish on close: %v", err)
+	}
+	info, err := os.Stat(conf.Path + ".1.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(info.ModTime()) > time.Hour {
+		t.Errorf("Wrong modification time of compressed backup: %v", info.ModTime())
+	}
+}
+
+func TestFileWriterLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewFileConfig()
+	conf.Path = filepath.Join(dir, "test.log")
+
+	f, err := NewFileWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	NewLogger(f, loggerConfig).Infoln("Info message")
+	f.Close()
+
+	if exp, act := "INFO | root | Info message\n", readFile(t, conf.Path); exp != act {
+		t.Errorf("Wrong file contents: %v != %v", act, exp)
+	}
+}
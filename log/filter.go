@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+FilterConfig - A rule that matches log messages in order to drop them, or to keep only the messages
+it matches, where Action is either "drop" or "keep". A message matches when all of the criteria that
+are set match, which are a regular expression for the message, a prefix that the prefix of the
+logger starts with, and the values of fields compared by their printed form. A message pattern that
+is not a valid regular expression is matched as literal text.
+*/
+type FilterConfig struct {
+	Action  string            `json:"action" yaml:"action"`
+	Message string            `json:"message" yaml:"message"`
+	Prefix  string            `json:"prefix" yaml:"prefix"`
+	Fields  map[string]string `json:"fields" yaml:"fields"`
+}
+
+// NewFilterConfig - Returns a filter configuration with the default values for each field.
+func NewFilterConfig() FilterConfig {
+	return FilterConfig{
+		Action:  "drop",
+		Message: "",
+		Prefix:  "",
+		Fields:  map[string]string{},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// entryFilter - A compiled filter rule.
+type entryFilter struct {
+	keep    bool
+	message *regexp.Regexp
+	prefix  string
+	fields  map[string]string
+}
+
+// matches - Returns whether an entry matches every criteria of the rule.
+func (f entryFilter) matches(e *Entry) bool {
+	if !strings.HasPrefix(e.Prefix, f.prefix) {
+		return false
+	}
+	if f.message != nil && !f.message.MatchString(strings.TrimSuffix(e.Message, "\n")) {
+		return false
+	}
+	for k, v := range f.fields {
+		value, ok := e.Fields[k]
+		if !ok || fmt.Sprintf("%v", value) != v {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+filterSet - Decides which messages are written to an output, where a message is dropped when it
+matches any drop rule, or when there are keep rules and it matches none of them. A nil set allows
+every message.
+*/
+type filterSet struct {
+	filters []entryFilter
+	keeps   bool
+}
+
+// newFilterSet - Compiles filter rules, or returns nil when there are none.
+func newFilterSet(configs []FilterConfig) *filterSet {
+	if len(configs) == 0 {
+		return nil
+	}
+	s := &filterSet{}
+	for _, c := range configs {
+		f := entryFilter{
+			keep:   c.Action == "keep",
+			prefix: c.Prefix,
+			fields: c.Fields,
+		}
+		if c.Message != "" {
+			re, err := regexp.Compile(c.Message)
+			if err != nil {
+				re = regexp.MustCompile(regexp.QuoteMeta(c.Message))
+			}
+			f.message = re
+		}
+		s.keeps = s.keeps || f.keep
+		s.filters = append(s.filters, f)
+	}
+	return s
+}
+
+// allows - Returns whether an entry is written.
+func (s *filterSet) allows(e *Entry) bool {
+	if s == nil {
+		return true
+	}
+	kept := !s.keeps
+	for _, f := range s.filters {
+		if !f.matches(e) {
+			continue
+		}
+		if !f.keep {
+			return false
+		}
+		kept = true
+	}
+	return kept
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import "testing"
+
+func TestFilters(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	benign := NewFilterConfig()
+	benign.Message = `^connection reset by peer`
+	health := NewFilterConfig()
+	health.Prefix = "root.http"
+	health.Fields = map[string]string{"path": "/health"}
+	loggerConfig.Filters = []FilterConfig{benign, health}
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	module := logger.NewModule(".http").(*Logger)
+
+	logger.Errorln("connection reset by peer: 10.0.0.1")
+	logger.Errorln("Database unreachable")
+	module.WithFields(map[string]interface{}{"path": "/health"}).Infoln("Request")
+	module.WithFields(map[string]interface{}{"path": "/api"}).Infoln("Request")
+	logger.WithFields(map[string]interface{}{"path": "/health"}).Infoln("Request")
+
+	expected := "ERROR | root | Database unreachable\n" +
+		"INFO | root.http | Request path=/api\n" +
+		"INFO | root | Request path=/health\n"
+	if expected != buf.data {
+		t.Errorf("Filtered output does not match: %v != %v", buf.data, expected)
+	}
+}
+
+func TestFilterRouting(t *testing.T) {
+	all := LogBuffer{data: ""}
+	audit := LogBuffer{data: ""}
+
+	keep := NewFilterConfig()
+	keep.Action = "keep"
+	keep.Prefix = "root.audit"
+	secret := NewFilterConfig()
+	secret.Message = "secret"
+
+	m := NewMultiWriter(
+		Target{Stream: &all, Filters: []FilterConfig{secret}},
+		Target{Stream: &audit, Filters: []FilterConfig{keep, secret}},
+	)
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	logger := NewLogger(m, loggerConfig)
+	logger.Infoln("Started")
+	logger.NewModule(".audit").Infoln("User created")
+	logger.NewModule(".audit").Infoln("User secret rotated")
+
+	if exp := "INFO | root | Started\nINFO | root.audit | User created\n"; exp != all.data {
+		t.Errorf("Wrong unrouted target: %v != %v", all.data, exp)
+	}
+	if exp := "INFO | root.audit | User created\n"; exp != audit.data {
+		t.Errorf("Wrong routed target: %v != %v", audit.data, exp)
+	}
+}
@@ -0,0 +1,406 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Entry - A single log message along with the details that are printed with it. A zero time means
+// that no timestamp is printed, an empty file means that no caller is printed and an empty stack
+// means that no stack trace is printed.
+type Entry struct {
+	Time    time.Time
+	Level   string
+	Prefix  string
+	Message string
+	Fields  map[string]interface{}
+
+	File     string
+	Line     int
+	Function string
+	Stack    string
+
+	// layout - The layout of the timestamp, see timestamp.
+	layout string
+}
+
+// formatter - Writes an entry to a buffer as a single line of a log format.
+type formatter func(buf *bytes.Buffer, e *Entry)
+
+// formatters - The formatter of each log format.
+var formatters = map[string]formatter{
+	"text":   formatText,
+	"json":   formatJSON,
+	"logfmt": formatLogfmt,
+}
+
+// bufferPool - Buffers that entries are formatted into, which are reused so that printing a message
+// does not allocate a new buffer each time.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer - The capacity above which a buffer is not returned to the pool, so that a single
+// large message does not keep its memory held.
+const maxPooledBuffer = 64 * 1024
+
+// getBuffer - Returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer - Returns a buffer to the pool once it is no longer used.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// formatterFor - Returns the formatter of the format selected by a config for a stream, where the
+// text format is coloured when colour is enabled for the stream, and the template format falls back
+// to text when its template is invalid.
+func formatterFor(config LoggerConfig, stream io.Writer) formatter {
+	if config.JSONFormat {
+		return formatJSON
+	}
+	if config.Format == "template" {
+		if f, ok := newTemplateFormatter(config.Template); ok {
+			return f
+		}
+	}
+	f, ok := formatters[config.Format]
+	if !ok {
+		f = formatText
+	}
+	if config.Format == "text" || !ok {
+		if colorEnabled(config.Color, stream) {
+			return formatColor
+		}
+	}
+	return f
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+formatText - Writes an entry as pipe separated text. The message is written as it is, and is
+therefore expected to carry its own line ending. Fields are written after the message as logfmt
+pairs in sorted order, before any line ending of the message. A stack trace is written on the lines
+following the message, where each line is indented by a tab.
+*/
+func formatText(buf *bytes.Buffer, e *Entry) {
+	writeTextLine(buf, e)
+	writeStack(buf, e.Stack)
+}
+
+// writeStack - Writes a stack trace on the lines following those of a buffer, where each line is
+// indented by a tab.
+func writeStack(buf *bytes.Buffer, stack string) {
+	if stack == "" {
+		return
+	}
+	if b := buf.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(stack, "\n"), "\n") {
+		buf.WriteByte('\t')
+		buf.WriteString(line)
+	}
+	buf.WriteByte('\n')
+}
+
+// writeTextLine - Writes the line of an entry as pipe separated text.
+func writeTextLine(buf *bytes.Buffer, e *Entry) {
+	if !e.Time.IsZero() {
+		var scratch [64]byte
+		buf.Write(e.appendTimestamp(scratch[:0]))
+		buf.WriteString(" | ")
+	}
+	buf.WriteString(e.Level)
+	buf.WriteString(" | ")
+	buf.WriteString(e.Prefix)
+	buf.WriteString(" | ")
+	if e.File != "" {
+		buf.WriteString(shortCaller(e.File, e.Line))
+		buf.WriteByte(' ')
+		buf.WriteString(shortFunction(e.Function))
+		buf.WriteString(" | ")
+	}
+	if len(e.Fields) == 0 {
+		buf.WriteString(e.Message)
+		return
+	}
+
+	// The buffer is never empty here, and so each pair is written with a leading space.
+	message := strings.TrimSuffix(e.Message, "\n")
+	buf.WriteString(message)
+	writeLogfmtFields(buf, e.Fields)
+	if len(message) < len(e.Message) {
+		buf.WriteByte('\n')
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// levelColors - The ANSI colour sequence of each level within coloured text.
+var levelColors = map[string]string{
+	"FATAL": "\x1b[1;35m",
+	"ERROR": "\x1b[31m",
+	"WARN":  "\x1b[33m",
+	"INFO":  "\x1b[32m",
+	"DEBUG": "\x1b[36m",
+	"TRACE": "\x1b[90m",
+}
+
+// coloredLevels - Each level wrapped in its colour sequence, which are built once rather than for
+// every message.
+var coloredLevels = func() map[string]string {
+	levels := make(map[string]string, len(levelColors))
+	for level, c := range levelColors {
+		levels[level] = c + level + "\x1b[0m"
+	}
+	return levels
+}()
+
+// isTerminal - Returns whether a stream is a file connected to a terminal.
+func isTerminal(stream io.Writer) bool {
+	f, ok := stream.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnabled - Returns whether text written to a stream is coloured, which is the case for a
+// color setting of "always", or of "auto" when the stream is a terminal.
+func colorEnabled(color string, stream io.Writer) bool {
+	switch color {
+	case "always":
+		return true
+	case "auto":
+		return isTerminal(stream)
+	}
+	return false
+}
+
+// formatColor - Writes an entry as pipe separated text where the level is coloured.
+func formatColor(buf *bytes.Buffer, e *Entry) {
+	colored := *e
+	if level, ok := coloredLevels[e.Level]; ok {
+		colored.Level = level
+	}
+	formatText(buf, &colored)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// writeJSONString - Writes a string as a quoted JSON string without escaping HTML characters.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	// The encoder terminates each value with a newline.
+	buf.Truncate(buf.Len() - 1)
+}
+
+// sortedFieldKeys - Returns the keys of fields in sorted order.
+func sortedFieldKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeJSONFields - Writes fields as a JSON object with sorted keys. Values that cannot be
+// marshalled are written as the string of their fmt representation.
+func writeJSONFields(buf *bytes.Buffer, fields map[string]interface{}) {
+	keys := sortedFieldKeys(fields)
+
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, k)
+		buf.WriteByte(':')
+
+		v := fields[k]
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			writeJSONString(buf, fmt.Sprintf("%v", v))
+			continue
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+}
+
+// formatJSON - Writes an entry as a single line JSON object, where a trailing line ending of the
+// message is dropped and any fields are nested within a "fields" object.
+func formatJSON(buf *bytes.Buffer, e *Entry) {
+	buf.WriteByte('{')
+	if !e.Time.IsZero() {
+		// Only custom layouts can produce characters that need escaping.
+		var scratch [64]byte
+		buf.WriteString(`"timestamp":`)
+		switch {
+		case e.numericTimestamp():
+			buf.Write(e.appendTimestamp(scratch[:0]))
+		case e.layout == "" || e.layout == "rfc3339" || e.layout == "rfc3339nano":
+			buf.WriteByte('"')
+			buf.Write(e.appendTimestamp(scratch[:0]))
+			buf.WriteByte('"')
+		default:
+			writeJSONString(buf, e.timestamp())
+		}
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"level":`)
+	writeJSONString(buf, e.Level)
+	buf.WriteString(`,"service":`)
+	writeJSONString(buf, e.Prefix)
+	if e.File != "" {
+		buf.WriteString(`,"caller":`)
+		writeJSONString(buf, shortCaller(e.File, e.Line))
+		buf.WriteString(`,"function":`)
+		writeJSONString(buf, e.Function)
+	}
+	buf.WriteString(`,"message":`)
+	writeJSONString(buf, strings.TrimSuffix(e.Message, "\n"))
+	if len(e.Fields) > 0 {
+		buf.WriteString(`,"fields":`)
+		writeJSONFields(buf, e.Fields)
+	}
+	if e.Stack != "" {
+		buf.WriteString(`,"stack":`)
+		writeJSONString(buf, e.Stack)
+	}
+	buf.WriteString("}\n")
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// writeLogfmtValue - Writes a logfmt value, which is quoted when it is empty or contains spaces,
+// quotes, equals signs or control characters.
+func writeLogfmtValue(buf *bytes.Buffer, s string) {
+	needsQuote := s == ""
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			needsQuote = true
+			break
+		}
+	}
+	if !needsQuote {
+		buf.WriteString(s)
+		return
+	}
+	writeJSONString(buf, s)
+}
+
+// writeLogfmtPair - Writes a space separated key=value pair, where keys are stripped of characters
+// that would break the pair.
+func writeLogfmtPair(buf *bytes.Buffer, key string, value interface{}) {
+	key = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, key)
+
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+
+	var scratch [32]byte
+	switch v := value.(type) {
+	case string:
+		writeLogfmtValue(buf, v)
+	case int:
+		buf.Write(strconv.AppendInt(scratch[:0], int64(v), 10))
+	case int64:
+		buf.Write(strconv.AppendInt(scratch[:0], v, 10))
+	case bool:
+		buf.Write(strconv.AppendBool(scratch[:0], v))
+	case error:
+		writeLogfmtValue(buf, v.Error())
+	default:
+		writeLogfmtValue(buf, fmt.Sprintf("%v", v))
+	}
+}
+
+// writeLogfmtFields - Writes fields as logfmt pairs in sorted order.
+func writeLogfmtFields(buf *bytes.Buffer, fields map[string]interface{}) {
+	for _, k := range sortedFieldKeys(fields) {
+		writeLogfmtPair(buf, k, fields[k])
+	}
+}
+
+// formatLogfmt - Writes an entry as a single line of logfmt key=value pairs, with the level in lower
+// case and fields following the message in sorted order.
+func formatLogfmt(buf *bytes.Buffer, e *Entry) {
+	line := getBuffer()
+	defer putBuffer(line)
+	if !e.Time.IsZero() {
+		writeLogfmtPair(line, "time", e.timestamp())
+	}
+	writeLogfmtPair(line, "level", strings.ToLower(e.Level))
+	writeLogfmtPair(line, "service", e.Prefix)
+	if e.File != "" {
+		writeLogfmtPair(line, "caller", shortCaller(e.File, e.Line))
+		writeLogfmtPair(line, "func", e.Function)
+	}
+	writeLogfmtPair(line, "msg", strings.TrimSuffix(e.Message, "\n"))
+
+	writeLogfmtFields(line, e.Fields)
+	if e.Stack != "" {
+		writeLogfmtPair(line, "stack", e.Stack)
+	}
+
+	line.WriteByte('\n')
+	buf.Write(line.Bytes())
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,186 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONFormat(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Format = "json"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig)
+	logger.Warnf("Warning %v message\n", `"quoted"`)
+	logger.Infoln("Info <html> message")
+	logger.Errorf("Error \x00 message")
+
+	expected := `{"level":"WARN","service":"root","message":"Warning \"quoted\" message"}` + "\n" +
+		`{"level":"INFO","service":"root","message":"Info <html> message"}` + "\n" +
+		`{"level":"ERROR","service":"root","message":"Error \u0000 message"}` + "\n"
+
+	if expected != buf.data {
+		t.Errorf("Json format does not match: %v != %v", buf.data, expected)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.data), "\n") {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Errorf("Line is not valid JSON: %v: %v", line, err)
+		}
+	}
+}
+
+func TestJSONFormatLegacy(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Prefix = "root"
+	loggerConfig.JSONFormat = true
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig)
+	logger.Infoln("Info message")
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.data), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj["timestamp"]; !ok {
+		t.Errorf("Expected timestamp field: %v", buf.data)
+	}
+	if exp, act := "Info message", obj["message"]; exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	e := Entry{
+		Level:   "INFO",
+		Prefix:  "root",
+		Message: "msg\n",
+		Fields: map[string]interface{}{
+			"b":   2,
+			"a":   "one",
+			"err": errors.New("failed"),
+			"fn":  func() {},
+		},
+	}
+
+	var buf bytes.Buffer
+	formatJSON(&buf, &e)
+
+	expected := `{"level":"INFO","service":"root","message":"msg",` +
+		`"fields":{"a":"one","b":2,"err":"failed","fn":"0x`
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("Wrong output: %v", buf.String())
+	}
+}
+
+func TestLogfmtFormat(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Format = "logfmt"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig)
+	logger.Warnf("Warning %v message\n", `"quoted"`)
+	logger.Infoln("single")
+	logger.Errorf("a=b")
+	logger.Debugln("hidden")
+
+	expected := `level=warn service=root msg="Warning \"quoted\" message"` + "\n" +
+		`level=info service=root msg=single` + "\n" +
+		`level=error service=root msg="a=b"` + "\n"
+
+	if expected != buf.data {
+		t.Errorf("Logfmt format does not match: %v != %v", buf.data, expected)
+	}
+}
+
+func TestLogfmtFields(t *testing.T) {
+	e := Entry{
+		Level:   "INFO",
+		Prefix:  "root",
+		Message: "msg\n",
+		Fields: map[string]interface{}{
+			"b":       2,
+			"a":       "",
+			"err":     errors.New("it failed"),
+			"bad key": "x",
+		},
+	}
+
+	var buf bytes.Buffer
+	formatLogfmt(&buf, &e)
+
+	expected := `level=info service=root msg=msg a="" b=2 bad_key=x err="it failed"` + "\n"
+	if exp, act := expected, buf.String(); exp != act {
+		t.Errorf("Wrong output: %v != %v", act, exp)
+	}
+}
+
+func TestColorFormat(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Color = "always"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig)
+	logger.Errorln("Error message")
+	logger.Infof("Info message\n")
+
+	expected := "\x1b[31mERROR\x1b[0m | root | Error message\n" +
+		"\x1b[32mINFO\x1b[0m | root | Info message\n"
+	if expected != buf.data {
+		t.Errorf("Color format does not match: %q != %q", buf.data, expected)
+	}
+
+	loggerConfig.Color = "auto"
+	buf = LogBuffer{data: ""}
+
+	NewLogger(&buf, loggerConfig).Errorln("Error message")
+	if exp := "ERROR | root | Error message\n"; exp != buf.data {
+		t.Errorf("Expected no color for a non terminal: %q != %q", buf.data, exp)
+	}
+
+	loggerConfig.Color = "always"
+	loggerConfig.Format = "json"
+	buf = LogBuffer{data: ""}
+
+	NewLogger(&buf, loggerConfig).Errorln("Error message")
+	if exp := `{"level":"ERROR","service":"root","message":"Error message"}` + "\n"; exp != buf.data {
+		t.Errorf("Expected no color for json: %q != %q", buf.data, exp)
+	}
+}
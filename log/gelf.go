@@ -0,0 +1,331 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the GELF target.
+var (
+	ErrGELFCompression = errors.New("gelf compression was not recognised")
+	ErrGELFTooLarge    = errors.New("gelf message exceeds the maximum number of chunks")
+)
+
+// gelfMaxChunks - The maximum number of chunks a GELF message can be split into.
+const gelfMaxChunks = 128
+
+// gelfChunkHeader - The length of the header of each GELF chunk.
+const gelfChunkHeader = 12
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+GELFConfig - Config for a Graylog target that sends GELF messages over "udp" or "tcp". Messages over
+UDP are compressed with "gzip", "zlib" or "none", and are split into chunks of at most ChunkSize
+bytes when they are larger than that. Messages over TCP are never compressed and are terminated by
+a null byte. The host defaults to the hostname of the machine.
+*/
+type GELFConfig struct {
+	Network     string `json:"network" yaml:"network"`
+	Address     string `json:"address" yaml:"address"`
+	Compression string `json:"compression" yaml:"compression"`
+	ChunkSize   int    `json:"chunk_size" yaml:"chunk_size"`
+	Host        string `json:"host" yaml:"host"`
+	Timeout     string `json:"timeout" yaml:"timeout"`
+}
+
+// NewGELFConfig - Returns a GELF target configuration with the default values for each field.
+func NewGELFConfig() GELFConfig {
+	return GELFConfig{
+		Network:     "udp",
+		Address:     "localhost:12201",
+		Compression: "gzip",
+		ChunkSize:   1420,
+		Host:        "",
+		Timeout:     "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+GELFWriter - An EntryWriter that sends each log message to Graylog, which can be used as the stream
+of a logger. The prefix of the logger is sent as the additional field "_service" and each field of
+the message is sent as an additional field, where characters not allowed within GELF field names are
+replaced with underscores. A captured caller is sent as "_file", "_line" and "_function", and a
+stack trace as "_stack". The connection is dialled again once if a write fails.
+*/
+type GELFWriter struct {
+	sync.Mutex
+
+	network     string
+	address     string
+	compression string
+	chunkSize   int
+	host        string
+	timeout     time.Duration
+
+	conn net.Conn
+}
+
+// NewGELFWriter - Creates a GELF writer from a config and dials Graylog.
+func NewGELFWriter(conf GELFConfig) (*GELFWriter, error) {
+	g := &GELFWriter{
+		network:     conf.Network,
+		address:     conf.Address,
+		compression: conf.Compression,
+		chunkSize:   conf.ChunkSize,
+		host:        conf.Host,
+	}
+	switch g.compression {
+	case "gzip", "zlib", "none":
+	case "":
+		g.compression = "none"
+	default:
+		return nil, ErrGELFCompression
+	}
+	if g.chunkSize <= gelfChunkHeader {
+		g.chunkSize = NewGELFConfig().ChunkSize
+	}
+	if g.host == "" {
+		g.host, _ = os.Hostname()
+	}
+	if conf.Timeout != "" {
+		var err error
+		if g.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout: %v", err)
+		}
+	}
+
+	var err error
+	if g.conn, err = net.DialTimeout(g.network, g.address, g.timeout); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// gelfFieldName - Returns a field name as a GELF additional field name, which may only contain word
+// characters, dots and dashes, and may not be "_id".
+func gelfFieldName(key string) string {
+	key = "_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+			r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, key)
+	if key == "_id" {
+		return "_id_"
+	}
+	return key
+}
+
+// gelfMessage - Returns an entry as a GELF message, where the short message is the first line of
+// the message and the full message is only set when there are more.
+func (g *GELFWriter) gelfMessage(e *Entry) ([]byte, error) {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	msg := strings.TrimSuffix(e.Message, "\n")
+
+	obj := map[string]interface{}{
+		"version":   "1.1",
+		"host":      g.host,
+		"timestamp": float64(t.UnixNano()/int64(time.Millisecond)) / 1000,
+		"level":     syslogSeverity(logLevelToInt(e.Level)),
+		"_service":  e.Prefix,
+	}
+	if e.File != "" {
+		obj["_file"] = e.File
+		obj["_line"] = e.Line
+		obj["_function"] = e.Function
+	}
+	if e.Stack != "" {
+		obj["_stack"] = e.Stack
+	}
+	if i := strings.Index(msg, "\n"); i >= 0 {
+		obj["short_message"] = msg[:i]
+		obj["full_message"] = msg
+	} else {
+		obj["short_message"] = msg
+	}
+
+	for k, v := range e.Fields {
+		switch t := v.(type) {
+		case string, bool, float32, float64, int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64:
+		case error:
+			v = t.Error()
+		default:
+			v = fmt.Sprintf("%v", t)
+		}
+		obj[gelfFieldName(k)] = v
+	}
+	return json.Marshal(obj)
+}
+
+// compress - Returns a message compressed with the configured compression.
+func (g *GELFWriter) compress(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch g.compression {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	default:
+		return msg, nil
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// packets - Returns the packets of a message to be sent over the configured network.
+func (g *GELFWriter) packets(msg []byte) ([][]byte, error) {
+	if !strings.HasPrefix(g.network, "udp") {
+		return [][]byte{append(msg, 0)}, nil
+	}
+
+	msg, err := g.compress(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) <= g.chunkSize {
+		return [][]byte{msg}, nil
+	}
+
+	size := g.chunkSize - gelfChunkHeader
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		return nil, ErrGELFTooLarge
+	}
+
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, gelfChunkHeader+end-i*size)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunks = append(chunks, append(chunk, msg[i*size:end]...))
+	}
+	return chunks, nil
+}
+
+// send - Writes the packets of a message, dialling Graylog again and retrying once if a write fails.
+func (g *GELFWriter) send(packets [][]byte) error {
+	g.Lock()
+	defer g.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if g.conn == nil {
+			if g.conn, err = net.DialTimeout(g.network, g.address, g.timeout); err != nil {
+				continue
+			}
+		}
+		if g.timeout > 0 {
+			g.conn.SetWriteDeadline(time.Now().Add(g.timeout))
+		}
+		for _, p := range packets {
+			if _, err = g.conn.Write(p); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		g.conn.Close()
+		g.conn = nil
+	}
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WriteEntry - Sends a log message to Graylog.
+func (g *GELFWriter) WriteEntry(e *Entry) error {
+	msg, err := g.gelfMessage(e)
+	if err != nil {
+		return err
+	}
+	packets, err := g.packets(msg)
+	if err != nil {
+		return err
+	}
+	return g.send(packets)
+}
+
+// Write - Sends a log line to Graylog as a message of the info level.
+func (g *GELFWriter) Write(p []byte) (int, error) {
+	if err := g.WriteEntry(&Entry{Level: "INFO", Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close - Closes the connection to Graylog.
+func (g *GELFWriter) Close() error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,221 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readGELFPacket(t *testing.T, conn net.PacketConn) []byte {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func TestGELFUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewGELFConfig()
+	conf.Address = conn.LocalAddr().String()
+	conf.Compression = "none"
+	conf.Host = "host"
+
+	g, err := NewGELFWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Prefix = "root"
+
+	logger := NewLogger(g, loggerConfig).(*Logger).WithFields(map[string]interface{}{
+		"request_id": "abc",
+		"id":         1,
+		"bad key":    errors.New("failed"),
+	})
+	logger.Warnf("First line\nSecond line\n")
+
+	var msg map[string]interface{}
+	if err = json.Unmarshal(readGELFPacket(t, conn), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg["timestamp"].(float64); !ok {
+		t.Errorf("Expected timestamp: %v", msg)
+	}
+	delete(msg, "timestamp")
+
+	expected := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "host",
+		"short_message": "First line",
+		"full_message":  "First line\nSecond line",
+		"level":         float64(4),
+		"_service":      "root",
+		"_request_id":   "abc",
+		"_id_":          float64(1),
+		"_bad_key":      "failed",
+	}
+	if !reflect.DeepEqual(expected, msg) {
+		t.Errorf("Wrong message: %v != %v", msg, expected)
+	}
+}
+
+func TestGELFChunked(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewGELFConfig()
+	conf.Address = conn.LocalAddr().String()
+	conf.ChunkSize = 32
+
+	g, err := NewGELFWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	if _, err = g.Write([]byte("a message long enough to be split into some chunks")); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload []byte
+	var id []byte
+	for i, count := 0, 1; i < count; i++ {
+		chunk := readGELFPacket(t, conn)
+		if chunk[0] != 0x1e || chunk[1] != 0x0f {
+			t.Fatalf("Wrong chunk magic: %v", chunk[:2])
+		}
+		if id == nil {
+			id = chunk[2:10]
+		} else if !bytes.Equal(id, chunk[2:10]) {
+			t.Errorf("Wrong chunk id: %v != %v", chunk[2:10], id)
+		}
+		if int(chunk[10]) != i {
+			t.Errorf("Wrong chunk sequence: %v != %v", chunk[10], i)
+		}
+		count = int(chunk[11])
+		if count < 2 {
+			t.Errorf("Expected several chunks: %v", count)
+		}
+		payload = append(payload, chunk[12:]...)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg map[string]interface{}
+	if err = json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "a message long enough to be split into some chunks", msg["short_message"]; exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	if exp, act := float64(6), msg["level"]; exp != act {
+		t.Errorf("Wrong level: %v != %v", act, exp)
+	}
+
+	g.chunkSize = gelfChunkHeader + 1
+	g.compression = "none"
+	if err = g.WriteEntry(&Entry{Message: strings.Repeat("x", 200)}); err != ErrGELFTooLarge {
+		t.Errorf("Wrong error: %v != %v", err, ErrGELFTooLarge)
+	}
+}
+
+func TestGELFTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conf := NewGELFConfig()
+	conf.Network = "tcp"
+	conf.Address = ln.Addr().String()
+
+	g, err := NewGELFWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	g.WriteEntry(&Entry{Level: "ERROR", Prefix: "root", Message: "one\n"})
+	g.WriteEntry(&Entry{Level: "DEBUG", Prefix: "root", Message: "two\n"})
+
+	r := bufio.NewReader(conn)
+	for _, exp := range []string{"one", "two"} {
+		data, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg map[string]interface{}
+		if err = json.Unmarshal(data[:len(data)-1], &msg); err != nil {
+			t.Fatal(err)
+		}
+		if act := msg["short_message"]; exp != act {
+			t.Errorf("Wrong message: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestGELFBadConfig(t *testing.T) {
+	conf := NewGELFConfig()
+	conf.Compression = "nope"
+	if _, err := NewGELFWriter(conf); err != ErrGELFCompression {
+		t.Errorf("Wrong error: %v != %v", err, ErrGELFCompression)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+/*
+Package grpcinterceptor - Interceptors for gRPC servers and clients that log each RPC with a Logger
+and record per method stats into a metrics type. Calls are logged with the fields grpc_method,
+grpc_code and latency, at the info level when they succeed, the error level when their code
+indicates a fault of the server, and the warn level otherwise.
+
+Stats are recorded under the paths "grpc.server.<service>.<method>" and
+"grpc.client.<service>.<method>", where dots within the service name are replaced with underscores,
+as the counters "calls" and "errors" and the timing "latency". A nil metrics type records no stats.
+*/
+package grpcinterceptor
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeffail/util/log"
+	"github.com/jeffail/util/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// methodPath - Returns the stats path of a full method name such as "/pkg.Service/Method", which
+// becomes "pkg_Service.Method".
+func methodPath(fullMethod string) string {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	service, method := fullMethod, "unknown"
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		service, method = fullMethod[:i], fullMethod[i+1:]
+	}
+	return strings.Replace(service, ".", "_", -1) + "." + method
+}
+
+// serverFault - Returns whether a code indicates a fault of the server rather than of the call.
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// record - Logs a finished RPC and records its stats under a side, either "server" or "client".
+func record(
+	logger *log.Logger, stats metrics.Type, side, fullMethod string, start time.Time, err error,
+) {
+	latency := time.Since(start)
+	code := status.Code(err)
+
+	if stats != nil {
+		path := "grpc." + side + "." + methodPath(fullMethod)
+		stats.Incr(path+".calls", 1)
+		if err != nil {
+			stats.Incr(path+".errors", 1)
+		}
+		stats.TimingDuration(path+".latency", latency)
+	}
+
+	rpc := logger.WithError(err).WithFields(map[string]interface{}{
+		"grpc_method": fullMethod,
+		"grpc_code":   code.String(),
+		"latency":     latency.String(),
+	})
+	switch {
+	case code == codes.OK:
+		rpc.Infof("%v %v\n", fullMethod, code)
+	case serverFault(code):
+		rpc.Errorf("%v %v\n", fullMethod, code)
+	default:
+		rpc.Warnf("%v %v\n", fullMethod, code)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// UnaryServerInterceptor - Returns a server interceptor that logs and records each unary RPC.
+func UnaryServerInterceptor(logger *log.Logger, stats metrics.Type) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		record(logger, stats, "server", info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor - Returns a server interceptor that logs and records each streaming RPC
+// once its handler returns.
+func StreamServerInterceptor(logger *log.Logger, stats metrics.Type) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		record(logger, stats, "server", info.FullMethod, start, err)
+		return err
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// UnaryClientInterceptor - Returns a client interceptor that logs and records each unary RPC.
+func UnaryClientInterceptor(logger *log.Logger, stats metrics.Type) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		record(logger, stats, "client", method, start, err)
+		return err
+	}
+}
+
+// clientStream - A ClientStream that logs and records its RPC once a receive ends the stream. When
+// the server does not stream, its single response also ends the stream.
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	finish        func(err error)
+}
+
+func (c *clientStream) RecvMsg(m interface{}) error {
+	err := c.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		c.once.Do(func() { c.finish(nil) })
+	case err != nil:
+		c.once.Do(func() { c.finish(err) })
+	case !c.serverStreams:
+		c.once.Do(func() { c.finish(nil) })
+	}
+	return err
+}
+
+/*
+StreamClientInterceptor - Returns a client interceptor that logs and records each streaming RPC.
+An RPC is finished when the stream fails to open or when a receive returns an error, where io.EOF
+marks a successful end of the stream, or when the response of a client streaming RPC is received.
+Streams that are abandoned before then are not recorded.
+*/
+func StreamClientInterceptor(logger *log.Logger, stats metrics.Type) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			record(logger, stats, "client", method, start, err)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream:  cs,
+			serverStreams: desc.ServerStreams,
+			finish: func(err error) {
+				record(logger, stats, "client", method, start, err)
+			},
+		}, nil
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
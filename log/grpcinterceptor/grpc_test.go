@@ -0,0 +1,275 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package grpcinterceptor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeffail/util/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordedStats - A metrics.Type that records counters and the number of timings of each path.
+type recordedStats struct {
+	sync.Mutex
+	counts  map[string]int64
+	timings map[string]int
+}
+
+func newRecordedStats() *recordedStats {
+	return &recordedStats{counts: map[string]int64{}, timings: map[string]int{}}
+}
+
+func (r *recordedStats) Incr(path string, count int64) error {
+	r.Lock()
+	defer r.Unlock()
+	r.counts[path] += count
+	return nil
+}
+
+func (r *recordedStats) Decr(path string, count int64) error {
+	return r.Incr(path, -count)
+}
+
+func (r *recordedStats) Timing(path string, delta int64) error {
+	r.Lock()
+	defer r.Unlock()
+	r.timings[path]++
+	return nil
+}
+
+func (r *recordedStats) TimingDuration(path string, d time.Duration) error {
+	return r.Timing(path, int64(d))
+}
+
+func (r *recordedStats) Gauge(path string, value int64) error {
+	return nil
+}
+
+func (r *recordedStats) Close() error {
+	return nil
+}
+
+func newTestLogger(buf *bytes.Buffer) *log.Logger {
+	loggerConfig := log.NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	return log.NewLogger(buf, loggerConfig).(*log.Logger)
+}
+
+func TestMethodPath(t *testing.T) {
+	for in, exp := range map[string]string{
+		"/helloworld.Greeter/SayHello": "helloworld_Greeter.SayHello",
+		"/Service/Method":              "Service.Method",
+		"broken":                       "broken.unknown",
+	} {
+		if act := methodPath(in); act != exp {
+			t.Errorf("Wrong path for %v: %v != %v", in, act, exp)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	stats := newRecordedStats()
+	intercept := UnaryServerInterceptor(newTestLogger(&buf), stats)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Greeter/SayHello"}
+	resp, err := intercept(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "resp", nil
+	})
+	if resp != "resp" || err != nil {
+		t.Errorf("Wrong result: %v, %v", resp, err)
+	}
+
+	notFound := status.Error(codes.NotFound, "no such greeting")
+	if _, err = intercept(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, notFound
+	}); err != notFound {
+		t.Errorf("Wrong error: %v", err)
+	}
+
+	internal := errors.New("broken")
+	intercept(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, internal
+	})
+
+	expected := regexp.MustCompile(`^` +
+		`INFO \| root \| /pkg.Greeter/SayHello OK grpc_code=OK grpc_method=/pkg.Greeter/SayHello latency=\S+\n` +
+		`WARN \| root \| /pkg.Greeter/SayHello NotFound error="rpc error: code = NotFound desc = no such greeting" grpc_code=NotFound grpc_method=/pkg.Greeter/SayHello latency=\S+\n` +
+		`ERROR \| root \| /pkg.Greeter/SayHello Unknown error=broken grpc_code=Unknown grpc_method=/pkg.Greeter/SayHello latency=\S+\n$`)
+	if !expected.MatchString(buf.String()) {
+		t.Errorf("Wrong RPC log: %v", buf.String())
+	}
+
+	expCounts := map[string]int64{
+		"grpc.server.pkg_Greeter.SayHello.calls":  3,
+		"grpc.server.pkg_Greeter.SayHello.errors": 2,
+	}
+	if !reflect.DeepEqual(expCounts, stats.counts) {
+		t.Errorf("Wrong stat counts: %v != %v", stats.counts, expCounts)
+	}
+	if exp, act := 3, stats.timings["grpc.server.pkg_Greeter.SayHello.latency"]; exp != act {
+		t.Errorf("Wrong timings: %v != %v", act, exp)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	stats := newRecordedStats()
+	intercept := StreamServerInterceptor(newTestLogger(&buf), nil)
+
+	info := &grpc.StreamServerInfo{FullMethod: "/pkg.Greeter/Stream"}
+	err := intercept(nil, nil, info, func(srv interface{}, stream grpc.ServerStream) error {
+		return status.Error(codes.Unavailable, "draining")
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Wrong error: %v", err)
+	}
+
+	expected := regexp.MustCompile(`^ERROR \| root \| /pkg.Greeter/Stream Unavailable .* grpc_code=Unavailable `)
+	if !expected.MatchString(buf.String()) {
+		t.Errorf("Wrong RPC log: %v", buf.String())
+	}
+	if len(stats.counts) != 0 {
+		t.Errorf("Unexpected stats: %v", stats.counts)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	stats := newRecordedStats()
+	intercept := UnaryClientInterceptor(newTestLogger(&buf), stats)
+
+	err := intercept(context.Background(), "/pkg.Greeter/SayHello", "req", nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := regexp.MustCompile(`^INFO \| root \| /pkg.Greeter/SayHello OK `)
+	if !expected.MatchString(buf.String()) {
+		t.Errorf("Wrong RPC log: %v", buf.String())
+	}
+	expCounts := map[string]int64{"grpc.client.pkg_Greeter.SayHello.calls": 1}
+	if !reflect.DeepEqual(expCounts, stats.counts) {
+		t.Errorf("Wrong stat counts: %v != %v", stats.counts, expCounts)
+	}
+}
+
+// fakeClientStream - A ClientStream that receives a number of messages before ending.
+type fakeClientStream struct {
+	grpc.ClientStream
+	remaining int
+}
+
+func (f *fakeClientStream) RecvMsg(m interface{}) error {
+	if f.remaining == 0 {
+		return io.EOF
+	}
+	f.remaining--
+	return nil
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	stats := newRecordedStats()
+	intercept := StreamClientInterceptor(newTestLogger(&buf), stats)
+
+	cs, err := intercept(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/pkg.Greeter/Stream",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{remaining: 2}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		err = cs.RecvMsg(nil)
+		if err == nil && buf.Len() > 0 {
+			t.Errorf("RPC logged before the stream ended: %v", buf.String())
+		}
+	}
+	cs.RecvMsg(nil)
+
+	expected := regexp.MustCompile(`^INFO \| root \| /pkg.Greeter/Stream OK [^\n]*\n$`)
+	if !expected.MatchString(buf.String()) {
+		t.Errorf("Wrong RPC log: %v", buf.String())
+	}
+
+	failed := status.Error(codes.PermissionDenied, "denied")
+	if _, err = intercept(context.Background(), &grpc.StreamDesc{}, nil, "/pkg.Greeter/Stream",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return nil, failed
+		}); err != failed {
+		t.Errorf("Wrong error: %v", err)
+	}
+
+	expCounts := map[string]int64{
+		"grpc.client.pkg_Greeter.Stream.calls":  2,
+		"grpc.client.pkg_Greeter.Stream.errors": 1,
+	}
+	if !reflect.DeepEqual(expCounts, stats.counts) {
+		t.Errorf("Wrong stat counts: %v != %v", stats.counts, expCounts)
+	}
+}
+
+func TestStreamClientInterceptorClientStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	stats := newRecordedStats()
+	intercept := StreamClientInterceptor(newTestLogger(&buf), stats)
+
+	desc := &grpc.StreamDesc{ClientStreams: true}
+	cs, err := intercept(context.Background(), desc, nil, "/pkg.Greeter/Upload",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{remaining: 1}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CloseAndRecv of a client streaming RPC receives the single response with a nil error.
+	if err = cs.RecvMsg(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := regexp.MustCompile(`^INFO \| root \| /pkg.Greeter/Upload OK [^\n]*\n$`)
+	if !expected.MatchString(buf.String()) {
+		t.Errorf("Wrong RPC log: %v", buf.String())
+	}
+	expCounts := map[string]int64{"grpc.client.pkg_Greeter.Upload.calls": 1}
+	if !reflect.DeepEqual(expCounts, stats.counts) {
+		t.Errorf("Wrong stat counts: %v != %v", stats.counts, expCounts)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// funcHook - A Hook made of a function.
+type funcHook struct {
+	levels []int
+	fire   func(e *Entry) error
+}
+
+func (f funcHook) Levels() []int {
+	return f.levels
+}
+
+func (f funcHook) Fire(e *Entry) error {
+	return f.fire(e)
+}
+
+// NewHook - Creates a Hook that calls a function for the entries of some levels.
+func NewHook(levels []int, fire func(e *Entry) error) Hook {
+	return funcHook{levels: levels, fire: fire}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// hookRegistry - The hooks of each level, shared by every module and child of a logger.
+type hookRegistry struct {
+	sync.RWMutex
+	levels map[int][]Hook
+
+	// errors - Where errors returned by hooks are reported.
+	errors io.Writer
+}
+
+// newHookRegistry - Creates an empty hook registry.
+func newHookRegistry() *hookRegistry {
+	return &hookRegistry{
+		levels: map[int][]Hook{},
+		errors: os.Stderr,
+	}
+}
+
+// add - Registers a hook for each of its levels.
+func (h *hookRegistry) add(hook Hook) {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, level := range hook.Levels() {
+		h.levels[level] = append(h.levels[level], hook)
+	}
+}
+
+// fire - Calls each hook of the level of an entry in the order they were added. Errors are reported
+// to the error stream rather than the logger, as the logger may itself be the cause.
+func (h *hookRegistry) fire(e *Entry) {
+	h.RLock()
+	hooks := h.levels[logLevelToInt(e.Level)]
+	h.RUnlock()
+
+	for _, hook := range hooks {
+		if err := hook.Fire(e); err != nil {
+			fmt.Fprintf(h.errors, "failed to fire log hook: %v\n", err)
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// AddHook - Registers a hook that receives the entries of messages printed at its levels, which
+// applies to every module and child of the logger. Hooks are called by the goroutine printing the
+// message, even when the logger is asynchronous.
+func (l *Logger) AddHook(hook Hook) {
+	l.hooks.add(hook)
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Async = true
+
+	logger := NewLogger(&LogCounter{}, loggerConfig).(*Logger)
+	defer logger.Close()
+
+	module := logger.NewModule(".foo").(*Logger).WithFields(map[string]interface{}{"a": 1})
+
+	var errs, fatals []string
+	logger.AddHook(NewHook([]int{LogError, LogFatal}, func(e *Entry) error {
+		errs = append(errs, fmt.Sprintf("%v: %v %v", e.Prefix, e.Message, e.Fields["a"]))
+		return nil
+	}))
+	module.AddHook(NewHook([]int{LogFatal}, func(e *Entry) error {
+		fatals = append(fatals, e.Message)
+		return nil
+	}))
+
+	logger.Warnln("Warning")
+	module.Errorln("Error")
+	logger.Fatalf("Fatal %v", 1)
+	module.Debugln("Hidden")
+
+	// Hooks are fired before returning, even when asynchronous.
+	if exp := []string{"root.foo: Error\n 1", "root: Fatal 1 <nil>"}; !reflect.DeepEqual(exp, errs) {
+		t.Errorf("Wrong error hook entries: %v != %v", errs, exp)
+	}
+	if exp := []string{"Fatal 1"}; !reflect.DeepEqual(exp, fatals) {
+		t.Errorf("Wrong fatal hook entries: %v != %v", fatals, exp)
+	}
+}
+
+func TestHookErrors(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	buf := LogBuffer{data: ""}
+	errBuf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.hooks.errors = &errBuf
+	logger.AddHook(NewHook([]int{LogInfo}, func(e *Entry) error {
+		return errors.New("hook failed")
+	}))
+
+	logger.Infoln("Info")
+
+	if exp := "INFO | root | Info\n"; exp != buf.data {
+		t.Errorf("Message not printed: %v != %v", buf.data, exp)
+	}
+	if exp := "failed to fire log hook: hook failed\n"; exp != errBuf.data {
+		t.Errorf("Wrong hook error: %v != %v", errBuf.data, exp)
+	}
+}
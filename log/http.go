@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// responseRecorder - A ResponseWriter that records the status and the number of bytes written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush - Flushes the underlying ResponseWriter when it supports it.
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack - Hijacks the connection of the underlying ResponseWriter when it supports it.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap - Returns the underlying ResponseWriter, for use by http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+HTTPMiddleware - Returns middleware that writes an access log message for each request, with the
+fields method, path, status, latency and bytes, where responses with a status of 500 or more are
+printed as errors and others as info messages. When stats is not nil each request also increments
+"http.requests", "http.status.<code>" and "http.status.<class>xx", such as "http.status.404" and
+"http.status.4xx", and records its latency as the timing "http.latency".
+*/
+func HTTPMiddleware(logger *Logger, stats StatRecorder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			latency := time.Since(start)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if stats != nil {
+				code := strconv.Itoa(status)
+				stats.Incr("http.requests", 1)
+				stats.Incr("http.status."+code, 1)
+				stats.Incr("http.status."+code[:1]+"xx", 1)
+				stats.TimingDuration("http.latency", latency)
+			}
+
+			access := logger.WithFields(map[string]interface{}{
+				"method":  r.Method,
+				"path":    r.URL.Path,
+				"status":  status,
+				"latency": latency.String(),
+				"bytes":   rec.bytes,
+			})
+			if status >= http.StatusInternalServerError {
+				access.Errorf("%v %v %v\n", r.Method, r.URL.Path, status)
+			} else {
+				access.Infof("%v %v %v\n", r.Method, r.URL.Path, status)
+			}
+		})
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,77 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	buf := LogBuffer{data: ""}
+	stats := &statCounts{counts: map[string]int64{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+	handler := HTTPMiddleware(NewLogger(&buf, loggerConfig).(*Logger), stats)(mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/fail", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+
+	expected := regexp.MustCompile(`^` +
+		`INFO \| root \| GET /ok 200 bytes=5 latency=\S+ method=GET path=/ok status=200\n` +
+		`ERROR \| root \| POST /fail 500 bytes=7 latency=\S+ method=POST path=/fail status=500\n` +
+		`INFO \| root \| GET /missing 404 bytes=19 latency=\S+ method=GET path=/missing status=404\n$`)
+	if !expected.MatchString(buf.data) {
+		t.Errorf("Wrong access log: %v", buf.data)
+	}
+
+	expCounts := map[string]int64{
+		"http.requests":   3,
+		"http.status.200": 1,
+		"http.status.2xx": 1,
+		"http.status.500": 1,
+		"http.status.5xx": 1,
+		"http.status.404": 1,
+		"http.status.4xx": 1,
+	}
+	if !reflect.DeepEqual(expCounts, stats.counts) {
+		t.Errorf("Wrong stat counts: %v != %v", stats.counts, expCounts)
+	}
+	if stats.timings["http.latency"] != 3 {
+		t.Errorf("Wrong timings: %v", stats.timings)
+	}
+}
@@ -22,6 +22,8 @@ THE SOFTWARE.
 
 package log
 
+import "time"
+
 /*--------------------------------------------------------------------------------------------------
  */
 
@@ -48,3 +50,61 @@ type Modular interface {
 
 /*--------------------------------------------------------------------------------------------------
  */
+
+// LevelWriter - A log stream that is told the level of each line it is given, which is one of the
+// Log level constants such as LogWarn.
+type LevelWriter interface {
+	WriteLevel(level int, p []byte) (n int, err error)
+}
+
+// EntryWriter - A log stream that is given each message as an unformatted entry, which is used
+// instead of formatting the message when a stream implements it.
+type EntryWriter interface {
+	WriteEntry(e *Entry) error
+}
+
+/*--------------------------------------------------------------------------------------------------
+ */
+
+// Hook - Receives the entry of each printed message of the levels it returns, which are Log level
+// constants such as LogError. Hooks must not modify the entry.
+type Hook interface {
+	Levels() []int
+	Fire(e *Entry) error
+}
+
+/*--------------------------------------------------------------------------------------------------
+ */
+
+// StatCounter - Increments counters of stats, which is satisfied by the Type of the metrics package.
+type StatCounter interface {
+	Incr(path string, count int64) error
+}
+
+// StatRecorder - Increments counters and records timings of stats, which is satisfied by the Type of
+// the metrics package.
+type StatRecorder interface {
+	StatCounter
+	TimingDuration(path string, d time.Duration) error
+}
+
+/*--------------------------------------------------------------------------------------------------
+ */
+
+// Reopener - A stream that can reopen the file it writes to, such as after an external tool has
+// rotated it, which is satisfied by FileWriter and MultiWriter.
+type Reopener interface {
+	Reopen() error
+}
+
+/*--------------------------------------------------------------------------------------------------
+ */
+
+// RiemannClient - Sends events to Riemann without waiting on the network, where the Riemann type of
+// the metrics package is adapted to it by the riemannadapter package.
+type RiemannClient interface {
+	SendEvent(event *RiemannEvent) error
+}
+
+/*--------------------------------------------------------------------------------------------------
+ */
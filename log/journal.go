@@ -0,0 +1,193 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// ErrJournalUnavailable - Returned when the journald socket does not exist.
+var ErrJournalUnavailable = errors.New("journald socket was not found")
+
+//--------------------------------------------------------------------------------------------------
+
+// JournalConfig - Config for a journald target. The identifier defaults to the name of the program.
+type JournalConfig struct {
+	Socket     string `json:"socket" yaml:"socket"`
+	Identifier string `json:"identifier" yaml:"identifier"`
+}
+
+// NewJournalConfig - Returns a journald target configuration with the default values for each
+// field.
+func NewJournalConfig() JournalConfig {
+	return JournalConfig{
+		Socket:     "/run/systemd/journal/socket",
+		Identifier: "",
+	}
+}
+
+// JournalAvailable - Returns whether the journald socket of a config exists, and therefore whether
+// a journald writer can be used on this machine.
+func JournalAvailable(conf JournalConfig) bool {
+	_, err := os.Stat(conf.Socket)
+	return err == nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+JournalWriter - An EntryWriter that sends each log message to journald with the native journal
+protocol, which can be used as the stream of a logger. The level is sent as PRIORITY, the prefix of
+the logger as SERVICE and each field of the message as a journal field of the upper cased key, such
+that journalctl filters such as "journalctl PRIORITY=3 REQUEST_ID=abc" match. A captured caller is
+sent as CODE_FILE, CODE_LINE and CODE_FUNC, and a stack trace as STACK_TRACE.
+*/
+type JournalWriter struct {
+	sync.Mutex
+
+	identifier string
+	conn       *net.UnixConn
+}
+
+// NewJournalWriter - Creates a journald writer from a config, or returns ErrJournalUnavailable when
+// journald is not running.
+func NewJournalWriter(conf JournalConfig) (*JournalWriter, error) {
+	if !JournalAvailable(conf) {
+		return nil, ErrJournalUnavailable
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: conf.Socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	j := &JournalWriter{
+		identifier: conf.Identifier,
+		conn:       conn,
+	}
+	if j.identifier == "" {
+		j.identifier = filepath.Base(os.Args[0])
+	}
+	return j, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// journalFieldName - Returns a key as a journal field name, which may only contain upper case
+// letters, digits and underscores, and may not begin with an underscore or digit.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "F_" + name
+	}
+	return name
+}
+
+// writeJournalField - Writes a field in the journal export format, where values containing a
+// newline are written with their length in binary.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalMessage - Returns an entry as a journal message.
+func (j *JournalWriter) journalMessage(e *Entry) []byte {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", strings.TrimSuffix(e.Message, "\n"))
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(logLevelToInt(e.Level))))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", j.identifier)
+	if e.Prefix != "" {
+		writeJournalField(&buf, "SERVICE", e.Prefix)
+	}
+	if e.File != "" {
+		writeJournalField(&buf, "CODE_FILE", e.File)
+		writeJournalField(&buf, "CODE_LINE", strconv.Itoa(e.Line))
+		writeJournalField(&buf, "CODE_FUNC", e.Function)
+	}
+	if e.Stack != "" {
+		writeJournalField(&buf, "STACK_TRACE", e.Stack)
+	}
+	for _, k := range sortedFieldKeys(e.Fields) {
+		v := e.Fields[k]
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		writeJournalField(&buf, journalFieldName(k), fmt.Sprintf("%v", v))
+	}
+	return buf.Bytes()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WriteEntry - Sends a log message to journald.
+func (j *JournalWriter) WriteEntry(e *Entry) error {
+	msg := j.journalMessage(e)
+
+	j.Lock()
+	defer j.Unlock()
+
+	_, err := j.conn.Write(msg)
+	return err
+}
+
+// Write - Sends a log line to journald as a message of the info level.
+func (j *JournalWriter) Write(p []byte) (int, error) {
+	if err := j.WriteEntry(&Entry{Level: "INFO", Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close - Closes the connection to journald.
+func (j *JournalWriter) Close() error {
+	return j.conn.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,105 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"request_id": "REQUEST_ID",
+		"Bad-Key":    "BAD_KEY",
+		"_hidden":    "HIDDEN",
+		"1st":        "F_1ST",
+		"__":         "F_",
+	}
+	for key, exp := range tests {
+		if act := journalFieldName(key); exp != act {
+			t.Errorf("Wrong field name for %v: %v != %v", key, act, exp)
+		}
+	}
+}
+
+func TestJournalWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewJournalConfig()
+	conf.Socket = filepath.Join(dir, "journal.sock")
+	conf.Identifier = "app"
+
+	if JournalAvailable(conf) {
+		t.Error("Expected journal to be unavailable")
+	}
+	if _, err = NewJournalWriter(conf); err != ErrJournalUnavailable {
+		t.Errorf("Wrong error: %v != %v", err, ErrJournalUnavailable)
+	}
+
+	conn, err := net.ListenPacket("unixgram", conf.Socket)
+	if err != nil {
+		t.Skipf("Unix datagram sockets not supported: %v", err)
+	}
+	defer conn.Close()
+
+	j, err := NewJournalWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Prefix = "root"
+
+	logger := NewLogger(j, loggerConfig).(*Logger).WithFields(map[string]interface{}{
+		"request_id": "abc",
+	})
+	logger.Errorf("Two\nlines\n")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected bytes.Buffer
+	expected.WriteString("MESSAGE\n")
+	binary.Write(&expected, binary.LittleEndian, uint64(9))
+	expected.WriteString("Two\nlines\n")
+	expected.WriteString("PRIORITY=3\nSYSLOG_IDENTIFIER=app\nSERVICE=root\nREQUEST_ID=abc\n")
+
+	if exp, act := expected.String(), string(buf[:n]); exp != act {
+		t.Errorf("Wrong message: %q != %q", act, exp)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+levelRegistry - Tracks the levels of a tree of loggers created from the same root logger. Each
+module is resolved to the level of the longest override that matches it, or to the base level when
+none match, and the resolved levels are stored such that loggers read them with a single atomic
+load. Levels are resolved again whenever the base level or an override changes.
+*/
+type levelRegistry struct {
+	sync.Mutex
+	root      string
+	base      int
+	overrides map[string]int
+	modules   map[string]*int32
+}
+
+// newLevelRegistry - Creates a registry from a logger config, where overrides of an unrecognised
+// level are ignored.
+func newLevelRegistry(config LoggerConfig) *levelRegistry {
+	r := &levelRegistry{
+		root:      config.Prefix,
+		base:      logLevelToInt(config.LogLevel),
+		overrides: map[string]int{},
+		modules:   map[string]*int32{},
+	}
+	for module, level := range config.ModuleLevels {
+		if i := logLevelToInt(level); i >= 0 {
+			r.overrides[module] = i
+		}
+	}
+	return r
+}
+
+// moduleName - Returns the name of a module from the prefix of its logger, which is the prefix with
+// the root prefix and separating dot removed.
+func (r *levelRegistry) moduleName(prefix string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(prefix, r.root), ".")
+}
+
+// matchesModule - Returns whether an override key applies to a name, which is the case when the key
+// is the name itself or a dot separated parent of the name.
+func matchesModule(key, name string) bool {
+	return key == name || strings.HasPrefix(name, key+".")
+}
+
+// resolve - Returns the level of a logger prefix. Overrides match either the module name or the
+// full prefix. Must be called whilst holding the lock.
+func (r *levelRegistry) resolve(prefix string) int {
+	name := r.moduleName(prefix)
+	level, longest := r.base, -1
+	for key, l := range r.overrides {
+		if len(key) <= longest {
+			continue
+		}
+		if (name != "" && matchesModule(key, name)) || matchesModule(key, prefix) {
+			level, longest = l, len(key)
+		}
+	}
+	return level
+}
+
+// get - Returns the level of a logger prefix, which is shared by all loggers of the same prefix.
+func (r *levelRegistry) get(prefix string) *int32 {
+	r.Lock()
+	defer r.Unlock()
+
+	if level, ok := r.modules[prefix]; ok {
+		return level
+	}
+	level := int32(r.resolve(prefix))
+	r.modules[prefix] = &level
+	return &level
+}
+
+// refresh - Resolves the level of every known prefix again. Must be called whilst holding the lock.
+func (r *levelRegistry) refresh() {
+	for prefix, level := range r.modules {
+		atomic.StoreInt32(level, int32(r.resolve(prefix)))
+	}
+}
+
+// setBase - Sets the level of modules without a matching override.
+func (r *levelRegistry) setBase(level int) {
+	r.Lock()
+	r.base = level
+	r.refresh()
+	r.Unlock()
+}
+
+// setOverride - Sets the level of a module and its children.
+func (r *levelRegistry) setOverride(module string, level int) {
+	r.Lock()
+	r.overrides[module] = level
+	r.refresh()
+	r.Unlock()
+}
+
+// clearOverride - Removes the override of a module such that it returns to the level of its parent.
+func (r *levelRegistry) clearOverride(module string) {
+	r.Lock()
+	delete(r.overrides, module)
+	r.refresh()
+	r.Unlock()
+}
+
+// snapshot - Returns the overrides as human readable levels.
+func (r *levelRegistry) snapshot() map[string]string {
+	r.Lock()
+	defer r.Unlock()
+
+	levels := make(map[string]string, len(r.overrides))
+	for module, level := range r.overrides {
+		levels[module] = intToLogLevel(level)
+	}
+	return levels
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestModuleLevels(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "WARN"
+	loggerConfig.ModuleLevels = map[string]string{
+		"http":         "DEBUG",
+		"http.client":  "ERROR",
+		"root.storage": "OFF",
+		"bad":          "NOPE",
+	}
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	httpLog := logger.NewModule(".http")
+	server := httpLog.NewModule(".server")
+	client := httpLog.NewModule(".client")
+	storage := logger.NewModule(".storage")
+	httpx := logger.NewModule(".httpx")
+
+	logger.Infoln("Hidden")
+	server.Debugln("Server debug")
+	client.Warnln("Hidden")
+	client.Errorln("Client error")
+	storage.Fatalln("Hidden")
+	httpx.Infoln("Hidden")
+
+	expected := "DEBUG | root.http.server | Server debug\n" +
+		"ERROR | root.http.client | Client error\n"
+	if expected != buf.data {
+		t.Errorf("Module levels not applied: %v != %v", buf.data, expected)
+	}
+
+	exp := map[string]string{"http": "DEBUG", "http.client": "ERROR", "root.storage": "OFF"}
+	if act := logger.ModuleLevels(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong module levels: %v != %v", act, exp)
+	}
+}
+
+func TestModuleLevelsRuntime(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "WARN"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	storage := logger.NewModule(".storage")
+	child := storage.(*Logger).WithFields(map[string]interface{}{"a": 1}).NewModule(".disk")
+
+	if err := logger.SetModuleLevel("storage", "nope"); err != ErrInvalidLogLevel {
+		t.Errorf("Wrong error: %v != %v", err, ErrInvalidLogLevel)
+	}
+	if err := logger.SetModuleLevel("storage", "DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	storage.Debugln("First")
+	child.Debugln("Second")
+
+	logger.SetLevel("ERROR")
+	if exp, act := "DEBUG", storage.(*Logger).Level(); exp != act {
+		t.Errorf("Wrong level: %v != %v", act, exp)
+	}
+
+	logger.ClearModuleLevel("storage")
+	storage.Warnln("Hidden")
+	child.Errorln("Third")
+
+	expected := "DEBUG | root.storage | First\n" +
+		"DEBUG | root.storage.disk | Second a=1\n" +
+		"ERROR | root.storage.disk | Third a=1\n"
+	if expected != buf.data {
+		t.Errorf("Module levels not applied: %v != %v", buf.data, expected)
+	}
+}
+
+func TestModuleLevelHandler(t *testing.T) {
+	logger := NewLogger(&LogCounter{}, NewLoggerConfig()).(*Logger)
+	handler := logger.LevelHandler()
+
+	tests := []struct {
+		method string
+		url    string
+		code   int
+		body   string
+	}{
+		{"GET", "/level?module=http", http.StatusOK, "NONE\n"},
+		{"POST", "/level?module=http&level=debug", http.StatusOK, "DEBUG\n"},
+		{"GET", "/level?module=http", http.StatusOK, "DEBUG\n"},
+		{"GET", "/level", http.StatusOK, "INFO\n"},
+		{"DELETE", "/level?module=http", http.StatusOK, "NONE\n"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(test.method, test.url, nil))
+		if exp, act := test.code, w.Code; exp != act {
+			t.Errorf("Wrong code for %v %v: %v != %v", test.method, test.url, act, exp)
+		}
+		if exp, act := test.body, w.Body.String(); exp != act {
+			t.Errorf("Wrong body for %v %v: %v != %v", test.method, test.url, act, exp)
+		}
+	}
+}
@@ -23,10 +23,14 @@ THE SOFTWARE.
 package log
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -93,40 +97,193 @@ func logLevelToInt(level string) int {
 
 //--------------------------------------------------------------------------------------------------
 
-// LoggerConfig - Holds configuration options for a logger object.
+/*
+LoggerConfig - Holds configuration options for a logger object. The format of each line is one of
+"text", "json", "logfmt" or "template", where JSONFormat is the older way of selecting "json" and
+takes precedence. An unrecognised format falls back to "text". Text is written with the level
+coloured when Color is "always", or is "auto" and the stream is a terminal, and is never coloured
+otherwise.
+
+When AddTimeStamp is set each line starts with a timestamp in the layout of TimestampFormat, which
+is "rfc3339", "rfc3339nano", "epoch", "epoch_millis" or a custom layout of the time package such as
+"2006-01-02 15:04:05.000", and in the zone of TimestampZone, which is "local", "utc" or the name of
+a zone such as "Europe/London". OmitManagedTimestamp disables timestamps when the output is captured
+by journald or docker, which timestamp each line themselves.
+
+The template format writes lines with the layout of Template, which is either a Go text/template
+such as `{{.Level}} {{.Message}} {{.Fields}}`, or a pattern such as "%time [%level] %msg %fields"
+with the tokens %time, %level, %prefix (or %service), %msg, %fields, %caller and %func. An invalid
+template falls back to "text".
+
+ModuleLevels overrides the level of modules, keyed either by the module prefix without the root
+prefix (such as "http" for a module "service.http") or by the full prefix. An override also applies
+to the children of a module, where the longest matching key wins.
+
+When Async is set messages are queued in a buffer of AsyncBufferSize messages and are formatted and
+written by a background loop, where printing blocks only whilst the buffer is full. When
+AsyncDropLevel is set, messages of that level or more verbose are dropped rather than blocking
+whilst the buffer is full, such as "INFO" to drop info, debug and trace messages but keep warnings
+and errors, and the number dropped is given by Dropped or counted in stats with SetDropStats.
+
+Redaction masks sensitive field values and matches of patterns before messages are formatted or
+given to hooks, see RedactionConfig.
+
+Filters drop messages that are known to be noisy before they are written to the stream, or keep
+only the messages they match, see FilterConfig. Hooks are still given filtered messages, and the
+targets of a MultiWriter can be filtered separately.
+
+When RingBufferSize is greater than zero the most recent messages of RingBufferLevel or lower are
+kept in memory, even those of levels that are not printed, so that recent debug messages can be
+inspected with Tail or TailHandler during an incident whilst only info messages are persisted.
+
+When DedupeWindow is a duration, such as "10s", identical consecutive messages are collapsed in the
+style of syslog, where repeats within the window are held back and summarised by a single "last
+message repeated N times" message. An empty or invalid window disables this.
+
+CallerLevels lists the levels of messages that are printed with the file, line and function of the
+call site, which has a runtime cost and is therefore disabled by default. Likewise StackLevels lists
+the levels of messages that are printed with a stack trace from the call site, such as "ERROR" and
+"FATAL", so that post-mortem debugging does not rely on one being printed by hand.
+
+When ExitOnFatal is set fatal messages run the exit hooks of the logger and then exit the process
+with a status of one, otherwise fatal messages are printed and the caller continues.
+*/
 type LoggerConfig struct {
 	Prefix       string `json:"prefix" yaml:"prefix"`
 	LogLevel     string `json:"log_level" yaml:"log_level"`
 	AddTimeStamp bool   `json:"add_timestamp" yaml:"add_timestamp"`
-	JSONFormat   bool   `json:"json_format" yaml:"json_format"`
+
+	TimestampFormat      string `json:"timestamp_format" yaml:"timestamp_format"`
+	TimestampZone        string `json:"timestamp_zone" yaml:"timestamp_zone"`
+	OmitManagedTimestamp bool   `json:"omit_managed_timestamp" yaml:"omit_managed_timestamp"`
+
+	Format       string            `json:"format" yaml:"format"`
+	Template     string            `json:"template" yaml:"template"`
+	JSONFormat   bool              `json:"json_format" yaml:"json_format"`
+	Color        string            `json:"color" yaml:"color"`
+	ModuleLevels map[string]string `json:"module_levels" yaml:"module_levels"`
+
+	Async           bool   `json:"async" yaml:"async"`
+	AsyncBufferSize int    `json:"async_buffer_size" yaml:"async_buffer_size"`
+	AsyncDropLevel  string `json:"async_drop_level" yaml:"async_drop_level"`
+
+	Sampling     SamplingConfig  `json:"sampling" yaml:"sampling"`
+	Redaction    RedactionConfig `json:"redaction" yaml:"redaction"`
+	Filters      []FilterConfig  `json:"filters" yaml:"filters"`
+	DedupeWindow string          `json:"dedupe_window" yaml:"dedupe_window"`
+	CallerLevels []string        `json:"caller_levels" yaml:"caller_levels"`
+	StackLevels  []string        `json:"stack_trace_levels" yaml:"stack_trace_levels"`
+	ExitOnFatal  bool            `json:"exit_on_fatal" yaml:"exit_on_fatal"`
+
+	RingBufferSize  int    `json:"ring_buffer_size" yaml:"ring_buffer_size"`
+	RingBufferLevel string `json:"ring_buffer_level" yaml:"ring_buffer_level"`
 }
 
-// NewLoggerConfig - Returns a logger configuration with the default values for each field.
+/*
+NewLoggerConfig - Returns a logger configuration with the default values for each field. The default
+level and format are taken from the environment variables LOG_LEVEL and LOG_FORMAT when they are
+set to a recognised level, such as "debug", or format, such as "json", so that containers can be
+tuned without editing config files.
+*/
 func NewLoggerConfig() LoggerConfig {
-	return LoggerConfig{
+	conf := LoggerConfig{
 		Prefix:       "service",
 		LogLevel:     "INFO",
 		AddTimeStamp: true,
+
+		TimestampFormat:      "rfc3339",
+		TimestampZone:        "local",
+		OmitManagedTimestamp: false,
+
+		Format:       "text",
+		Template:     "",
 		JSONFormat:   false,
+		Color:        "never",
+		ModuleLevels: map[string]string{},
+
+		Async:           false,
+		AsyncBufferSize: 1000,
+		AsyncDropLevel:  "",
+
+		Sampling:     NewSamplingConfig(),
+		Redaction:    NewRedactionConfig(),
+		Filters:      []FilterConfig{},
+		DedupeWindow: "",
+		CallerLevels: []string{},
+		StackLevels:  []string{},
+		ExitOnFatal:  false,
+
+		RingBufferSize:  0,
+		RingBufferLevel: "DEBUG",
 	}
+	if level := strings.ToUpper(os.Getenv("LOG_LEVEL")); level != "" && logLevelToInt(level) >= 0 {
+		conf.LogLevel = level
+	}
+	if format := strings.ToLower(os.Getenv("LOG_FORMAT")); format != "" {
+		if _, ok := formatters[format]; ok {
+			conf.Format = format
+		}
+	}
+	return conf
 }
 
 //--------------------------------------------------------------------------------------------------
 
 // Logger - A logger object with support for levelled logging and modular components.
 type Logger struct {
-	stream io.Writer
-	config LoggerConfig
-	level  int
+	stream  io.Writer
+	config  LoggerConfig
+	levels  *levelRegistry
+	level   *int32
+	format  formatter
+	fields  map[string]interface{}
+	async   *asyncQueue
+	sampler *sampler
+	dedupe  *deduper
+	redact  *redactor
+	filters *filterSet
+	ring    *ringBuffer
+	hooks   *hookRegistry
+	exits   *exitHooks
+	context *atomic.Value
+	audit   *atomic.Value
+
+	location *time.Location
+
+	callerLevels uint32
+	stackLevels  uint32
+	callerSkip   int
 }
 
 // NewLogger - Create and return a new logger object.
 func NewLogger(stream io.Writer, config LoggerConfig) Modular {
+	if config.OmitManagedTimestamp && managedTimestamps() {
+		config.AddTimeStamp = false
+	}
+	levels := newLevelRegistry(config)
 	logger := Logger{
-		stream: stream,
-		config: config,
-		level:  logLevelToInt(config.LogLevel),
+		stream:  stream,
+		config:  config,
+		levels:  levels,
+		level:   levels.get(config.Prefix),
+		format:  formatterFor(config, stream),
+		hooks:   newHookRegistry(),
+		exits:   &exitHooks{},
+		context: newContextExtractor(),
+		audit:   newAuditHolder(),
+
+		location:     timestampLocation(config.TimestampZone),
+		callerLevels: callerLevelMask(config.CallerLevels),
+		stackLevels:  callerLevelMask(config.StackLevels),
 	}
+	if config.Async {
+		logger.async = newAsyncQueue(config.AsyncBufferSize, logLevelToInt(config.AsyncDropLevel))
+	}
+	logger.sampler = newSampler(config.Sampling)
+	logger.dedupe = newDeduper(config.DedupeWindow)
+	logger.redact = newRedactor(config.Redaction)
+	logger.filters = newFilterSet(config.Filters)
+	logger.ring = newRingBuffer(config.RingBufferSize, config.RingBufferLevel)
 	return &logger
 }
 
@@ -136,165 +293,444 @@ func (l *Logger) NewModule(prefix string) Modular {
 	config := l.config
 	config.Prefix = fmt.Sprintf("%v%v", config.Prefix, prefix)
 
-	return &Logger{
-		stream: l.stream,
-		config: config,
-		level:  l.level,
+	child := *l
+	child.config = config
+	child.level = l.levels.get(config.Prefix)
+	return &child
+}
+
+/*
+WithFields - Creates a new logger object from the previous that attaches a set of fields to every
+message it prints, along with any fields of the previous logger. Fields of the same key replace
+those of the previous logger, and the map is copied so it can be safely modified afterwards.
+*/
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	child := *l
+	child.fields = merged
+	return &child
+}
+
+/*
+WithError - Creates a new logger object from the previous that attaches an error to every message
+it prints as the field "error". Errors that carry fields, such as those of the errutil package, have
+their fields attached as well, and the class of an error is attached as the field "error_class".
+*/
+func (l *Logger) WithError(err error) *Logger {
+	if err == nil {
+		return l
+	}
+	fields := map[string]interface{}{}
+
+	var fielder interface {
+		Fields() map[string]interface{}
+	}
+	if errors.As(err, &fielder) {
+		for k, v := range fielder.Fields() {
+			fields[k] = v
+		}
+	}
+	var classer interface {
+		Class() string
 	}
+	if errors.As(err, &classer) {
+		if class := classer.Class(); class != "" {
+			fields["error_class"] = class
+		}
+	}
+	fields["error"] = err.Error()
+	return l.WithFields(fields)
 }
 
 //--------------------------------------------------------------------------------------------------
 
-// printf - Prints a log message with any configured extras prepended.
-func (l *Logger) printf(message, level string, other ...interface{}) {
-	if l.config.JSONFormat {
-		if l.config.AddTimeStamp {
-			fmt.Fprintf(l.stream, fmt.Sprintf(
-				"{\"timestamp\":\"%v\",\"level\":\"%v\",\"service\":\"%v\",\"message\":%v}\n",
-				time.Now().Format(time.RFC3339), level, l.config.Prefix,
-				strconv.QuoteToASCII(message),
-			), other...)
-		} else {
-			fmt.Fprintf(l.stream, fmt.Sprintf(
-				"{\"level\":\"%v\",\"service\":\"%v\",\"message\":%v}\n",
-				level, l.config.Prefix,
-				strconv.QuoteToASCII(message),
-			), other...)
+// enabled - Returns whether messages of a level are currently printed.
+func (l *Logger) enabled(level int) bool {
+	return level <= int(atomic.LoadInt32(l.level))
+}
+
+// Enabled - Returns whether messages of a level, such as "WARN", are currently printed. Callers box
+// the arguments of formatted messages before the level is checked, so hot paths that print values
+// other than constants can check Enabled first to avoid the allocation of a suppressed message.
+func (l *Logger) Enabled(level string) bool {
+	i := logLevelToInt(level)
+	return i > LogOff && l.enabled(i)
+}
+
+/*
+SetLevel - Changes the level of the logger at runtime, and is safe to call whilst messages are
+being printed. The level is shared with every module and child created from the same root logger,
+so raising the level of any of them raises it for all modules without a level override.
+*/
+func (l *Logger) SetLevel(level string) error {
+	i := logLevelToInt(level)
+	if i < 0 {
+		return ErrInvalidLogLevel
+	}
+	l.levels.setBase(i)
+	return nil
+}
+
+// Level - Returns the current level of the logger, including any override of its module.
+func (l *Logger) Level() string {
+	return intToLogLevel(int(atomic.LoadInt32(l.level)))
+}
+
+// SetModuleLevel - Overrides the level of a module and its children at runtime, where the module is
+// named as it would be within the ModuleLevels config field.
+func (l *Logger) SetModuleLevel(module, level string) error {
+	i := logLevelToInt(level)
+	if i < 0 {
+		return ErrInvalidLogLevel
+	}
+	l.levels.setOverride(module, i)
+	return nil
+}
+
+// ClearModuleLevel - Removes the level override of a module at runtime.
+func (l *Logger) ClearModuleLevel(module string) {
+	l.levels.clearOverride(module)
+}
+
+// ModuleLevels - Returns the current level overrides of each module.
+func (l *Logger) ModuleLevels() map[string]string {
+	return l.levels.snapshot()
+}
+
+/*
+LevelHandler - Returns an admin handler for reading and changing the level of the logger at runtime.
+A GET request responds with the current level, and a POST or PUT request sets the level from the
+"level" query parameter, or from the request body when the parameter is absent. When a "module"
+query parameter is given the level override of that module is read or set instead, and a DELETE
+request removes it.
+*/
+func (l *Logger) LevelHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		module := r.URL.Query().Get("module")
+		isModule := module != ""
+
+		switch r.Method {
+		case "GET":
+		case "DELETE":
+			if !isModule {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			l.ClearModuleLevel(module)
+		case "POST", "PUT":
+			level := r.URL.Query().Get("level")
+			if level == "" {
+				body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				level = strings.TrimSpace(string(body))
+			}
+			var err error
+			if isModule {
+				err = l.SetModuleLevel(module, level)
+			} else {
+				err = l.SetLevel(level)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
-	} else {
-		if l.config.AddTimeStamp {
-			fmt.Fprintf(l.stream, fmt.Sprintf(
-				"%v | %v | %v | %v",
-				time.Now().Format(time.RFC3339), level, l.config.Prefix, message,
-			), other...)
-		} else {
-			fmt.Fprintf(l.stream, fmt.Sprintf(
-				"%v | %v | %v", level, l.config.Prefix, message,
-			), other...)
+		level := l.Level()
+		if isModule {
+			if level = l.ModuleLevels()[module]; level == "" {
+				level = "NONE"
+			}
 		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, level+"\n")
 	}
 }
 
-// printLine - Prints a log message with any configured extras prepended.
+//--------------------------------------------------------------------------------------------------
+
+// sprintf - Formats a message, where messages without arguments or verbs are returned as they are
+// rather than being given to fmt.
+func sprintf(message string, other []interface{}) string {
+	if len(other) > 0 || strings.IndexByte(message, '%') >= 0 {
+		return fmt.Sprintf(message, other...)
+	}
+	return message
+}
+
+// printf - Prints a formatted log message with any configured extras prepended, unless it is dropped
+// by sampling.
+func (l *Logger) printf(message, level string, other ...interface{}) {
+	if l.sampled(level, message) {
+		l.print(level, sprintf(message, other), false)
+	}
+}
+
+// forcef - Prints a formatted log message regardless of the level, unless it is dropped by sampling.
+func (l *Logger) forcef(message, level string, other ...interface{}) {
+	if l.sampled(level, message) {
+		l.print(level, sprintf(message, other), true)
+	}
+}
+
+// printLine - Prints a log message with any configured extras prepended, unless it is dropped by
+// sampling.
 func (l *Logger) printLine(message, level string) {
-	if l.config.JSONFormat {
-		if l.config.AddTimeStamp {
-			fmt.Fprintf(l.stream,
-				"{\"timestamp\":\"%v\",\"level\":\"%v\",\"service\":\"%v\",\"message\":%v}\n",
-				time.Now().Format(time.RFC3339), level, l.config.Prefix,
-				strconv.QuoteToASCII(message),
-			)
-		} else {
-			fmt.Fprintf(l.stream,
-				"{\"level\":\"%v\",\"service\":\"%v\",\"message\":%v}\n",
-				level, l.config.Prefix,
-				strconv.QuoteToASCII(message),
-			)
+	if l.sampled(level, message) {
+		l.print(level, message+"\n", false)
+	}
+}
+
+// forceLine - Prints a log message regardless of the level, unless it is dropped by sampling.
+func (l *Logger) forceLine(message, level string) {
+	if l.sampled(level, message) {
+		l.print(level, message+"\n", true)
+	}
+}
+
+// print - Creates an entry of a message and dispatches it, where a forced entry is emitted even when
+// its level is disabled.
+func (l *Logger) print(level, message string, force bool) {
+	e := Entry{
+		Level:   level,
+		Prefix:  l.config.Prefix,
+		Message: message,
+		Fields:  l.fields,
+	}
+	l.stamp(&e)
+	l.captureCaller(&e, logLevelToInt(level))
+	l.dispatch(&e, force)
+}
+
+/*
+dispatch - Masks the sensitive values of an entry and records it in the ring buffer, then fires its
+hooks and emits it, unless it is held back as a duplicate of the previous entry. When a ring buffer
+is in use entries of disabled levels are only recorded in it, unless they are forced.
+*/
+func (l *Logger) dispatch(e *Entry, force bool) {
+	if l.redact != nil {
+		l.redact.redact(e)
+	}
+	if l.ring != nil {
+		l.ring.push(e)
+		if !force && !l.enabled(logLevelToInt(e.Level)) {
+			return
 		}
+	}
+	if l.dedupe != nil && !l.dedupe.check(l, e) {
+		return
+	}
+	l.hooks.fire(e)
+	l.emit(e)
+}
+
+/*
+PrintEntry - Prints an entry created outside of the logger, such as by an adapter of another logging
+library, when its level is enabled and it passes sampling. The entry takes the prefix of the logger
+when it has none, and has its time set according to the timestamp setting of the logger. Fields of
+the logger are added to those of the entry, and a caller or stack trace of the entry is only kept
+when enabled for its level.
+*/
+func (l *Logger) PrintEntry(e Entry) {
+	level := logLevelToInt(e.Level)
+	if level < 0 || !l.recorded(level) || !l.sampled(e.Level, e.Message) {
+		return
+	}
+	if e.Prefix == "" {
+		e.Prefix = l.config.Prefix
+	}
+	if !l.config.AddTimeStamp {
+		e.Time = time.Time{}
+	} else if e.Time.IsZero() {
+		l.stamp(&e)
 	} else {
-		if l.config.AddTimeStamp {
-			fmt.Fprintf(
-				l.stream, "%v | %v | %v | %v\n",
-				time.Now().Format(time.RFC3339), level, l.config.Prefix, message,
-			)
-		} else {
-			fmt.Fprintf(l.stream, "%v | %v | %v\n", level, l.config.Prefix, message)
+		e.Time = e.Time.In(l.location)
+		e.layout = l.config.TimestampFormat
+	}
+	if len(l.fields) > 0 {
+		fields := make(map[string]interface{}, len(l.fields)+len(e.Fields))
+		for k, v := range l.fields {
+			fields[k] = v
+		}
+		for k, v := range e.Fields {
+			fields[k] = v
 		}
+		e.Fields = fields
+	}
+	if l.callerLevels&(1<<uint(level)) == 0 {
+		e.File, e.Line, e.Function = "", 0, ""
+	}
+	if l.stackLevels&(1<<uint(level)) == 0 {
+		e.Stack = ""
+	}
+	l.dispatch(&e, false)
+}
+
+// emit - Writes an entry, or queues it to be written when the logger is asynchronous.
+func (l *Logger) emit(e *Entry) {
+	if l.async != nil {
+		l.async.push(logJob{logger: l, entry: *e}, logLevelToInt(e.Level))
+		return
 	}
+	l.write(e)
+}
+
+// write - Writes an entry to the stream of the logger.
+func (l *Logger) write(e *Entry) {
+	if !l.filters.allows(e) {
+		return
+	}
+	writeEntry(l.stream, l.format, e)
+}
+
+/*
+writeEntry - Formats an entry and writes it to a stream in a single write, which is given the level
+of the entry when the stream is a LevelWriter. When the stream is an EntryWriter it is given the
+entry itself instead.
+*/
+func writeEntry(stream io.Writer, format formatter, e *Entry) error {
+	if ew, ok := stream.(EntryWriter); ok {
+		return ew.WriteEntry(e)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	format(buf, e)
+	if lw, ok := stream.(LevelWriter); ok {
+		_, err := lw.WriteLevel(logLevelToInt(e.Level), buf.Bytes())
+		return err
+	}
+	_, err := stream.Write(buf.Bytes())
+	return err
 }
 
 //--------------------------------------------------------------------------------------------------
 
-// Fatalf - Print a fatal message to the console. Does NOT cause panic.
+/*
+Fatalf - Print a fatal message to the console. Does NOT cause panic. When the logger is asynchronous
+this blocks until all queued messages are written, or until the fatal flush timeout. When
+ExitOnFatal is configured the exit hooks are run and the process exits afterwards.
+*/
 func (l *Logger) Fatalf(message string, other ...interface{}) {
-	if LogFatal <= l.level {
+	if l.recorded(LogFatal) {
 		l.printf(message, "FATAL", other...)
+		l.Flush(fatalFlushTimeout)
+	}
+	if l.config.ExitOnFatal {
+		l.exit()
 	}
 }
 
 // Errorf - Print an error message to the console.
 func (l *Logger) Errorf(message string, other ...interface{}) {
-	if LogError <= l.level {
+	if l.recorded(LogError) {
 		l.printf(message, "ERROR", other...)
 	}
 }
 
 // Warnf - Print a warning message to the console.
 func (l *Logger) Warnf(message string, other ...interface{}) {
-	if LogWarn <= l.level {
+	if l.recorded(LogWarn) {
 		l.printf(message, "WARN", other...)
 	}
 }
 
 // Infof - Print an information message to the console.
 func (l *Logger) Infof(message string, other ...interface{}) {
-	if LogInfo <= l.level {
+	if l.recorded(LogInfo) {
 		l.printf(message, "INFO", other...)
 	}
 }
 
 // Debugf - Print a debug message to the console.
 func (l *Logger) Debugf(message string, other ...interface{}) {
-	if LogDebug <= l.level {
+	if l.recorded(LogDebug) {
 		l.printf(message, "DEBUG", other...)
 	}
 }
 
 // Tracef - Print a trace message to the console.
 func (l *Logger) Tracef(message string, other ...interface{}) {
-	if LogTrace <= l.level {
+	if l.recorded(LogTrace) {
 		l.printf(message, "TRACE", other...)
 	}
 }
 
 //--------------------------------------------------------------------------------------------------
 
-// Fatalln - Print a fatal message to the console. Does NOT cause panic.
+/*
+Fatalln - Print a fatal message to the console. Does NOT cause panic. When the logger is
+asynchronous this blocks until all queued messages are written, or until the fatal flush timeout.
+When ExitOnFatal is configured the exit hooks are run and the process exits afterwards.
+*/
 func (l *Logger) Fatalln(message string) {
-	if LogFatal <= l.level {
+	if l.recorded(LogFatal) {
 		l.printLine(message, "FATAL")
+		l.Flush(fatalFlushTimeout)
+	}
+	if l.config.ExitOnFatal {
+		l.exit()
 	}
 }
 
 // Errorln - Print an error message to the console.
 func (l *Logger) Errorln(message string) {
-	if LogError <= l.level {
+	if l.recorded(LogError) {
 		l.printLine(message, "ERROR")
 	}
 }
 
 // Warnln - Print a warning message to the console.
 func (l *Logger) Warnln(message string) {
-	if LogWarn <= l.level {
+	if l.recorded(LogWarn) {
 		l.printLine(message, "WARN")
 	}
 }
 
 // Infoln - Print an information message to the console.
 func (l *Logger) Infoln(message string) {
-	if LogInfo <= l.level {
+	if l.recorded(LogInfo) {
 		l.printLine(message, "INFO")
 	}
 }
 
 // Debugln - Print a debug message to the console.
 func (l *Logger) Debugln(message string) {
-	if LogDebug <= l.level {
+	if l.recorded(LogDebug) {
 		l.printLine(message, "DEBUG")
 	}
 }
 
 // Traceln - Print a trace message to the console.
 func (l *Logger) Traceln(message string) {
-	if LogTrace <= l.level {
+	if l.recorded(LogTrace) {
 		l.printLine(message, "TRACE")
 	}
 }
 
 //--------------------------------------------------------------------------------------------------
 
-// Output - Prints s to our output. Calldepth is ignored.
+// Output - Prints s to our output, masking matches of any redaction patterns. Calldepth is ignored.
 func (l *Logger) Output(calldepth int, s string) error {
+	if l.redact != nil {
+		s = l.redact.redactString(s)
+	}
+	if l.async != nil {
+		l.async.push(logJob{logger: l, raw: s}, LogInfo)
+		return nil
+	}
 	io.WriteString(l.stream, s)
 	return nil
 }
@@ -24,7 +24,14 @@ package log
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/jeffail/util/errutil"
 )
 
 type LogBuffer struct {
@@ -141,3 +148,250 @@ func TestLogLevels(t *testing.T) {
 		}
 	}
 }
+
+func TestWithFields(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	buf := LogBuffer{data: ""}
+
+	fields := map[string]interface{}{"request_id": "abc", "attempt": 1}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	child := logger.WithFields(fields)
+	fields["request_id"] = "changed"
+
+	grandChild := child.WithFields(map[string]interface{}{"attempt": 2, "component": "http server"})
+	module := grandChild.NewModule(".foo")
+
+	logger.Infoln("Root message")
+	child.Infof("Child %v\n", "message")
+	grandChild.Infof("No line ending")
+	module.Warnln("Module message")
+
+	expected := "INFO | root | Root message\n" +
+		"INFO | root | Child message attempt=1 request_id=abc\n" +
+		"INFO | root | No line ending attempt=2 component=\"http server\" request_id=abc" +
+		"WARN | root.foo | Module message attempt=2 component=\"http server\" request_id=abc\n"
+
+	if expected != buf.data {
+		t.Errorf("Fields logging does not match: %v != %v", buf.data, expected)
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "WARN"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	module := logger.NewModule(".foo")
+
+	module.Infoln("Hidden")
+	if err := logger.SetLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+	module.Infoln("Shown")
+
+	if err := logger.SetLevel("nope"); err != ErrInvalidLogLevel {
+		t.Errorf("Wrong error: %v != %v", err, ErrInvalidLogLevel)
+	}
+	if exp, act := "INFO", logger.Level(); exp != act {
+		t.Errorf("Wrong level: %v != %v", act, exp)
+	}
+
+	expected := "INFO | root.foo | Shown\n"
+	if expected != buf.data {
+		t.Errorf("Level change not applied: %v != %v", buf.data, expected)
+	}
+}
+
+func TestSetLevelConcurrent(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	logger := NewLogger(&LogCounter{}, loggerConfig).(*Logger)
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			logger.SetLevel([]string{"DEBUG", "ERROR"}[i%2])
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			logger.Debugln("Message")
+		}
+	}()
+	wg.Wait()
+}
+
+func TestLevelHandler(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	logger := NewLogger(&LogCounter{}, loggerConfig).(*Logger)
+	handler := logger.LevelHandler()
+
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		code   int
+		level  string
+	}{
+		{"GET", "/level", "", http.StatusOK, "INFO\n"},
+		{"POST", "/level?level=debug", "", http.StatusOK, "DEBUG\n"},
+		{"PUT", "/level", "trace\n", http.StatusOK, "TRACE\n"},
+		{"POST", "/level?level=nope", "", http.StatusBadRequest, ""},
+		{"DELETE", "/level", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(test.method, test.url, strings.NewReader(test.body)))
+		if exp, act := test.code, w.Code; exp != act {
+			t.Errorf("Wrong code for %v %v: %v != %v", test.method, test.url, act, exp)
+		}
+		if test.code == http.StatusOK && test.level != w.Body.String() {
+			t.Errorf("Wrong level for %v %v: %v != %v", test.method, test.url, w.Body.String(), test.level)
+		}
+	}
+	if exp, act := "TRACE", logger.Level(); exp != act {
+		t.Errorf("Wrong level: %v != %v", act, exp)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_FORMAT", "JSON")
+
+	conf := NewLoggerConfig()
+	if conf.LogLevel != "DEBUG" || conf.Format != "json" {
+		t.Errorf("Wrong config from env: %v %v", conf.LogLevel, conf.Format)
+	}
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("LOG_FORMAT", "xml")
+
+	conf = NewLoggerConfig()
+	if conf.LogLevel != "INFO" || conf.Format != "text" {
+		t.Errorf("Expected unrecognised env to be ignored: %v %v", conf.LogLevel, conf.Format)
+	}
+}
+
+func TestWithError(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	buf := LogBuffer{data: ""}
+	stats := &statCounts{counts: map[string]int64{}}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.AddHook(NewStatsHook(stats, ""))
+
+	err := errutil.Wrap(errutil.New("timed out").WithClass("timeout").With("host", "db1"), "query failed")
+	logger.WithError(err).Errorln("Request failed")
+	logger.WithError(fmt.Errorf("plain")).Warnln("Retrying")
+	logger.WithError(nil).Infoln("Done")
+
+	expected := "ERROR | root | Request failed error=\"query failed: timed out\" error_class=timeout host=db1\n" +
+		"WARN | root | Retrying error=plain\n" +
+		"INFO | root | Done\n"
+	if expected != buf.data {
+		t.Errorf("Error logging does not match: %v != %v", buf.data, expected)
+	}
+	if stats.counts["log.errors.timeout"] != 1 {
+		t.Errorf("Wrong stat counts: %v", stats.counts)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+func TestSuppressedAllocs(t *testing.T) {
+	logger := newBenchLogger("INFO")
+	module := logger.NewModule(".module")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debugln("Suppressed message")
+		logger.Tracef("Suppressed message %v of %v\n", "one", "test")
+		module.Debugf("Suppressed message\n")
+	})
+	if allocs != 0 {
+		t.Errorf("Suppressed messages allocated: %v", allocs)
+	}
+}
+
+func newBenchLogger(level string) *Logger {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Prefix = "bench"
+	loggerConfig.LogLevel = level
+	return NewLogger(ioutil.Discard, loggerConfig).(*Logger)
+}
+
+func BenchmarkSuppressedln(b *testing.B) {
+	logger := newBenchLogger("INFO")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugln("Suppressed message")
+	}
+}
+
+func BenchmarkSuppressedf(b *testing.B) {
+	logger := newBenchLogger("INFO")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("Suppressed message %v of %v\n", "one", "bench")
+	}
+}
+
+func BenchmarkSuppressedModule(b *testing.B) {
+	logger := newBenchLogger("INFO").NewModule(".module")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugln("Suppressed message")
+	}
+}
+
+func BenchmarkInfoln(b *testing.B) {
+	logger := newBenchLogger("INFO")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infoln("Simple message")
+	}
+}
+
+func BenchmarkInfof(b *testing.B) {
+	logger := newBenchLogger("INFO")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infof("Simple message\n")
+	}
+}
+
+func BenchmarkInfofArgs(b *testing.B) {
+	logger := newBenchLogger("INFO")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infof("Message %v of %v\n", i, "bench")
+	}
+}
+
+func BenchmarkInfoFields(b *testing.B) {
+	logger := newBenchLogger("INFO").WithFields(map[string]interface{}{"a": 1, "b": "two"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infoln("Message with fields")
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+/*
+Package logrusadapter - Adapters between logrus and the log package, which ease the incremental
+migration of services with mixed logging stacks. A Hook forwards the entries of a logrus logger
+into a Logger, and a Writer forwards the messages of a Logger into a logrus logger.
+*/
+package logrusadapter
+
+import (
+	"strings"
+
+	"github.com/jeffail/util/log"
+	"github.com/sirupsen/logrus"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// levelFromLogrus - Returns the log level of a logrus level, where panic is treated as fatal.
+func levelFromLogrus(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return "FATAL"
+	case logrus.ErrorLevel:
+		return "ERROR"
+	case logrus.WarnLevel:
+		return "WARN"
+	case logrus.InfoLevel:
+		return "INFO"
+	case logrus.DebugLevel:
+		return "DEBUG"
+	}
+	return "TRACE"
+}
+
+// levelToLogrus - Returns the logrus level of a log level, where fatal messages are logged at the
+// fatal level without exiting.
+func levelToLogrus(level string) logrus.Level {
+	switch level {
+	case "FATAL":
+		return logrus.FatalLevel
+	case "ERROR":
+		return logrus.ErrorLevel
+	case "WARN":
+		return logrus.WarnLevel
+	case "INFO":
+		return logrus.InfoLevel
+	case "DEBUG":
+		return logrus.DebugLevel
+	}
+	return logrus.TraceLevel
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Hook - A logrus.Hook that prints the entries of a logrus logger with a Logger, such that they share
+the levels, outputs, formats, sampling and hooks of the log package. The data of entries is printed
+as fields, and the caller of an entry is used when caller information is enabled for its level.
+The output of the logrus logger can be set to ioutil.Discard in order to avoid printing twice.
+*/
+type Hook struct {
+	logger *log.Logger
+}
+
+// NewHook - Creates a logrus.Hook that prints entries with a logger.
+func NewHook(l *log.Logger) *Hook {
+	return &Hook{logger: l}
+}
+
+// Levels - Returns every logrus level, as entries are filtered by the level of the logger.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire - Prints a logrus entry with the logger.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	entry := log.Entry{
+		Time:    e.Time,
+		Level:   levelFromLogrus(e.Level),
+		Message: strings.TrimSuffix(e.Message, "\n") + "\n",
+	}
+	if len(e.Data) > 0 {
+		entry.Fields = make(map[string]interface{}, len(e.Data))
+		for k, v := range e.Data {
+			entry.Fields[k] = v
+		}
+	}
+	if e.Caller != nil {
+		entry.File, entry.Line, entry.Function = e.Caller.File, e.Caller.Line, e.Caller.Function
+	}
+	h.logger.PrintEntry(entry)
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Writer - A log.EntryWriter that forwards log messages to a logrus logger, which can be used as the
+stream of a Logger in order to write into an existing logrus pipeline. The prefix of the logger is
+added as the field "service", and a stack trace is added as the field "stack".
+*/
+type Writer struct {
+	logger *logrus.Logger
+}
+
+// NewWriter - Creates a writer that forwards log messages to a logrus logger.
+func NewWriter(l *logrus.Logger) *Writer {
+	return &Writer{logger: l}
+}
+
+// WriteEntry - Forwards a log message to the logrus logger if it is enabled for its level.
+func (w *Writer) WriteEntry(e *log.Entry) error {
+	level := levelToLogrus(e.Level)
+	if !w.logger.IsLevelEnabled(level) {
+		return nil
+	}
+
+	fields := make(logrus.Fields, len(e.Fields)+2)
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+	if e.Prefix != "" {
+		fields["service"] = e.Prefix
+	}
+	if e.Stack != "" {
+		fields["stack"] = e.Stack
+	}
+
+	entry := w.logger.WithFields(fields)
+	if !e.Time.IsZero() {
+		entry = entry.WithTime(e.Time)
+	}
+	entry.Log(level, strings.TrimSuffix(e.Message, "\n"))
+	return nil
+}
+
+// Write - Forwards a raw log line to the logrus logger as a message of the info level.
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.WriteEntry(&log.Entry{Level: "INFO", Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package logrusadapter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jeffail/util/log"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHook(t *testing.T) {
+	loggerConfig := log.NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "WARN"
+
+	var buf bytes.Buffer
+
+	l := logrus.New()
+	l.Out = ioutil.Discard
+	l.SetLevel(logrus.TraceLevel)
+	l.AddHook(NewHook(log.NewLogger(&buf, loggerConfig).(*log.Logger)))
+
+	l.Info("Hidden")
+	l.WithField("a", 1).Warn("Warn message")
+	l.WithFields(logrus.Fields{"error": errors.New("broken"), "b": "two words"}).Log(logrus.ErrorLevel, "Error message")
+
+	expected := "WARN | root | Warn message a=1\n" +
+		"ERROR | root | Error message b=\"two words\" error=broken\n"
+	if act := buf.String(); expected != act {
+		t.Errorf("Hook output does not match: %v != %v", act, expected)
+	}
+}
+
+type captureHook struct {
+	entries []*logrus.Entry
+}
+
+func (c *captureHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (c *captureHook) Fire(e *logrus.Entry) error {
+	c.entries = append(c.entries, e)
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	capture := &captureHook{}
+
+	l := logrus.New()
+	l.Out = ioutil.Discard
+	l.SetLevel(logrus.WarnLevel)
+	l.AddHook(capture)
+
+	loggerConfig := log.NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	logger := log.NewLogger(NewWriter(l), loggerConfig).(*log.Logger).WithFields(map[string]interface{}{"a": 1})
+	logger.Infoln("Hidden")
+	logger.Errorf("Error message\n")
+	logger.Fatalln("Fatal message")
+
+	if len(capture.entries) != 2 {
+		t.Fatalf("Wrong count of entries: %v != 2", len(capture.entries))
+	}
+	e := capture.entries[0]
+	if e.Level != logrus.ErrorLevel || e.Message != "Error message" {
+		t.Errorf("Wrong entry: %v %v", e.Level, e.Message)
+	}
+	if e.Data["service"] != "root" || e.Data["a"] != 1 {
+		t.Errorf("Wrong entry data: %v", e.Data)
+	}
+	if e := capture.entries[1]; e.Level != logrus.FatalLevel || e.Message != "Fatal message" {
+		t.Errorf("Wrong entry: %v %v", e.Level, e.Message)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"io"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Target - A stream that a MultiWriter writes to, along with the level and format of the messages
+written to it. The level, format, template, color and filters fields are the same as those of
+LoggerConfig, where an empty level writes messages of all levels and an empty format is "text".
+Filters allow messages to be silenced for one target, or routed to a target by keeping only those
+they match.
+*/
+type Target struct {
+	Stream   io.Writer
+	Level    string
+	Format   string
+	Template string
+	Color    string
+	Filters  []FilterConfig
+}
+
+// multiTarget - A target along with its resolved level, formatter and filters.
+type multiTarget struct {
+	stream  io.Writer
+	level   int
+	format  formatter
+	filters *filterSet
+}
+
+/*
+MultiWriter - An EntryWriter that writes each log message to several targets, which can be used as
+the stream of a logger. Each target only receives messages of its level or lower, and formats them
+with its own format, or receives the entry itself when it is an EntryWriter. Since the level of the
+logger is applied first, it should be set to the most verbose level of any target.
+*/
+type MultiWriter struct {
+	targets []multiTarget
+}
+
+// NewMultiWriter - Creates a writer that writes to each of a list of targets.
+func NewMultiWriter(targets ...Target) *MultiWriter {
+	m := &MultiWriter{}
+	for _, t := range targets {
+		level := LogAll
+		if t.Level != "" {
+			level = logLevelToInt(t.Level)
+		}
+		format := t.Format
+		if format == "" {
+			format = "text"
+		}
+		m.targets = append(m.targets, multiTarget{
+			stream:  t.Stream,
+			level:   level,
+			format:  formatterFor(LoggerConfig{Format: format, Template: t.Template, Color: t.Color}, t.Stream),
+			filters: newFilterSet(t.Filters),
+		})
+	}
+	return m
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WriteEntry - Writes a log message to each target of its level that its filters allow, returning
+// the first error after every target has been written to.
+func (m *MultiWriter) WriteEntry(e *Entry) error {
+	level := logLevelToInt(e.Level)
+
+	var err error
+	for _, t := range m.targets {
+		if level > t.level || !t.filters.allows(e) {
+			continue
+		}
+		if werr := writeEntry(t.stream, t.format, e); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// Write - Writes a raw log line to every target, returning the first error after every target has
+// been written to.
+func (m *MultiWriter) Write(p []byte) (int, error) {
+	var err error
+	for _, t := range m.targets {
+		if _, werr := t.stream.Write(p); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sync - Syncs each target that supports it, such as files, returning the first error.
+func (m *MultiWriter) Sync() error {
+	var err error
+	for _, t := range m.targets {
+		if s, ok := t.stream.(syncer); ok {
+			if serr := s.Sync(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	}
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// entryBuffer - An EntryWriter that records the messages of entries.
+type entryBuffer struct {
+	messages []string
+}
+
+func (e *entryBuffer) WriteEntry(entry *Entry) error {
+	e.messages = append(e.messages, entry.Level+":"+entry.Message)
+	return nil
+}
+
+func (e *entryBuffer) Write(p []byte) (int, error) {
+	e.messages = append(e.messages, string(p))
+	return len(p), nil
+}
+
+// failWriter - A writer that always fails.
+type failWriter struct{}
+
+func (f failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestMultiWriter(t *testing.T) {
+	text := LogBuffer{data: ""}
+	json := LogBuffer{data: ""}
+	entries := &entryBuffer{}
+
+	m := NewMultiWriter(
+		Target{Stream: &text, Level: "DEBUG"},
+		Target{Stream: &json, Level: "WARN", Format: "json"},
+		Target{Stream: entries, Level: "ERROR"},
+	)
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "ALL"
+
+	logger := NewLogger(m, loggerConfig)
+	logger.Traceln("Trace")
+	logger.Debugln("Debug")
+	logger.Warnln("Warn")
+	logger.Errorln("Error")
+	logger.Output(0, "Raw\n")
+
+	expText := "DEBUG | root | Debug\nWARN | root | Warn\nERROR | root | Error\nRaw\n"
+	if expText != text.data {
+		t.Errorf("Wrong text target: %v != %v", text.data, expText)
+	}
+	expJSON := `{"level":"WARN","service":"root","message":"Warn"}` + "\n" +
+		`{"level":"ERROR","service":"root","message":"Error"}` + "\n" +
+		"Raw\n"
+	if expJSON != json.data {
+		t.Errorf("Wrong json target: %v != %v", json.data, expJSON)
+	}
+	if exp := []string{"ERROR:Error\n", "Raw\n"}; !reflect.DeepEqual(exp, entries.messages) {
+		t.Errorf("Wrong entry target: %v != %v", entries.messages, exp)
+	}
+}
+
+func TestMultiWriterErrors(t *testing.T) {
+	buf := LogBuffer{data: ""}
+	m := NewMultiWriter(Target{Stream: failWriter{}}, Target{Stream: &buf})
+
+	if err := m.WriteEntry(&Entry{Level: "INFO", Message: "msg\n"}); err == nil {
+		t.Error("Expected error")
+	}
+	if _, err := m.Write([]byte("raw\n")); err == nil {
+		t.Error("Expected error")
+	}
+	if exp := "INFO |  | msg\nraw\n"; exp != buf.data {
+		t.Errorf("Later targets not written: %v != %v", buf.data, exp)
+	}
+	if err := m.Sync(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the network target.
+var (
+	ErrNetworkFraming = errors.New("network framing was not recognised")
+	ErrNetworkClosed  = errors.New("network writer was closed")
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+NetworkConfig - Config for a network target that streams log lines to a collector over "tcp" or
+"udp". With the "newline" framing each line is terminated by a newline, and with the
+"length_prefix" framing each line is preceded by its length as a four byte big endian integer.
+Lines are buffered whilst the collector is unreachable, where up to BufferSize lines are kept and
+lines written once the buffer is full are dropped and counted.
+*/
+type NetworkConfig struct {
+	Network    string `json:"network" yaml:"network"`
+	Address    string `json:"address" yaml:"address"`
+	Framing    string `json:"framing" yaml:"framing"`
+	BufferSize int    `json:"buffer_size" yaml:"buffer_size"`
+	Backoff    string `json:"reconnect_backoff" yaml:"reconnect_backoff"`
+	Timeout    string `json:"timeout" yaml:"timeout"`
+}
+
+// NewNetworkConfig - Returns a network target configuration with the default values for each field.
+func NewNetworkConfig() NetworkConfig {
+	return NetworkConfig{
+		Network:    "tcp",
+		Address:    "localhost:5170",
+		Framing:    "newline",
+		BufferSize: 1000,
+		Backoff:    "1s",
+		Timeout:    "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+NetworkWriter - An io.WriteCloser that streams log lines to a remote collector, which can be used
+as the stream of a logger. Writes never block on the network, instead lines are queued and sent by
+a background loop that reconnects with a backoff whenever the connection fails.
+*/
+type NetworkWriter struct {
+	network string
+	address string
+	prefix  bool
+	backoff time.Duration
+	timeout time.Duration
+
+	lines   chan []byte
+	dropped int64
+	conn    net.Conn
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+	closed    chan struct{}
+}
+
+// NewNetworkWriter - Creates a network writer from a config and starts its background loop. The
+// collector is dialled lazily so that it need not be reachable when the writer is created.
+func NewNetworkWriter(conf NetworkConfig) (*NetworkWriter, error) {
+	n := &NetworkWriter{
+		network:   conf.Network,
+		address:   conf.Address,
+		closeChan: make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	switch conf.Framing {
+	case "newline", "":
+	case "length_prefix":
+		n.prefix = true
+	default:
+		return nil, ErrNetworkFraming
+	}
+
+	var err error
+	if n.backoff, err = time.ParseDuration(conf.Backoff); err != nil {
+		return nil, fmt.Errorf("failed to parse reconnect_backoff: %v", err)
+	}
+	if conf.Timeout != "" {
+		if n.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout: %v", err)
+		}
+	}
+
+	bufferSize := conf.BufferSize
+	if bufferSize < 0 {
+		bufferSize = 0
+	}
+	n.lines = make(chan []byte, bufferSize)
+
+	go n.loop()
+	return n, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// frame - Returns a copy of a log line framed for sending.
+func (n *NetworkWriter) frame(p []byte) []byte {
+	if n.prefix {
+		if l := len(p); l > 0 && p[l-1] == '\n' {
+			p = p[:l-1]
+		}
+		b := make([]byte, 4, 4+len(p))
+		binary.BigEndian.PutUint32(b, uint32(len(p)))
+		return append(b, p...)
+	}
+	b := make([]byte, len(p), len(p)+1)
+	copy(b, p)
+	if len(b) == 0 || b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// send - Writes a frame, dialling the collector whenever there is no connection. Unless retry is set
+// the frame is dropped after a single failed attempt, otherwise attempts are repeated after the
+// backoff until the writer is closed. Returns whether the frame was sent.
+func (n *NetworkWriter) send(b []byte, retry bool) bool {
+	for {
+		var err error
+		if n.conn == nil {
+			n.conn, err = net.DialTimeout(n.network, n.address, n.timeout)
+		}
+		if err == nil {
+			if n.timeout > 0 {
+				n.conn.SetWriteDeadline(time.Now().Add(n.timeout))
+			}
+			if _, err = n.conn.Write(b); err == nil {
+				return true
+			}
+			n.conn.Close()
+			n.conn = nil
+		}
+		if !retry {
+			return false
+		}
+		select {
+		case <-time.After(n.backoff):
+		case <-n.closeChan:
+			return false
+		}
+	}
+}
+
+// loop - Sends queued frames until the writer is closed, after which any frames left in the queue
+// are given a single attempt.
+func (n *NetworkWriter) loop() {
+	defer func() {
+		if n.conn != nil {
+			n.conn.Close()
+		}
+		close(n.closed)
+	}()
+
+	for {
+		select {
+		case b := <-n.lines:
+			if !n.send(b, true) {
+				atomic.AddInt64(&n.dropped, 1)
+			}
+		case <-n.closeChan:
+			for {
+				select {
+				case b := <-n.lines:
+					if !n.send(b, false) {
+						atomic.AddInt64(&n.dropped, 1)
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Write - Queues a log line to be sent, dropping it if the buffer is full.
+func (n *NetworkWriter) Write(p []byte) (int, error) {
+	select {
+	case <-n.closeChan:
+		return 0, ErrNetworkClosed
+	default:
+	}
+	select {
+	case n.lines <- n.frame(p):
+	default:
+		atomic.AddInt64(&n.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped - Returns the number of log lines dropped because the buffer was full or because they
+// could not be sent before the writer was closed.
+func (n *NetworkWriter) Dropped() int64 {
+	return atomic.LoadInt64(&n.dropped)
+}
+
+// Close - Stops the background loop after attempting to send any buffered lines, and closes the
+// connection.
+func (n *NetworkWriter) Close() error {
+	n.closeOnce.Do(func() {
+		close(n.closeChan)
+	})
+	<-n.closed
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
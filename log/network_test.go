@@ -0,0 +1,163 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNetworkNewline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conf := NewNetworkConfig()
+	conf.Address = ln.Addr().String()
+
+	n, err := NewNetworkWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	logger := NewLogger(n, loggerConfig)
+	logger.Infoln("First")
+	logger.Warnf("Second")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	r := bufio.NewReader(conn)
+	for _, exp := range []string{"INFO | root | First\n", "WARN | root | Second\n"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp != line {
+			t.Errorf("Wrong line: %v != %v", line, exp)
+		}
+	}
+
+	n.Close()
+	if _, err = n.Write([]byte("closed")); err != ErrNetworkClosed {
+		t.Errorf("Wrong error: %v != %v", err, ErrNetworkClosed)
+	}
+}
+
+func TestNetworkLengthPrefixReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	conf := NewNetworkConfig()
+	conf.Address = addr
+	conf.Framing = "length_prefix"
+	conf.Backoff = "10ms"
+
+	n, err := NewNetworkWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	// Written whilst the collector is down.
+	n.Write([]byte("buffered\n"))
+
+	time.Sleep(30 * time.Millisecond)
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("Unable to listen on the same address: %v", err)
+	}
+	defer ln.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	var length uint32
+	if err = binary.Read(conn, binary.BigEndian, &length); err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, length)
+	if _, err = io.ReadFull(conn, msg); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "buffered", string(msg); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+}
+
+func TestNetworkDropped(t *testing.T) {
+	conf := NewNetworkConfig()
+	conf.Address = "127.0.0.1:1"
+	conf.BufferSize = 1
+	conf.Backoff = "1h"
+
+	n, err := NewNetworkWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		n.Write([]byte("line"))
+	}
+	n.Close()
+
+	// Lines beyond the buffer are dropped immediately, and the rest fail to send on close.
+	if dropped := n.Dropped(); dropped != 5 {
+		t.Errorf("Wrong dropped count: %v != %v", dropped, 5)
+	}
+}
+
+func TestNetworkBadConfig(t *testing.T) {
+	conf := NewNetworkConfig()
+	conf.Framing = "nope"
+	if _, err := NewNetworkWriter(conf); err != ErrNetworkFraming {
+		t.Errorf("Wrong error: %v != %v", err, ErrNetworkFraming)
+	}
+
+	conf = NewNetworkConfig()
+	conf.Backoff = "nope"
+	if _, err := NewNetworkWriter(conf); err == nil {
+		t.Error("Expected error from bad backoff")
+	}
+}
@@ -30,5 +30,6 @@ import "errors"
 
 // Errors used throughout the package.
 var (
-	ErrClientNil = errors.New("the client pointer was nil")
+	ErrClientNil       = errors.New("the client pointer was nil")
+	ErrInvalidLogLevel = errors.New("log level was not recognised")
 )
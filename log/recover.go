@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// panicStack - Returns the stack trace of a panic whilst it is being recovered, starting at the
+// function that panicked rather than at the deferred function recovering it.
+func panicStack() string {
+	stack := stackOf(0)
+	i := strings.Index(stack, "runtime.gopanic\n")
+	if i < 0 {
+		return stack
+	}
+	lines := strings.SplitAfter(stack[i:], "\n")
+
+	// Each frame is a line of the function followed by a line of its location, where the frames of
+	// the runtime that raised the panic are dropped.
+	j := 0
+	for j+1 < len(lines) && strings.HasPrefix(lines[j], "runtime.") {
+		j += 2
+	}
+	return strings.Join(lines[j:], "")
+}
+
+// printPanic - Prints a recovered panic as an error message along with its stack trace, regardless
+// of the stack trace levels of the logger.
+func (l *Logger) printPanic(v interface{}, stack string) {
+	if !l.recorded(LogError) {
+		return
+	}
+	e := Entry{
+		Level:   "ERROR",
+		Prefix:  l.config.Prefix,
+		Message: fmt.Sprintf("recovered from panic: %v\n", v),
+		Fields:  l.fields,
+		Stack:   stack,
+	}
+	l.stamp(&e)
+	l.dispatch(&e, false)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+PanicHandler - Standardises the handling of panics, intended for use with defer at the top of
+goroutines. A recovered panic is printed with Logger as an error message along with its stack trace,
+the stat StatPath of Stats is incremented, where an empty path is "panics", and when Repanic is set
+the logger is flushed and the panic is raised again. Any of Logger and Stats may be nil.
+*/
+type PanicHandler struct {
+	Logger   *Logger
+	Stats    StatCounter
+	StatPath string
+	Repanic  bool
+}
+
+// Recover - Handles a panic of the goroutine when called with defer, and does nothing otherwise.
+func (h PanicHandler) Recover() {
+	if v := recover(); v != nil {
+		h.handle(v, panicStack())
+	}
+}
+
+// handle - Prints and counts a recovered panic, and raises it again when configured to.
+func (h PanicHandler) handle(v interface{}, stack string) {
+	if h.Logger != nil {
+		h.Logger.printPanic(v, stack)
+	}
+	if h.Stats != nil {
+		path := h.StatPath
+		if path == "" {
+			path = "panics"
+		}
+		h.Stats.Incr(path, 1)
+	}
+	if h.Repanic {
+		if h.Logger != nil {
+			h.Logger.Flush(fatalFlushTimeout)
+		}
+		panic(v)
+	}
+}
+
+// Recover - Prints a panic of the goroutine along with its stack trace as an error message when
+// called with defer, such as `defer log.Recover(logger)`, after which the goroutine returns.
+func Recover(l *Logger) {
+	if v := recover(); v != nil {
+		PanicHandler{Logger: l}.handle(v, panicStack())
+	}
+}
+
+// RecoverWith - Calls a function with a panic of the goroutine and its stack trace when called with
+// defer, such as `defer log.RecoverWith(fn)`, after which the goroutine returns.
+func RecoverWith(fn func(v interface{}, stack string)) {
+	if v := recover(); v != nil {
+		fn(v, panicStack())
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,85 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func panics(h PanicHandler) {
+	defer h.Recover()
+	panic("boom")
+}
+
+func TestRecover(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	buf := LogBuffer{data: ""}
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+
+	func() {
+		defer Recover(logger)
+		var m map[string]int
+		m["a"] = 1
+	}()
+
+	prefix := "ERROR | root | recovered from panic: assignment to entry in nil map\n\tlog.TestRecover.func1\n"
+	if !strings.HasPrefix(strings.Replace(buf.data, "github.com/jeffail/util/", "", -1), prefix) {
+		t.Errorf("Wrong panic output: %v", buf.data)
+	}
+
+	var stack string
+	func() {
+		defer RecoverWith(func(v interface{}, s string) {
+			stack = s
+		})
+		panic("boom")
+	}()
+	if !strings.Contains(stack, "log.TestRecover.func2") || strings.HasPrefix(stack, "runtime.") {
+		t.Errorf("Wrong panic stack: %v", stack)
+	}
+}
+
+func TestPanicHandler(t *testing.T) {
+	stats := &statCounts{counts: map[string]int64{}}
+
+	panics(PanicHandler{Stats: stats})
+	if stats.counts["panics"] != 1 {
+		t.Errorf("Wrong stat counts: %v", stats.counts)
+	}
+
+	var repanicked interface{}
+	func() {
+		defer func() { repanicked = recover() }()
+		panics(PanicHandler{Stats: stats, StatPath: "worker.panics", Repanic: true})
+	}()
+	if repanicked != "boom" {
+		t.Errorf("Expected panic to be raised again: %v", repanicked)
+	}
+	if stats.counts["worker.panics"] != 1 {
+		t.Errorf("Wrong stat counts: %v", stats.counts)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"regexp"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+RedactionConfig - Config for masking sensitive values before messages are formatted or given to
+hooks, so that passwords, tokens and personal information never reach disk or remote collectors.
+The value of a field is replaced by Mask when its name matches one of Fields, ignoring case, and
+each match of one of Patterns within the message or a string field is replaced by Mask. A pattern
+that is not a valid regular expression is matched as literal text.
+*/
+type RedactionConfig struct {
+	Fields   []string `json:"fields" yaml:"fields"`
+	Patterns []string `json:"patterns" yaml:"patterns"`
+	Mask     string   `json:"mask" yaml:"mask"`
+}
+
+// NewRedactionConfig - Returns a redaction configuration with the default values for each field.
+func NewRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Fields:   []string{},
+		Patterns: []string{},
+		Mask:     "[REDACTED]",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// redactor - Masks the sensitive values of entries according to redaction rules.
+type redactor struct {
+	fields   map[string]struct{}
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+// newRedactor - Creates a redactor from a config, or returns nil when there are no rules.
+func newRedactor(config RedactionConfig) *redactor {
+	if len(config.Fields) == 0 && len(config.Patterns) == 0 {
+		return nil
+	}
+	r := &redactor{
+		fields: make(map[string]struct{}, len(config.Fields)),
+		mask:   config.Mask,
+	}
+	for _, name := range config.Fields {
+		r.fields[strings.ToLower(name)] = struct{}{}
+	}
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			re = regexp.MustCompile(regexp.QuoteMeta(pattern))
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r
+}
+
+// redactString - Replaces each match of the patterns within a string by the mask.
+func (r *redactor) redactString(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, r.mask)
+	}
+	return s
+}
+
+// redactField - Returns the masked value of a field and whether it differs from the value.
+func (r *redactor) redactField(key string, value interface{}) (interface{}, bool) {
+	if _, ok := r.fields[strings.ToLower(key)]; ok {
+		return r.mask, true
+	}
+	if len(r.patterns) == 0 {
+		return value, false
+	}
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		return value, false
+	}
+	if masked := r.redactString(s); masked != s {
+		return masked, true
+	}
+	return value, false
+}
+
+// redact - Masks the message and fields of an entry. The fields are copied when any are masked, as
+// they may be shared with the logger.
+func (r *redactor) redact(e *Entry) {
+	e.Message = r.redactString(e.Message)
+	if len(e.Fields) == 0 {
+		return
+	}
+
+	var fields map[string]interface{}
+	for k, v := range e.Fields {
+		masked, changed := r.redactField(k, v)
+		if !changed {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{}, len(e.Fields))
+			for k2, v2 := range e.Fields {
+				fields[k2] = v2
+			}
+		}
+		fields[k] = masked
+	}
+	if fields != nil {
+		e.Fields = fields
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
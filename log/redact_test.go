@@ -0,0 +1,57 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Redaction.Fields = []string{"Password"}
+	loggerConfig.Redaction.Patterns = []string{`token=\w+`, `[invalid`}
+
+	buf := LogBuffer{data: ""}
+
+	fields := map[string]interface{}{"password": "hunter2", "user": "bob", "ids": []int{1}}
+	logger := NewLogger(&buf, loggerConfig).(*Logger).WithFields(fields)
+
+	logger.Infof("Login with token=%v\n", "abc123")
+	logger.WithFields(map[string]interface{}{
+		"err": errors.New("bad token=xyz"),
+	}).Warnln("Failed [invalid request")
+	logger.Output(0, "Raw token=def\n")
+
+	expected := "INFO | root | Login with [REDACTED] ids=[1] password=[REDACTED] user=bob\n" +
+		"WARN | root | Failed [REDACTED] request err=\"bad [REDACTED]\" ids=[1] password=[REDACTED] user=bob\n" +
+		"Raw [REDACTED]\n"
+	if expected != buf.data {
+		t.Errorf("Redacted output does not match: %v != %v", buf.data, expected)
+	}
+	if fields["password"] != "hunter2" {
+		t.Error("Expected fields of the logger to be left unmodified")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Reopen - Closes the file and opens it again at its path without rotating it, which allows external
+tools such as logrotate to move the file aside and have a new one created in its place. Writes made
+whilst the file is being reopened wait for it.
+*/
+func (f *FileWriter) Reopen() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Reopen - Reopens each target that is a Reopener, returning the first error after every target has
+// been reopened.
+func (m *MultiWriter) Reopen() error {
+	var err error
+	for _, t := range m.targets {
+		if r, ok := t.stream.(Reopener); ok {
+			if rerr := r.Reopen(); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+	}
+	return err
+}
+
+// Reopen - Reopens the stream of the logger when it is a Reopener, such as a FileWriter, and does
+// nothing otherwise.
+func (l *Logger) Reopen() error {
+	if r, ok := l.stream.(Reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+ReopenOnSignal - Reopens each of a list of targets, such as a Logger or FileWriter, every time the
+process receives SIGHUP, which is the signal sent by logrotate configs that use postrotate. Errors
+are reported to stderr, as the logger may be unable to write. The returned function stops listening
+for the signal.
+*/
+func ReopenOnSignal(targets ...Reopener) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				for _, t := range targets {
+					if err := t.Reopen(); err != nil {
+						fmt.Fprintf(os.Stderr, "failed to reopen log output: %v\n", err)
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,115 @@
+//go:build !windows
+// +build !windows
+
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileWriterReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewFileConfig()
+	conf.Path = filepath.Join(dir, "test.log")
+
+	f, err := NewFileWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	logger := NewLogger(NewMultiWriter(Target{Stream: f}), loggerConfig).(*Logger)
+
+	logger.Infoln("Before rotation")
+	if err = os.Rename(conf.Path, conf.Path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Infoln("Before reopen")
+	if err = logger.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Infoln("After reopen")
+
+	if exp, act := "INFO | root | Before rotation\nINFO | root | Before reopen\n", readFile(t, conf.Path+".old"); exp != act {
+		t.Errorf("Wrong rotated contents: %v != %v", act, exp)
+	}
+	if exp, act := "INFO | root | After reopen\n", readFile(t, conf.Path); exp != act {
+		t.Errorf("Wrong file contents: %v != %v", act, exp)
+	}
+}
+
+func TestReopenOnSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewFileConfig()
+	conf.Path = filepath.Join(dir, "test.log")
+
+	f, err := NewFileWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stop := ReopenOnSignal(f)
+	defer stop()
+
+	if err = os.Rename(conf.Path, conf.Path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err = syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err = os.Stat(conf.Path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("File was not reopened after SIGHUP")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stop()
+	stop()
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// RiemannEvent - The fields of a Riemann event that are set from a log message, where the host, TTL
+// and tags are left to the client.
+type RiemannEvent struct {
+	Time        int64
+	State       string
+	Service     string
+	Description string
+	Attributes  map[string]string
+}
+
+// riemannStates - The state of the Riemann event of each level.
+var riemannStates = map[string]string{
+	"FATAL": "critical",
+	"ERROR": "critical",
+	"WARN":  "warning",
+}
+
+/*
+NewRiemannHook - Creates a Hook that sends a Riemann event for every printed warning, error and
+fatal message, so that critical log lines trigger alerting directly. The service of each event is
+the prefix of the logger, the description is the message and the state is "warning" for warnings
+and "critical" otherwise. Fields are sent as attributes, in place of the attributes configured for
+the client, along with the attribute "level". Register it with AddHook for the messages of every
+module of a logger, where the client is usually the Riemann type shared with stats as adapted by
+riemannadapter.NewClient.
+*/
+func NewRiemannHook(client RiemannClient) Hook {
+	return NewHook([]int{LogFatal, LogError, LogWarn}, func(e *Entry) error {
+		t := e.Time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		attributes := make(map[string]string, len(e.Fields)+1)
+		for k, v := range e.Fields {
+			attributes[k] = fmt.Sprintf("%v", v)
+		}
+		attributes["level"] = e.Level
+
+		return client.SendEvent(&RiemannEvent{
+			Time:        t.Unix(),
+			State:       riemannStates[e.Level],
+			Service:     e.Prefix,
+			Description: strings.TrimSuffix(e.Message, "\n"),
+			Attributes:  attributes,
+		})
+	})
+}
+
+//--------------------------------------------------------------------------------------------------
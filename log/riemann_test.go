@@ -0,0 +1,72 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"sync"
+	"testing"
+)
+
+type riemannEvents struct {
+	sync.Mutex
+	events []*RiemannEvent
+}
+
+func (r *riemannEvents) SendEvent(event *RiemannEvent) error {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestRiemannHook(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "INFO"
+
+	buf := LogBuffer{data: ""}
+	client := &riemannEvents{}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.AddHook(NewRiemannHook(client))
+
+	logger.Infoln("Info message")
+	logger.Warnln("Warn message")
+	logger.WithFields(map[string]interface{}{"a": 1}).Errorf("Error message\n")
+
+	if len(client.events) != 2 {
+		t.Fatalf("Wrong count of events: %v != 2", len(client.events))
+	}
+	e := client.events[0]
+	if e.Service != "root" || e.State != "warning" || e.Description != "Warn message" {
+		t.Errorf("Wrong event: %+v", e)
+	}
+	if e.Time == 0 || e.Attributes["level"] != "WARN" {
+		t.Errorf("Wrong event: %+v", e)
+	}
+	e = client.events[1]
+	if e.State != "critical" || e.Description != "Error message" || e.Attributes["a"] != "1" {
+		t.Errorf("Wrong event: %+v", e)
+	}
+}
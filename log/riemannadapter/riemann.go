@@ -0,0 +1,65 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+/*
+Package riemannadapter - Adapts the Riemann type of the metrics package to the RiemannClient of the
+log package, so that log messages can be sent as events with the client shared with stats without
+the log package depending on the metrics package.
+*/
+package riemannadapter
+
+import (
+	"github.com/jeffail/util/log"
+	"github.com/jeffail/util/metrics"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Sender - Sends Riemann events without waiting on the network, which is satisfied by the Riemann
+// type of the metrics package.
+type Sender interface {
+	SendEvent(event *metrics.RiemannEvent) error
+}
+
+// client - A log.RiemannClient that converts events for a Sender.
+type client struct {
+	sender Sender
+}
+
+// NewClient - Creates a log.RiemannClient that sends events with a Sender, such as the Riemann type
+// of the metrics package, where the host, TTL and tags of the config of the sender are applied.
+func NewClient(sender Sender) log.RiemannClient {
+	return client{sender: sender}
+}
+
+// SendEvent - Converts an event and sends it.
+func (c client) SendEvent(event *log.RiemannEvent) error {
+	return c.sender.SendEvent(&metrics.RiemannEvent{
+		Time:        event.Time,
+		State:       event.State,
+		Service:     event.Service,
+		Description: event.Description,
+		Attributes:  event.Attributes,
+	})
+}
+
+//--------------------------------------------------------------------------------------------------
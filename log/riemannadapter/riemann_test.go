@@ -0,0 +1,66 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package riemannadapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jeffail/util/log"
+	"github.com/jeffail/util/metrics"
+)
+
+var _ Sender = &metrics.Riemann{}
+
+type sentEvents struct {
+	events []*metrics.RiemannEvent
+}
+
+func (s *sentEvents) SendEvent(event *metrics.RiemannEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	loggerConfig := log.NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	var buf bytes.Buffer
+	sender := &sentEvents{}
+
+	logger := log.NewLogger(&buf, loggerConfig).(*log.Logger)
+	logger.AddHook(log.NewRiemannHook(NewClient(sender)))
+	logger.Errorln("Error message")
+
+	if len(sender.events) != 1 {
+		t.Fatalf("Wrong count of events: %v != 1", len(sender.events))
+	}
+	e := sender.events[0]
+	if e.Service != "root" || e.State != "critical" || e.Description != "Error message" {
+		t.Errorf("Wrong event: %+v", e)
+	}
+	if e.Time == 0 || e.Attributes["level"] != "ERROR" {
+		t.Errorf("Wrong event: %+v", e)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// ringBuffer - The most recent entries of a level or lower, shared by every module and child of a
+// logger.
+type ringBuffer struct {
+	sync.Mutex
+	level   int
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// newRingBuffer - Creates a ring buffer of a size, or returns nil when the size is not positive.
+// An unrecognised level records every level.
+func newRingBuffer(size int, level string) *ringBuffer {
+	if size <= 0 {
+		return nil
+	}
+	i := logLevelToInt(level)
+	if i < 0 {
+		i = LogAll
+	}
+	return &ringBuffer{level: i, entries: make([]Entry, size)}
+}
+
+// push - Records a copy of an entry when it is of the level of the buffer or lower, replacing the
+// oldest entry once the buffer is full.
+func (r *ringBuffer) push(e *Entry) {
+	if logLevelToInt(e.Level) > r.level {
+		return
+	}
+	r.Lock()
+	r.entries[r.next] = *e
+	r.next++
+	if r.next == len(r.entries) {
+		r.next, r.full = 0, true
+	}
+	r.Unlock()
+}
+
+// tail - Returns up to the n most recent entries, oldest first, where n less than one returns all.
+func (r *ringBuffer) tail(n int) []Entry {
+	r.Lock()
+	defer r.Unlock()
+
+	size := r.next
+	if r.full {
+		size = len(r.entries)
+	}
+	if n < 1 || n > size {
+		n = size
+	}
+	tail := make([]Entry, 0, n)
+	for i := r.next - n; i < r.next; i++ {
+		tail = append(tail, r.entries[(i+len(r.entries))%len(r.entries)])
+	}
+	return tail
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// recorded - Returns whether messages of a level are either printed or recorded in the ring buffer.
+func (l *Logger) recorded(level int) bool {
+	return l.enabled(level) || (l.ring != nil && level > LogOff && level <= l.ring.level)
+}
+
+// Tail - Returns up to the n most recent messages kept in the ring buffer, oldest first, where n less
+// than one returns every message kept. Returns nil when the ring buffer is disabled.
+func (l *Logger) Tail(n int) []Entry {
+	if l.ring == nil {
+		return nil
+	}
+	return l.ring.tail(n)
+}
+
+/*
+TailHandler - Returns an admin handler that responds with the messages kept in the ring buffer,
+oldest first, written in the format of the logger. The "n" query parameter limits the response to
+the most recent n messages.
+*/
+func (l *Logger) TailHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.ring == nil {
+			http.Error(w, "ring buffer is disabled", http.StatusNotFound)
+			return
+		}
+		n := 0
+		if s := r.URL.Query().Get("n"); s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil {
+				http.Error(w, "n must be an integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var buf bytes.Buffer
+		for _, e := range l.ring.tail(n) {
+			l.format(&buf, &e)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(buf.Bytes())
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,73 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "INFO"
+	loggerConfig.RingBufferSize = 3
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.Traceln("Hidden trace")
+	logger.Debugln("Debug 1")
+	logger.Infoln("Info 1")
+	logger.NewModule(".http").Debugf("Debug %v\n", 2)
+	logger.Errorln("Error 1")
+
+	if expected := "INFO | root | Info 1\nERROR | root | Error 1\n"; expected != buf.data {
+		t.Errorf("Printed output does not match: %v != %v", buf.data, expected)
+	}
+
+	tail := logger.Tail(0)
+	if len(tail) != 3 {
+		t.Fatalf("Wrong count of entries: %v != 3", len(tail))
+	}
+	if tail[0].Message != "Info 1\n" || tail[1].Prefix != "root.http" || tail[2].Level != "ERROR" {
+		t.Errorf("Wrong entries: %+v", tail)
+	}
+	if tail = logger.Tail(1); len(tail) != 1 || tail[0].Message != "Error 1\n" {
+		t.Errorf("Wrong entries: %+v", tail)
+	}
+
+	rec := httptest.NewRecorder()
+	logger.TailHandler()(rec, httptest.NewRequest("GET", "/logs?n=2", nil))
+	if exp := "DEBUG | root.http | Debug 2\nERROR | root | Error 1\n"; exp != rec.Body.String() {
+		t.Errorf("Wrong handler response: %v != %v", rec.Body.String(), exp)
+	}
+
+	rec = httptest.NewRecorder()
+	NewLogger(&buf, NewLoggerConfig()).(*Logger).TailHandler()(rec, httptest.NewRequest("GET", "/logs", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Wrong status for disabled ring buffer: %v", rec.Code)
+	}
+}
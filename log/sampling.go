@@ -0,0 +1,170 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// SampleRule - Limits the messages of a level to one in every Every messages, and to at most
+// PerSecond messages each second. A value of zero disables the respective limit.
+type SampleRule struct {
+	Every     int `json:"every" yaml:"every"`
+	PerSecond int `json:"per_second" yaml:"per_second"`
+}
+
+/*
+SamplingConfig - Config for sampling log messages, where Levels is the rule of each level keyed by
+level name, such as "DEBUG". When ByMessage is set each distinct message of a level is sampled
+separately, where the message of a formatted print is its format string, such that a hot path can be
+sampled without silencing others of the same level.
+*/
+type SamplingConfig struct {
+	Levels    map[string]SampleRule `json:"levels" yaml:"levels"`
+	ByMessage bool                  `json:"by_message" yaml:"by_message"`
+}
+
+// NewSamplingConfig - Returns a sampling configuration with the default values for each field.
+func NewSamplingConfig() SamplingConfig {
+	return SamplingConfig{
+		Levels:    map[string]SampleRule{},
+		ByMessage: false,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// samplerMaxKeys - The number of message keys tracked before the counts of all keys are reset.
+const samplerMaxKeys = 10000
+
+// sampleKey - Identifies the count of a level, or of a message of a level.
+type sampleKey struct {
+	level   int
+	message string
+}
+
+// sampleCount - The number of messages seen of a key, and the number allowed within the current
+// second.
+type sampleCount struct {
+	seen    int64
+	second  int64
+	allowed int
+}
+
+// sampler - Decides which messages are printed according to sampling rules, shared by every module
+// and child of a logger.
+type sampler struct {
+	sync.Mutex
+
+	rules      map[int]SampleRule
+	byMessage  bool
+	counts     map[sampleKey]*sampleCount
+	suppressed int64
+
+	now func() time.Time
+}
+
+// newSampler - Creates a sampler from a config, or returns nil when there are no rules. Rules of an
+// unrecognised level are ignored.
+func newSampler(conf SamplingConfig) *sampler {
+	rules := map[int]SampleRule{}
+	for level, rule := range conf.Levels {
+		if i := logLevelToInt(level); i >= 0 && (rule.Every > 1 || rule.PerSecond > 0) {
+			rules[i] = rule
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &sampler{
+		rules:     rules,
+		byMessage: conf.ByMessage,
+		counts:    map[sampleKey]*sampleCount{},
+		now:       time.Now,
+	}
+}
+
+// allow - Returns whether a message of a level is printed, counting it as suppressed otherwise.
+func (s *sampler) allow(level int, message string) bool {
+	rule, ok := s.rules[level]
+	if !ok {
+		return true
+	}
+
+	key := sampleKey{level: level}
+	if s.byMessage {
+		key.message = message
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	c, ok := s.counts[key]
+	if !ok {
+		if len(s.counts) >= samplerMaxKeys {
+			s.counts = map[sampleKey]*sampleCount{}
+		}
+		c = &sampleCount{}
+		s.counts[key] = c
+	}
+	c.seen++
+
+	allowed := rule.Every <= 1 || (c.seen-1)%int64(rule.Every) == 0
+	if allowed && rule.PerSecond > 0 {
+		if second := s.now().Unix(); second != c.second {
+			c.second, c.allowed = second, 0
+		}
+		if allowed = c.allowed < rule.PerSecond; allowed {
+			c.allowed++
+		}
+	}
+	if !allowed {
+		atomic.AddInt64(&s.suppressed, 1)
+	}
+	return allowed
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// sampled - Returns whether a message of a level passes sampling.
+func (l *Logger) sampled(level, message string) bool {
+	if l.sampler == nil {
+		return true
+	}
+	return l.sampler.allow(logLevelToInt(level), message)
+}
+
+// Suppressed - Returns the number of messages that were not printed due to sampling, counted across
+// every module and child of the logger.
+func (l *Logger) Suppressed() int64 {
+	if l.sampler == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&l.sampler.suppressed)
+}
+
+//--------------------------------------------------------------------------------------------------
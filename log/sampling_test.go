@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSamplingEvery(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Sampling.Levels = map[string]SampleRule{
+		"ERROR": {Every: 3},
+		"nope":  {Every: 2},
+	}
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	module := logger.NewModule(".foo")
+	for i := 0; i < 7; i++ {
+		module.Errorf("Error %v\n", i)
+	}
+	logger.Warnln("Warning")
+
+	expected := "ERROR | root.foo | Error 0\n" +
+		"ERROR | root.foo | Error 3\n" +
+		"ERROR | root.foo | Error 6\n" +
+		"WARN | root | Warning\n"
+	if expected != buf.data {
+		t.Errorf("Sampling does not match: %v != %v", buf.data, expected)
+	}
+	if exp, act := int64(4), logger.Suppressed(); exp != act {
+		t.Errorf("Wrong suppressed count: %v != %v", act, exp)
+	}
+}
+
+func TestSamplingPerSecondByMessage(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Sampling.ByMessage = true
+	loggerConfig.Sampling.Levels = map[string]SampleRule{
+		"INFO": {PerSecond: 2},
+	}
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+
+	now := time.Unix(100, 0)
+	logger.sampler.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		logger.Infof("Hot %v\n", i)
+	}
+	logger.Infoln("Cold")
+	now = now.Add(time.Second)
+	logger.Infof("Hot %v\n", 3)
+
+	expected := "INFO | root | Hot 0\n" +
+		"INFO | root | Hot 1\n" +
+		"INFO | root | Cold\n" +
+		"INFO | root | Hot 3\n"
+	if expected != buf.data {
+		t.Errorf("Sampling does not match: %v != %v", buf.data, expected)
+	}
+	if exp, act := int64(1), logger.Suppressed(); exp != act {
+		t.Errorf("Wrong suppressed count: %v != %v", act, exp)
+	}
+}
+
+func TestSamplingMaxKeys(t *testing.T) {
+	s := newSampler(SamplingConfig{
+		Levels:    map[string]SampleRule{"DEBUG": {Every: 2}},
+		ByMessage: true,
+	})
+	for i := 0; i < samplerMaxKeys+1; i++ {
+		s.allow(LogDebug, fmt.Sprintf("%v", i))
+	}
+	if exp, act := 1, len(s.counts); exp != act {
+		t.Errorf("Wrong number of keys: %v != %v", act, exp)
+	}
+	if newSampler(NewSamplingConfig()) != nil {
+		t.Error("Expected nil sampler without rules")
+	}
+}
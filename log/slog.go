@@ -0,0 +1,212 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// slogToLevel - Returns the log level of a slog level, where levels below debug are trace and levels
+// above error are fatal.
+func slogToLevel(level slog.Level) int {
+	switch {
+	case level < slog.LevelDebug:
+		return LogTrace
+	case level < slog.LevelInfo:
+		return LogDebug
+	case level < slog.LevelWarn:
+		return LogInfo
+	case level < slog.LevelError:
+		return LogWarn
+	case level < slog.LevelError+4:
+		return LogError
+	}
+	return LogFatal
+}
+
+// levelToSlog - Returns the slog level of a log level.
+func levelToSlog(level int) slog.Level {
+	switch level {
+	case LogFatal:
+		return slog.LevelError + 4
+	case LogError:
+		return slog.LevelError
+	case LogWarn:
+		return slog.LevelWarn
+	case LogInfo:
+		return slog.LevelInfo
+	case LogDebug:
+		return slog.LevelDebug
+	}
+	return slog.LevelDebug - 4
+}
+
+// addSlogAttr - Adds an attribute to fields, where the keys of attributes within groups are prefixed
+// by the group names separated by dots. Attributes with empty keys are ignored, and the attributes of
+// groups with empty keys are added without a prefix.
+func addSlogAttr(fields map[string]interface{}, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			addSlogAttr(fields, prefix, a)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+	fields[prefix+attr.Key] = attr.Value.Any()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+SlogHandler - A slog.Handler that prints records with a Logger, such that code using the structured
+logging of the standard library shares the levels, outputs, formats, sampling and hooks of this
+package. Attributes are printed as fields, where the keys of attributes within groups are prefixed
+by the group names separated by dots.
+*/
+type SlogHandler struct {
+	logger *Logger
+	fields map[string]interface{}
+	group  string
+}
+
+// NewSlogHandler - Creates a slog.Handler that prints records with a logger.
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// Enabled - Returns whether records of a level are printed by the logger.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.recorded(slogToLevel(level))
+}
+
+// Handle - Prints a record with the logger, where the caller of the record is used when caller
+// information is enabled for its level.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(map[string]interface{}, len(h.fields)+r.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.group, a)
+		return true
+	})
+
+	e := Entry{
+		Time:    r.Time,
+		Level:   intToLogLevel(slogToLevel(r.Level)),
+		Message: r.Message + "\n",
+		Fields:  fields,
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		e.File, e.Line, e.Function = frame.File, frame.Line, frame.Function
+	}
+	h.logger.PrintEntry(e)
+	return nil
+}
+
+// WithAttrs - Returns a handler that adds attributes to every record.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := *h
+	child.fields = make(map[string]interface{}, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		child.fields[k] = v
+	}
+	for _, a := range attrs {
+		addSlogAttr(child.fields, h.group, a)
+	}
+	return &child
+}
+
+// WithGroup - Returns a handler that prefixes the keys of subsequent attributes with a group name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	child := *h
+	child.group = h.group + name + "."
+	return &child
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+SlogWriter - An EntryWriter that forwards log messages to a slog.Handler, which can be used as the
+stream of a logger in order to write into an existing slog pipeline. The prefix of the logger is
+added as the attribute "service" and fields are added as attributes in sorted order.
+*/
+type SlogWriter struct {
+	handler slog.Handler
+}
+
+// NewSlogWriter - Creates a writer that forwards log messages to a slog.Handler.
+func NewSlogWriter(h slog.Handler) *SlogWriter {
+	return &SlogWriter{handler: h}
+}
+
+// WriteEntry - Forwards a log message to the handler if it is enabled for its level.
+func (s *SlogWriter) WriteEntry(e *Entry) error {
+	level := levelToSlog(logLevelToInt(e.Level))
+	ctx := context.Background()
+	if !s.handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	r := slog.NewRecord(t, level, strings.TrimSuffix(e.Message, "\n"), 0)
+	if e.Prefix != "" {
+		r.AddAttrs(slog.String("service", e.Prefix))
+	}
+	for _, k := range sortedFieldKeys(e.Fields) {
+		r.AddAttrs(slog.Any(k, e.Fields[k]))
+	}
+	return s.handler.Handle(ctx, r)
+}
+
+// Write - Forwards a raw log line to the handler as a message of the info level.
+func (s *SlogWriter) Write(p []byte) (int, error) {
+	if err := s.WriteEntry(&Entry{Level: "INFO", Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//--------------------------------------------------------------------------------------------------
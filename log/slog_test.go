@@ -0,0 +1,103 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "INFO"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger).WithFields(map[string]interface{}{"a": 1})
+	s := slog.New(NewSlogHandler(logger))
+
+	s.Debug("Hidden")
+	s.Info("Info message", "b", "two words")
+	s.With("c", 3).WithGroup("req").Warn("Warn message", "id", "abc", slog.Group("user", "name", "x"))
+	s.Log(context.Background(), slog.LevelError+4, "Fatal message")
+
+	expected := "INFO | root | Info message a=1 b=\"two words\"\n" +
+		"WARN | root | Warn message a=1 c=3 req.id=abc req.user.name=x\n" +
+		"FATAL | root | Fatal message a=1\n"
+	if expected != buf.data {
+		t.Errorf("Slog handler output does not match: %v != %v", buf.data, expected)
+	}
+}
+
+func TestSlogHandlerCaller(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.CallerLevels = []string{"INFO"}
+
+	buf := LogBuffer{data: ""}
+
+	s := slog.New(NewSlogHandler(NewLogger(&buf, loggerConfig).(*Logger)))
+	s.Info("Info message")
+
+	if exp := "INFO | root | log/slog_test.go:69 log.TestSlogHandlerCaller | Info message\n"; exp != buf.data {
+		t.Errorf("Wrong caller: %v != %v", buf.data, exp)
+	}
+}
+
+func TestSlogWriter(t *testing.T) {
+	var out bytes.Buffer
+	h := slog.NewTextHandler(&out, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	logger := NewLogger(NewSlogWriter(h), loggerConfig).(*Logger).WithFields(map[string]interface{}{"b": 2, "a": 1})
+	logger.Infoln("Hidden")
+	logger.Errorf("Error message\n")
+
+	expected := "level=ERROR msg=\"Error message\" service=root a=1 b=2\n"
+	if act := out.String(); expected != act {
+		t.Errorf("Slog writer output does not match: %v != %v", act, expected)
+	}
+	if strings.Contains(out.String(), "Hidden") {
+		t.Error("Expected disabled level to be dropped")
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import "strings"
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+NewStatsHook - Creates a Hook that increments a counter of stats for every printed message, giving
+error rates without an Incr call next to every Errorf. Each message increments the path
+"<path>.<level>", such as "log.error", and when the logger has a prefix also the path
+"<path>.<prefix>.<level>", such as "log.service.http.error". Messages with the field "error_class",
+such as those of WithError, also increment the path "<path>.errors.<class>". An empty path is
+treated as "log".
+Register it with AddHook for the counts of every module of a logger.
+*/
+func NewStatsHook(stats StatCounter, path string) Hook {
+	if path == "" {
+		path = "log"
+	}
+	levels := []int{LogFatal, LogError, LogWarn, LogInfo, LogDebug, LogTrace}
+	return NewHook(levels, func(e *Entry) error {
+		level := strings.ToLower(e.Level)
+		if err := stats.Incr(path+"."+level, 1); err != nil {
+			return err
+		}
+		if class, ok := e.Fields["error_class"].(string); ok && class != "" {
+			if err := stats.Incr(path+".errors."+class, 1); err != nil {
+				return err
+			}
+		}
+		if e.Prefix == "" {
+			return nil
+		}
+		return stats.Incr(path+"."+e.Prefix+"."+level, 1)
+	})
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statCounts - A StatRecorder that records counters and the number of timings of each path.
+type statCounts struct {
+	sync.Mutex
+	counts  map[string]int64
+	timings map[string]int
+}
+
+func (s *statCounts) Incr(path string, count int64) error {
+	s.Lock()
+	defer s.Unlock()
+	s.counts[path] += count
+	return nil
+}
+
+func (s *statCounts) Decr(path string, count int64) error {
+	return s.Incr(path, -count)
+}
+
+func (s *statCounts) Timing(path string, delta int64) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.timings == nil {
+		s.timings = map[string]int{}
+	}
+	s.timings[path]++
+	return nil
+}
+
+func (s *statCounts) TimingDuration(path string, d time.Duration) error {
+	return s.Timing(path, int64(d))
+}
+
+func (s *statCounts) Gauge(path string, value int64) error {
+	return nil
+}
+
+func (s *statCounts) Close() error {
+	return nil
+}
+
+func TestStatsHook(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "INFO"
+
+	buf := LogBuffer{data: ""}
+	stats := &statCounts{counts: map[string]int64{}}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	logger.AddHook(NewStatsHook(stats, ""))
+
+	module := logger.NewModule(".http")
+	logger.Errorln("Error message")
+	module.Errorf("Error message %v\n", 2)
+	module.Warnln("Warn message")
+	module.Debugln("Hidden")
+
+	expected := map[string]int64{
+		"log.error":           2,
+		"log.root.error":      1,
+		"log.root.http.error": 1,
+		"log.warn":            1,
+		"log.root.http.warn":  1,
+	}
+	if !reflect.DeepEqual(expected, stats.counts) {
+		t.Errorf("Wrong stat counts: %v != %v", stats.counts, expected)
+	}
+}
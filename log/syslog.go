@@ -0,0 +1,258 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the syslog target.
+var (
+	ErrSyslogFacility = errors.New("syslog facility was not recognised")
+	ErrSyslogNoSocket = errors.New("no local syslog socket was found")
+)
+
+// syslogFacilities - The code of each syslog facility by name.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogLocalSockets - The paths searched for a local syslog socket when no address is configured.
+var syslogLocalSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogSeverity - Returns the syslog severity of a log level, where unknown levels are info.
+func syslogSeverity(level int) int {
+	switch level {
+	case LogFatal:
+		return 2 // crit
+	case LogError:
+		return 3 // err
+	case LogWarn:
+		return 4 // warning
+	case LogDebug, LogTrace, LogAll:
+		return 7 // debug
+	}
+	return 6 // info
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+SyslogConfig - Config for a syslog target. An empty network writes to the local syslog socket in the
+traditional local format, where the address is the path of the socket and is searched for when
+empty. A network of "udp" or "tcp" writes RFC 5424 messages to a remote address, where messages
+over TCP are framed by octet counting. The tag defaults to the name of the program and the hostname
+to that of the machine.
+*/
+type SyslogConfig struct {
+	Network  string `json:"network" yaml:"network"`
+	Address  string `json:"address" yaml:"address"`
+	Facility string `json:"facility" yaml:"facility"`
+	Tag      string `json:"tag" yaml:"tag"`
+	Hostname string `json:"hostname" yaml:"hostname"`
+	Timeout  string `json:"timeout" yaml:"timeout"`
+}
+
+// NewSyslogConfig - Returns a syslog target configuration with the default values for each field.
+func NewSyslogConfig() SyslogConfig {
+	return SyslogConfig{
+		Network:  "",
+		Address:  "",
+		Facility: "local0",
+		Tag:      "",
+		Hostname: "",
+		Timeout:  "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+SyslogWriter - A LevelWriter that sends each log line to syslog with a severity mapped from its
+level, which can be used as the stream of a logger. Lines written without a level are sent with the
+info severity. The connection is dialled again once if a write fails.
+*/
+type SyslogWriter struct {
+	sync.Mutex
+
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	timeout  time.Duration
+
+	conn net.Conn
+	now  func() time.Time
+}
+
+// NewSyslogWriter - Creates a syslog writer from a config and dials syslog.
+func NewSyslogWriter(conf SyslogConfig) (*SyslogWriter, error) {
+	facility, ok := syslogFacilities[strings.ToLower(conf.Facility)]
+	if !ok {
+		return nil, ErrSyslogFacility
+	}
+	s := &SyslogWriter{
+		network:  conf.Network,
+		address:  conf.Address,
+		facility: facility,
+		tag:      conf.Tag,
+		hostname: conf.Hostname,
+		now:      time.Now,
+	}
+	if s.tag == "" {
+		s.tag = filepath.Base(os.Args[0])
+	}
+	if s.hostname == "" {
+		if s.hostname, _ = os.Hostname(); s.hostname == "" {
+			s.hostname = "-"
+		}
+	}
+	if conf.Timeout != "" {
+		var err error
+		if s.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout: %v", err)
+		}
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// local - Returns whether the writer sends to a local socket.
+func (s *SyslogWriter) local() bool {
+	return s.network == "" || s.network == "unix" || s.network == "unixgram"
+}
+
+// dialLocal - Dials a local socket as a datagram socket, falling back to a stream socket.
+func dialLocal(path string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("unixgram", path, timeout)
+	if err != nil {
+		conn, err = net.DialTimeout("unix", path, timeout)
+	}
+	return conn, err
+}
+
+// dial - Connects to syslog. Must be called whilst holding the lock.
+func (s *SyslogWriter) dial() error {
+	var err error
+	if !s.local() {
+		s.conn, err = net.DialTimeout(s.network, s.address, s.timeout)
+		return err
+	}
+	if s.address != "" {
+		s.conn, err = dialLocal(s.address, s.timeout)
+		return err
+	}
+	for _, path := range syslogLocalSockets {
+		if s.conn, err = dialLocal(path, s.timeout); err == nil {
+			return nil
+		}
+	}
+	return ErrSyslogNoSocket
+}
+
+// format - Returns a log line as a syslog message of a severity.
+func (s *SyslogWriter) format(severity int, p []byte) []byte {
+	msg := strings.TrimSuffix(string(p), "\n")
+	pri := s.facility*8 + severity
+
+	if s.local() {
+		return []byte(fmt.Sprintf("<%d>%s %s[%d]: %s\n",
+			pri, s.now().Format(time.Stamp), s.tag, os.Getpid(), msg))
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		pri, s.now().Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, s.tag, os.Getpid(), msg)
+	if s.network == "tcp" {
+		return []byte(fmt.Sprintf("%d %s", len(line), line))
+	}
+	return []byte(line)
+}
+
+// send - Writes a message, dialling syslog again and retrying once if the write fails.
+func (s *SyslogWriter) send(msg []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err = s.dial(); err != nil {
+				continue
+			}
+		}
+		if s.timeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		}
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// WriteLevel - Sends a log line to syslog with the severity of a log level.
+func (s *SyslogWriter) WriteLevel(level int, p []byte) (int, error) {
+	if err := s.send(s.format(syslogSeverity(level), p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write - Sends a log line to syslog with the info severity.
+func (s *SyslogWriter) Write(p []byte) (int, error) {
+	return s.WriteLevel(LogInfo, p)
+}
+
+// Close - Closes the connection to syslog.
+func (s *SyslogWriter) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
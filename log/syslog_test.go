@@ -0,0 +1,186 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "syslog.sock")
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("Unix datagram sockets not supported: %v", err)
+	}
+	defer conn.Close()
+
+	conf := NewSyslogConfig()
+	conf.Address = path
+	conf.Tag = "app"
+
+	s, err := NewSyslogWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.now = func() time.Time { return time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	NewLogger(s, loggerConfig).Warnln("Warning message")
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("<132>Jan  2 03:04:05 app[%d]: WARN | root | Warning message\n", os.Getpid())
+	if act := string(buf[:n]); expected != act {
+		t.Errorf("Wrong message: %v != %v", act, expected)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewSyslogConfig()
+	conf.Network = "udp"
+	conf.Address = conn.LocalAddr().String()
+	conf.Facility = "daemon"
+	conf.Tag = "app"
+	conf.Hostname = "host"
+
+	s, err := NewSyslogWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.now = func() time.Time { return time.Date(2016, 1, 2, 3, 4, 5, 6000, time.UTC) }
+
+	s.WriteLevel(LogError, []byte("Error message\n"))
+	s.Write([]byte("Plain message"))
+
+	expected := []string{
+		fmt.Sprintf("<27>1 2016-01-02T03:04:05.000006Z host app %d - - Error message", os.Getpid()),
+		fmt.Sprintf("<30>1 2016-01-02T03:04:05.000006Z host app %d - - Plain message", os.Getpid()),
+	}
+	buf := make([]byte, 1024)
+	for _, exp := range expected {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := string(buf[:n]); exp != act {
+			t.Errorf("Wrong message: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conf := NewSyslogConfig()
+	conf.Network = "tcp"
+	conf.Address = ln.Addr().String()
+	conf.Tag = "app"
+	conf.Hostname = "host"
+
+	s, err := NewSyslogWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.now = func() time.Time { return time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s.WriteLevel(LogDebug, []byte("one\n"))
+	s.WriteLevel(LogFatal, []byte("two\n"))
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for _, exp := range []string{
+		fmt.Sprintf("<135>1 2016-01-02T03:04:05.000000Z host app %d - - one", os.Getpid()),
+		fmt.Sprintf("<130>1 2016-01-02T03:04:05.000000Z host app %d - - two", os.Getpid()),
+	} {
+		var length int
+		if _, err = fmt.Fscanf(r, "%d ", &length); err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, length)
+		if _, err = io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		if act := string(msg); exp != act {
+			t.Errorf("Wrong message: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestSyslogBadConfig(t *testing.T) {
+	conf := NewSyslogConfig()
+	conf.Facility = "nope"
+	if _, err := NewSyslogWriter(conf); err != ErrSyslogFacility {
+		t.Errorf("Wrong error: %v != %v", err, ErrSyslogFacility)
+	}
+
+	conf = NewSyslogConfig()
+	conf.Network = "udp"
+	conf.Address = "127.0.0.1:1"
+	conf.Timeout = "nope"
+	if _, err := NewSyslogWriter(conf); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected timeout error: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// templateEntry - The values of an entry given to a Go template, where each is a printed string
+// except for FieldMap.
+type templateEntry struct {
+	Time     string
+	Level    string
+	Prefix   string
+	Message  string
+	Fields   string
+	Caller   string
+	Function string
+	FieldMap map[string]interface{}
+}
+
+// newTemplateEntry - Returns the printed values of an entry.
+func newTemplateEntry(e *Entry) templateEntry {
+	t := templateEntry{
+		Level:    e.Level,
+		Prefix:   e.Prefix,
+		Message:  strings.TrimSuffix(e.Message, "\n"),
+		FieldMap: e.Fields,
+	}
+	if !e.Time.IsZero() {
+		t.Time = e.timestamp()
+	}
+	if len(e.Fields) > 0 {
+		var pairs bytes.Buffer
+		writeLogfmtFields(&pairs, e.Fields)
+		t.Fields = pairs.String()
+	}
+	if e.File != "" {
+		t.Caller = shortCaller(e.File, e.Line)
+		t.Function = shortFunction(e.Function)
+	}
+	return t
+}
+
+// templateTokens - The value of each token of a pattern layout.
+var templateTokens = map[string]func(t *templateEntry) string{
+	"time":    func(t *templateEntry) string { return t.Time },
+	"level":   func(t *templateEntry) string { return t.Level },
+	"prefix":  func(t *templateEntry) string { return t.Prefix },
+	"service": func(t *templateEntry) string { return t.Prefix },
+	"msg":     func(t *templateEntry) string { return t.Message },
+	"fields":  func(t *templateEntry) string { return t.Fields },
+	"caller":  func(t *templateEntry) string { return t.Caller },
+	"func":    func(t *templateEntry) string { return t.Function },
+}
+
+// parsePattern - Splits a pattern layout into literal text and the functions of its tokens, where
+// "%%" is a literal percent sign and unrecognised tokens are kept as literal text.
+func parsePattern(pattern string) []func(t *templateEntry) string {
+	var parts []func(t *templateEntry) string
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			s := literal.String()
+			parts = append(parts, func(*templateEntry) string { return s })
+			literal.Reset()
+		}
+	}
+	for len(pattern) > 0 {
+		i := strings.IndexByte(pattern, '%')
+		if i < 0 {
+			literal.WriteString(pattern)
+			break
+		}
+		literal.WriteString(pattern[:i])
+		pattern = pattern[i+1:]
+		if strings.HasPrefix(pattern, "%") {
+			literal.WriteByte('%')
+			pattern = pattern[1:]
+			continue
+		}
+		j := 0
+		for j < len(pattern) && pattern[j] >= 'a' && pattern[j] <= 'z' {
+			j++
+		}
+		fn, ok := templateTokens[pattern[:j]]
+		if !ok {
+			literal.WriteByte('%')
+			continue
+		}
+		flush()
+		parts = append(parts, fn)
+		pattern = pattern[j:]
+	}
+	flush()
+	return parts
+}
+
+/*
+newTemplateFormatter - Creates a formatter that writes each entry with a layout, followed by a line
+ending and any stack trace indented as in the text format. A layout containing "{{" is a Go
+text/template given the fields Time, Level, Prefix, Message, Fields, Caller, Function and FieldMap,
+otherwise it is a pattern of tokens %time, %level, %prefix (or %service), %msg, %fields, %caller and
+%func. Returns false when the layout is empty or the template cannot be parsed.
+*/
+func newTemplateFormatter(layout string) (formatter, bool) {
+	if layout == "" {
+		return nil, false
+	}
+	var write func(buf *bytes.Buffer, t *templateEntry)
+	if strings.Contains(layout, "{{") {
+		tmpl, err := template.New("log").Parse(layout)
+		if err != nil {
+			return nil, false
+		}
+		write = func(buf *bytes.Buffer, t *templateEntry) {
+			tmpl.Execute(buf, t)
+		}
+	} else {
+		parts := parsePattern(layout)
+		write = func(buf *bytes.Buffer, t *templateEntry) {
+			for _, part := range parts {
+				buf.WriteString(part(t))
+			}
+		}
+	}
+	return func(buf *bytes.Buffer, e *Entry) {
+		t := newTemplateEntry(e)
+		write(buf, &t)
+		buf.WriteByte('\n')
+		writeStack(buf, e.Stack)
+	}, true
+}
+
+//--------------------------------------------------------------------------------------------------
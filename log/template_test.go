@@ -0,0 +1,80 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTemplateFormat(t *testing.T) {
+	e := &Entry{
+		Time:    time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:   "WARN",
+		Prefix:  "root",
+		Message: "Warn message\n",
+		Fields:  map[string]interface{}{"b": "two words", "a": 1},
+	}
+
+	tests := map[string]string{
+		"%time [%level] %service: %msg %fields 100%% %unknown": "2016-01-02T03:04:05Z [WARN] root: Warn message a=1 b=\"two words\" 100% %unknown\n",
+		`{{.Level}}|{{.Message}}|{{index .FieldMap "a"}}`:      "WARN|Warn message|1\n",
+	}
+	for layout, expected := range tests {
+		f, ok := newTemplateFormatter(layout)
+		if !ok {
+			t.Errorf("Failed to parse layout: %v", layout)
+			continue
+		}
+		buf := LogBuffer{data: ""}
+		writeEntry(&buf, f, e)
+		if expected != buf.data {
+			t.Errorf("Wrong template output: %v != %v", buf.data, expected)
+		}
+	}
+
+	if _, ok := newTemplateFormatter("{{.Level"); ok {
+		t.Error("Expected invalid template to fail")
+	}
+}
+
+func TestTemplateLogger(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.Format = "template"
+	loggerConfig.Template = "%level %prefix %msg"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig)
+	logger.Infoln("Info message")
+
+	loggerConfig.Template = "{{.Level"
+	logger = NewLogger(&buf, loggerConfig)
+	logger.Infoln("Fallback")
+
+	if expected := "INFO root Info message\nINFO | root | Fallback\n"; expected != buf.data {
+		t.Errorf("Template logger output does not match: %v != %v", buf.data, expected)
+	}
+}
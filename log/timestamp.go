@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// timestamp - Returns the timestamp of an entry written in its layout, which is one of "rfc3339",
+// "rfc3339nano", "epoch", "epoch_millis" or a custom layout of the time package. An empty layout is
+// "rfc3339".
+func (e *Entry) timestamp() string {
+	var scratch [64]byte
+	return string(e.appendTimestamp(scratch[:0]))
+}
+
+// appendTimestamp - Appends the timestamp of an entry to a byte slice, which allows formatters to
+// write it from a buffer on the stack rather than allocating a string for every message.
+func (e *Entry) appendTimestamp(b []byte) []byte {
+	switch e.layout {
+	case "", "rfc3339":
+		return e.Time.AppendFormat(b, time.RFC3339)
+	case "rfc3339nano":
+		return e.Time.AppendFormat(b, time.RFC3339Nano)
+	case "epoch":
+		return strconv.AppendInt(b, e.Time.Unix(), 10)
+	case "epoch_millis":
+		return strconv.AppendInt(b, e.Time.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return e.Time.AppendFormat(b, e.layout)
+}
+
+// numericTimestamp - Returns whether the timestamp of an entry is a number rather than a string.
+func (e *Entry) numericTimestamp() bool {
+	return e.layout == "epoch" || e.layout == "epoch_millis"
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// managedTimestamps - Returns whether the output of the process is captured by journald, which is
+// detected by the JOURNAL_STREAM environment variable, or by docker, which is detected by the file
+// /.dockerenv. Both of these timestamp each line themselves.
+var managedTimestamps = func() bool {
+	if os.Getenv("JOURNAL_STREAM") != "" {
+		return true
+	}
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
+
+// timestampLocation - Returns the location of a timestamp zone, which is "local", "utc" or the name
+// of a zone such as "Europe/London", where an empty or unknown zone is local.
+func timestampLocation(zone string) *time.Location {
+	switch zone {
+	case "", "local", "Local":
+		return time.Local
+	case "utc", "UTC":
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// stamp - Sets the time of an entry to now, in the zone and layout of the logger, when timestamps
+// are enabled.
+func (l *Logger) stamp(e *Entry) {
+	if l.config.AddTimeStamp {
+		e.Time = time.Now().In(l.location)
+		e.layout = l.config.TimestampFormat
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
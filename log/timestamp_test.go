@@ -0,0 +1,80 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTimestampLayouts(t *testing.T) {
+	ts := time.Date(2016, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	tests := map[string]string{
+		"":                    "2016-01-02T03:04:05Z",
+		"rfc3339nano":         "2016-01-02T03:04:05.006Z",
+		"epoch":               "1451703845",
+		"epoch_millis":        "1451703845006",
+		"2006-01-02 15:04:05": "2016-01-02 03:04:05",
+	}
+	for layout, expected := range tests {
+		e := Entry{Time: ts, layout: layout}
+		if act := e.timestamp(); expected != act {
+			t.Errorf("Wrong timestamp for %q: %v != %v", layout, act, expected)
+		}
+	}
+
+	buf := LogBuffer{data: ""}
+	writeEntry(&buf, formatJSON, &Entry{Time: ts, Level: "INFO", Message: "msg\n", layout: "epoch"})
+	if exp := `{"timestamp":1451703845,"level":"INFO","service":"","message":"msg"}` + "\n"; exp != buf.data {
+		t.Errorf("Wrong JSON timestamp: %v != %v", buf.data, exp)
+	}
+}
+
+func TestTimestampConfig(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.Prefix = "root"
+	loggerConfig.TimestampFormat = "2006-01-02 15:04:05 MST"
+	loggerConfig.TimestampZone = "utc"
+
+	buf := LogBuffer{data: ""}
+
+	NewLogger(&buf, loggerConfig).Infoln("Info message")
+	if !regexp.MustCompile(`^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d UTC \| INFO \| root \| Info message\n$`).MatchString(buf.data) {
+		t.Errorf("Wrong timestamp output: %v", buf.data)
+	}
+
+	managed := managedTimestamps
+	defer func() {
+		managedTimestamps = managed
+	}()
+	managedTimestamps = func() bool { return true }
+
+	buf.data = ""
+	loggerConfig.OmitManagedTimestamp = true
+	NewLogger(&buf, loggerConfig).Infoln("Info message")
+	if exp := "INFO | root | Info message\n"; exp != buf.data {
+		t.Errorf("Expected managed timestamp to be omitted: %v != %v", buf.data, exp)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"io"
+	stdlog "log"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// maxLineSize - The size at which an unterminated line written to a line writer is printed anyway.
+const maxLineSize = 64 * 1024
+
+/*
+lineWriter - An io.Writer that prints each line written to it as a message of a level, holding back
+an unterminated line until the rest of it is written. Empty lines are ignored.
+*/
+type lineWriter struct {
+	logger *Logger
+	level  string
+
+	mut sync.Mutex
+	buf []byte
+}
+
+// Write - Prints each complete line of p, and holds any remainder until its line ending arrives.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.printLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxLineSize {
+		w.printLine(w.buf)
+		w.buf = nil
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// printLine - Prints a line without its line ending, unless it is empty or its level is disabled.
+func (w *lineWriter) printLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 || !w.logger.recorded(logLevelToInt(w.level)) {
+		return
+	}
+	w.logger.printLine(string(line), w.level)
+}
+
+/*
+Writer - Returns an io.Writer that prints each line written to it as a message of a level, such as
+"WARN", in order to redirect the output of libraries that only accept an io.Writer. Unrecognised
+levels are printed as INFO. An unterminated line is held back until the rest of it is written, and
+when caller information is enabled the caller is whatever called Write.
+*/
+func (l *Logger) Writer(level string) io.Writer {
+	if i := logLevelToInt(level); i <= LogOff || i >= LogAll {
+		level = "INFO"
+	}
+	return &lineWriter{logger: l.WithCallerSkip(1), level: level}
+}
+
+/*
+StdLogger - Returns a *log.Logger of the standard library that prints each message as a message of
+a level, such as "WARN", in order to redirect the output of libraries that only accept a standard
+logger. The prefix and flags of the standard logger are empty, as the prefix and timestamp of this
+logger are printed instead, and when caller information is enabled the caller is the code calling
+the standard logger.
+*/
+func (l *Logger) StdLogger(level string) *stdlog.Logger {
+	return stdlog.New(l.WithCallerSkip(2).Writer(level), "", 0)
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,70 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package log
+
+import (
+	"io"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "WARN"
+
+	buf := LogBuffer{data: ""}
+
+	logger := NewLogger(&buf, loggerConfig).(*Logger)
+	io.WriteString(logger.Writer("INFO"), "Hidden\n")
+
+	w := logger.Writer("ERROR")
+	io.WriteString(w, "First line\nSecond ")
+	io.WriteString(w, "line\r\n\n")
+	io.WriteString(w, "Unterminated")
+
+	expected := "ERROR | root | First line\nERROR | root | Second line\n"
+	if expected != buf.data {
+		t.Errorf("Writer output does not match: %v != %v", buf.data, expected)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	loggerConfig := NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.CallerLevels = []string{"WARN"}
+
+	buf := LogBuffer{data: ""}
+
+	std := NewLogger(&buf, loggerConfig).(*Logger).StdLogger("WARN")
+	std.Printf("Warn %v", 1)
+	std.Println("Multi\nline")
+
+	expected := "WARN | root | log/writer_test.go:61 log.TestStdLogger | Warn 1\n" +
+		"WARN | root | log/writer_test.go:62 log.TestStdLogger | Multi\n" +
+		"WARN | root | log/writer_test.go:62 log.TestStdLogger | line\n"
+	if expected != buf.data {
+		t.Errorf("Std logger output does not match: %v != %v", buf.data, expected)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+/*
+Package zapadapter - Adapters between zap and the log package, which ease the incremental migration
+of services with mixed logging stacks. A Core prints the entries of a zap logger with a Logger, and
+a Writer forwards the messages of a Logger into a zap logger.
+*/
+package zapadapter
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeffail/util/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// syncTimeout - How long a Sync waits for the queued messages of an asynchronous logger.
+const syncTimeout = 5 * time.Second
+
+// levelFromZap - Returns the log level of a zap level, where levels above error are fatal.
+func levelFromZap(level zapcore.Level) string {
+	switch {
+	case level < zapcore.InfoLevel:
+		return "DEBUG"
+	case level < zapcore.WarnLevel:
+		return "INFO"
+	case level < zapcore.ErrorLevel:
+		return "WARN"
+	case level < zapcore.DPanicLevel:
+		return "ERROR"
+	}
+	return "FATAL"
+}
+
+// levelToZap - Returns the zap level of a log level, where trace messages are logged at the debug
+// level and fatal messages are logged at the fatal level without exiting.
+func levelToZap(level string) zapcore.Level {
+	switch level {
+	case "FATAL":
+		return zapcore.FatalLevel
+	case "ERROR":
+		return zapcore.ErrorLevel
+	case "WARN":
+		return zapcore.WarnLevel
+	case "INFO":
+		return zapcore.InfoLevel
+	}
+	return zapcore.DebugLevel
+}
+
+// addZapFields - Adds zap fields to a map of fields.
+func addZapFields(to map[string]interface{}, fields []zapcore.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	for k, v := range enc.Fields {
+		to[k] = v
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Core - A zapcore.Core that prints the entries of a zap logger with a Logger, such that they share
+the levels, outputs, formats, sampling and hooks of the log package. Zap fields are printed as
+fields, the name of a zap logger is added as the field "logger", and the caller and stack trace of
+an entry are used when enabled for its level. A zap logger using the core exits on fatal messages
+regardless of the ExitOnFatal setting of the logger.
+*/
+type Core struct {
+	logger *log.Logger
+	fields map[string]interface{}
+}
+
+// NewCore - Creates a zapcore.Core that prints entries with a logger.
+func NewCore(l *log.Logger) *Core {
+	return &Core{logger: l}
+}
+
+// Enabled - Returns whether entries of a level are printed by the logger.
+func (c *Core) Enabled(level zapcore.Level) bool {
+	return c.logger.Enabled(levelFromZap(level))
+}
+
+// With - Returns a core that adds fields to every entry.
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	child := *c
+	child.fields = make(map[string]interface{}, len(c.fields)+len(fields))
+	for k, v := range c.fields {
+		child.fields[k] = v
+	}
+	addZapFields(child.fields, fields)
+	return &child
+}
+
+// Check - Adds the core to a checked entry when the level of the entry is enabled.
+func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write - Prints an entry and its fields with the logger.
+func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	e := log.Entry{
+		Time:    ent.Time,
+		Level:   levelFromZap(ent.Level),
+		Message: strings.TrimSuffix(ent.Message, "\n") + "\n",
+		Stack:   ent.Stack,
+	}
+	if len(c.fields)+len(fields) > 0 || ent.LoggerName != "" {
+		e.Fields = make(map[string]interface{}, len(c.fields)+len(fields)+1)
+		for k, v := range c.fields {
+			e.Fields[k] = v
+		}
+		addZapFields(e.Fields, fields)
+		if ent.LoggerName != "" {
+			e.Fields["logger"] = ent.LoggerName
+		}
+	}
+	if ent.Caller.Defined {
+		e.File, e.Line, e.Function = ent.Caller.File, ent.Caller.Line, ent.Caller.Function
+	}
+	c.logger.PrintEntry(e)
+	return nil
+}
+
+// Sync - Waits for the queued messages of an asynchronous logger to be written.
+func (c *Core) Sync() error {
+	return c.logger.Flush(syncTimeout)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Writer - A log.EntryWriter that forwards log messages to a zap logger, which can be used as the
+stream of a Logger in order to write into an existing zap pipeline. The prefix of the logger is used
+as the name of the zap entry, fields are added as zap fields in sorted order, and the caller and
+stack trace of a message are carried over to the zap entry.
+*/
+type Writer struct {
+	core zapcore.Core
+}
+
+// NewWriter - Creates a writer that forwards log messages to the core of a zap logger.
+func NewWriter(l *zap.Logger) *Writer {
+	return &Writer{core: l.Core()}
+}
+
+// WriteEntry - Forwards a log message to the zap logger if it is enabled for its level.
+func (w *Writer) WriteEntry(e *log.Entry) error {
+	ent := zapcore.Entry{
+		Level:      levelToZap(e.Level),
+		Time:       e.Time,
+		LoggerName: e.Prefix,
+		Message:    strings.TrimSuffix(e.Message, "\n"),
+		Stack:      e.Stack,
+	}
+	if ent.Time.IsZero() {
+		ent.Time = time.Now()
+	}
+	if e.File != "" {
+		ent.Caller = zapcore.EntryCaller{
+			Defined: true, File: e.File, Line: e.Line, Function: e.Function,
+		}
+	}
+
+	ce := w.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]zapcore.Field, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, zap.Any(k, e.Fields[k]))
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+// Write - Forwards a raw log line to the zap logger as a message of the info level.
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.WriteEntry(&log.Entry{Level: "INFO", Message: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//--------------------------------------------------------------------------------------------------
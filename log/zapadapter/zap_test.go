@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package zapadapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jeffail/util/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCore(t *testing.T) {
+	loggerConfig := log.NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+	loggerConfig.LogLevel = "INFO"
+
+	var buf bytes.Buffer
+
+	z := zap.New(NewCore(log.NewLogger(&buf, loggerConfig).(*log.Logger)))
+	z.Debug("Hidden")
+	z.With(zap.Int("a", 1)).Info("Info message", zap.String("b", "two words"))
+	z.Named("db").Error("Error message", zap.String("error", "broken"))
+
+	expected := "INFO | root | Info message a=1 b=\"two words\"\n" +
+		"ERROR | root | Error message error=broken logger=db\n"
+	if act := buf.String(); expected != act {
+		t.Errorf("Core output does not match: %v != %v", act, expected)
+	}
+	if err := z.Sync(); err != nil {
+		t.Error(err)
+	}
+}
+
+type captureCore struct {
+	zapcore.LevelEnabler
+	entries []zapcore.Entry
+	fields  [][]zapcore.Field
+}
+
+func (c *captureCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *captureCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *captureCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.entries = append(c.entries, ent)
+	c.fields = append(c.fields, fields)
+	return nil
+}
+
+func (c *captureCore) Sync() error {
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	capture := &captureCore{LevelEnabler: zapcore.WarnLevel}
+
+	loggerConfig := log.NewLoggerConfig()
+	loggerConfig.AddTimeStamp = false
+	loggerConfig.Prefix = "root"
+
+	logger := log.NewLogger(NewWriter(zap.New(capture)), loggerConfig).(*log.Logger)
+	logger = logger.WithFields(map[string]interface{}{"b": 2, "a": 1})
+	logger.Infoln("Hidden")
+	logger.Errorf("Error message\n")
+	logger.Fatalln("Fatal message")
+
+	if len(capture.entries) != 2 {
+		t.Fatalf("Wrong count of entries: %v != 2", len(capture.entries))
+	}
+	e := capture.entries[0]
+	if e.Level != zapcore.ErrorLevel || e.Message != "Error message" || e.LoggerName != "root" {
+		t.Errorf("Wrong entry: %v %v %v", e.Level, e.Message, e.LoggerName)
+	}
+	if f := capture.fields[0]; len(f) != 2 || f[0].Key != "a" || f[1].Key != "b" {
+		t.Errorf("Wrong entry fields: %v", f)
+	}
+	if e := capture.entries[1]; e.Level != zapcore.FatalLevel || e.Message != "Fatal message" {
+		t.Errorf("Wrong entry: %v %v", e.Level, e.Message)
+	}
+}
@@ -0,0 +1,414 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+This file implements the subset of AMQP 0-9-1 needed to publish messages with publisher confirms
+without a client library: the connection handshake with PLAIN authentication, a single channel in
+confirm mode, and Basic.Publish with content header and body frames. Heartbeats are disabled, and
+consumers, transactions and TLS are not supported.
+*/
+
+// AMQP frame types and the frame terminator.
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xCE
+)
+
+// AMQP class and method ids, combined as class<<16 | method.
+const (
+	amqpConnectionStart   = 10<<16 | 10
+	amqpConnectionStartOk = 10<<16 | 11
+	amqpConnectionTune    = 10<<16 | 30
+	amqpConnectionTuneOk  = 10<<16 | 31
+	amqpConnectionOpen    = 10<<16 | 40
+	amqpConnectionOpenOk  = 10<<16 | 41
+	amqpConnectionClose   = 10<<16 | 50
+	amqpConnectionCloseOk = 10<<16 | 51
+	amqpChannelOpen       = 20<<16 | 10
+	amqpChannelOpenOk     = 20<<16 | 11
+	amqpChannelClose      = 20<<16 | 40
+	amqpBasicPublish      = 60<<16 | 40
+	amqpBasicAck          = 60<<16 | 80
+	amqpBasicNack         = 60<<16 | 120
+	amqpConfirmSelect     = 85<<16 | 10
+	amqpConfirmSelectOk   = 85<<16 | 11
+)
+
+// Errors for the AMQP protocol.
+var (
+	ErrAMQPFrame = errors.New("amqp frame was malformed")
+	ErrAMQPNack  = errors.New("amqp broker rejected a published message")
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// amqpArgs - Encodes the arguments of a method.
+type amqpArgs struct {
+	bytes.Buffer
+}
+
+func (a *amqpArgs) octet(v uint8) *amqpArgs {
+	a.WriteByte(v)
+	return a
+}
+
+func (a *amqpArgs) short(v uint16) *amqpArgs {
+	binary.Write(a, binary.BigEndian, v)
+	return a
+}
+
+func (a *amqpArgs) long(v uint32) *amqpArgs {
+	binary.Write(a, binary.BigEndian, v)
+	return a
+}
+
+func (a *amqpArgs) longlong(v uint64) *amqpArgs {
+	binary.Write(a, binary.BigEndian, v)
+	return a
+}
+
+func (a *amqpArgs) shortstr(v string) *amqpArgs {
+	a.WriteByte(byte(len(v)))
+	a.WriteString(v)
+	return a
+}
+
+func (a *amqpArgs) longstr(v string) *amqpArgs {
+	a.long(uint32(len(v)))
+	a.WriteString(v)
+	return a
+}
+
+// table - Encodes a field table of string values.
+func (a *amqpArgs) table(fields map[string]string) *amqpArgs {
+	var t amqpArgs
+	for _, k := range sortedKeys(fields) {
+		t.shortstr(k).octet('S').longstr(fields[k])
+	}
+	a.long(uint32(t.Len()))
+	a.Write(t.Bytes())
+	return a
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// amqpFrame - A frame read from the broker.
+type amqpFrame struct {
+	frameType uint8
+	channel   uint16
+	payload   []byte
+}
+
+// method - Returns the combined class and method id and the arguments of a method frame.
+func (f amqpFrame) method() (uint32, []byte, error) {
+	if f.frameType != amqpFrameMethod || len(f.payload) < 4 {
+		return 0, nil, ErrAMQPFrame
+	}
+	return binary.BigEndian.Uint32(f.payload), f.payload[4:], nil
+}
+
+// amqpCloseReason - Decodes the reply code and text of a Connection.Close or Channel.Close.
+func amqpCloseReason(args []byte) error {
+	if len(args) < 3 || len(args) < 3+int(args[2]) {
+		return ErrAMQPFrame
+	}
+	code := binary.BigEndian.Uint16(args)
+	return fmt.Errorf("amqp broker closed with %v: %s", code, args[3:3+int(args[2])])
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// amqpConn - A connection to a broker with a single channel in confirm mode. An amqpConn is not
+// safe for concurrent use.
+type amqpConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	timeout  time.Duration
+	frameMax uint32
+
+	// The delivery tag of the last published message.
+	published uint64
+}
+
+// dialAMQP - Connects to a broker, authenticates with PLAIN and opens a channel in confirm mode.
+func dialAMQP(address, vhost, username, password string, timeout time.Duration) (*amqpConn, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &amqpConn{
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+		timeout:  timeout,
+		frameMax: 131072,
+	}
+	if err = c.handshake(vhost, username, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake - Performs the connection handshake and opens channel 1.
+func (c *amqpConn) handshake(vhost, username, password string) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write([]byte("AMQP\x00\x00\x09\x01")); err != nil {
+		return err
+	}
+	if _, err := c.expect(amqpConnectionStart); err != nil {
+		return err
+	}
+
+	startOk := (&amqpArgs{}).
+		table(map[string]string{"product": "benthos"}).
+		shortstr("PLAIN").
+		longstr("\x00" + username + "\x00" + password).
+		shortstr("en_US")
+	if err := c.writeMethod(0, amqpConnectionStartOk, startOk.Bytes()); err != nil {
+		return err
+	}
+
+	tune, err := c.expect(amqpConnectionTune)
+	if err != nil {
+		return err
+	}
+	if len(tune) < 8 {
+		return ErrAMQPFrame
+	}
+	channelMax := binary.BigEndian.Uint16(tune)
+	if frameMax := binary.BigEndian.Uint32(tune[2:]); frameMax > 0 && frameMax < c.frameMax {
+		c.frameMax = frameMax
+	}
+	tuneOk := (&amqpArgs{}).short(channelMax).long(c.frameMax).short(0)
+	if err = c.writeMethod(0, amqpConnectionTuneOk, tuneOk.Bytes()); err != nil {
+		return err
+	}
+
+	open := (&amqpArgs{}).shortstr(vhost).shortstr("").octet(0)
+	if err = c.writeMethod(0, amqpConnectionOpen, open.Bytes()); err != nil {
+		return err
+	}
+	if _, err = c.expect(amqpConnectionOpenOk); err != nil {
+		return err
+	}
+
+	if err = c.writeMethod(1, amqpChannelOpen, (&amqpArgs{}).shortstr("").Bytes()); err != nil {
+		return err
+	}
+	if _, err = c.expect(amqpChannelOpenOk); err != nil {
+		return err
+	}
+	if err = c.writeMethod(1, amqpConfirmSelect, (&amqpArgs{}).octet(0).Bytes()); err != nil {
+		return err
+	}
+	_, err = c.expect(amqpConfirmSelectOk)
+	return err
+}
+
+// writeFrame - Writes a single frame.
+func (c *amqpConn) writeFrame(buf *bytes.Buffer, frameType uint8, channel uint16, payload []byte) {
+	buf.WriteByte(frameType)
+	binary.Write(buf, binary.BigEndian, channel)
+	binary.Write(buf, binary.BigEndian, uint32(len(payload)))
+	buf.Write(payload)
+	buf.WriteByte(amqpFrameEnd)
+}
+
+// writeMethod - Writes a method frame to the connection.
+func (c *amqpConn) writeMethod(channel uint16, method uint32, args []byte) error {
+	var buf bytes.Buffer
+	payload := make([]byte, 4, 4+len(args))
+	binary.BigEndian.PutUint32(payload, method)
+	c.writeFrame(&buf, amqpFrameMethod, channel, append(payload, args...))
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+// readFrame - Reads the next frame, skipping heartbeats.
+func (c *amqpConn) readFrame() (amqpFrame, error) {
+	for {
+		header := make([]byte, 7)
+		if _, err := io.ReadFull(c.r, header); err != nil {
+			return amqpFrame{}, err
+		}
+		size := binary.BigEndian.Uint32(header[3:])
+		if size > c.frameMax {
+			return amqpFrame{}, ErrAMQPFrame
+		}
+		payload := make([]byte, size+1)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return amqpFrame{}, err
+		}
+		if payload[size] != amqpFrameEnd {
+			return amqpFrame{}, ErrAMQPFrame
+		}
+		if header[0] == amqpFrameHeartbeat {
+			continue
+		}
+		return amqpFrame{
+			frameType: header[0],
+			channel:   binary.BigEndian.Uint16(header[1:]),
+			payload:   payload[:size],
+		}, nil
+	}
+}
+
+// readMethod - Reads the next method, returning an error when the broker closes the connection or
+// channel.
+func (c *amqpConn) readMethod() (uint32, []byte, error) {
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		if f.frameType != amqpFrameMethod {
+			// Content of returned messages is ignored.
+			continue
+		}
+		method, args, err := f.method()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch method {
+		case amqpConnectionClose:
+			c.writeMethod(0, amqpConnectionCloseOk, nil)
+			return 0, nil, amqpCloseReason(args)
+		case amqpChannelClose:
+			return 0, nil, amqpCloseReason(args)
+		}
+		return method, args, nil
+	}
+}
+
+// expect - Reads the next method, which must be of the expected id.
+func (c *amqpConn) expect(method uint32) ([]byte, error) {
+	actual, args, err := c.readMethod()
+	if err != nil {
+		return nil, err
+	}
+	if actual != method {
+		return nil, fmt.Errorf("amqp broker sent unexpected method: %v.%v", actual>>16, actual&0xFFFF)
+	}
+	return args, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// amqpPublishing - A message to publish.
+type amqpPublishing struct {
+	routingKey string
+	body       []byte
+}
+
+// publish - Publishes messages to an exchange and waits for the broker to confirm all of them.
+func (c *amqpConn) publish(exchange string, persistent bool, messages []amqpPublishing) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	deliveryMode := uint8(1)
+	if persistent {
+		deliveryMode = 2
+	}
+
+	var buf bytes.Buffer
+	for _, m := range messages {
+		publish := (&amqpArgs{}).short(0).shortstr(exchange).shortstr(m.routingKey).octet(0)
+		payload := make([]byte, 4, 4+publish.Len())
+		binary.BigEndian.PutUint32(payload, amqpBasicPublish)
+		c.writeFrame(&buf, amqpFrameMethod, 1, append(payload, publish.Bytes()...))
+
+		// Content header with the content-type and delivery-mode properties.
+		header := (&amqpArgs{}).short(60).short(0).longlong(uint64(len(m.body))).
+			short(0x8000 | 0x1000).shortstr("application/json").octet(deliveryMode)
+		c.writeFrame(&buf, amqpFrameHeader, 1, header.Bytes())
+
+		maxBody := int(c.frameMax) - 8
+		for body := m.body; len(body) > 0; {
+			n := len(body)
+			if n > maxBody {
+				n = maxBody
+			}
+			c.writeFrame(&buf, amqpFrameBody, 1, body[:n])
+			body = body[n:]
+		}
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	first := c.published + 1
+	c.published += uint64(len(messages))
+	for remaining := uint64(len(messages)); remaining > 0; {
+		method, args, err := c.readMethod()
+		if err != nil {
+			return err
+		}
+		if method != amqpBasicAck && method != amqpBasicNack {
+			continue
+		}
+		if len(args) < 9 {
+			return ErrAMQPFrame
+		}
+		tag, multiple := binary.BigEndian.Uint64(args), args[8]&1 == 1
+		if method == amqpBasicNack && tag >= first {
+			return ErrAMQPNack
+		}
+		if tag < first {
+			continue
+		}
+		if multiple {
+			remaining = c.published - tag
+		} else {
+			remaining--
+		}
+	}
+	return nil
+}
+
+// close - Closes the connection, attempting a graceful Connection.Close first.
+func (c *amqpConn) close() error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	args := (&amqpArgs{}).short(200).shortstr("").short(0).short(0)
+	if err := c.writeMethod(0, amqpConnectionClose, args.Bytes()); err == nil {
+		c.expect(amqpConnectionCloseOk)
+	}
+	return c.conn.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
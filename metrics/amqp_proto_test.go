@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// amqpReceived - A message published to a fake broker.
+type amqpReceived struct {
+	exchange   string
+	routingKey string
+	header     []byte
+	body       []byte
+}
+
+// serveAMQP - Runs a fake broker that completes the handshake, records the PLAIN response and
+// published messages, and confirms each message with an ack, or with a nack when nack is true.
+func serveAMQP(t *testing.T, nack bool) (string, <-chan string, <-chan amqpReceived) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on tcp: %v", err)
+	}
+	auths := make(chan string, 1)
+	received := make(chan amqpReceived, 10)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		c := &amqpConn{conn: conn, r: bufio.NewReader(conn), timeout: time.Second, frameMax: 131072}
+		protocol := make([]byte, 8)
+		if _, err = io.ReadFull(c.r, protocol); err != nil || string(protocol) != "AMQP\x00\x00\x09\x01" {
+			t.Errorf("Wrong protocol header: %q", protocol)
+			return
+		}
+		start := (&amqpArgs{}).octet(0).octet(9).table(nil).longstr("PLAIN").longstr("en_US")
+		c.writeMethod(0, amqpConnectionStart, start.Bytes())
+
+		tag := uint64(0)
+		var pending *amqpReceived
+		for {
+			f, err := c.readFrame()
+			if err != nil {
+				return
+			}
+			switch f.frameType {
+			case amqpFrameHeader:
+				pending.header = f.payload
+				continue
+			case amqpFrameBody:
+				pending.body = append(pending.body, f.payload...)
+				if uint64(len(pending.body)) == binary.BigEndian.Uint64(pending.header[4:]) {
+					received <- *pending
+					tag++
+					ack := (&amqpArgs{}).longlong(tag).octet(0)
+					if nack {
+						c.writeMethod(1, amqpBasicNack, ack.Bytes())
+					} else {
+						c.writeMethod(1, amqpBasicAck, ack.Bytes())
+					}
+				}
+				continue
+			}
+
+			method, args, _ := f.method()
+			switch method {
+			case amqpConnectionStartOk:
+				tableLen := binary.BigEndian.Uint32(args)
+				args = args[4+tableLen:]
+				args = args[1+int(args[0]):] // mechanism
+				auths <- string(args[4 : 4+binary.BigEndian.Uint32(args)])
+				tune := (&amqpArgs{}).short(0).long(4096).short(60)
+				c.writeMethod(0, amqpConnectionTune, tune.Bytes())
+			case amqpConnectionOpen:
+				c.writeMethod(0, amqpConnectionOpenOk, (&amqpArgs{}).shortstr("").Bytes())
+			case amqpChannelOpen:
+				c.writeMethod(1, amqpChannelOpenOk, (&amqpArgs{}).longstr("").Bytes())
+			case amqpConfirmSelect:
+				c.writeMethod(1, amqpConfirmSelectOk, nil)
+			case amqpBasicPublish:
+				exchange := string(args[3 : 3+int(args[2])])
+				rest := args[3+int(args[2]):]
+				pending = &amqpReceived{exchange: exchange, routingKey: string(rest[1 : 1+int(rest[0])])}
+			case amqpConnectionClose:
+				c.writeMethod(0, amqpConnectionCloseOk, nil)
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), auths, received
+}
+
+func TestAMQPConnPublish(t *testing.T) {
+	addr, auths, received := serveAMQP(t, false)
+
+	c, err := dialAMQP(addr, "/", "foo", "bar", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "\x00foo\x00bar", <-auths; exp != act {
+		t.Errorf("Wrong auth: %q != %q", exp, act)
+	}
+	if exp, act := uint32(4096), c.frameMax; exp != act {
+		t.Errorf("Wrong frame max: %v != %v", exp, act)
+	}
+
+	large := bytes.Repeat([]byte("x"), 10000)
+	err = c.publish("ex", true, []amqpPublishing{{"a", []byte("foo")}, {"b", large}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := <-received; m.exchange != "ex" || m.routingKey != "a" || string(m.body) != "foo" {
+		t.Errorf("Wrong message: %+v", m)
+	}
+	if m := <-received; m.routingKey != "b" || !bytes.Equal(m.body, large) {
+		t.Errorf("Wrong large message: %v", len(m.body))
+	}
+	if err = c.close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAMQPConnNack(t *testing.T) {
+	addr, _, _ := serveAMQP(t, true)
+
+	c, err := dialAMQP(addr, "/", "guest", "guest", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+
+	if err = c.publish("ex", false, []amqpPublishing{{"a", []byte("foo")}}); err != ErrAMQPNack {
+		t.Errorf("Expected nack error: %v", err)
+	}
+}
+
+func TestAMQPCloseReason(t *testing.T) {
+	args := (&amqpArgs{}).short(404).shortstr("NOT_FOUND - no exchange").short(60).short(40)
+	err := amqpCloseReason(args.Bytes())
+	if exp := "amqp broker closed with 404: NOT_FOUND - no exchange"; err == nil || exp != err.Error() {
+		t.Errorf("Wrong error: %v != %v", exp, err)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
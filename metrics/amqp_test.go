@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestAMQPSinkFlush(t *testing.T) {
+	addr, auths, received := serveAMQP(t, false)
+
+	conf := NewConfig()
+	conf.AMQP.URL = "amqp://foo:bar@" + addr + "/stats"
+
+	sink, err := NewAMQPSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if exp, act := "stats", sink.(*AMQPSink).vhost; exp != act {
+		t.Errorf("Wrong vhost: %v != %v", exp, act)
+	}
+
+	err = sink.Flush(Snapshot{
+		Time:    time.Unix(100, 0),
+		Gauges:  map[string]int64{Tagged("queue", map[string]string{"name": "a"}): 3},
+		Timings: map[string][]int64{"latency": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "\x00foo\x00bar", <-auths; exp != act {
+		t.Errorf("Wrong auth: %q != %q", exp, act)
+	}
+
+	for _, exp := range []string{"benthos.stats.timing.latency", "benthos.stats.gauge.queue"} {
+		m := <-received
+		if m.exchange != "amq.topic" || m.routingKey != exp {
+			t.Errorf("Wrong exchange or routing key: %v %v != %v", m.exchange, m.routingKey, exp)
+		}
+		var doc metricDocument
+		if err = json.Unmarshal(m.body, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Type == KindGauge && (doc.Tags["name"] != "a" || *doc.Value != 3) {
+			t.Errorf("Wrong gauge document: %+v", doc)
+		}
+	}
+}
+
+func TestAMQPSinkURL(t *testing.T) {
+	conf := NewConfig()
+	conf.AMQP.URL = "amqps://localhost"
+	if _, err := NewAMQPSink(conf); err == nil {
+		t.Error("Expected error from unsupported scheme")
+	}
+
+	conf.AMQP.URL = "amqp://localhost"
+	sink, err := NewAMQPSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := sink.(*AMQPSink); a.address != "localhost:5672" || a.vhost != "/" || a.username != "guest" {
+		t.Errorf("Wrong defaults: %v %v %v", a.address, a.vhost, a.username)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,397 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["azure_monitor"] = sinkSpec{
+		constructor: NewAzureMonitorSink,
+		description: `
+Posts snapshots to Azure Monitor. When 'auth' is "managed_identity" stats are
+posted to the custom metrics API of the resource at resource_id, authenticating
+with a token for the managed identity of the host from the instance metadata
+service, where an empty client_id selects the system assigned identity. The
+region and resource id are also read from the instance metadata service when
+left empty. When 'auth' is "instrumentation_key" stats are instead tracked as
+metrics of the Application Insights resource of the key.
+
+Counters are posted as the delta of each flush, gauges as their value and
+timings as aggregates of their count, sum, min and max. The dimensions of the
+config and the tags of stats created with metrics.Tagged become dimensions of
+custom metrics, or properties of Application Insights metrics.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// AzureMonitorConfig - Config for the Azure Monitor sink.
+type AzureMonitorConfig struct {
+	Auth               string            `json:"auth" yaml:"auth"`
+	Region             string            `json:"region" yaml:"region"`
+	ResourceID         string            `json:"resource_id" yaml:"resource_id"`
+	ClientID           string            `json:"client_id" yaml:"client_id"`
+	Namespace          string            `json:"namespace" yaml:"namespace"`
+	InstrumentationKey string            `json:"instrumentation_key" yaml:"instrumentation_key"`
+	IngestionURL       string            `json:"ingestion_url" yaml:"ingestion_url"`
+	Dimensions         map[string]string `json:"dimensions" yaml:"dimensions"`
+	Timeout            string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewAzureMonitorConfig - Creates an AzureMonitorConfig struct with default values.
+func NewAzureMonitorConfig() AzureMonitorConfig {
+	return AzureMonitorConfig{
+		Auth:               "managed_identity",
+		Region:             "",
+		ResourceID:         "",
+		ClientID:           "",
+		Namespace:          "benthos",
+		InstrumentationKey: "",
+		IngestionURL:       "https://dc.services.visualstudio.com/v2/track",
+		Dimensions:         map[string]string{},
+		Timeout:            "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// azureIMDSURL - The base URL of the Azure instance metadata service.
+const azureIMDSURL = "http://169.254.169.254/metadata/"
+
+// azureMonitorResource - The audience of tokens for the custom metrics API.
+const azureMonitorResource = "https://monitoring.azure.com/"
+
+// AzureMonitorSink - A Sink that posts snapshots to Azure Monitor custom metrics or Application
+// Insights.
+type AzureMonitorSink struct {
+	sync.Mutex
+
+	conf   AzureMonitorConfig
+	imds   func(path string) ([]byte, error)
+	token  *tokenSource
+	sender func(url string, body []byte, headers map[string]string) error
+
+	metricsURL string
+}
+
+// NewAzureMonitorSink - Create a new Azure Monitor sink.
+func NewAzureMonitorSink(config Config) (Sink, error) {
+	conf := config.AzureMonitor
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	a := &AzureMonitorSink{
+		conf: conf,
+		imds: func(path string) ([]byte, error) {
+			return doHTTP(client, "GET", azureIMDSURL+path, nil, map[string]string{"Metadata": "true"})
+		},
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}
+	switch conf.Auth {
+	case "managed_identity":
+		if len(conf.Namespace) == 0 {
+			return nil, fmt.Errorf("azure monitor namespace must not be empty")
+		}
+		a.token = &tokenSource{fetch: a.managedIdentityToken}
+	case "instrumentation_key":
+		if len(conf.InstrumentationKey) == 0 {
+			return nil, fmt.Errorf("azure monitor instrumentation key must not be empty")
+		}
+	default:
+		return nil, fmt.Errorf("azure monitor auth not recognised: %v", conf.Auth)
+	}
+	return a, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// managedIdentityToken - Fetches a token for the custom metrics API from the instance metadata
+// service.
+func (a *AzureMonitorSink) managedIdentityToken() (accessToken, error) {
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {azureMonitorResource},
+	}
+	if len(a.conf.ClientID) > 0 {
+		query.Set("client_id", a.conf.ClientID)
+	}
+	var t accessToken
+	body, err := a.imds("identity/oauth2/token?" + query.Encode())
+	if err == nil {
+		err = json.Unmarshal(body, &t)
+	}
+	return t, err
+}
+
+// resolveMetricsURL - Determines the custom metrics URL of the resource, reading the region and
+// resource id from the instance metadata service where they are not configured.
+func (a *AzureMonitorSink) resolveMetricsURL() error {
+	if len(a.metricsURL) > 0 {
+		return nil
+	}
+	region, resourceID := a.conf.Region, a.conf.ResourceID
+	if len(region) == 0 || len(resourceID) == 0 {
+		body, err := a.imds("instance/compute?api-version=2021-02-01")
+		if err != nil {
+			return fmt.Errorf("azure monitor region and resource id must be configured off Azure: %v", err)
+		}
+		var compute struct {
+			Location   string `json:"location"`
+			ResourceID string `json:"resourceId"`
+		}
+		if err = json.Unmarshal(body, &compute); err != nil {
+			return err
+		}
+		if len(region) == 0 {
+			region = compute.Location
+		}
+		if len(resourceID) == 0 {
+			resourceID = compute.ResourceID
+		}
+	}
+	a.metricsURL = "https://" + region + ".monitoring.azure.com/" +
+		strings.TrimPrefix(resourceID, "/") + "/metrics"
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// azureAggregate - The aggregate of the values of a stat over a flush.
+type azureAggregate struct {
+	name  string
+	tags  map[string]string
+	count int64
+	sum   int64
+	min   int64
+	max   int64
+}
+
+// azureAggregates - Converts a snapshot into aggregates for each stat.
+func (a *AzureMonitorSink) azureAggregates(snap Snapshot) []azureAggregate {
+	aggs := []azureAggregate{}
+	for _, path := range snap.Paths() {
+		name, pathTags := splitTags(path)
+		tags := mergeTags(a.conf.Dimensions, pathTags)
+		value := func(v int64) {
+			aggs = append(aggs, azureAggregate{name: name, tags: tags, count: 1, sum: v, min: v, max: v})
+		}
+
+		if delta, ok := snap.CounterDeltas[path]; ok {
+			value(delta)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			value(v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			sum := SummariseTimings(samples)
+			aggs = append(aggs, azureAggregate{
+				name: name, tags: tags, count: sum.Count, sum: sum.Sum, min: sum.Min, max: sum.Max,
+			})
+		}
+	}
+	return aggs
+}
+
+type (
+	azureCustomMetric struct {
+		Time string          `json:"time"`
+		Data azureCustomData `json:"data"`
+	}
+	azureCustomData struct {
+		BaseData azureCustomBaseData `json:"baseData"`
+	}
+	azureCustomBaseData struct {
+		Metric    string              `json:"metric"`
+		Namespace string              `json:"namespace"`
+		DimNames  []string            `json:"dimNames,omitempty"`
+		Series    []azureCustomSeries `json:"series"`
+	}
+	azureCustomSeries struct {
+		DimValues []string `json:"dimValues,omitempty"`
+		Min       int64    `json:"min"`
+		Max       int64    `json:"max"`
+		Sum       int64    `json:"sum"`
+		Count     int64    `json:"count"`
+	}
+)
+
+// azureCustomMetrics - Groups aggregates into custom metric documents, where every series of a
+// document must share a metric name and the names of its dimensions.
+func (a *AzureMonitorSink) azureCustomMetrics(snap Snapshot) []azureCustomMetric {
+	ts := snap.Time.UTC().Format(time.RFC3339)
+
+	metrics := []azureCustomMetric{}
+	index := map[string]int{}
+	for _, agg := range a.azureAggregates(snap) {
+		dims := sortedKeys(agg.tags)
+		key := agg.name + "\x00" + strings.Join(dims, "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(metrics)
+			index[key] = i
+			metrics = append(metrics, azureCustomMetric{
+				Time: ts,
+				Data: azureCustomData{BaseData: azureCustomBaseData{
+					Metric:    agg.name,
+					Namespace: a.conf.Namespace,
+					DimNames:  dims,
+				}},
+			})
+		}
+		series := azureCustomSeries{Min: agg.min, Max: agg.max, Sum: agg.sum, Count: agg.count}
+		for _, k := range dims {
+			series.DimValues = append(series.DimValues, agg.tags[k])
+		}
+		base := &metrics[i].Data.BaseData
+		base.Series = append(base.Series, series)
+	}
+	return metrics
+}
+
+type (
+	azureEnvelope struct {
+		Name string            `json:"name"`
+		Time string            `json:"time"`
+		IKey string            `json:"iKey"`
+		Data azureEnvelopeData `json:"data"`
+	}
+	azureEnvelopeData struct {
+		BaseType string              `json:"baseType"`
+		BaseData azureInsightsMetric `json:"baseData"`
+	}
+	azureInsightsMetric struct {
+		Ver        int                  `json:"ver"`
+		Metrics    []azureInsightsPoint `json:"metrics"`
+		Properties map[string]string    `json:"properties,omitempty"`
+	}
+	azureInsightsPoint struct {
+		Name  string `json:"name"`
+		Kind  int    `json:"kind"`
+		Value int64  `json:"value"`
+		Count int64  `json:"count"`
+		Min   int64  `json:"min"`
+		Max   int64  `json:"max"`
+	}
+)
+
+// azureEnvelopes - Converts a snapshot into Application Insights metric envelopes.
+func (a *AzureMonitorSink) azureEnvelopes(snap Snapshot) []azureEnvelope {
+	ts := snap.Time.UTC().Format(time.RFC3339Nano)
+	ikey := strings.Replace(a.conf.InstrumentationKey, "-", "", -1)
+	name := "Microsoft.ApplicationInsights." + ikey + ".Metric"
+
+	envelopes := []azureEnvelope{}
+	for _, agg := range a.azureAggregates(snap) {
+		kind := 0
+		if agg.count > 1 {
+			kind = 1
+		}
+		envelopes = append(envelopes, azureEnvelope{
+			Name: name,
+			Time: ts,
+			IKey: a.conf.InstrumentationKey,
+			Data: azureEnvelopeData{
+				BaseType: "MetricData",
+				BaseData: azureInsightsMetric{
+					Ver: 2,
+					Metrics: []azureInsightsPoint{{
+						Name:  agg.name,
+						Kind:  kind,
+						Value: agg.sum,
+						Count: agg.count,
+						Min:   agg.min,
+						Max:   agg.max,
+					}},
+					Properties: agg.tags,
+				},
+			},
+		})
+	}
+	return envelopes
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Flush - Posts a snapshot to Azure Monitor, with a request for each custom metric or a single
+// request of all Application Insights metrics.
+func (a *AzureMonitorSink) Flush(snap Snapshot) error {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.token == nil {
+		envelopes := a.azureEnvelopes(snap)
+		if len(envelopes) == 0 {
+			return nil
+		}
+		body, err := json.Marshal(envelopes)
+		if err != nil {
+			return err
+		}
+		return a.sender(a.conf.IngestionURL, body, map[string]string{"Content-Type": "application/json"})
+	}
+
+	metrics := a.azureCustomMetrics(snap)
+	if len(metrics) == 0 {
+		return nil
+	}
+	if err := a.resolveMetricsURL(); err != nil {
+		return err
+	}
+	token, err := a.token.get(time.Now())
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": "Bearer " + token,
+	}
+	for _, m := range metrics {
+		body, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err = a.sender(a.metricsURL, body, headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (a *AzureMonitorSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
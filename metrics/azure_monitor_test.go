@@ -0,0 +1,175 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestAzureMonitorSinkCustomMetrics(t *testing.T) {
+	conf := NewConfig()
+	conf.AzureMonitor.Dimensions = map[string]string{"env": "prod"}
+
+	sink, err := NewAzureMonitorSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := sink.(*AzureMonitorSink)
+
+	var imdsPaths []string
+	a.imds = func(path string) ([]byte, error) {
+		imdsPaths = append(imdsPaths, path)
+		if strings.HasPrefix(path, "identity/") {
+			return []byte(`{"access_token":"tok","expires_in":"3599"}`), nil
+		}
+		return []byte(`{"location":"westeurope","resourceId":"/subscriptions/s/resourceGroups/g/providers/Microsoft.Compute/virtualMachines/vm"}`), nil
+	}
+	var sentURL string
+	var sent []string
+	var sentHeaders map[string]string
+	a.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentHeaders = url, headers
+		sent = append(sent, string(body))
+		return nil
+	}
+
+	err = a.Flush(Snapshot{
+		Time: time.Unix(100, 0),
+		CounterDeltas: map[string]int64{
+			Tagged("requests", map[string]string{"code": "200"}): 4,
+			Tagged("requests", map[string]string{"code": "500"}): 1,
+		},
+		Counters: map[string]int64{
+			Tagged("requests", map[string]string{"code": "200"}): 9,
+			Tagged("requests", map[string]string{"code": "500"}): 1,
+		},
+		Timings: map[string][]int64{"latency": {2, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "https://westeurope.monitoring.azure.com/subscriptions/s/resourceGroups/g/providers/Microsoft.Compute/virtualMachines/vm/metrics", sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	if exp, act := "Bearer tok", sentHeaders["Authorization"]; exp != act {
+		t.Errorf("Wrong authorization: %v != %v", exp, act)
+	}
+	if exp, act := "identity/oauth2/token?api-version=2018-02-01&resource=https%3A%2F%2Fmonitoring.azure.com%2F", imdsPaths[1]; exp != act {
+		t.Errorf("Wrong token path: %v != %v", exp, act)
+	}
+
+	exp := []string{
+		`{"time":"1970-01-01T00:01:40Z","data":{"baseData":{"metric":"latency","namespace":"benthos",` +
+			`"dimNames":["env"],"series":[{"dimValues":["prod"],"min":2,"max":4,"sum":6,"count":2}]}}}`,
+		`{"time":"1970-01-01T00:01:40Z","data":{"baseData":{"metric":"requests","namespace":"benthos",` +
+			`"dimNames":["code","env"],"series":[{"dimValues":["200","prod"],"min":4,"max":4,"sum":4,"count":1},` +
+			`{"dimValues":["500","prod"],"min":1,"max":1,"sum":1,"count":1}]}}}`,
+	}
+	if len(sent) != len(exp) {
+		t.Fatalf("Wrong count of requests: %v != %v", len(exp), len(sent))
+	}
+	for i := range exp {
+		if exp[i] != sent[i] {
+			t.Errorf("Wrong body %v:\n%v\n!=\n%v", i, exp[i], sent[i])
+		}
+	}
+
+	imdsPaths = nil
+	if err = a.Flush(Snapshot{Time: time.Unix(110, 0), Gauges: map[string]int64{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(imdsPaths) != 0 {
+		t.Errorf("Expected resource and token to be cached: %v", imdsPaths)
+	}
+}
+
+func TestAzureMonitorSinkNoIMDS(t *testing.T) {
+	sink, err := NewAzureMonitorSink(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := sink.(*AzureMonitorSink)
+	a.imds = func(path string) ([]byte, error) {
+		return nil, errors.New("unreachable")
+	}
+	if err = a.Flush(Snapshot{Gauges: map[string]int64{"a": 1}}); err == nil {
+		t.Error("Expected error without a region and resource id")
+	}
+}
+
+func TestAzureMonitorSinkInsights(t *testing.T) {
+	conf := NewConfig()
+	conf.AzureMonitor.Auth = "instrumentation_key"
+	conf.AzureMonitor.InstrumentationKey = "ab-cd"
+
+	sink, err := NewAzureMonitorSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := sink.(*AzureMonitorSink)
+
+	var sentURL, sentBody string
+	a.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentBody = url, string(body)
+		return nil
+	}
+	err = a.Flush(Snapshot{
+		Time:    time.Unix(100, 0),
+		Gauges:  map[string]int64{Tagged("queue", map[string]string{"name": "a"}): 3},
+		Timings: map[string][]int64{"latency": {2, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "https://dc.services.visualstudio.com/v2/track"; exp != sentURL {
+		t.Errorf("Wrong url: %v != %v", exp, sentURL)
+	}
+	envelope := `{"name":"Microsoft.ApplicationInsights.abcd.Metric","time":"1970-01-01T00:01:40Z","iKey":"ab-cd",` +
+		`"data":{"baseType":"MetricData","baseData":{"ver":2,"metrics":`
+	exp := `[` + envelope + `[{"name":"latency","kind":1,"value":6,"count":2,"min":2,"max":4}]}}},` +
+		envelope + `[{"name":"queue","kind":0,"value":3,"count":1,"min":3,"max":3}],"properties":{"name":"a"}}}}]`
+	if exp != sentBody {
+		t.Errorf("Wrong body:\n%v\n!=\n%v", exp, sentBody)
+	}
+}
+
+func TestAzureMonitorSinkBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.AzureMonitor.Auth = "instrumentation_key"
+	if _, err := NewAzureMonitorSink(conf); err == nil {
+		t.Error("Expected error from missing instrumentation key")
+	}
+	conf.AzureMonitor.Auth = "nope"
+	if _, err := NewAzureMonitorSink(conf); err == nil {
+		t.Error("Expected error from bad auth")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
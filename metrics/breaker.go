@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// BreakerState - The state of a circuit breaker.
+type BreakerState int
+
+// Circuit breaker states, a closed breaker allows all attempts, an open breaker allows none until the
+// probe interval has passed, at which point it is half open and allows a single probe attempt.
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+// String - Returns a human readable name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	}
+	return "unknown"
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// BreakerConfig - Configuration fields for a circuit breaker. A threshold of zero disables the breaker.
+type BreakerConfig struct {
+	Threshold     int    `json:"threshold" yaml:"threshold"`
+	ProbeInterval string `json:"probe_interval" yaml:"probe_interval"`
+}
+
+// NewBreakerConfig - Create a new circuit breaker config with default values.
+func NewBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Threshold:     5,
+		ProbeInterval: "10s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+breaker - A circuit breaker that opens after a threshold of consecutive failures, refusing attempts
+until the probe interval has passed. The first attempt after that is a probe, when it succeeds the
+breaker closes and when it fails the breaker opens again for another probe interval. A breaker is
+not safe for concurrent use and must be guarded by its owner.
+*/
+type breaker struct {
+	threshold     int
+	probeInterval time.Duration
+
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+// newBreaker - Creates a circuit breaker from a config.
+func newBreaker(conf BreakerConfig) (*breaker, error) {
+	b := &breaker{}
+	if err := b.configure(conf); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// configure - Applies the threshold and probe interval of a config, keeping the current state.
+func (b *breaker) configure(conf BreakerConfig) error {
+	interval, err := time.ParseDuration(conf.ProbeInterval)
+	if err != nil {
+		return fmt.Errorf("failed to parse circuit breaker probe interval: %v", err)
+	}
+	b.threshold, b.probeInterval = conf.Threshold, interval
+	if b.threshold <= 0 {
+		b.state, b.failures = BreakerClosed, 0
+	}
+	return nil
+}
+
+// allow - Returns whether an attempt should be made, moving an open breaker to half open once the
+// probe interval has passed.
+func (b *breaker) allow(now time.Time) bool {
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openedAt.Add(b.probeInterval)) {
+			return false
+		}
+		b.state = BreakerHalfOpen
+	}
+	return true
+}
+
+// success - Records a successful attempt, closing the breaker.
+func (b *breaker) success() {
+	b.state, b.failures = BreakerClosed, 0
+}
+
+// failure - Records a failed attempt, returning true if the breaker was opened as a result.
+func (b *breaker) failure(now time.Time) bool {
+	b.failures++
+	if b.threshold <= 0 || b.state == BreakerOpen {
+		return false
+	}
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt = BreakerOpen, now
+		return true
+	}
+	return false
+}
+
+//--------------------------------------------------------------------------------------------------
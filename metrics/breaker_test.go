@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestBreaker(t *testing.T) {
+	b, err := newBreaker(BreakerConfig{Threshold: 2, ProbeInterval: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+
+	if !b.allow(now) {
+		t.Error("Expected closed breaker to allow attempts")
+	}
+	if b.failure(now) {
+		t.Error("Breaker opened before threshold")
+	}
+	if !b.failure(now) {
+		t.Error("Breaker did not open at threshold")
+	}
+	if b.allow(now.Add(time.Millisecond * 500)) {
+		t.Error("Expected open breaker to refuse attempts")
+	}
+	if !b.allow(now.Add(time.Second)) {
+		t.Error("Expected breaker to allow a probe")
+	}
+	if exp, act := BreakerHalfOpen, b.state; exp != act {
+		t.Errorf("Wrong state: %v != %v", exp, act)
+	}
+
+	now = now.Add(time.Second)
+	if !b.failure(now) {
+		t.Error("Expected failed probe to reopen breaker")
+	}
+	if b.allow(now) {
+		t.Error("Expected reopened breaker to refuse attempts")
+	}
+	if !b.allow(now.Add(time.Second)) {
+		t.Error("Expected breaker to allow a probe")
+	}
+	b.success()
+	if exp, act := BreakerClosed, b.state; exp != act {
+		t.Errorf("Wrong state: %v != %v", exp, act)
+	}
+	if b.failure(now) {
+		t.Error("Breaker opened before threshold after closing")
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b, err := newBreaker(BreakerConfig{Threshold: 0, ProbeInterval: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if b.failure(time.Now()) {
+			t.Fatal("Disabled breaker opened")
+		}
+	}
+	if !b.allow(time.Now()) {
+		t.Error("Expected disabled breaker to allow attempts")
+	}
+
+	if _, err = newBreaker(BreakerConfig{ProbeInterval: "nope"}); err == nil {
+		t.Error("Expected error from bad probe interval")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,298 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["cloudwatch"] = sinkSpec{
+		constructor: NewCloudWatchSink,
+		description: `
+Publishes snapshots to AWS CloudWatch with the PutMetricData API, sending at most
+20 metrics per request. Counters are published as the delta of each flush with
+the unit 'Count', gauges with the unit 'None' and timings as statistic sets in
+the configured timing unit. The dimensions of the config are added to every
+metric along with the tags of stats created with metrics.Tagged. Credentials are
+read from the config, or from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+AWS_SESSION_TOKEN environment variables when the config leaves them empty.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// CloudWatchConfig - Config for the AWS CloudWatch sink.
+type CloudWatchConfig struct {
+	Region          string            `json:"region" yaml:"region"`
+	Endpoint        string            `json:"endpoint" yaml:"endpoint"`
+	Namespace       string            `json:"namespace" yaml:"namespace"`
+	Dimensions      map[string]string `json:"dimensions" yaml:"dimensions"`
+	AccessKeyID     string            `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string            `json:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string            `json:"session_token" yaml:"session_token"`
+	Timeout         string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewCloudWatchConfig - Creates a CloudWatchConfig struct with default values.
+func NewCloudWatchConfig() CloudWatchConfig {
+	return CloudWatchConfig{
+		Region:          "us-east-1",
+		Endpoint:        "",
+		Namespace:       "benthos",
+		Dimensions:      map[string]string{},
+		AccessKeyID:     "",
+		SecretAccessKey: "",
+		SessionToken:    "",
+		Timeout:         "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// cloudWatchMaxBatch - The maximum number of metrics published in a single PutMetricData request.
+const cloudWatchMaxBatch = 20
+
+// CloudWatchSink - A Sink that publishes snapshots to AWS CloudWatch.
+type CloudWatchSink struct {
+	conf       CloudWatchConfig
+	endpoint   string
+	timingUnit string
+	sender     func(url string, body []byte, headers map[string]string) error
+}
+
+// NewCloudWatchSink - Create a new AWS CloudWatch sink.
+func NewCloudWatchSink(config Config) (Sink, error) {
+	conf := config.CloudWatch
+	if len(conf.Namespace) == 0 {
+		return nil, fmt.Errorf("cloudwatch namespace must not be empty")
+	}
+	if len(conf.AccessKeyID) == 0 {
+		conf.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		conf.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		conf.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if len(conf.AccessKeyID) == 0 || len(conf.SecretAccessKey) == 0 {
+		return nil, fmt.Errorf("cloudwatch credentials must not be empty")
+	}
+	if _, err := parseTimingUnit(config.TimingUnit); err != nil {
+		return nil, err
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := conf.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "https://monitoring." + conf.Region + ".amazonaws.com/"
+	}
+	return &CloudWatchSink{
+		conf:       conf,
+		endpoint:   endpoint,
+		timingUnit: cloudWatchUnit(config.TimingUnit),
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+// cloudWatchUnit - Returns the CloudWatch unit of a timing unit, CloudWatch has no unit for
+// nanoseconds and so those are published as 'None'.
+func cloudWatchUnit(timingUnit string) string {
+	switch timingUnit {
+	case "us", "µs":
+		return "Microseconds"
+	case "ms":
+		return "Milliseconds"
+	case "s":
+		return "Seconds"
+	}
+	return "None"
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// cloudWatchDatum - A single metric of a PutMetricData request, encoded as form values with the
+// prefix of its position in the request.
+type cloudWatchDatum func(prefix string, form url.Values)
+
+// cloudWatchData - Converts a snapshot into PutMetricData data.
+func (c *CloudWatchSink) cloudWatchData(snap Snapshot) []cloudWatchDatum {
+	timestamp := snap.Time.UTC().Format(time.RFC3339)
+	data := []cloudWatchDatum{}
+
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		dims := mergeTags(c.conf.Dimensions, tags)
+		common := func(prefix string, form url.Values, unit string) {
+			form.Set(prefix+"MetricName", name)
+			form.Set(prefix+"Timestamp", timestamp)
+			form.Set(prefix+"Unit", unit)
+			for i, k := range sortedKeys(dims) {
+				dim := prefix + "Dimensions.member." + strconv.Itoa(i+1) + "."
+				form.Set(dim+"Name", k)
+				form.Set(dim+"Value", dims[k])
+			}
+		}
+
+		if delta, ok := snap.CounterDeltas[path]; ok {
+			data = append(data, func(prefix string, form url.Values) {
+				common(prefix, form, "Count")
+				form.Set(prefix+"Value", strconv.FormatInt(delta, 10))
+			})
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			data = append(data, func(prefix string, form url.Values) {
+				common(prefix, form, "None")
+				form.Set(prefix+"Value", strconv.FormatInt(v, 10))
+			})
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			sum := SummariseTimings(samples)
+			data = append(data, func(prefix string, form url.Values) {
+				common(prefix, form, c.timingUnit)
+				form.Set(prefix+"StatisticValues.SampleCount", strconv.FormatInt(sum.Count, 10))
+				form.Set(prefix+"StatisticValues.Sum", strconv.FormatInt(sum.Sum, 10))
+				form.Set(prefix+"StatisticValues.Minimum", strconv.FormatInt(sum.Min, 10))
+				form.Set(prefix+"StatisticValues.Maximum", strconv.FormatInt(sum.Max, 10))
+			})
+		}
+	}
+	return data
+}
+
+// Flush - Publishes a snapshot in batches of at most 20 metrics, stopping at the first batch that
+// fails.
+func (c *CloudWatchSink) Flush(snap Snapshot) error {
+	data := c.cloudWatchData(snap)
+	for start := 0; start < len(data); start += cloudWatchMaxBatch {
+		end := start + cloudWatchMaxBatch
+		if end > len(data) {
+			end = len(data)
+		}
+
+		form := url.Values{}
+		form.Set("Action", "PutMetricData")
+		form.Set("Version", "2010-08-01")
+		form.Set("Namespace", c.conf.Namespace)
+		for i, datum := range data[start:end] {
+			datum("MetricData.member."+strconv.Itoa(i+1)+".", form)
+		}
+
+		body := []byte(form.Encode())
+		headers, err := c.sign(body, time.Now())
+		if err != nil {
+			return err
+		}
+		if err = c.sender(c.endpoint, body, headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (c *CloudWatchSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// cloudWatchHMAC - Returns the HMAC-SHA256 of data with a key.
+func cloudWatchHMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// cloudWatchSHA256 - Returns the hex encoded SHA256 of data.
+func cloudWatchSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign - Returns the headers of a request with the body signed with AWS signature version 4.
+func (c *CloudWatchSink) sign(body []byte, now time.Time) (map[string]string, error) {
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	path := u.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + c.conf.Region + "/monitoring/aws4_request"
+
+	headers := map[string]string{
+		"content-type": "application/x-www-form-urlencoded; charset=utf-8",
+		"host":         u.Host,
+		"x-amz-date":   amzDate,
+	}
+	if len(c.conf.SessionToken) > 0 {
+		headers["x-amz-security-token"] = c.conf.SessionToken
+	}
+
+	names := sortedKeys(headers)
+	canonicalHeaders := ""
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		"POST", path, u.RawQuery, canonicalHeaders, signedHeaders, cloudWatchSHA256(body),
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, cloudWatchSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := cloudWatchHMAC([]byte("AWS4"+c.conf.SecretAccessKey), date)
+	key = cloudWatchHMAC(key, c.conf.Region)
+	key = cloudWatchHMAC(key, "monitoring")
+	key = cloudWatchHMAC(key, "aws4_request")
+	signature := hex.EncodeToString(cloudWatchHMAC(key, stringToSign))
+
+	delete(headers, "host")
+	headers["authorization"] = fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		c.conf.AccessKeyID, scope, signedHeaders, signature,
+	)
+	return headers, nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestCloudWatchSinkBatches(t *testing.T) {
+	conf := NewConfig()
+	conf.CloudWatch.AccessKeyID = "foo"
+	conf.CloudWatch.SecretAccessKey = "bar"
+	conf.CloudWatch.Dimensions = map[string]string{"host": "a"}
+
+	sink, err := NewCloudWatchSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := sink.(*CloudWatchSink)
+
+	forms := []url.Values{}
+	c.sender = func(u string, body []byte, headers map[string]string) error {
+		if exp, act := "https://monitoring.us-east-1.amazonaws.com/", u; exp != act {
+			t.Errorf("Wrong endpoint: %v != %v", exp, act)
+		}
+		if !strings.HasPrefix(headers["authorization"], "AWS4-HMAC-SHA256 Credential=foo/") {
+			t.Errorf("Wrong authorization: %v", headers["authorization"])
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		forms = append(forms, form)
+		return nil
+	}
+
+	gauges := map[string]int64{}
+	for i := 0; i < 45; i++ {
+		gauges[fmt.Sprintf("gauge%02d", i)] = int64(i)
+	}
+	requests := Tagged("requests", map[string]string{"code": "200"})
+	err = c.Flush(Snapshot{
+		Time:          time.Unix(100, 0),
+		Counters:      map[string]int64{requests: 10},
+		CounterDeltas: map[string]int64{requests: 4},
+		Gauges:        gauges,
+		Timings:       map[string][]int64{"latency": {1, 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := 3, len(forms); exp != act {
+		t.Fatalf("Wrong count of requests: %v != %v", exp, act)
+	}
+	if exp, act := "gauge19", forms[0].Get("MetricData.member.20.MetricName"); exp != act {
+		t.Errorf("Wrong metric: %v != %v", exp, act)
+	}
+	if act := forms[0].Get("MetricData.member.21.MetricName"); act != "" {
+		t.Errorf("Batch exceeds limit: %v", act)
+	}
+
+	last := forms[2]
+	exp := map[string]string{
+		"Namespace":                      "benthos",
+		"MetricData.member.6.MetricName": "latency",
+		"MetricData.member.6.StatisticValues.SampleCount": "2",
+		"MetricData.member.6.StatisticValues.Maximum":     "3",
+		"MetricData.member.7.MetricName":                  "requests",
+		"MetricData.member.7.Value":                       "4",
+		"MetricData.member.7.Unit":                        "Count",
+		"MetricData.member.7.Dimensions.member.1.Name":    "code",
+		"MetricData.member.7.Dimensions.member.2.Value":   "a",
+		"MetricData.member.7.Timestamp":                   "1970-01-01T00:01:40Z",
+	}
+	for k, v := range exp {
+		if act := last.Get(k); act != v {
+			t.Errorf("Wrong %v: %v != %v", k, v, act)
+		}
+	}
+}
+
+func TestCloudWatchSinkCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := NewCloudWatchSink(NewConfig()); err == nil {
+		t.Error("Expected error from missing credentials")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "foo")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "bar")
+	if _, err := NewCloudWatchSink(NewConfig()); err != nil {
+		t.Error(err)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,319 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["collectd"] = sinkSpec{
+		constructor: NewCollectdSink,
+		description: `
+Sends snapshots to a collectd network plugin over udp using the binary network
+protocol. Each stat is sent under the configured host, plugin and plugin instance
+with the path of the stat as the type instance, where the tag values of stats
+created with metrics.Tagged are appended to the type instance ordered by tag key
+and separated by dashes. Counters are sent with the type 'derive', and gauges and
+the count, min, max, mean and p99 of timings with the type 'gauge'. The security
+level may be 'none', 'sign' or 'encrypt', and signing and encryption require a
+username and password known to the receiving collectd.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// CollectdConfig - Config for the collectd network protocol sink.
+type CollectdConfig struct {
+	Address        string `json:"address" yaml:"address"`
+	Host           string `json:"host" yaml:"host"`
+	Plugin         string `json:"plugin" yaml:"plugin"`
+	PluginInstance string `json:"plugin_instance" yaml:"plugin_instance"`
+	SecurityLevel  string `json:"security_level" yaml:"security_level"`
+	Username       string `json:"username" yaml:"username"`
+	Password       string `json:"password" yaml:"password"`
+	MaxPacketSize  int    `json:"max_packet_size" yaml:"max_packet_size"`
+}
+
+// NewCollectdConfig - Creates a CollectdConfig struct with default values.
+func NewCollectdConfig() CollectdConfig {
+	return CollectdConfig{
+		Address:        "localhost:25826",
+		Host:           "",
+		Plugin:         "benthos",
+		PluginInstance: "",
+		SecurityLevel:  "none",
+		Username:       "",
+		Password:       "",
+		MaxPacketSize:  1452,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Part types of the collectd network protocol.
+const (
+	collectdPartHost           = 0x0000
+	collectdPartPlugin         = 0x0002
+	collectdPartPluginInstance = 0x0003
+	collectdPartType           = 0x0004
+	collectdPartTypeInstance   = 0x0005
+	collectdPartValues         = 0x0006
+	collectdPartTimeHR         = 0x0008
+	collectdPartIntervalHR     = 0x0009
+	collectdPartSignature      = 0x0200
+	collectdPartEncryption     = 0x0210
+)
+
+// Data source types of the collectd network protocol.
+const (
+	collectdGauge  = 1
+	collectdDerive = 2
+)
+
+// CollectdSink - A Sink that sends snapshots to collectd with the binary network protocol.
+type CollectdSink struct {
+	conf CollectdConfig
+	conn net.Conn
+}
+
+// NewCollectdSink - Create a new collectd network protocol sink.
+func NewCollectdSink(config Config) (Sink, error) {
+	conf := config.Collectd
+	switch conf.SecurityLevel {
+	case "none":
+	case "sign", "encrypt":
+		if len(conf.Username) == 0 || len(conf.Password) == 0 {
+			return nil, fmt.Errorf("collectd username and password must not be empty")
+		}
+	default:
+		return nil, fmt.Errorf("collectd security level not recognised: %v", conf.SecurityLevel)
+	}
+	if len(conf.Host) == 0 {
+		conf.Host, _ = os.Hostname()
+	}
+	return &CollectdSink{conf: conf}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// collectdHRTime - Converts a time or duration in nanoseconds to units of 2^-30 seconds.
+func collectdHRTime(nanos int64) uint64 {
+	secs, frac := nanos/1e9, nanos%1e9
+	return uint64(secs)<<30 | uint64(frac)<<30/1e9
+}
+
+// collectdString - Appends a string part.
+func collectdString(buf *bytes.Buffer, partType uint16, value string) {
+	binary.Write(buf, binary.BigEndian, partType)
+	binary.Write(buf, binary.BigEndian, uint16(4+len(value)+1))
+	buf.WriteString(value)
+	buf.WriteByte(0)
+}
+
+// collectdNumber - Appends a numeric part.
+func collectdNumber(buf *bytes.Buffer, partType uint16, value uint64) {
+	binary.Write(buf, binary.BigEndian, partType)
+	binary.Write(buf, binary.BigEndian, uint16(12))
+	binary.Write(buf, binary.BigEndian, value)
+}
+
+// collectdValue - Appends the type instance, type and values parts of a single value, where
+// gauges are encoded as little endian doubles and derives as big endian integers.
+func collectdValue(buf *bytes.Buffer, typeInstance string, dsType byte, value int64) {
+	if dsType == collectdDerive {
+		collectdString(buf, collectdPartType, "derive")
+	} else {
+		collectdString(buf, collectdPartType, "gauge")
+	}
+	collectdString(buf, collectdPartTypeInstance, typeInstance)
+	binary.Write(buf, binary.BigEndian, uint16(collectdPartValues))
+	binary.Write(buf, binary.BigEndian, uint16(4+2+1+8))
+	binary.Write(buf, binary.BigEndian, uint16(1))
+	buf.WriteByte(dsType)
+	if dsType == collectdDerive {
+		binary.Write(buf, binary.BigEndian, value)
+	} else {
+		binary.Write(buf, binary.LittleEndian, math.Float64bits(float64(value)))
+	}
+}
+
+// collectdTypeInstance - Returns the type instance of a stat path and an optional suffix.
+func collectdTypeInstance(path, suffix string) string {
+	name, tags := splitTags(path)
+	parts := []string{name + suffix}
+	for _, k := range sortedKeys(tags) {
+		parts = append(parts, tags[k])
+	}
+	return strings.Join(parts, "-")
+}
+
+// collectdPackets - Encodes a snapshot as packets, where each packet begins with the parts that
+// identify the host, plugin and time of its values and stays within the max packet size after
+// signing or encryption.
+func (c *CollectdSink) collectdPackets(snap Snapshot) [][]byte {
+	var header bytes.Buffer
+	collectdString(&header, collectdPartHost, c.conf.Host)
+	collectdNumber(&header, collectdPartTimeHR, collectdHRTime(snap.Time.UnixNano()))
+	if snap.Interval > 0 {
+		collectdNumber(&header, collectdPartIntervalHR, collectdHRTime(int64(snap.Interval)))
+	}
+	collectdString(&header, collectdPartPlugin, c.conf.Plugin)
+	if len(c.conf.PluginInstance) > 0 {
+		collectdString(&header, collectdPartPluginInstance, c.conf.PluginInstance)
+	}
+
+	maxSize := c.conf.MaxPacketSize
+	switch c.conf.SecurityLevel {
+	case "sign":
+		maxSize -= 4 + sha256.Size + len(c.conf.Username)
+	case "encrypt":
+		maxSize -= 4 + 2 + len(c.conf.Username) + aes.BlockSize + sha1.Size
+	}
+
+	packets := [][]byte{}
+	var packet bytes.Buffer
+	add := func(typeInstance string, dsType byte, value int64) {
+		var v bytes.Buffer
+		collectdValue(&v, typeInstance, dsType, value)
+		if packet.Len() > header.Len() && packet.Len()+v.Len() > maxSize {
+			packets = append(packets, c.secure(packet.Bytes()))
+			packet.Reset()
+		}
+		if packet.Len() == 0 {
+			packet.Write(header.Bytes())
+		}
+		packet.Write(v.Bytes())
+	}
+
+	for _, path := range snap.Paths() {
+		if v, ok := snap.Counters[path]; ok {
+			add(collectdTypeInstance(path, ""), collectdDerive, v)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add(collectdTypeInstance(path, ""), collectdGauge, v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				add(collectdTypeInstance(path, "."+agg.name), collectdGauge, agg.value)
+			}
+		}
+	}
+	if packet.Len() > 0 {
+		packets = append(packets, c.secure(packet.Bytes()))
+	}
+	return packets
+}
+
+// secure - Signs or encrypts a packet according to the security level.
+func (c *CollectdSink) secure(payload []byte) []byte {
+	user := c.conf.Username
+	var buf bytes.Buffer
+
+	switch c.conf.SecurityLevel {
+	case "sign":
+		mac := hmac.New(sha256.New, []byte(c.conf.Password))
+		mac.Write([]byte(user))
+		mac.Write(payload)
+
+		binary.Write(&buf, binary.BigEndian, uint16(collectdPartSignature))
+		binary.Write(&buf, binary.BigEndian, uint16(4+sha256.Size+len(user)))
+		buf.Write(mac.Sum(nil))
+		buf.WriteString(user)
+		buf.Write(payload)
+	case "encrypt":
+		key := sha256.Sum256([]byte(c.conf.Password))
+		block, _ := aes.NewCipher(key[:])
+		iv := make([]byte, aes.BlockSize)
+		rand.Read(iv)
+
+		hash := sha1.Sum(payload)
+		plain := append(hash[:], payload...)
+		encrypted := make([]byte, len(plain))
+		cipher.NewOFB(block, iv).XORKeyStream(encrypted, plain)
+
+		binary.Write(&buf, binary.BigEndian, uint16(collectdPartEncryption))
+		binary.Write(&buf, binary.BigEndian, uint16(4+2+len(user)+len(iv)+len(encrypted)))
+		binary.Write(&buf, binary.BigEndian, uint16(len(user)))
+		buf.WriteString(user)
+		buf.Write(iv)
+		buf.Write(encrypted)
+	default:
+		return append([]byte(nil), payload...)
+	}
+	return buf.Bytes()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Flush - Sends a snapshot to collectd, dropping the connection after a failed write so that the
+// next flush dials again.
+func (c *CollectdSink) Flush(snap Snapshot) error {
+	packets := c.collectdPackets(snap)
+	if len(packets) == 0 {
+		return nil
+	}
+	if c.conn == nil {
+		conn, err := net.Dial("udp", c.conf.Address)
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+	}
+	for _, packet := range packets {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := c.conn.Write(packet); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - Closes the connection to collectd if open.
+func (c *CollectdSink) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
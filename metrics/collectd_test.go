@@ -0,0 +1,221 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// collectdPart - A decoded part of a collectd packet.
+type collectdPart struct {
+	partType uint16
+	body     []byte
+}
+
+// parseCollectdParts - Splits a collectd packet into parts.
+func parseCollectdParts(t *testing.T, packet []byte) []collectdPart {
+	parts := []collectdPart{}
+	for len(packet) > 0 {
+		if len(packet) < 4 {
+			t.Fatalf("Truncated part header: %v", packet)
+		}
+		partType := binary.BigEndian.Uint16(packet)
+		size := int(binary.BigEndian.Uint16(packet[2:]))
+		if size < 4 || size > len(packet) {
+			t.Fatalf("Wrong part size: %v", size)
+		}
+		parts = append(parts, collectdPart{partType, packet[4:size]})
+		packet = packet[size:]
+	}
+	return parts
+}
+
+// collectdValues - Returns the type instances and values of parts.
+func collectdValues(parts []collectdPart) map[string]float64 {
+	values := map[string]float64{}
+	typeInstance := ""
+	for _, p := range parts {
+		switch p.partType {
+		case collectdPartTypeInstance:
+			typeInstance = string(bytes.TrimRight(p.body, "\x00"))
+		case collectdPartValues:
+			if p.body[2] == collectdDerive {
+				values[typeInstance] = float64(int64(binary.BigEndian.Uint64(p.body[3:])))
+			} else {
+				values[typeInstance] = math.Float64frombits(binary.LittleEndian.Uint64(p.body[3:]))
+			}
+		}
+	}
+	return values
+}
+
+func newTestCollectdSink(t *testing.T, level string, maxSize int) *CollectdSink {
+	conf := NewConfig()
+	conf.Collectd.Host = "foo"
+	conf.Collectd.SecurityLevel = level
+	conf.Collectd.Username = "user"
+	conf.Collectd.Password = "pass"
+	conf.Collectd.MaxPacketSize = maxSize
+
+	sink, err := NewCollectdSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink.(*CollectdSink)
+}
+
+var testCollectdSnapshot = Snapshot{
+	Time:     time.Unix(100, 0),
+	Interval: 10 * time.Second,
+	Counters: map[string]int64{Tagged("requests", map[string]string{"code": "200"}): 5},
+	Gauges:   map[string]int64{"queue": 3},
+	Timings:  map[string][]int64{"latency": {2}},
+}
+
+func TestCollectdSinkPackets(t *testing.T) {
+	c := newTestCollectdSink(t, "none", 150)
+
+	packets := c.collectdPackets(testCollectdSnapshot)
+	if len(packets) < 2 {
+		t.Fatalf("Expected packets to be split: %v", len(packets))
+	}
+
+	values := map[string]float64{}
+	for _, packet := range packets {
+		if len(packet) > 150 {
+			t.Errorf("Packet exceeds max size: %v", len(packet))
+		}
+		parts := parseCollectdParts(t, packet)
+		if parts[0].partType != collectdPartHost || string(parts[0].body) != "foo\x00" {
+			t.Errorf("Packet does not begin with host: %+v", parts[0])
+		}
+		if exp, act := uint64(100)<<30, binary.BigEndian.Uint64(parts[1].body); exp != act {
+			t.Errorf("Wrong time: %v != %v", exp, act)
+		}
+		for k, v := range collectdValues(parts) {
+			values[k] = v
+		}
+	}
+
+	exp := map[string]float64{
+		"requests-200":  5,
+		"queue":         3,
+		"latency.count": 1,
+		"latency.min":   2,
+		"latency.max":   2,
+		"latency.mean":  2,
+		"latency.p99":   2,
+	}
+	for k, v := range exp {
+		if act, ok := values[k]; !ok || v != act {
+			t.Errorf("Wrong value of %v: %v != %v", k, v, act)
+		}
+	}
+}
+
+func TestCollectdSinkSign(t *testing.T) {
+	c := newTestCollectdSink(t, "sign", 1452)
+
+	packets := c.collectdPackets(testCollectdSnapshot)
+	if len(packets) != 1 {
+		t.Fatalf("Wrong count of packets: %v", len(packets))
+	}
+	packet := packets[0]
+	if exp, act := uint16(collectdPartSignature), binary.BigEndian.Uint16(packet); exp != act {
+		t.Fatalf("Wrong part type: %v != %v", exp, act)
+	}
+	size := int(binary.BigEndian.Uint16(packet[2:]))
+	sig, user, payload := packet[4:36], packet[36:size], packet[size:]
+
+	mac := hmac.New(sha256.New, []byte("pass"))
+	mac.Write(user)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		t.Error("Wrong signature")
+	}
+	if exp, act := 5.0, collectdValues(parseCollectdParts(t, payload))["requests-200"]; exp != act {
+		t.Errorf("Wrong value: %v != %v", exp, act)
+	}
+}
+
+func TestCollectdSinkEncrypt(t *testing.T) {
+	c := newTestCollectdSink(t, "encrypt", 1452)
+
+	packets := c.collectdPackets(testCollectdSnapshot)
+	if len(packets) != 1 {
+		t.Fatalf("Wrong count of packets: %v", len(packets))
+	}
+	parts := parseCollectdParts(t, packets[0])
+	if len(parts) != 1 || parts[0].partType != collectdPartEncryption {
+		t.Fatalf("Wrong parts: %+v", parts)
+	}
+	body := parts[0].body
+	userLen := int(binary.BigEndian.Uint16(body))
+	if exp, act := "user", string(body[2:2+userLen]); exp != act {
+		t.Errorf("Wrong username: %v != %v", exp, act)
+	}
+	iv, encrypted := body[2+userLen:2+userLen+aes.BlockSize], body[2+userLen+aes.BlockSize:]
+
+	key := sha256.Sum256([]byte("pass"))
+	block, _ := aes.NewCipher(key[:])
+	plain := make([]byte, len(encrypted))
+	cipher.NewOFB(block, iv).XORKeyStream(plain, encrypted)
+
+	hash, payload := plain[:sha1.Size], plain[sha1.Size:]
+	if sum := sha1.Sum(payload); !bytes.Equal(hash, sum[:]) {
+		t.Error("Wrong hash of decrypted payload")
+	}
+	if exp, act := 3.0, collectdValues(parseCollectdParts(t, payload))["queue"]; exp != act {
+		t.Errorf("Wrong value: %v != %v", exp, act)
+	}
+}
+
+func TestCollectdSinkSend(t *testing.T) {
+	pc := listenUDP(t)
+	defer pc.Close()
+
+	c := newTestCollectdSink(t, "none", 1452)
+	c.conf.Address = pc.LocalAddr().String()
+	defer c.Close()
+
+	if err := c.Flush(testCollectdSnapshot); err != nil {
+		t.Fatal(err)
+	}
+	packet := readPackets(t, pc, 1)[0]
+	if exp, act := 7, len(collectdValues(parseCollectdParts(t, []byte(packet)))); exp != act {
+		t.Errorf("Wrong count of values: %v != %v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -25,8 +25,12 @@ package metrics
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 //--------------------------------------------------------------------------------------------------
@@ -34,6 +38,9 @@ import (
 // Errors for the metrics package.
 var (
 	ErrInvalidMetricOutputType = errors.New("invalid metrics output type")
+	ErrNotReconfigurable       = errors.New("metrics type cannot be reconfigured at runtime")
+	ErrFilterNotEnabled        = errors.New("filters were not enabled when the metrics type was created")
+	ErrMaxPathsNotEnabled      = errors.New("max paths was not enabled when the metrics type was created")
 )
 
 //--------------------------------------------------------------------------------------------------
@@ -50,20 +57,134 @@ var constructors = map[string]typeSpec{}
 
 // Config - The all encompassing configuration struct for all metric output types.
 type Config struct {
-	Type    string        `json:"type" yaml:"type"`
-	HTTP    HTTPConfig    `json:"http_server" yaml:"http_server"`
-	Riemann RiemannConfig `json:"riemann" yaml:"riemann"`
-	Statsd  StatsdConfig  `json:"statsd" yaml:"statsd"`
+	Type       string        `json:"type" yaml:"type"`
+	TimingUnit string        `json:"timing_unit" yaml:"timing_unit"`
+	Filter     FilterConfig  `json:"filter" yaml:"filter"`
+	MaxPaths   int           `json:"max_paths" yaml:"max_paths"`
+	ClampZero  bool          `json:"clamp_counters_at_zero" yaml:"clamp_counters_at_zero"`
+	HTTP       HTTPConfig    `json:"http_server" yaml:"http_server"`
+	Riemann    RiemannConfig `json:"riemann" yaml:"riemann"`
+	Statsd     StatsdConfig  `json:"statsd" yaml:"statsd"`
+	Sinks      SinksConfig   `json:"sinks" yaml:"sinks"`
+
+	DogStatsd     DogStatsdConfig     `json:"dogstatsd" yaml:"dogstatsd"`
+	Prometheus    PrometheusConfig    `json:"prometheus" yaml:"prometheus"`
+	Pushgateway   PushgatewayConfig   `json:"pushgateway" yaml:"pushgateway"`
+	Graphite      GraphiteConfig      `json:"graphite" yaml:"graphite"`
+	Influx        InfluxConfig        `json:"influxdb" yaml:"influxdb"`
+	OTLP          OTLPConfig          `json:"otlp" yaml:"otlp"`
+	CloudWatch    CloudWatchConfig    `json:"cloudwatch" yaml:"cloudwatch"`
+	Datadog       DatadogConfig       `json:"datadog" yaml:"datadog"`
+	NewRelic      NewRelicConfig      `json:"newrelic" yaml:"newrelic"`
+	Librato       LibratoConfig       `json:"librato" yaml:"librato"`
+	Zabbix        ZabbixConfig        `json:"zabbix" yaml:"zabbix"`
+	Collectd      CollectdConfig      `json:"collectd" yaml:"collectd"`
+	Elasticsearch ElasticsearchConfig `json:"elasticsearch" yaml:"elasticsearch"`
+	Kafka         KafkaConfig         `json:"kafka" yaml:"kafka"`
+	NATS          NATSConfig          `json:"nats" yaml:"nats"`
+	AMQP          AMQPConfig          `json:"amqp" yaml:"amqp"`
+	SignalFx      SignalFxConfig      `json:"signalfx" yaml:"signalfx"`
+	Wavefront     WavefrontConfig     `json:"wavefront" yaml:"wavefront"`
+	RemoteWrite   RemoteWriteConfig   `json:"prometheus_remote_write" yaml:"prometheus_remote_write"`
+	OpenTSDB      OpenTSDBConfig      `json:"opentsdb" yaml:"opentsdb"`
+	MQTT          MQTTConfig          `json:"mqtt" yaml:"mqtt"`
+	File          FileSinkConfig      `json:"file" yaml:"file"`
+	GCM           GCMConfig           `json:"gcm" yaml:"gcm"`
+	AzureMonitor  AzureMonitorConfig  `json:"azure_monitor" yaml:"azure_monitor"`
+	Webhook       WebhookConfig       `json:"webhook" yaml:"webhook"`
 }
 
 // NewConfig - Returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:    "none",
-		HTTP:    NewHTTPConfig(),
-		Riemann: NewRiemannConfig(),
-		Statsd:  NewStatsdConfig(),
+		Type:       "none",
+		TimingUnit: "ns",
+		Filter:     NewFilterConfig(),
+		MaxPaths:   0,
+		ClampZero:  false,
+		HTTP:       NewHTTPConfig(),
+		Riemann:    NewRiemannConfig(),
+		Statsd:     NewStatsdConfig(),
+		Sinks:      NewSinksConfig(),
+
+		DogStatsd:     NewDogStatsdConfig(),
+		Prometheus:    NewPrometheusConfig(),
+		Pushgateway:   NewPushgatewayConfig(),
+		Graphite:      NewGraphiteConfig(),
+		Influx:        NewInfluxConfig(),
+		OTLP:          NewOTLPConfig(),
+		CloudWatch:    NewCloudWatchConfig(),
+		Datadog:       NewDatadogConfig(),
+		NewRelic:      NewNewRelicConfig(),
+		Librato:       NewLibratoConfig(),
+		Zabbix:        NewZabbixConfig(),
+		Collectd:      NewCollectdConfig(),
+		Elasticsearch: NewElasticsearchConfig(),
+		Kafka:         NewKafkaConfig(),
+		NATS:          NewNATSConfig(),
+		AMQP:          NewAMQPConfig(),
+		SignalFx:      NewSignalFxConfig(),
+		Wavefront:     NewWavefrontConfig(),
+		RemoteWrite:   NewRemoteWriteConfig(),
+		OpenTSDB:      NewOpenTSDBConfig(),
+		MQTT:          NewMQTTConfig(),
+		File:          NewFileSinkConfig(),
+		GCM:           NewGCMConfig(),
+		AzureMonitor:  NewAzureMonitorConfig(),
+		Webhook:       NewWebhookConfig(),
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+expandPrefix - Resolves placeholder tokens within a stats prefix so that instances of a service can
+be distinguished without bespoke wiring. Supported tokens are:
+
+{hostname} - The hostname of the machine, with dots replaced by underscores.
+{pid}      - The process ID of the service.
+{env}      - The value of the ENV environment variable.
+{env:FOO}  - The value of the environment variable FOO.
+*/
+func expandPrefix(prefix string) string {
+	if !strings.Contains(prefix, "{") {
+		return prefix
 	}
+	hostname, _ := os.Hostname()
+	prefix = strings.Replace(prefix, "{hostname}", strings.Replace(hostname, ".", "_", -1), -1)
+	prefix = strings.Replace(prefix, "{pid}", strconv.Itoa(os.Getpid()), -1)
+	prefix = strings.Replace(prefix, "{env}", os.Getenv("ENV"), -1)
+	for {
+		start := strings.Index(prefix, "{env:")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(prefix[start:], "}")
+		if end < 0 {
+			break
+		}
+		end += start
+		prefix = prefix[:start] + os.Getenv(prefix[start+5:end]) + prefix[end+1:]
+	}
+	return prefix
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// parseTimingUnit - Returns the duration represented by a single unit of a timing metric. An empty
+// unit is treated as nanoseconds.
+func parseTimingUnit(unit string) (time.Duration, error) {
+	switch unit {
+	case "", "ns":
+		return time.Nanosecond, nil
+	case "us", "µs":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	}
+	return 0, fmt.Errorf("timing unit not recognised: %v", unit)
 }
 
 //--------------------------------------------------------------------------------------------------
@@ -94,18 +215,95 @@ func Descriptions() string {
 			buf.WriteString("\n")
 		}
 	}
+
+	if len(sinkConstructors) == 0 {
+		return buf.String()
+	}
+
+	names = []string{}
+	for name := range sinkConstructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf.WriteString("\nMETRIC SINKS\n")
+	buf.WriteString(strings.Repeat("=", 80))
+	buf.WriteString("\n\n")
+
+	for i, name := range names {
+		buf.WriteString(name)
+		buf.WriteString("\n")
+		buf.WriteString(strings.Repeat("-", 80))
+		buf.WriteString("\n")
+		buf.WriteString(sinkConstructors[name].description)
+		buf.WriteString("\n")
+		if i != (len(names) - 1) {
+			buf.WriteString("\n")
+		}
+	}
 	return buf.String()
 }
 
-// New - Create a metric output type based on a configuration.
+/*
+New - Create a metric output type based on a configuration. The type is wrapped according to the
+configuration so that stats rejected by filter patterns are dropped, and stats beyond the maximum
+number of distinct paths (when MaxPaths is greater than zero) are redirected to an overflow path.
+*/
 func New(conf Config) (Type, error) {
 	if conf.Type == "none" {
 		return DudType{}, nil
 	}
-	if c, ok := constructors[conf.Type]; ok {
-		return c.constructor(conf)
+	c, ok := constructors[conf.Type]
+	if !ok {
+		return nil, ErrInvalidMetricOutputType
+	}
+	t, err := c.constructor(conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.MaxPaths > 0 {
+		t = NewLimited(t, conf.MaxPaths)
+	}
+	if !conf.Filter.IsEmpty() {
+		f, err := NewFiltered(t, conf.Filter)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t = f
+	}
+	return t, nil
+}
+
+/*
+Reconfigure - Applies a new config to a metrics type at runtime, changing fields such as prefixes,
+intervals and filters without tearing down and rebuilding the type. The type of metrics output
+cannot be changed this way, and filters or max paths can only be changed if they were enabled when
+the type was created with New.
+*/
+func Reconfigure(t Type, conf Config) error {
+	var hasFilter, hasLimit bool
+	for w := t; w != nil; {
+		switch v := w.(type) {
+		case *Filtered:
+			hasFilter, w = true, v.t
+		case *Limited:
+			hasLimit, w = true, v.t
+		default:
+			w = nil
+		}
+	}
+	if !hasFilter && !conf.Filter.IsEmpty() {
+		return ErrFilterNotEnabled
+	}
+	if !hasLimit && conf.MaxPaths > 0 {
+		return ErrMaxPathsNotEnabled
+	}
+	r, ok := t.(Reconfigurable)
+	if !ok {
+		return ErrNotReconfigurable
 	}
-	return nil, ErrInvalidMetricOutputType
+	return r.Reconfigure(conf)
 }
 
 //--------------------------------------------------------------------------------------------------
@@ -22,7 +22,13 @@ THE SOFTWARE.
 
 package metrics
 
-import "testing"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestInterfaces(t *testing.T) {
 	foo, err := New(NewConfig())
@@ -33,3 +39,47 @@ func TestInterfaces(t *testing.T) {
 	foo.Incr("nope", 1)
 	bar.Incr("nope", 1)
 }
+
+func TestParseTimingUnit(t *testing.T) {
+	for unit, exp := range map[string]time.Duration{
+		"":   time.Nanosecond,
+		"ns": time.Nanosecond,
+		"us": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+	} {
+		act, err := parseTimingUnit(unit)
+		if err != nil {
+			t.Errorf("Unit %v: %v", unit, err)
+		} else if act != exp {
+			t.Errorf("Unit %v: %v != %v", unit, act, exp)
+		}
+	}
+	if _, err := parseTimingUnit("fortnights"); err == nil {
+		t.Error("Expected error from unrecognised unit")
+	}
+}
+
+func TestExpandPrefix(t *testing.T) {
+	os.Setenv("ENV", "prod")
+	os.Setenv("METRICS_TEST_REGION", "eu")
+	defer os.Unsetenv("ENV")
+	defer os.Unsetenv("METRICS_TEST_REGION")
+
+	hostname, _ := os.Hostname()
+	hostname = strings.Replace(hostname, ".", "_", -1)
+
+	for input, exp := range map[string]string{
+		"service":                             "service",
+		"{env}.service":                       "prod.service",
+		"{env:METRICS_TEST_REGION}.{env}.svc": "eu.prod.svc",
+		"service.{hostname}":                  "service." + hostname,
+		"service.{pid}":                       "service." + strconv.Itoa(os.Getpid()),
+		"service.{unknown}":                   "service.{unknown}",
+		"{env:METRICS_TEST_REGION":            "{env:METRICS_TEST_REGION",
+	} {
+		if act := expandPrefix(input); exp != act {
+			t.Errorf("Wrong expansion of %v: %v != %v", input, exp, act)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import "math"
+
+//--------------------------------------------------------------------------------------------------
+
+// ClampedCountPath - The path of a counter incremented by metric types each time a counter would have
+// overflowed, or dropped below zero whilst counter clamping is enabled.
+const ClampedCountPath = "_clamped_count"
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+addCounter - Adds delta to a counter total. Rather than silently wrapping around the result
+saturates at the bounds of int64, and when clampZero is set the result is not allowed to drop below
+zero. Returns the new total and whether it was clamped.
+*/
+func addCounter(total, delta int64, clampZero bool) (int64, bool) {
+	clamped := false
+	switch {
+	case delta > 0 && total > math.MaxInt64-delta:
+		total, clamped = math.MaxInt64, true
+	case delta < 0 && total < math.MinInt64-delta:
+		total, clamped = math.MinInt64, true
+	default:
+		total += delta
+	}
+	if clampZero && total < 0 {
+		total, clamped = 0, true
+	}
+	return total, clamped
+}
+
+// negate - Returns the negation of a value, saturating at math.MaxInt64.
+func negate(value int64) int64 {
+	if value == math.MinInt64 {
+		return math.MaxInt64
+	}
+	return -value
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,51 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAddCounter(t *testing.T) {
+	type testCase struct {
+		total, delta int64
+		clampZero    bool
+		exp          int64
+		expClamped   bool
+	}
+	for _, c := range []testCase{
+		{1, 2, false, 3, false},
+		{1, -2, false, -1, false},
+		{1, -2, true, 0, true},
+		{math.MaxInt64 - 1, 5, false, math.MaxInt64, true},
+		{math.MinInt64 + 1, -5, false, math.MinInt64, true},
+		{math.MinInt64 + 1, -5, true, 0, true},
+		{5, negate(math.MinInt64), false, math.MaxInt64, true},
+	} {
+		act, clamped := addCounter(c.total, c.delta, c.clampZero)
+		if act != c.exp || clamped != c.expClamped {
+			t.Errorf("Wrong result for %+v: %v, %v", c, act, clamped)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["datadog"] = sinkSpec{
+		constructor: NewDatadogSink,
+		description: `
+Posts snapshots directly to the Datadog series API without a local agent.
+Counters are posted as counts of the delta of each flush, gauges as gauges and
+timings as gauges of their count, min, max, mean and p99. The tags of the config
+and of stats created with metrics.Tagged become Datadog tags.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// DatadogConfig - Config for the Datadog HTTP API sink.
+type DatadogConfig struct {
+	URL     string            `json:"url" yaml:"url"`
+	APIKey  string            `json:"api_key" yaml:"api_key"`
+	Host    string            `json:"host" yaml:"host"`
+	Tags    map[string]string `json:"tags" yaml:"tags"`
+	Timeout string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewDatadogConfig - Creates a DatadogConfig struct with default values.
+func NewDatadogConfig() DatadogConfig {
+	return DatadogConfig{
+		URL:     "https://api.datadoghq.com",
+		APIKey:  "",
+		Host:    "",
+		Tags:    map[string]string{},
+		Timeout: "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// DatadogSink - A Sink that posts snapshots to the Datadog series API.
+type DatadogSink struct {
+	seriesURL string
+	headers   map[string]string
+	host      string
+	tags      map[string]string
+	sender    func(url string, body []byte, headers map[string]string) error
+}
+
+// NewDatadogSink - Create a new Datadog HTTP API sink.
+func NewDatadogSink(config Config) (Sink, error) {
+	conf := config.Datadog
+	if len(conf.APIKey) == 0 {
+		return nil, fmt.Errorf("datadog api key must not be empty")
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &DatadogSink{
+		seriesURL: strings.TrimSuffix(conf.URL, "/") + "/api/v1/series",
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"DD-API-KEY":   conf.APIKey,
+		},
+		host: conf.Host,
+		tags: conf.Tags,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// datadogSeries - A series of the Datadog series API.
+type datadogSeries struct {
+	Metric   string     `json:"metric"`
+	Points   [][2]int64 `json:"points"`
+	Type     string     `json:"type"`
+	Interval int64      `json:"interval,omitempty"`
+	Host     string     `json:"host,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+}
+
+// datadogSeriesFor - Converts a snapshot into Datadog series.
+func (d *DatadogSink) datadogSeriesFor(snap Snapshot) []datadogSeries {
+	ts := snap.Time.Unix()
+	interval := int64(snap.Interval.Seconds())
+
+	series := []datadogSeries{}
+	for _, path := range snap.Paths() {
+		name, pathTags := splitTags(path)
+		tags := mergeTags(d.tags, pathTags)
+		var ddTags []string
+		for _, k := range sortedKeys(tags) {
+			ddTags = append(ddTags, k+":"+tags[k])
+		}
+		add := func(metric, kind string, value int64) {
+			s := datadogSeries{
+				Metric: metric,
+				Points: [][2]int64{{ts, value}},
+				Type:   kind,
+				Host:   d.host,
+				Tags:   ddTags,
+			}
+			if kind == "count" {
+				s.Interval = interval
+			}
+			series = append(series, s)
+		}
+
+		if delta, ok := snap.CounterDeltas[path]; ok {
+			add(name, "count", delta)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add(name, "gauge", v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				add(name+"."+agg.name, "gauge", agg.value)
+			}
+		}
+	}
+	return series
+}
+
+// Flush - Posts a snapshot to Datadog.
+func (d *DatadogSink) Flush(snap Snapshot) error {
+	series := d.datadogSeriesFor(snap)
+	if len(series) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string][]datadogSeries{"series": series})
+	if err != nil {
+		return err
+	}
+	return d.sender(d.seriesURL, body, d.headers)
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (d *DatadogSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
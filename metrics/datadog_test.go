@@ -0,0 +1,91 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestDatadogSinkSeries(t *testing.T) {
+	conf := NewConfig()
+	conf.Datadog.APIKey = "foo"
+	conf.Datadog.Host = "a"
+	conf.Datadog.Tags = map[string]string{"env": "prod"}
+
+	sink, err := NewDatadogSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := sink.(*DatadogSink)
+
+	var sentURL string
+	var sentBody []byte
+	var sentHeaders map[string]string
+	d.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentBody, sentHeaders = url, body, headers
+		return nil
+	}
+
+	requests := Tagged("requests", map[string]string{"code": "200"})
+	err = d.Flush(Snapshot{
+		Time:          time.Unix(100, 0),
+		Interval:      10 * time.Second,
+		Counters:      map[string]int64{requests: 9},
+		CounterDeltas: map[string]int64{requests: 4},
+		Gauges:        map[string]int64{"queue": 3},
+		Timings:       map[string][]int64{"latency": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "https://api.datadoghq.com/api/v1/series", sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	if exp, act := "foo", sentHeaders["DD-API-KEY"]; exp != act {
+		t.Errorf("Wrong api key: %v != %v", exp, act)
+	}
+
+	exp := `{"series":[` +
+		`{"metric":"latency.count","points":[[100,1]],"type":"gauge","host":"a","tags":["env:prod"]},` +
+		`{"metric":"latency.min","points":[[100,2]],"type":"gauge","host":"a","tags":["env:prod"]},` +
+		`{"metric":"latency.max","points":[[100,2]],"type":"gauge","host":"a","tags":["env:prod"]},` +
+		`{"metric":"latency.mean","points":[[100,2]],"type":"gauge","host":"a","tags":["env:prod"]},` +
+		`{"metric":"latency.p99","points":[[100,2]],"type":"gauge","host":"a","tags":["env:prod"]},` +
+		`{"metric":"queue","points":[[100,3]],"type":"gauge","host":"a","tags":["env:prod"]},` +
+		`{"metric":"requests","points":[[100,4]],"type":"count","interval":10,"host":"a","tags":["code:200","env:prod"]}]}`
+	if act := string(sentBody); exp != act {
+		t.Errorf("Wrong body:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+func TestDatadogSinkAPIKey(t *testing.T) {
+	if _, err := NewDatadogSink(NewConfig()); err == nil {
+		t.Error("Expected error from missing api key")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
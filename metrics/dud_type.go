@@ -22,6 +22,8 @@ THE SOFTWARE.
 
 package metrics
 
+import "time"
+
 //--------------------------------------------------------------------------------------------------
 
 // DudType - Implements the Type interface but doesn't actual do anything.
@@ -36,9 +38,15 @@ func (d DudType) Decr(path string, count int64) error { return nil }
 // Timing - Does nothing.
 func (d DudType) Timing(path string, delta int64) error { return nil }
 
+// TimingDuration - Does nothing.
+func (d DudType) TimingDuration(path string, t time.Duration) error { return nil }
+
 // Gauge - Does nothing.
 func (d DudType) Gauge(path string, value int64) error { return nil }
 
+// Reconfigure - Does nothing.
+func (d DudType) Reconfigure(conf Config) error { return nil }
+
 // Close - Does nothing.
 func (d DudType) Close() error { return nil }
 
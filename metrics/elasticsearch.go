@@ -0,0 +1,247 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["elasticsearch"] = sinkSpec{
+		constructor: NewElasticsearchSink,
+		description: `
+Indexes snapshots into Elasticsearch with the bulk API, writing a document for
+each stat of a flush with the fields '@timestamp', 'name', 'type' and 'tags'.
+Counters and gauges have a 'value' field, where counters are running totals
+with the delta of the flush in 'delta', and timings have the fields 'count',
+'min', 'max', 'mean' and 'p99'. Parts of the index enclosed in braces are
+formatted as a Go time layout of the flush time in UTC, e.g. 'stats-{2006.01}'
+indexes into 'stats-2024.05'. A flush fails when any document fails to index.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// ElasticsearchConfig - Config for the Elasticsearch bulk index sink.
+type ElasticsearchConfig struct {
+	URL      string `json:"url" yaml:"url"`
+	Index    string `json:"index" yaml:"index"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	APIKey   string `json:"api_key" yaml:"api_key"`
+	Timeout  string `json:"timeout" yaml:"timeout"`
+}
+
+// NewElasticsearchConfig - Creates an ElasticsearchConfig struct with default values.
+func NewElasticsearchConfig() ElasticsearchConfig {
+	return ElasticsearchConfig{
+		URL:      "http://localhost:9200",
+		Index:    "stats-{2006.01.02}",
+		Username: "",
+		Password: "",
+		APIKey:   "",
+		Timeout:  "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// ElasticsearchSink - A Sink that indexes snapshots into Elasticsearch.
+type ElasticsearchSink struct {
+	bulkURL string
+	index   string
+	headers map[string]string
+	sender  func(url string, body []byte, headers map[string]string) ([]byte, error)
+}
+
+// NewElasticsearchSink - Create a new Elasticsearch bulk index sink.
+func NewElasticsearchSink(config Config) (Sink, error) {
+	conf := config.Elasticsearch
+	if len(conf.Index) == 0 {
+		return nil, fmt.Errorf("elasticsearch index must not be empty")
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{"Content-Type": "application/x-ndjson"}
+	if len(conf.APIKey) > 0 {
+		headers["Authorization"] = "ApiKey " + conf.APIKey
+	} else if len(conf.Username) > 0 {
+		auth := base64.StdEncoding.EncodeToString([]byte(conf.Username + ":" + conf.Password))
+		headers["Authorization"] = "Basic " + auth
+	}
+	return &ElasticsearchSink{
+		bulkURL: strings.TrimSuffix(conf.URL, "/") + "/_bulk",
+		index:   conf.Index,
+		headers: headers,
+		sender: func(url string, body []byte, headers map[string]string) ([]byte, error) {
+			return doHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// elasticsearchIndexLayout - Matches the time layouts of an index pattern.
+var elasticsearchIndexLayout = regexp.MustCompile(`\{[^}]*\}`)
+
+// elasticsearchIndex - Returns the index of a flush time from an index pattern.
+func elasticsearchIndex(pattern string, t time.Time) string {
+	return elasticsearchIndexLayout.ReplaceAllStringFunc(pattern, func(layout string) string {
+		return t.UTC().Format(layout[1 : len(layout)-1])
+	})
+}
+
+// elasticsearchDoc - A document of a single stat of a snapshot.
+type elasticsearchDoc struct {
+	Timestamp string            `json:"@timestamp"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Value     *int64            `json:"value,omitempty"`
+	Delta     *int64            `json:"delta,omitempty"`
+	Count     *int64            `json:"count,omitempty"`
+	Min       *int64            `json:"min,omitempty"`
+	Max       *int64            `json:"max,omitempty"`
+	Mean      *int64            `json:"mean,omitempty"`
+	P99       *int64            `json:"p99,omitempty"`
+}
+
+// elasticsearchBulk - Formats a snapshot as the body of a bulk request.
+func (e *ElasticsearchSink) elasticsearchBulk(snap Snapshot) ([]byte, error) {
+	action, err := json.Marshal(map[string]interface{}{
+		"index": map[string]string{"_index": elasticsearchIndex(e.index, snap.Time)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	timestamp := snap.Time.UTC().Format(time.RFC3339Nano)
+
+	var buf bytes.Buffer
+	write := func(doc elasticsearchDoc) error {
+		docBytes, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(docBytes)
+		buf.WriteByte('\n')
+		return nil
+	}
+	int64Ptr := func(v int64) *int64 {
+		return &v
+	}
+
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		doc := func(kind string) elasticsearchDoc {
+			return elasticsearchDoc{Timestamp: timestamp, Name: name, Type: kind, Tags: tags}
+		}
+
+		if v, ok := snap.Counters[path]; ok {
+			d := doc(KindCounter)
+			d.Value, d.Delta = int64Ptr(v), int64Ptr(snap.CounterDeltas[path])
+			if err := write(d); err != nil {
+				return nil, err
+			}
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			d := doc(KindGauge)
+			d.Value = int64Ptr(v)
+			if err := write(d); err != nil {
+				return nil, err
+			}
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			sum := SummariseTimings(samples)
+			d := doc(KindTiming)
+			d.Count, d.Min, d.Max = int64Ptr(sum.Count), int64Ptr(sum.Min), int64Ptr(sum.Max)
+			d.Mean, d.P99 = int64Ptr(sum.Mean()), int64Ptr(sum.Quantile(0.99))
+			if err := write(d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// elasticsearchBulkResponse - The parts of a bulk response used to detect failed documents.
+type elasticsearchBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// Flush - Indexes a snapshot into Elasticsearch.
+func (e *ElasticsearchSink) Flush(snap Snapshot) error {
+	body, err := e.elasticsearchBulk(snap)
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	resBody, err := e.sender(e.bulkURL, body, e.headers)
+	if err != nil {
+		return err
+	}
+
+	var res elasticsearchBulkResponse
+	if err = json.Unmarshal(resBody, &res); err != nil {
+		return fmt.Errorf("failed to parse elasticsearch response: %v", err)
+	}
+	if !res.Errors {
+		return nil
+	}
+	failed := 0
+	reason := ""
+	for _, item := range res.Items {
+		for _, result := range item {
+			if result.Status < 200 || result.Status > 299 {
+				failed++
+				reason = result.Error.Type + ": " + result.Error.Reason
+			}
+		}
+	}
+	return fmt.Errorf("elasticsearch failed to index %v documents: %v", failed, reason)
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (e *ElasticsearchSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestElasticsearchSinkBulk(t *testing.T) {
+	conf := NewConfig()
+	conf.Elasticsearch.Index = "stats-{2006.01}"
+	conf.Elasticsearch.Username = "foo"
+
+	sink, err := NewElasticsearchSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := sink.(*ElasticsearchSink)
+
+	var sentURL, sentBody string
+	e.sender = func(url string, body []byte, headers map[string]string) ([]byte, error) {
+		if headers["Authorization"] != "Basic Zm9vOg==" {
+			t.Errorf("Wrong authorization: %v", headers["Authorization"])
+		}
+		sentURL, sentBody = url, string(body)
+		return []byte(`{"took":1,"errors":false,"items":[]}`), nil
+	}
+
+	requests := Tagged("requests", map[string]string{"code": "200"})
+	err = e.Flush(Snapshot{
+		Time:          time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC),
+		Counters:      map[string]int64{requests: 9},
+		CounterDeltas: map[string]int64{requests: 4},
+		Gauges:        map[string]int64{"queue": 0},
+		Timings:       map[string][]int64{"latency": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "http://localhost:9200/_bulk", sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	action := `{"index":{"_index":"stats-2024.05"}}` + "\n"
+	exp := action +
+		`{"@timestamp":"2024-05-03T10:00:00Z","name":"latency","type":"timing","count":1,"min":2,"max":2,"mean":2,"p99":2}` + "\n" +
+		action +
+		`{"@timestamp":"2024-05-03T10:00:00Z","name":"queue","type":"gauge","value":0}` + "\n" +
+		action +
+		`{"@timestamp":"2024-05-03T10:00:00Z","name":"requests","type":"counter","tags":{"code":"200"},"value":9,"delta":4}` + "\n"
+	if exp != sentBody {
+		t.Errorf("Wrong body:\n%v\n!=\n%v", exp, sentBody)
+	}
+}
+
+func TestElasticsearchSinkErrors(t *testing.T) {
+	sink, err := NewElasticsearchSink(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := sink.(*ElasticsearchSink)
+	e.sender = func(url string, body []byte, headers map[string]string) ([]byte, error) {
+		return []byte(`{"errors":true,"items":[` +
+			`{"index":{"status":201}},` +
+			`{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`), nil
+	}
+
+	err = e.Flush(Snapshot{Gauges: map[string]int64{"a": 1, "b": 2}})
+	if exp, act := "elasticsearch failed to index 1 documents: mapper_parsing_exception: bad", err; act == nil || exp != act.Error() {
+		t.Errorf("Wrong error: %v != %v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,235 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["file"] = sinkSpec{
+		constructor: NewFileSink,
+		description: `
+Appends each snapshot to a local file, which suits air-gapped environments where
+no metrics backend is reachable. With the 'jsonl' format each snapshot is written
+as a single line of JSON, and with the 'csv' format each stat is written as a row
+of its time, type, path and value, where timings are written as a row for each of
+their count, min, max, mean and p99. Once the file would exceed max_size_bytes it
+is rotated by renaming it with the suffix '.1', shifting older files up by one,
+and no more than max_files rotated files are kept. A max_size_bytes of zero
+disables rotation.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// FileSinkConfig - Config for the rolling file sink.
+type FileSinkConfig struct {
+	Path     string `json:"path" yaml:"path"`
+	Format   string `json:"format" yaml:"format"`
+	MaxSize  int64  `json:"max_size_bytes" yaml:"max_size_bytes"`
+	MaxFiles int    `json:"max_files" yaml:"max_files"`
+}
+
+// NewFileSinkConfig - Creates a FileSinkConfig struct with default values.
+func NewFileSinkConfig() FileSinkConfig {
+	return FileSinkConfig{
+		Path:     "stats.jsonl",
+		Format:   "jsonl",
+		MaxSize:  16 * 1024 * 1024,
+		MaxFiles: 5,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// fileSinkCSVHeader - The header row of each csv file.
+var fileSinkCSVHeader = []string{"time", "type", "path", "value"}
+
+// FileSink - A Sink that appends snapshots to a rotating local file.
+type FileSink struct {
+	sync.Mutex
+
+	path     string
+	csv      bool
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+// NewFileSink - Create a new rolling file sink.
+func NewFileSink(config Config) (Sink, error) {
+	conf := config.File
+	if len(conf.Path) == 0 {
+		return nil, fmt.Errorf("file sink path must not be empty")
+	}
+	f := &FileSink{
+		path:     conf.Path,
+		maxSize:  conf.MaxSize,
+		maxFiles: conf.MaxFiles,
+	}
+	switch conf.Format {
+	case "jsonl":
+	case "csv":
+		f.csv = true
+	default:
+		return nil, fmt.Errorf("file sink format not recognised: %v", conf.Format)
+	}
+	return f, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// encodeCSV - Formats a snapshot as csv rows.
+func (f *FileSink) encodeCSV(snap Snapshot, header bool) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if header {
+		w.Write(fileSinkCSVHeader)
+	}
+	ts := snap.Time.UTC().Format(time.RFC3339Nano)
+	row := func(kind, path string, value int64) {
+		w.Write([]string{ts, kind, path, strconv.FormatInt(value, 10)})
+	}
+	for _, path := range snap.Paths() {
+		if v, ok := snap.Counters[path]; ok {
+			row(KindCounter, path, v)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			row(KindGauge, path, v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				row(KindTiming, path+"."+agg.name, agg.value)
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// encode - Formats a snapshot in the configured format, where header is true if the snapshot is
+// the first to be written to a file.
+func (f *FileSink) encode(snap Snapshot, header bool) ([]byte, error) {
+	if f.csv {
+		return f.encodeCSV(snap, header)
+	}
+	doc, err := marshalSnapshot(snap)
+	if err != nil {
+		return nil, err
+	}
+	return append(doc, '\n'), nil
+}
+
+// open - Opens the file for appending if not already open.
+func (f *FileSink) open() error {
+	if f.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate - Closes the file and shifts it and the rotated files before it up by one suffix, removing
+// the oldest beyond the maximum number of files.
+func (f *FileSink) rotate() error {
+	f.file.Close()
+	f.file = nil
+
+	if f.maxFiles <= 0 {
+		return os.Remove(f.path)
+	}
+	os.Remove(f.path + "." + strconv.Itoa(f.maxFiles))
+	for i := f.maxFiles - 1; i > 0; i-- {
+		from := f.path + "." + strconv.Itoa(i)
+		if _, err := os.Stat(from); err == nil {
+			if err = os.Rename(from, f.path+"."+strconv.Itoa(i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(f.path, f.path+".1")
+}
+
+// Flush - Appends a snapshot to the file, rotating it first if it would exceed the maximum size.
+func (f *FileSink) Flush(snap Snapshot) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.open(); err != nil {
+		return err
+	}
+	data, err := f.encode(snap, f.size == 0)
+	if err != nil {
+		return err
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err = f.rotate(); err != nil {
+			return err
+		}
+		if err = f.open(); err != nil {
+			return err
+		}
+		if data, err = f.encode(snap, true); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return err
+}
+
+// Close - Closes the file if open.
+func (f *FileSink) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,145 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestFileSinkJSONL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewConfig()
+	conf.File.Path = filepath.Join(dir, "stats.jsonl")
+
+	sink, err := NewFileSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 2; i++ {
+		if err = sink.Flush(Snapshot{Time: time.Unix(100, 0), Gauges: map[string]int64{"a": i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+
+	data, err := ioutil.ReadFile(conf.File.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Wrong count of lines: %v", len(lines))
+	}
+	var doc snapshotDocument
+	if err = json.Unmarshal([]byte(lines[1]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := int64(2), doc.Gauges["a"]; exp != act {
+		t.Errorf("Wrong gauge: %v != %v", exp, act)
+	}
+}
+
+func TestFileSinkCSVRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewConfig()
+	conf.File.Path = filepath.Join(dir, "stats.csv")
+	conf.File.Format = "csv"
+	conf.File.MaxSize = 60
+	conf.File.MaxFiles = 2
+
+	sink, err := NewFileSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for i := int64(1); i <= 4; i++ {
+		if err = sink.Flush(Snapshot{Time: time.Unix(100, 0), Counters: map[string]int64{"a": i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path, exp := range map[string]string{
+		conf.File.Path:        "time,type,path,value\n1970-01-01T00:01:40Z,counter,a,4\n",
+		conf.File.Path + ".1": "time,type,path,value\n1970-01-01T00:01:40Z,counter,a,3\n",
+		conf.File.Path + ".2": "time,type,path,value\n1970-01-01T00:01:40Z,counter,a,2\n",
+	} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := string(data); exp != act {
+			t.Errorf("Wrong contents of %v: %q != %q", path, exp, act)
+		}
+	}
+	if _, err = os.Stat(conf.File.Path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected oldest file to be removed: %v", err)
+	}
+}
+
+func TestFileSinkCSVTimings(t *testing.T) {
+	f := &FileSink{csv: true}
+	data, err := f.encodeCSV(Snapshot{
+		Time:    time.Unix(100, 0),
+		Timings: map[string][]int64{Tagged("b", map[string]string{"k": "v,w"}): {2}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `1970-01-01T00:01:40Z,timing,"b;k=v,w.count",1` + "\n" +
+		`1970-01-01T00:01:40Z,timing,"b;k=v,w.min",2` + "\n" +
+		`1970-01-01T00:01:40Z,timing,"b;k=v,w.max",2` + "\n" +
+		`1970-01-01T00:01:40Z,timing,"b;k=v,w.mean",2` + "\n" +
+		`1970-01-01T00:01:40Z,timing,"b;k=v,w.p99",2` + "\n"
+	if act := string(data); exp != act {
+		t.Errorf("Wrong csv:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+func TestFileSinkBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.File.Format = "xml"
+	if _, err := NewFileSink(conf); err == nil {
+		t.Error("Expected error from bad format")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,191 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+FilterConfig - Patterns for selecting which stats are kept. Patterns are matched against the full
+path of a stat using the same syntax as path.Match, where '*' matches any sequence of characters
+including dots, e.g. "http.requests.*".
+
+When Include is non-empty a stat must match at least one of its patterns to be kept, and any stat
+matching a pattern within Exclude is always dropped.
+*/
+type FilterConfig struct {
+	Include []string `json:"include" yaml:"include"`
+	Exclude []string `json:"exclude" yaml:"exclude"`
+}
+
+// NewFilterConfig - Returns a filter configuration that keeps all stats.
+func NewFilterConfig() FilterConfig {
+	return FilterConfig{
+		Include: []string{},
+		Exclude: []string{},
+	}
+}
+
+// IsEmpty - Returns true if the config has no patterns and would therefore keep all stats.
+func (f FilterConfig) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// validate - Returns an error if any pattern is malformed.
+func (f FilterConfig) validate() error {
+	for _, patterns := range [][]string{f.Include, f.Exclude} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid filter pattern '%v': %v", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows - Returns true if a stat path passes the filter.
+func (f FilterConfig) Allows(stat string) bool {
+	if len(f.Include) > 0 && !matchesAny(f.Include, stat) {
+		return false
+	}
+	return !matchesAny(f.Exclude, stat)
+}
+
+func matchesAny(patterns []string, stat string) bool {
+	for _, p := range patterns {
+		if matched, _ := path.Match(p, stat); matched {
+			return true
+		}
+	}
+	return false
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Filtered - Wraps a Type and drops any stats whose path is rejected by a filter before they reach
+// the wrapped Type.
+type Filtered struct {
+	t      Type
+	filter FilterConfig
+
+	sync.RWMutex
+}
+
+// NewFiltered - Wrap a Type with a filter.
+func NewFiltered(t Type, filter FilterConfig) (*Filtered, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	return &Filtered{
+		t:      t,
+		filter: filter,
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// allows - Returns true if a stat path passes the current filter.
+func (f *Filtered) allows(stat string) bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.filter.Allows(stat)
+}
+
+// Incr - Increment a stat by a value if it passes the filter.
+func (f *Filtered) Incr(stat string, value int64) error {
+	if !f.allows(stat) {
+		return nil
+	}
+	return f.t.Incr(stat, value)
+}
+
+// Decr - Decrement a stat by a value if it passes the filter.
+func (f *Filtered) Decr(stat string, value int64) error {
+	if !f.allows(stat) {
+		return nil
+	}
+	return f.t.Decr(stat, value)
+}
+
+// Timing - Set a stat representing a duration if it passes the filter.
+func (f *Filtered) Timing(stat string, delta int64) error {
+	if !f.allows(stat) {
+		return nil
+	}
+	return f.t.Timing(stat, delta)
+}
+
+// TimingDuration - Set a stat representing a duration if it passes the filter.
+func (f *Filtered) TimingDuration(stat string, d time.Duration) error {
+	if !f.allows(stat) {
+		return nil
+	}
+	return f.t.TimingDuration(stat, d)
+}
+
+// Gauge - Set a stat as a gauge value if it passes the filter.
+func (f *Filtered) Gauge(stat string, value int64) error {
+	if !f.allows(stat) {
+		return nil
+	}
+	return f.t.Gauge(stat, value)
+}
+
+// SetString - Set a stat as a string value if it passes the filter and the wrapped Type supports
+// string values.
+func (f *Filtered) SetString(stat string, value string) error {
+	if s, ok := f.t.(StringSetter); ok && f.allows(stat) {
+		return s.SetString(stat, value)
+	}
+	return nil
+}
+
+// Reconfigure - Replaces the filter with that of a new config, and reconfigures the wrapped Type if
+// it supports reconfiguration.
+func (f *Filtered) Reconfigure(conf Config) error {
+	if err := conf.Filter.validate(); err != nil {
+		return err
+	}
+	if r, ok := f.t.(Reconfigurable); ok {
+		if err := r.Reconfigure(conf); err != nil {
+			return err
+		}
+	}
+	f.Lock()
+	f.filter = conf.Filter
+	f.Unlock()
+	return nil
+}
+
+// Close - Closes the wrapped Type.
+func (f *Filtered) Close() error {
+	return f.t.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import "testing"
+
+func TestFiltered(t *testing.T) {
+	rec := newRecorder()
+	f, err := NewFiltered(rec, FilterConfig{
+		Include: []string{"http.*", "db.query"},
+		Exclude: []string{"http.*.debug"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Incr("http.requests", 1)
+	f.Incr("http.requests.debug", 1)
+	f.Incr("db.query", 1)
+	f.Incr("db.query.slow", 1)
+	f.Gauge("other", 1)
+
+	for stat, exp := range map[string]bool{
+		"http.requests":       true,
+		"http.requests.debug": false,
+		"db.query":            true,
+		"db.query.slow":       false,
+	} {
+		if _, act := rec.counts[stat]; exp != act {
+			t.Errorf("Wrong filter result for %v: %v != %v", stat, exp, act)
+		}
+	}
+	if _, ok := rec.gauges["other"]; ok {
+		t.Error("Stat not matching include was kept")
+	}
+}
+
+func TestFilteredBadPattern(t *testing.T) {
+	if _, err := NewFiltered(DudType{}, FilterConfig{Exclude: []string{"["}}); err == nil {
+		t.Error("Expected error from bad pattern")
+	}
+}
+
+func TestFilteredReconfigure(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "http_server"
+	conf.HTTP.Address = "localhost:0"
+	conf.Filter.Exclude = []string{"foo"}
+
+	met, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer met.Close()
+
+	conf.Filter.Exclude = []string{"bar"}
+	conf.HTTP.Prefix = "renamed"
+	if err = Reconfigure(met, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	f := met.(*Filtered)
+	if !f.allows("foo") || f.allows("bar") {
+		t.Error("Filter was not replaced")
+	}
+	if exp, act := "renamed", f.t.(*HTTP).config.Prefix; exp != act {
+		t.Errorf("Wrong prefix after reconfigure: %v != %v", exp, act)
+	}
+
+	if err = Reconfigure(DudType{}, conf); err != ErrFilterNotEnabled {
+		t.Errorf("Wrong error: %v != %v", err, ErrFilterNotEnabled)
+	}
+}
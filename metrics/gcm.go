@@ -0,0 +1,352 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["gcm"] = sinkSpec{
+		constructor: NewGCMSink,
+		description: `
+Writes snapshots as custom metrics to Google Cloud Monitoring (Stackdriver), with
+at most 200 time series per request. Each stat becomes the metric type
+'custom.googleapis.com/<prefix>/<path>' with dots replaced by slashes, where
+counters are written as cumulative running totals, gauges as gauges and timings
+as gauges of their count, min, max, mean and p99. The labels of the config and
+the tags of stats created with metrics.Tagged become metric labels.
+
+When running on GCE or GKE the monitored resource is derived from the metadata
+server as a 'gce_instance' or 'k8s_container' respectively, and 'global'
+otherwise, unless a resource type and labels are configured. Tokens are fetched
+from the metadata server, or with the service account key file at
+credentials_file when set.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// GCMConfig - Config for the Google Cloud Monitoring sink.
+type GCMConfig struct {
+	URL             string            `json:"url" yaml:"url"`
+	ProjectID       string            `json:"project_id" yaml:"project_id"`
+	CredentialsFile string            `json:"credentials_file" yaml:"credentials_file"`
+	Prefix          string            `json:"prefix" yaml:"prefix"`
+	Labels          map[string]string `json:"labels" yaml:"labels"`
+	ResourceType    string            `json:"resource_type" yaml:"resource_type"`
+	ResourceLabels  map[string]string `json:"resource_labels" yaml:"resource_labels"`
+	Timeout         string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewGCMConfig - Creates a GCMConfig struct with default values.
+func NewGCMConfig() GCMConfig {
+	return GCMConfig{
+		URL:             "https://monitoring.googleapis.com",
+		ProjectID:       "",
+		CredentialsFile: "",
+		Prefix:          "benthos",
+		Labels:          map[string]string{},
+		ResourceType:    "",
+		ResourceLabels:  map[string]string{},
+		Timeout:         "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// gcmMaxBatch - The maximum number of time series written in a single request.
+const gcmMaxBatch = 200
+
+// gcmResource - A monitored resource.
+type gcmResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+}
+
+// GCMSink - A Sink that writes snapshots to Google Cloud Monitoring.
+type GCMSink struct {
+	sync.Mutex
+
+	conf     GCMConfig
+	start    time.Time
+	metadata func(path string) (string, error)
+	token    *tokenSource
+	sender   func(url string, body []byte, headers map[string]string) error
+
+	projectID string
+	resource  *gcmResource
+}
+
+// NewGCMSink - Create a new Google Cloud Monitoring sink.
+func NewGCMSink(config Config) (Sink, error) {
+	conf := config.GCM
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	g := &GCMSink{
+		conf:      conf,
+		start:     time.Now(),
+		metadata:  gcmMetadataGetter(client, gcmMetadataURL),
+		projectID: conf.ProjectID,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}
+	g.token = &tokenSource{fetch: gcmMetadataToken(g.metadata)}
+	if len(conf.CredentialsFile) > 0 {
+		account, err := loadGCMServiceAccount(conf.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		g.token = &tokenSource{fetch: account.tokenFetcher(client)}
+		if len(g.projectID) == 0 {
+			g.projectID = account.ProjectID
+		}
+	}
+	return g, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// resolveResource - Determines the project and monitored resource, reading from the metadata server
+// where they are not configured. The result is kept for all subsequent flushes.
+func (g *GCMSink) resolveResource() error {
+	if g.resource != nil {
+		return nil
+	}
+
+	if len(g.projectID) == 0 {
+		project, err := g.metadata("project/project-id")
+		if err != nil {
+			return fmt.Errorf("gcm project id must be configured when not running on GCP: %v", err)
+		}
+		g.projectID = project
+	}
+
+	if len(g.conf.ResourceType) > 0 {
+		labels := map[string]string{"project_id": g.projectID}
+		for k, v := range g.conf.ResourceLabels {
+			labels[k] = v
+		}
+		g.resource = &gcmResource{Type: g.conf.ResourceType, Labels: labels}
+		return nil
+	}
+
+	instanceID, err := g.metadata("instance/id")
+	if err != nil {
+		g.resource = &gcmResource{Type: "global", Labels: map[string]string{"project_id": g.projectID}}
+		return nil
+	}
+	zone, _ := g.metadata("instance/zone")
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+
+	if cluster, err := g.metadata("instance/attributes/cluster-name"); err == nil && len(cluster) > 0 {
+		location, err := g.metadata("instance/attributes/cluster-location")
+		if err != nil || len(location) == 0 {
+			location = zone
+		}
+		g.resource = &gcmResource{Type: "k8s_container", Labels: map[string]string{
+			"project_id":     g.projectID,
+			"location":       location,
+			"cluster_name":   cluster,
+			"namespace_name": gcmPodNamespace(),
+			"pod_name":       gcmPodName(),
+			"container_name": os.Getenv("CONTAINER_NAME"),
+		}}
+		return nil
+	}
+
+	g.resource = &gcmResource{Type: "gce_instance", Labels: map[string]string{
+		"project_id":  g.projectID,
+		"instance_id": instanceID,
+		"zone":        zone,
+	}}
+	return nil
+}
+
+// gcmPodNamespace - Returns the namespace of the pod from the environment or its service account.
+func gcmPodNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); len(ns) > 0 {
+		return ns
+	}
+	if ns, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return "default"
+}
+
+// gcmPodName - Returns the name of the pod, which is the hostname of its containers by default.
+func gcmPodName() string {
+	if name := os.Getenv("POD_NAME"); len(name) > 0 {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+//--------------------------------------------------------------------------------------------------
+
+type (
+	gcmTimeSeries struct {
+		Metric     gcmMetric   `json:"metric"`
+		Resource   gcmResource `json:"resource"`
+		MetricKind string      `json:"metricKind"`
+		ValueType  string      `json:"valueType"`
+		Points     []gcmPoint  `json:"points"`
+	}
+	gcmMetric struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels,omitempty"`
+	}
+	gcmPoint struct {
+		Interval gcmInterval `json:"interval"`
+		Value    gcmValue    `json:"value"`
+	}
+	gcmInterval struct {
+		StartTime string `json:"startTime,omitempty"`
+		EndTime   string `json:"endTime"`
+	}
+	gcmValue struct {
+		Int64Value int64 `json:"int64Value,string"`
+	}
+)
+
+// gcmLabel - Converts a tag key into a valid label key of lower case letters, digits and
+// underscores that begins with a letter.
+func gcmLabel(key string) string {
+	b := []byte(strings.ToLower(key))
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || b[0] < 'a' || b[0] > 'z' {
+		return "l_" + string(b)
+	}
+	return string(b)
+}
+
+// gcmTimeSeriesFor - Converts a snapshot into time series of a single point each.
+func (g *GCMSink) gcmTimeSeriesFor(snap Snapshot) []gcmTimeSeries {
+	end := snap.Time.UTC().Format(time.RFC3339Nano)
+	start := g.start.UTC().Format(time.RFC3339Nano)
+	prefix := "custom.googleapis.com/"
+	if len(g.conf.Prefix) > 0 {
+		prefix += strings.Trim(g.conf.Prefix, "/") + "/"
+	}
+
+	series := []gcmTimeSeries{}
+	for _, path := range snap.Paths() {
+		name, pathTags := splitTags(path)
+		tags := mergeTags(g.conf.Labels, pathTags)
+		var labels map[string]string
+		if len(tags) > 0 {
+			labels = make(map[string]string, len(tags))
+			for k, v := range tags {
+				labels[gcmLabel(k)] = v
+			}
+		}
+		add := func(metric, kind, startTime string, value int64) {
+			series = append(series, gcmTimeSeries{
+				Metric: gcmMetric{
+					Type:   prefix + strings.Replace(metric, ".", "/", -1),
+					Labels: labels,
+				},
+				Resource:   *g.resource,
+				MetricKind: kind,
+				ValueType:  "INT64",
+				Points: []gcmPoint{{
+					Interval: gcmInterval{StartTime: startTime, EndTime: end},
+					Value:    gcmValue{Int64Value: value},
+				}},
+			})
+		}
+
+		if v, ok := snap.Counters[path]; ok {
+			add(name, "CUMULATIVE", start, v)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add(name, "GAUGE", "", v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				add(name+"."+agg.name, "GAUGE", "", agg.value)
+			}
+		}
+	}
+	return series
+}
+
+// Flush - Writes a snapshot in batches, stopping at the first batch that fails.
+func (g *GCMSink) Flush(snap Snapshot) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if err := g.resolveResource(); err != nil {
+		return err
+	}
+	series := g.gcmTimeSeriesFor(snap)
+	if len(series) == 0 {
+		return nil
+	}
+	token, err := g.token.get(time.Now())
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(g.conf.URL, "/") + "/v3/projects/" + g.projectID + "/timeSeries"
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": "Bearer " + token,
+	}
+	for start := 0; start < len(series); start += gcmMaxBatch {
+		end := start + gcmMaxBatch
+		if end > len(series) {
+			end = len(series)
+		}
+		body, err := json.Marshal(map[string][]gcmTimeSeries{"timeSeries": series[start:end]})
+		if err != nil {
+			return err
+		}
+		if err = g.sender(url, body, headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (g *GCMSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
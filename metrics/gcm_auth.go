@@ -0,0 +1,176 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+This file implements the two ways the Google Cloud Monitoring sink authenticates without a client
+library: the GCE metadata server, which provides tokens for the default service account of an
+instance or GKE node, and service account key files, where a JWT signed with the private key of the
+account is exchanged for a token.
+*/
+
+// gcmMetadataURL - The base URL of the GCE metadata server.
+const gcmMetadataURL = "http://metadata.google.internal/computeMetadata/v1/"
+
+// gcmScope - The OAuth scope required to write time series.
+const gcmScope = "https://www.googleapis.com/auth/monitoring.write"
+
+// Errors for Google Cloud authentication.
+var (
+	ErrGCMPrivateKey = errors.New("service account private key is not a PEM encoded RSA key")
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// gcmMetadataGetter - Returns a function that reads a value from the metadata server.
+func gcmMetadataGetter(client *http.Client, baseURL string) func(path string) (string, error) {
+	return func(path string) (string, error) {
+		body, err := doHTTP(client, "GET", baseURL+path, nil, map[string]string{
+			"Metadata-Flavor": "Google",
+		})
+		return strings.TrimSpace(string(body)), err
+	}
+}
+
+// gcmMetadataToken - Returns a function that fetches a token for the default service account from
+// the metadata server.
+func gcmMetadataToken(metadata func(path string) (string, error)) func() (accessToken, error) {
+	return func() (accessToken, error) {
+		var t accessToken
+		body, err := metadata("instance/service-accounts/default/token")
+		if err == nil {
+			err = json.Unmarshal([]byte(body), &t)
+		}
+		return t, err
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// gcmServiceAccount - The fields of a service account key file that are needed to fetch a token.
+type gcmServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// loadGCMServiceAccount - Reads and parses a service account key file.
+func loadGCMServiceAccount(path string) (*gcmServiceAccount, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	account := &gcmServiceAccount{}
+	if err = json.Unmarshal(data, account); err != nil {
+		return nil, fmt.Errorf("failed to parse service account key file: %v", err)
+	}
+	if len(account.TokenURI) == 0 {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, ErrGCMPrivateKey
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, ErrGCMPrivateKey
+		}
+	}
+	var ok bool
+	if account.key, ok = key.(*rsa.PrivateKey); !ok {
+		return nil, ErrGCMPrivateKey
+	}
+	return account, nil
+}
+
+// jwt - Creates a JWT assertion for the monitoring write scope, signed with RS256.
+func (a *gcmServiceAccount) jwt(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   a.ClientEmail,
+		"scope": gcmScope,
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// tokenFetcher - Returns a function that exchanges a JWT assertion for a token.
+func (a *gcmServiceAccount) tokenFetcher(client *http.Client) func() (accessToken, error) {
+	return func() (accessToken, error) {
+		var t accessToken
+		assertion, err := a.jwt(time.Now())
+		if err != nil {
+			return t, err
+		}
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		body, err := doHTTP(client, "POST", a.TokenURI, []byte(form.Encode()), map[string]string{
+			"Content-Type": "application/x-www-form-urlencoded",
+		})
+		if err == nil {
+			err = json.Unmarshal(body, &t)
+		}
+		return t, err
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
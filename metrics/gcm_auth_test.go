@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestGCMMetadataToken(t *testing.T) {
+	fetch := gcmMetadataToken(fakeGCMMetadata(map[string]string{
+		"instance/service-accounts/default/token": `{"access_token":"tok","expires_in":60}`,
+	}))
+	token, err := fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "tok" || token.ExpiresIn != "60" {
+		t.Errorf("Wrong token: %+v", token)
+	}
+}
+
+func TestGCMServiceAccountJWT(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile, _ := json.Marshal(map[string]string{
+		"project_id":   "proj",
+		"client_email": "stats@proj.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	path := filepath.Join(dir, "key.json")
+	if err = ioutil.WriteFile(path, keyFile, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := loadGCMServiceAccount(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "https://oauth2.googleapis.com/token", account.TokenURI; exp != act {
+		t.Errorf("Wrong token uri: %v != %v", exp, act)
+	}
+
+	jwt, err := account.jwt(time.Unix(100, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Wrong jwt: %v", jwt)
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	exp := `{"aud":"https://oauth2.googleapis.com/token","exp":3700,"iat":100,` +
+		`"iss":"stats@proj.iam.gserviceaccount.com","scope":"https://www.googleapis.com/auth/monitoring.write"}`
+	if act := string(claims); exp != act {
+		t.Errorf("Wrong claims: %v != %v", exp, act)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("Wrong signature: %v", err)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"private_key":"nope"}`), 0600)
+	if _, err = loadGCMServiceAccount(path); err != ErrGCMPrivateKey {
+		t.Errorf("Wrong error from bad key: %v", err)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
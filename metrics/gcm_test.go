@@ -0,0 +1,180 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// fakeGCMMetadata - Returns a metadata getter that serves values from a map.
+func fakeGCMMetadata(values map[string]string) func(path string) (string, error) {
+	return func(path string) (string, error) {
+		if v, ok := values[path]; ok {
+			return v, nil
+		}
+		return "", errors.New("not found")
+	}
+}
+
+func TestGCMSinkResource(t *testing.T) {
+	os.Setenv("POD_NAMESPACE", "stats")
+	os.Setenv("POD_NAME", "pod-1")
+	os.Setenv("CONTAINER_NAME", "app")
+	defer os.Unsetenv("POD_NAMESPACE")
+	defer os.Unsetenv("POD_NAME")
+	defer os.Unsetenv("CONTAINER_NAME")
+
+	instance := map[string]string{
+		"project/project-id": "proj",
+		"instance/id":        "123",
+		"instance/zone":      "projects/456/zones/europe-west1-b",
+	}
+	gke := map[string]string{"instance/attributes/cluster-name": "prod"}
+	for k, v := range instance {
+		gke[k] = v
+	}
+
+	for _, test := range []struct {
+		conf     GCMConfig
+		metadata map[string]string
+		exp      gcmResource
+	}{
+		{
+			conf:     GCMConfig{ProjectID: "mine"},
+			metadata: map[string]string{},
+			exp:      gcmResource{Type: "global", Labels: map[string]string{"project_id": "mine"}},
+		},
+		{
+			conf:     GCMConfig{},
+			metadata: instance,
+			exp: gcmResource{Type: "gce_instance", Labels: map[string]string{
+				"project_id": "proj", "instance_id": "123", "zone": "europe-west1-b",
+			}},
+		},
+		{
+			conf:     GCMConfig{},
+			metadata: gke,
+			exp: gcmResource{Type: "k8s_container", Labels: map[string]string{
+				"project_id": "proj", "location": "europe-west1-b", "cluster_name": "prod",
+				"namespace_name": "stats", "pod_name": "pod-1", "container_name": "app",
+			}},
+		},
+		{
+			conf: GCMConfig{
+				ResourceType:   "generic_node",
+				ResourceLabels: map[string]string{"node_id": "a"},
+			},
+			metadata: instance,
+			exp: gcmResource{Type: "generic_node", Labels: map[string]string{
+				"project_id": "proj", "node_id": "a",
+			}},
+		},
+	} {
+		g := &GCMSink{conf: test.conf, projectID: test.conf.ProjectID, metadata: fakeGCMMetadata(test.metadata)}
+		if err := g.resolveResource(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.exp, *g.resource) {
+			t.Errorf("Wrong resource: %v != %v", test.exp, *g.resource)
+		}
+	}
+
+	g := &GCMSink{metadata: fakeGCMMetadata(map[string]string{})}
+	if err := g.resolveResource(); err == nil {
+		t.Error("Expected error from missing project id")
+	}
+}
+
+func TestGCMSinkFlush(t *testing.T) {
+	conf := NewConfig()
+	conf.GCM.ProjectID = "proj"
+	conf.GCM.Labels = map[string]string{"Env": "prod"}
+
+	sink, err := NewGCMSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := sink.(*GCMSink)
+	g.start = time.Unix(50, 0)
+	g.metadata = fakeGCMMetadata(map[string]string{})
+	g.token = &tokenSource{fetch: func() (accessToken, error) {
+		return accessToken{AccessToken: "tok", ExpiresIn: "3600"}, nil
+	}}
+
+	var sentURL string
+	var sent []string
+	var sentHeaders map[string]string
+	g.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentHeaders = url, headers
+		sent = append(sent, string(body))
+		return nil
+	}
+
+	err = g.Flush(Snapshot{
+		Time:     time.Unix(100, 0),
+		Counters: map[string]int64{Tagged("http.requests", map[string]string{"2xx": "y"}): 9},
+		Gauges:   map[string]int64{"queue": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "https://monitoring.googleapis.com/v3/projects/proj/timeSeries", sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	if exp, act := "Bearer tok", sentHeaders["Authorization"]; exp != act {
+		t.Errorf("Wrong authorization: %v != %v", exp, act)
+	}
+
+	resource := `"resource":{"type":"global","labels":{"project_id":"proj"}}`
+	exp := `{"timeSeries":[` +
+		`{"metric":{"type":"custom.googleapis.com/benthos/http/requests","labels":{"env":"prod","l_2xx":"y"}},` +
+		resource + `,"metricKind":"CUMULATIVE","valueType":"INT64","points":[{"interval":` +
+		`{"startTime":"1970-01-01T00:00:50Z","endTime":"1970-01-01T00:01:40Z"},"value":{"int64Value":"9"}}]},` +
+		`{"metric":{"type":"custom.googleapis.com/benthos/queue","labels":{"env":"prod"}},` +
+		resource + `,"metricKind":"GAUGE","valueType":"INT64","points":[{"interval":` +
+		`{"endTime":"1970-01-01T00:01:40Z"},"value":{"int64Value":"3"}}]}]}`
+	if len(sent) != 1 || exp != sent[0] {
+		t.Errorf("Wrong body:\n%v\n!=\n%v", exp, sent)
+	}
+
+	sent = nil
+	gauges := map[string]int64{}
+	for i := 0; i < gcmMaxBatch+1; i++ {
+		gauges["g"+strings.Repeat("x", i)] = 1
+	}
+	if err = g.Flush(Snapshot{Time: time.Unix(100, 0), Gauges: gauges}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("Wrong count of batches: %v", len(sent))
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,211 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"math"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["go_metrics"] = sinkSpec{
+		constructor: func(conf Config) (Sink, error) {
+			return NewGoMetricsBridge(gometrics.DefaultRegistry, nil, ""), nil
+		},
+		description: `
+Writes snapshots into the default registry of rcrowley/go-metrics, easing the
+migration of services that report that registry elsewhere. Counters are written
+to go-metrics counters, gauges to gauges and timings to histograms. Importing
+a registry into stats requires a GoMetricsBridge to be created in code.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+GoMetricsBridge - Bridges stats and a rcrowley/go-metrics registry in both directions. As a Sink,
+each flushed snapshot is written into the registry: counters are added to go-metrics counters,
+gauges update gauges and every timing sample updates a histogram. Each flush also imports the
+registry into the stats Type given, if any, where metrics are written under the import prefix:
+
+	Counter, Meter       - Incr by the change of count since the previous import.
+	Gauge, GaugeFloat64  - Gauge with the value, rounded to an integer.
+	Histogram, Timer     - Incr <path>.count and Gauge <path>.min, .max, .mean, .p50 and .p99.
+
+Metrics written into the registry by the bridge are never imported, and stats imported by the
+bridge are never written into the registry, so that the two directions do not feed each other.
+*/
+type GoMetricsBridge struct {
+	registry gometrics.Registry
+	stats    Type
+	prefix   string
+
+	lastCounts map[string]int64
+	exported   map[string]struct{}
+	imported   map[string]struct{}
+
+	sync.Mutex
+}
+
+// NewGoMetricsBridge - Creates a bridge between a go-metrics registry and stats, where stats may be
+// nil when only writing snapshots into the registry.
+func NewGoMetricsBridge(registry gometrics.Registry, stats Type, prefix string) *GoMetricsBridge {
+	return &GoMetricsBridge{
+		registry:   registry,
+		stats:      stats,
+		prefix:     prefix,
+		lastCounts: map[string]int64{},
+		exported:   map[string]struct{}{},
+		imported:   map[string]struct{}{},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Import - Copies the metrics of the registry into stats. This is called on every flush, and may
+// also be called directly when the bridge is not used as a Sink.
+func (g *GoMetricsBridge) Import() {
+	if g.stats == nil {
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+
+	g.registry.Each(func(name string, metric interface{}) {
+		if _, ok := g.exported[name]; ok {
+			return
+		}
+		path := g.prefix + name
+
+		switch m := metric.(type) {
+		case gometrics.Counter:
+			g.importCount(path, m.Count())
+		case gometrics.Meter:
+			g.importCount(path, m.Snapshot().Count())
+		case gometrics.Gauge:
+			g.gauge(path, m.Value())
+		case gometrics.GaugeFloat64:
+			g.gauge(path, int64(math.Floor(m.Value()+0.5)))
+		case gometrics.Histogram:
+			s := m.Snapshot()
+			g.importDistribution(path, s.Count(), s.Min(), s.Max(), s.Mean(), s.Percentile)
+		case gometrics.Timer:
+			s := m.Snapshot()
+			g.importDistribution(path, s.Count(), s.Min(), s.Max(), s.Mean(), s.Percentile)
+		}
+	})
+}
+
+// importCount - Increments a stat by the change of a cumulative count. Must be called whilst
+// holding the lock.
+func (g *GoMetricsBridge) importCount(path string, count int64) {
+	g.imported[path] = struct{}{}
+	if delta := count - g.lastCounts[path]; delta != 0 {
+		g.stats.Incr(path, delta)
+	}
+	g.lastCounts[path] = count
+}
+
+// importDistribution - Writes the count and summary of a histogram or timer. Must be called whilst
+// holding the lock.
+func (g *GoMetricsBridge) importDistribution(
+	path string, count, min, max int64, mean float64, percentile func(float64) float64,
+) {
+	g.importCount(path+".count", count)
+	g.gauge(path+".min", min)
+	g.gauge(path+".max", max)
+	g.gauge(path+".mean", int64(math.Floor(mean+0.5)))
+	g.gauge(path+".p50", int64(math.Floor(percentile(0.5)+0.5)))
+	g.gauge(path+".p99", int64(math.Floor(percentile(0.99)+0.5)))
+}
+
+// gauge - Sets an imported gauge. Must be called whilst holding the lock.
+func (g *GoMetricsBridge) gauge(path string, value int64) {
+	g.imported[path] = struct{}{}
+	g.stats.Gauge(path, value)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// exportable - Returns true if a stat path may be written into the registry, which is the case
+// unless it was imported or the registry holds a metric of the same name that the bridge did not
+// write. Must be called whilst holding the lock.
+func (g *GoMetricsBridge) exportable(path string) bool {
+	if _, ok := g.imported[path]; ok {
+		return false
+	}
+	if _, ok := g.exported[path]; !ok && g.registry.Get(path) != nil {
+		return false
+	}
+	g.exported[path] = struct{}{}
+	return true
+}
+
+// Flush - Writes a snapshot into the registry and then imports the registry into stats.
+func (g *GoMetricsBridge) Flush(snap Snapshot) error {
+	g.Lock()
+	for path, delta := range snap.CounterDeltas {
+		if !g.exportable(path) {
+			continue
+		}
+		if c, ok := g.registry.GetOrRegister(path, gometrics.NewCounter).(gometrics.Counter); ok {
+			c.Inc(delta)
+		}
+	}
+	for path, v := range snap.Gauges {
+		if !g.exportable(path) {
+			continue
+		}
+		if gauge, ok := g.registry.GetOrRegister(path, gometrics.NewGauge).(gometrics.Gauge); ok {
+			gauge.Update(v)
+		}
+	}
+	for path, samples := range snap.Timings {
+		if !g.exportable(path) {
+			continue
+		}
+		newHistogram := func() gometrics.Histogram {
+			return gometrics.NewHistogram(gometrics.NewExpDecaySample(1028, 0.015))
+		}
+		if h, ok := g.registry.GetOrRegister(path, newHistogram).(gometrics.Histogram); ok {
+			for _, s := range samples {
+				h.Update(s)
+			}
+		}
+	}
+	g.Unlock()
+
+	g.Import()
+	return nil
+}
+
+// Close - Does nothing as the registry is owned by the caller.
+func (g *GoMetricsBridge) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestGoMetricsBridgeImport(t *testing.T) {
+	registry := gometrics.NewRegistry()
+	gometrics.GetOrRegisterCounter("requests", registry).Inc(3)
+	gometrics.GetOrRegisterGauge("queue", registry).Update(7)
+	timer := gometrics.NewTimer()
+	registry.Register("latency", timer)
+	timer.Update(2)
+	timer.Update(4)
+
+	stats := newRecorder()
+	bridge := NewGoMetricsBridge(registry, stats, "gm.")
+
+	bridge.Import()
+	gometrics.GetOrRegisterCounter("requests", registry).Inc(2)
+	bridge.Import()
+
+	if exp, act := int64(5), stats.counts["gm.requests"]; exp != act {
+		t.Errorf("Wrong imported counter: %v != %v", exp, act)
+	}
+	if exp, act := int64(7), stats.gauges["gm.queue"]; exp != act {
+		t.Errorf("Wrong imported gauge: %v != %v", exp, act)
+	}
+	if exp, act := int64(2), stats.counts["gm.latency.count"]; exp != act {
+		t.Errorf("Wrong imported timer count: %v != %v", exp, act)
+	}
+	if exp, act := int64(4), stats.gauges["gm.latency.max"]; exp != act {
+		t.Errorf("Wrong imported timer max: %v != %v", exp, act)
+	}
+	if exp, act := int64(3), stats.gauges["gm.latency.mean"]; exp != act {
+		t.Errorf("Wrong imported timer mean: %v != %v", exp, act)
+	}
+}
+
+func TestGoMetricsBridgeFlush(t *testing.T) {
+	registry := gometrics.NewRegistry()
+	gometrics.GetOrRegisterGauge("foreign", registry).Update(1)
+
+	stats := newRecorder()
+	bridge := NewGoMetricsBridge(registry, stats, "gm.")
+
+	for i := 0; i < 2; i++ {
+		snap := Snapshot{
+			Time:          time.Unix(100, 0),
+			CounterDeltas: map[string]int64{"requests": 2},
+			Gauges:        map[string]int64{"queue": 3, "foreign": 9},
+			Timings:       map[string][]int64{"latency": {5}},
+		}
+		if i > 0 {
+			// The second snapshot holds the stat imported by the first flush.
+			snap.Gauges["gm.foreign"] = stats.gauges["gm.foreign"]
+		}
+		err := bridge.Flush(snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if exp, act := int64(4), registry.Get("requests").(gometrics.Counter).Count(); exp != act {
+		t.Errorf("Wrong exported counter: %v != %v", exp, act)
+	}
+	if exp, act := int64(3), registry.Get("queue").(gometrics.Gauge).Value(); exp != act {
+		t.Errorf("Wrong exported gauge: %v != %v", exp, act)
+	}
+	if exp, act := int64(2), registry.Get("latency").(gometrics.Histogram).Count(); exp != act {
+		t.Errorf("Wrong exported histogram count: %v != %v", exp, act)
+	}
+	if exp, act := int64(1), registry.Get("foreign").(gometrics.Gauge).Value(); exp != act {
+		t.Errorf("Foreign gauge was overwritten: %v != %v", exp, act)
+	}
+	if registry.Get("gm.foreign") != nil {
+		t.Error("Imported stat was exported")
+	}
+
+	// Only the foreign gauge is imported, as the others were written by the bridge.
+	if _, ok := stats.gauges["gm.queue"]; ok {
+		t.Error("Exported gauge was imported")
+	}
+	if exp, act := int64(1), stats.gauges["gm.foreign"]; exp != act {
+		t.Errorf("Wrong imported gauge: %v != %v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
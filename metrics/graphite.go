@@ -0,0 +1,173 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["graphite"] = sinkSpec{
+		constructor: NewGraphiteSink,
+		description: `
+Sends snapshots to Graphite (Carbon) using the plaintext protocol, where the dot
+separated path of each stat maps directly onto the Graphite tree. Counters are
+sent as running totals, and timings as a count, minimum, maximum, mean and 99th
+percentile of the samples since the previous flush. The flush interval of the
+sinks type can be lengthened for Graphite alone with 'flush_interval'.
+
+The tags of stats created with metrics.Tagged are flattened into the path of
+the stat as pairs of key and value segments, e.g. 'requests.code.200'. When
+'tag_support' is enabled they are instead sent with the 'name;tag=value' syntax
+of tagged series, which is supported from Graphite 1.1 onwards.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// GraphiteConfig - Config for the Graphite sink.
+type GraphiteConfig struct {
+	Address       string `json:"address" yaml:"address"`
+	Network       string `json:"network" yaml:"network"`
+	Prefix        string `json:"prefix" yaml:"prefix"`
+	FlushInterval string `json:"flush_interval" yaml:"flush_interval"`
+	Timeout       string `json:"timeout" yaml:"timeout"`
+	TagSupport    bool   `json:"tag_support" yaml:"tag_support"`
+}
+
+// NewGraphiteConfig - Creates a GraphiteConfig struct with default values.
+func NewGraphiteConfig() GraphiteConfig {
+	return GraphiteConfig{
+		Address:       "localhost:2003",
+		Network:       "tcp",
+		Prefix:        "",
+		FlushInterval: "",
+		Timeout:       "5s",
+		TagSupport:    false,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// GraphiteSink - A Sink that writes snapshots using the Graphite plaintext protocol.
+type GraphiteSink struct {
+	prefix     string
+	tagSupport bool
+	writer     *lineWriter
+}
+
+// NewGraphiteSink - Create a new Graphite sink.
+func NewGraphiteSink(config Config) (Sink, error) {
+	conf := config.Graphite
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	maxPacket := 0
+	switch conf.Network {
+	case "tcp":
+	case "udp":
+		maxPacket = 1440
+	default:
+		return nil, fmt.Errorf("graphite network not recognised: %v", conf.Network)
+	}
+	return newThrottledSink(&GraphiteSink{
+		prefix:     expandPrefix(conf.Prefix),
+		tagSupport: conf.TagSupport,
+		writer:     newLineWriter(conf.Network, conf.Address, timeout, maxPacket),
+	}, conf.FlushInterval)
+}
+
+// graphiteTagReplacer - Replaces the characters that are not permitted within the tag keys of
+// tagged series, and the spaces that would break a line of the plaintext protocol.
+var graphiteTagReplacer = strings.NewReplacer(";", "_", "~", "_", " ", "_", "!", "_", "^", "_", "=", "_")
+
+// graphiteSegmentReplacer - Replaces the characters that would split a flattened tag into several
+// segments of the path.
+var graphiteSegmentReplacer = strings.NewReplacer(".", "_", " ", "_")
+
+// graphiteTags - Formats the tags of a stat as the suffix of its path, either with the syntax of
+// tagged series or flattened into segments.
+func (g *GraphiteSink) graphiteTags(tags map[string]string) string {
+	var suffix string
+	for _, k := range sortedKeys(tags) {
+		v := tags[k]
+		if len(k) == 0 || len(v) == 0 {
+			continue
+		}
+		if g.tagSupport {
+			suffix += ";" + graphiteTagReplacer.Replace(k) + "=" + strings.Replace(v, " ", "_", -1)
+		} else {
+			suffix += "." + graphiteSegmentReplacer.Replace(k) + "." + graphiteSegmentReplacer.Replace(v)
+		}
+	}
+	return suffix
+}
+
+// graphiteLines - Formats a snapshot as lines of the Graphite plaintext protocol.
+func (g *GraphiteSink) graphiteLines(snap Snapshot) []string {
+	ts := " " + strconv.FormatInt(snap.Time.Unix(), 10)
+
+	lines := []string{}
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		tagSuffix := g.graphiteTags(tags)
+		line := func(suffix string, value int64) string {
+			metric := name + tagSuffix + suffix
+			if g.tagSupport {
+				metric = name + suffix + tagSuffix
+			}
+			return g.prefix + metric + " " + strconv.FormatInt(value, 10) + ts
+		}
+
+		if v, ok := snap.Counters[path]; ok {
+			lines = append(lines, line("", v))
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			lines = append(lines, line("", v))
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				lines = append(lines, line("."+agg.name, agg.value))
+			}
+		}
+	}
+	return lines
+}
+
+// Flush - Writes a snapshot to Graphite.
+func (g *GraphiteSink) Flush(snap Snapshot) error {
+	return g.writer.writeLines(g.graphiteLines(snap))
+}
+
+// Close - Closes the connection to Graphite.
+func (g *GraphiteSink) Close() error {
+	return g.writer.close()
+}
+
+//--------------------------------------------------------------------------------------------------
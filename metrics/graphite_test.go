@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestGraphiteSinkLines(t *testing.T) {
+	g := &GraphiteSink{prefix: "svc."}
+	lines := g.graphiteLines(Snapshot{
+		Time:     time.Unix(100, 0),
+		Counters: map[string]int64{"a": 5},
+		Gauges:   map[string]int64{"b": 2},
+		Timings:  map[string][]int64{"c": {3, 1, 2}},
+	})
+	exp := []string{
+		"svc.a 5 100",
+		"svc.b 2 100",
+		"svc.c.count 3 100",
+		"svc.c.min 1 100",
+		"svc.c.max 3 100",
+		"svc.c.mean 2 100",
+		"svc.c.p99 3 100",
+	}
+	if !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong lines: %v != %v", exp, lines)
+	}
+}
+
+func TestGraphiteSinkTags(t *testing.T) {
+	snap := Snapshot{
+		Time:    time.Unix(100, 0),
+		Gauges:  map[string]int64{Tagged("a", map[string]string{"host": "web.1", "dc": "eu west"}): 1},
+		Timings: map[string][]int64{Tagged("b", map[string]string{"code": "200"}): {2}},
+	}
+
+	g := &GraphiteSink{}
+	exp := []string{
+		"a.dc.eu_west.host.web_1 1 100",
+		"b.code.200.count 1 100",
+		"b.code.200.min 2 100",
+		"b.code.200.max 2 100",
+		"b.code.200.mean 2 100",
+		"b.code.200.p99 2 100",
+	}
+	if lines := g.graphiteLines(snap); !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong flattened lines: %v != %v", exp, lines)
+	}
+
+	g = &GraphiteSink{prefix: "svc.", tagSupport: true}
+	exp = []string{
+		"svc.a;dc=eu_west;host=web.1 1 100",
+		"svc.b.count;code=200 1 100",
+		"svc.b.min;code=200 2 100",
+		"svc.b.max;code=200 2 100",
+		"svc.b.mean;code=200 2 100",
+		"svc.b.p99;code=200 2 100",
+	}
+	if lines := g.graphiteLines(snap); !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong tagged lines: %v != %v", exp, lines)
+	}
+}
+
+func TestGraphiteSinkFlush(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on tcp: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	conf := NewConfig()
+	conf.Graphite.Address = ln.Addr().String()
+	sink, err := NewGraphiteSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err = sink.Flush(Snapshot{Time: time.Unix(100, 0), Gauges: map[string]int64{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case line := <-received:
+		if exp := "a 1 100\n"; exp != line {
+			t.Errorf("Wrong line: %q != %q", exp, line)
+		}
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for line")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,74 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+TimeFunc - Runs fn and records its execution time as a timing metric at stat.latency. The outcome
+is also recorded with RecordOutcome using stat as the prefix, so that every metric is a sibling
+under stat, which types holding stats as a tree such as HTTP require. The error returned by fn is
+passed back to the caller.
+*/
+func TimeFunc(t Type, stat string, fn func() error) error {
+	started := time.Now()
+	err := fn()
+	t.TimingDuration(stat+".latency", time.Since(started))
+	RecordOutcome(t, stat, err)
+	return err
+}
+
+/*
+RecordOutcome - Increments prefix.success when err is nil and prefix.failure otherwise. When t is
+able to hold string values (implements StringSetter) the error string is also stored at
+prefix.last_error.
+*/
+func RecordOutcome(t Type, prefix string, err error) {
+	if err == nil {
+		t.Incr(prefix+".success", 1)
+		return
+	}
+	t.Incr(prefix+".failure", 1)
+	if s, ok := t.(StringSetter); ok {
+		s.SetString(prefix+".last_error", err.Error())
+	}
+}
+
+/*
+Timed - Starts timing stat and returns a func that records the elapsed time when called, intended
+to be deferred at the top of a function:
+
+``` go
+defer metrics.Timed(stats, "path.to.metric")()
+```
+*/
+func Timed(t Type, stat string) func() {
+	started := time.Now()
+	return func() {
+		t.TimingDuration(stat, time.Since(started))
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,182 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeffail/gabs"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// recorder - A Type that keeps hold of every value it receives, for testing.
+type recorder struct {
+	sync.Mutex
+	counts  map[string]int64
+	timings map[string]int64
+	gauges  map[string]int64
+	strings map[string]string
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		counts:  map[string]int64{},
+		timings: map[string]int64{},
+		gauges:  map[string]int64{},
+		strings: map[string]string{},
+	}
+}
+
+func (r *recorder) Incr(path string, count int64) error {
+	r.Lock()
+	r.counts[path] += count
+	r.Unlock()
+	return nil
+}
+
+func (r *recorder) Decr(path string, count int64) error {
+	r.Lock()
+	r.counts[path] -= count
+	r.Unlock()
+	return nil
+}
+
+func (r *recorder) Timing(path string, delta int64) error {
+	r.Lock()
+	r.timings[path] = delta
+	r.Unlock()
+	return nil
+}
+
+func (r *recorder) TimingDuration(path string, d time.Duration) error {
+	return r.Timing(path, int64(d))
+}
+
+func (r *recorder) Gauge(path string, value int64) error {
+	r.Lock()
+	r.gauges[path] = value
+	r.Unlock()
+	return nil
+}
+
+func (r *recorder) SetString(path string, value string) error {
+	r.Lock()
+	r.strings[path] = value
+	r.Unlock()
+	return nil
+}
+
+func (r *recorder) Close() error { return nil }
+
+//--------------------------------------------------------------------------------------------------
+
+func TestTimeFunc(t *testing.T) {
+	rec := newRecorder()
+
+	if err := TimeFunc(rec, "foo", func() error { return nil }); err != nil {
+		t.Error(err)
+	}
+	errFoo := errors.New("foo")
+	if err := TimeFunc(rec, "foo", func() error { return errFoo }); err != errFoo {
+		t.Errorf("Wrong error returned: %v != %v", err, errFoo)
+	}
+
+	if _, ok := rec.timings["foo.latency"]; !ok {
+		t.Error("Timing was not recorded")
+	}
+	if exp, act := int64(1), rec.counts["foo.success"]; exp != act {
+		t.Errorf("Wrong success count: %v != %v", exp, act)
+	}
+	if exp, act := int64(1), rec.counts["foo.failure"]; exp != act {
+		t.Errorf("Wrong failure count: %v != %v", exp, act)
+	}
+}
+
+func TestTimeFuncHTTP(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+	conf.HTTP.CollectInterval = ""
+
+	h, err := NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	TimeFunc(h, "foo", func() error { return nil })
+	TimeFunc(h, "foo", func() error { return errors.New("foo") })
+
+	rec := httptest.NewRecorder()
+	h.(*HTTP).JSONHandler()(rec, httptest.NewRequest("GET", "/stats", nil))
+	blob, err := gabs.ParseJSON(rec.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"service.foo.latency", "service.foo.success", "service.foo.failure"} {
+		if blob.Path(path).Data() == nil {
+			t.Errorf("Missing stat %v: %s", path, rec.Body.Bytes())
+		}
+	}
+}
+
+func TestRecordOutcome(t *testing.T) {
+	rec := newRecorder()
+
+	RecordOutcome(rec, "foo", nil)
+	RecordOutcome(rec, "foo", nil)
+	RecordOutcome(rec, "foo", errors.New("first"))
+	RecordOutcome(rec, "foo", errors.New("second"))
+
+	if exp, act := int64(2), rec.counts["foo.success"]; exp != act {
+		t.Errorf("Wrong success count: %v != %v", exp, act)
+	}
+	if exp, act := int64(2), rec.counts["foo.failure"]; exp != act {
+		t.Errorf("Wrong failure count: %v != %v", exp, act)
+	}
+	if exp, act := "second", rec.strings["foo.last_error"]; exp != act {
+		t.Errorf("Wrong last error: %v != %v", exp, act)
+	}
+
+	// Types without string support must still count outcomes.
+	RecordOutcome(DudType{}, "foo", errors.New("ignored"))
+}
+
+func TestTimed(t *testing.T) {
+	rec := newRecorder()
+
+	func() {
+		defer Timed(rec, "bar")()
+		<-time.After(time.Millisecond)
+	}()
+
+	if act := rec.timings["bar"]; act < int64(time.Millisecond) {
+		t.Errorf("Timing too short: %v", time.Duration(act))
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
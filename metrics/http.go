@@ -23,10 +23,15 @@ THE SOFTWARE.
 package metrics
 
 import (
+	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,7 +45,10 @@ func init() {
 		constructor: NewHTTP,
 		description: `
 Benthos can host its own stats endpoint, where a GET request will receive a JSON
-blob of all metrics tracked within Benthos.`,
+blob of all metrics tracked within Benthos. When expvar_name is set all stats are
+also mirrored into the expvar variable of that name keyed by their full paths,
+and the expvar variables listed in expvar_import ("*" for all) are copied into
+the blob under "expvar". Either option also serves /debug/vars.`,
 	}
 }
 
@@ -55,17 +63,46 @@ var (
 
 // HTTPConfig - Config for the HTTP metrics type.
 type HTTPConfig struct {
-	Prefix  string `json:"stats_prefix" yaml:"stats_prefix"`
-	Address string `json:"address" yaml:"address"`
-	Path    string `json:"path" yaml:"path"`
+	Prefix          string `json:"stats_prefix" yaml:"stats_prefix"`
+	Address         string `json:"address" yaml:"address"`
+	Path            string `json:"path" yaml:"path"`
+	CollectInterval string `json:"collect_interval" yaml:"collect_interval"`
+	PrettyPrint     bool   `json:"pretty_print" yaml:"pretty_print"`
+	PersistPath     string `json:"persist_path" yaml:"persist_path"`
+	PersistInterval string `json:"persist_interval" yaml:"persist_interval"`
+
+	Sections         map[string]HTTPSectionConfig `json:"sections" yaml:"sections"`
+	InternalsSection string                       `json:"internals_section" yaml:"internals_section"`
+
+	ExpvarName   string   `json:"expvar_name" yaml:"expvar_name"`
+	ExpvarImport []string `json:"expvar_import" yaml:"expvar_import"`
+}
+
+// HTTPSectionConfig - Config for a named section of the HTTP JSON blob. Stats whose path begins
+// with the name of a section are stored under the root of that section rather than the stats
+// prefix, e.g. with a section "runtime" the stat "runtime.gc.count" is stored at the path
+// "<root>.gc.count". When ResetOnRead is set the section is cleared each time the stats are read.
+type HTTPSectionConfig struct {
+	Root        string `json:"root" yaml:"root"`
+	ResetOnRead bool   `json:"reset_on_read" yaml:"reset_on_read"`
 }
 
 // NewHTTPConfig - Creates an HTTPConfig struct with default values.
 func NewHTTPConfig() HTTPConfig {
 	return HTTPConfig{
-		Prefix:  "service",
-		Address: "localhost:4040",
-		Path:    "/stats",
+		Prefix:          "service",
+		Address:         "localhost:4040",
+		Path:            "/stats",
+		CollectInterval: "1s",
+		PrettyPrint:     false,
+		PersistPath:     "",
+		PersistInterval: "1m",
+
+		Sections:         map[string]HTTPSectionConfig{},
+		InternalsSection: "",
+
+		ExpvarName:   "",
+		ExpvarImport: []string{},
 	}
 }
 
@@ -79,35 +116,116 @@ type HTTP struct {
 	flatMetrics map[string]int64
 	pathPrefix  string
 	timestamp   time.Time
+	timingUnit  time.Duration
+	clampZero   bool
+	sections    map[string]*httpSection
+	subscribers map[<-chan []byte]chan []byte
+
+	collectInterval time.Duration
+	persistInterval time.Duration
+	reset           chan struct{}
+	quit            chan struct{}
+	done            chan struct{}
+	closeOnce       sync.Once
 
 	sync.Mutex
 }
 
+// httpSection - A section of the JSON blob with its own root path.
+type httpSection struct {
+	root        string
+	resetOnRead bool
+	json        *gabs.Container
+}
+
+// parseHTTPIntervals - Parses the collect and persist intervals of a config, where an interval of
+// zero means the corresponding task is disabled.
+func parseHTTPIntervals(conf HTTPConfig) (collect, persist time.Duration, err error) {
+	if len(conf.CollectInterval) > 0 {
+		if collect, err = time.ParseDuration(conf.CollectInterval); err != nil {
+			return 0, 0, fmt.Errorf("failed to parse collect interval: %v", err)
+		}
+	}
+	if len(conf.PersistPath) > 0 && len(conf.PersistInterval) > 0 {
+		if persist, err = time.ParseDuration(conf.PersistInterval); err != nil {
+			return 0, 0, fmt.Errorf("failed to parse persist interval: %v", err)
+		}
+	}
+	return collect, persist, nil
+}
+
 // NewHTTP - Create and return a new HTTP object.
 func NewHTTP(config Config) (Type, error) {
-	var jsonRoot, json *gabs.Container
+	timingUnit, err := parseTimingUnit(config.TimingUnit)
+	if err != nil {
+		return nil, err
+	}
+	collectInterval, persistInterval, err := parseHTTPIntervals(config.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	config.HTTP.Prefix = expandPrefix(config.HTTP.Prefix)
+
+	var jsonRoot, jsonSection *gabs.Container
 	var pathPrefix string
 
 	jsonRoot = gabs.New()
 	if len(config.HTTP.Prefix) > 0 {
 		pathPrefix = config.HTTP.Prefix + "."
-		json, _ = jsonRoot.ObjectP(config.HTTP.Prefix)
+		jsonSection, _ = jsonRoot.ObjectP(config.HTTP.Prefix)
 	} else {
-		json = jsonRoot
+		jsonSection = jsonRoot
 	}
 
 	t := &HTTP{
 		config:      config.HTTP,
 		jsonRoot:    jsonRoot,
-		json:        json,
+		json:        jsonSection,
 		flatMetrics: map[string]int64{},
 		pathPrefix:  pathPrefix,
 		timestamp:   time.Now(),
+		timingUnit:  timingUnit,
+		clampZero:   config.ClampZero,
+		sections:    map[string]*httpSection{},
+		subscribers: map[<-chan []byte]chan []byte{},
+
+		collectInterval: collectInterval,
+		persistInterval: persistInterval,
+		reset:           make(chan struct{}, 1),
+		quit:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	for name, sConf := range config.HTTP.Sections {
+		root := sConf.Root
+		if len(root) == 0 {
+			root = name
+		}
+		sJSON, err := jsonRoot.ObjectP(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create section %v: %v", name, err)
+		}
+		t.sections[name] = &httpSection{
+			root:        root,
+			resetOnRead: sConf.ResetOnRead,
+			json:        sJSON,
+		}
 	}
+	if len(config.HTTP.PersistPath) > 0 {
+		if err = t.restore(); err != nil {
+			return nil, err
+		}
+	}
+	t.publishExpvar()
+	t.updateInternals()
 
+	go t.loop()
 	go func() {
 		mux := http.NewServeMux()
 		mux.HandleFunc(config.HTTP.Path, t.JSONHandler())
+		if len(config.HTTP.ExpvarName) > 0 || len(config.HTTP.ExpvarImport) > 0 {
+			mux.Handle("/debug/vars", expvar.Handler())
+		}
 
 		http.ListenAndServe(config.HTTP.Address, mux)
 	}()
@@ -117,18 +235,22 @@ func NewHTTP(config Config) (Type, error) {
 
 //--------------------------------------------------------------------------------------------------
 
-// JSONHandler - Returns a handler for accessing metrics as a JSON blob.
+// JSONHandler - Returns a handler for accessing metrics as a JSON blob. The blob is indented when
+// pretty printing is configured or when the request has a "pretty" query parameter.
 func (h *HTTP) JSONHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		uptime := time.Since(h.timestamp).String()
-		goroutines := runtime.NumGoroutine()
+		h.updateInternals()
 
-		h.Lock()
-
-		h.json.SetP(fmt.Sprintf("%v", uptime), "uptime")
-		h.json.SetP(goroutines, "goroutines")
-		blob := h.jsonRoot.Bytes()
+		_, pretty := r.URL.Query()["pretty"]
+		pretty = pretty || h.config.PrettyPrint
 
+		h.Lock()
+		blob := h.marshal(pretty)
+		for name, section := range h.sections {
+			if section.resetOnRead {
+				h.resetSection(name, section)
+			}
+		}
 		h.Unlock()
 
 		w.Header().Set("Content-Type", "application/json")
@@ -136,14 +258,191 @@ func (h *HTTP) JSONHandler() http.HandlerFunc {
 	}
 }
 
+// marshal - Serializes the JSON blob, indented when pretty is set. Must be called whilst holding the
+// lock.
+func (h *HTTP) marshal(pretty bool) []byte {
+	if pretty {
+		return h.jsonRoot.BytesIndent("", "\t")
+	}
+	return h.jsonRoot.Bytes()
+}
+
+// updateInternals - Refreshes the stats we track about the service itself, including any imported
+// expvar variables.
+func (h *HTTP) updateInternals() {
+	h.importExpvars()
+
+	uptime := time.Since(h.timestamp).String()
+	goroutines := runtime.NumGoroutine()
+
+	h.Lock()
+	var prefix string
+	if len(h.config.InternalsSection) > 0 {
+		prefix = h.config.InternalsSection + "."
+	}
+	h.set(prefix+"uptime", fmt.Sprintf("%v", uptime))
+	h.set(prefix+"goroutines", goroutines)
+	h.Unlock()
+}
+
+// set - Sets a value in the JSON blob at the path of a stat, taking sections into account. Must be
+// called whilst holding the lock.
+func (h *HTTP) set(stat string, value interface{}) {
+	if i := strings.Index(stat, "."); i > 0 {
+		if section, ok := h.sections[stat[:i]]; ok {
+			section.json.SetP(value, stat[i+1:])
+			return
+		}
+	}
+	h.json.SetP(value, stat)
+}
+
+// resetSection - Clears all values of a section. Must be called whilst holding the lock.
+func (h *HTTP) resetSection(name string, section *httpSection) {
+	section.json, _ = h.jsonRoot.ObjectP(section.root)
+	for stat := range h.flatMetrics {
+		if strings.HasPrefix(stat, name+".") {
+			delete(h.flatMetrics, stat)
+		}
+	}
+}
+
+// loop - Refreshes internal stats and persists counters on their intervals until the HTTP object is
+// closed. This is independent of when the stats are read so that internals do not go stale between
+// requests. An interval of zero disables the corresponding task.
+func (h *HTTP) loop() {
+	defer close(h.done)
+	for h.runTickers() {
+	}
+}
+
+// runTickers - Runs tasks on the currently configured intervals until either the intervals are
+// reset, in which case true is returned, or the HTTP object is closed.
+func (h *HTTP) runTickers() bool {
+	h.Lock()
+	collectInterval, persistInterval := h.collectInterval, h.persistInterval
+	h.Unlock()
+
+	var collectChan, persistChan <-chan time.Time
+	if collectInterval > 0 {
+		ticker := time.NewTicker(collectInterval)
+		defer ticker.Stop()
+		collectChan = ticker.C
+	}
+	if persistInterval > 0 {
+		ticker := time.NewTicker(persistInterval)
+		defer ticker.Stop()
+		persistChan = ticker.C
+	}
+	for {
+		select {
+		case <-collectChan:
+			h.updateInternals()
+			h.publish()
+		case <-persistChan:
+			h.persist()
+		case <-h.reset:
+			return true
+		case <-h.quit:
+			return false
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// restore - Reads previously persisted counters from the persist path, if the file exists.
+func (h *HTTP) restore() error {
+	blob, err := ioutil.ReadFile(h.config.PersistPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read persisted stats: %v", err)
+	}
+	counters := map[string]int64{}
+	if err = json.Unmarshal(blob, &counters); err != nil {
+		return fmt.Errorf("failed to parse persisted stats: %v", err)
+	}
+
+	h.Lock()
+	for stat, total := range counters {
+		h.flatMetrics[stat] = total
+		h.set(stat, total)
+	}
+	h.Unlock()
+	return nil
+}
+
+// persist - Writes all counters to the persist path. The file is replaced atomically so that a
+// crash part way through cannot corrupt previously persisted counters.
+func (h *HTTP) persist() error {
+	h.Lock()
+	persistPath := h.config.PersistPath
+	blob, err := json.Marshal(h.flatMetrics)
+	h.Unlock()
+	if err != nil || len(persistPath) == 0 {
+		return err
+	}
+
+	tmpPath := persistPath + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, persistPath)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Subscribe - Returns a channel that receives a JSON snapshot of all stats on every collect interval,
+allowing live stats to be streamed without polling the HTTP endpoint. A subscriber that falls behind
+only receives the most recent snapshot. The channel is closed when the HTTP object is closed, or
+when it is passed to Unsubscribe.
+*/
+func (h *HTTP) Subscribe() <-chan []byte {
+	c := make(chan []byte, 1)
+
+	h.Lock()
+	h.subscribers[c] = c
+	h.Unlock()
+	return c
+}
+
+// Unsubscribe - Stops a channel returned by Subscribe from receiving snapshots and closes it.
+func (h *HTTP) Unsubscribe(c <-chan []byte) {
+	h.Lock()
+	if sub, ok := h.subscribers[c]; ok {
+		delete(h.subscribers, c)
+		close(sub)
+	}
+	h.Unlock()
+}
+
+// publish - Sends a snapshot to each subscriber without blocking.
+func (h *HTTP) publish() {
+	h.Lock()
+	defer h.Unlock()
+
+	if len(h.subscribers) == 0 {
+		return
+	}
+	blob := h.marshal(h.config.PrettyPrint)
+	for _, sub := range h.subscribers {
+		select {
+		case <-sub:
+		default:
+		}
+		sub <- blob
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
 // Incr - Increment a stat by a value.
 func (h *HTTP) Incr(stat string, value int64) error {
 	h.Lock()
-	total, _ := h.flatMetrics[stat]
-	total += value
-
-	h.flatMetrics[stat] = total
-	h.json.SetP(total, stat)
+	h.addCounter(stat, value)
 	h.Unlock()
 	return nil
 }
@@ -151,36 +450,131 @@ func (h *HTTP) Incr(stat string, value int64) error {
 // Decr - Decrement a stat by a value.
 func (h *HTTP) Decr(stat string, value int64) error {
 	h.Lock()
-	total, _ := h.flatMetrics[stat]
-	total -= value
-
-	h.flatMetrics[stat] = total
-	h.json.SetP(total, stat)
+	h.addCounter(stat, negate(value))
 	h.Unlock()
 	return nil
 }
 
+// addCounter - Adds a delta to a counter, recording when the result is clamped. Must be called
+// whilst holding the lock.
+func (h *HTTP) addCounter(stat string, delta int64) {
+	total, clamped := addCounter(h.flatMetrics[stat], delta, h.clampZero)
+	h.flatMetrics[stat] = total
+	h.set(stat, total)
+
+	if clamped {
+		total, _ = addCounter(h.flatMetrics[ClampedCountPath], 1, false)
+		h.flatMetrics[ClampedCountPath] = total
+		h.set(ClampedCountPath, total)
+	}
+}
+
 // Timing - Set a stat representing a duration.
 func (h *HTTP) Timing(stat string, delta int64) error {
-	readable := time.Duration(delta).String()
+	readable := (time.Duration(delta) * h.unit()).String()
 
 	h.Lock()
-	h.json.SetP(delta, stat)
-	h.json.SetP(readable, stat+"_readable")
+	h.set(stat, delta)
+	h.set(stat+"_readable", readable)
 	h.Unlock()
 	return nil
 }
 
+// TimingDuration - Set a stat representing a duration, in the configured timing unit.
+func (h *HTTP) TimingDuration(stat string, d time.Duration) error {
+	return h.Timing(stat, int64(d/h.unit()))
+}
+
+// unit - Returns the configured timing unit, defaulting to nanoseconds.
+func (h *HTTP) unit() time.Duration {
+	if h.timingUnit <= 0 {
+		return time.Nanosecond
+	}
+	return h.timingUnit
+}
+
 // Gauge - Set a stat as a gauge value.
 func (h *HTTP) Gauge(stat string, value int64) error {
 	h.Lock()
-	h.json.SetP(value, stat)
+	h.set(stat, value)
+	h.Unlock()
+	return nil
+}
+
+/*
+Reconfigure - Applies the stats prefix, pretty print, collect, persist and clamping fields of a new
+config without rebuilding the HTTP object. Stats already held under the previous prefix are moved
+to the new prefix. A prefix cannot be added to or removed from an HTTP object at runtime.
+*/
+func (h *HTTP) Reconfigure(config Config) error {
+	conf := config.HTTP
+	conf.Prefix = expandPrefix(conf.Prefix)
+
+	collectInterval, persistInterval, err := parseHTTPIntervals(conf)
+	if err != nil {
+		return err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if conf.Prefix != h.config.Prefix {
+		if len(conf.Prefix) == 0 || len(h.config.Prefix) == 0 {
+			return errors.New("stats prefix cannot be added or removed at runtime")
+		}
+		data := h.json.Data()
+		h.jsonRoot.DeleteP(h.config.Prefix)
+		if h.json, err = h.jsonRoot.SetP(data, conf.Prefix); err != nil {
+			return err
+		}
+		h.pathPrefix = conf.Prefix + "."
+	}
+
+	h.config.Prefix = conf.Prefix
+	h.config.PrettyPrint = conf.PrettyPrint
+	h.config.CollectInterval = conf.CollectInterval
+	h.config.PersistPath = conf.PersistPath
+	h.config.PersistInterval = conf.PersistInterval
+	h.config.InternalsSection = conf.InternalsSection
+
+	h.clampZero = config.ClampZero
+	h.collectInterval, h.persistInterval = collectInterval, persistInterval
+	select {
+	case h.reset <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetString - Set a stat as a string value.
+func (h *HTTP) SetString(stat string, value string) error {
+	h.Lock()
+	h.set(stat, value)
 	h.Unlock()
 	return nil
 }
 
-// Close - Stops the HTTP object from aggregating metrics and cleans up resources.
+// Close - Stops the HTTP object from aggregating metrics and cleans up resources. The loop is
+// stopped before counters are persisted for the last time so that the two never write the persist
+// path at once. It is safe to call Close more than once.
 func (h *HTTP) Close() error {
+	h.closeOnce.Do(func() {
+		if h.quit != nil {
+			close(h.quit)
+		}
+		if h.done != nil {
+			<-h.done
+		}
+		h.persist()
+		h.unpublishExpvar()
+
+		h.Lock()
+		for c, sub := range h.subscribers {
+			delete(h.subscribers, c)
+			close(sub)
+		}
+		h.Unlock()
+	})
 	return nil
 }
 
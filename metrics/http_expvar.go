@@ -0,0 +1,163 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"strings"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// The HTTP types that expvar variables mirror, by variable name. An expvar variable cannot be
+// removed once published, so each name is published once and reads whichever HTTP type currently
+// owns it.
+var (
+	expvarMut     sync.Mutex
+	expvarTargets = map[string]*HTTP{}
+)
+
+// publishExpvar - Mirrors the stats of the HTTP type into the expvar variable of the config, taking
+// over the variable from any HTTP type that previously published it.
+func (h *HTTP) publishExpvar() {
+	name := h.config.ExpvarName
+	if len(name) == 0 {
+		return
+	}
+
+	expvarMut.Lock()
+	defer expvarMut.Unlock()
+
+	if _, published := expvarTargets[name]; !published && expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			expvarMut.Lock()
+			target := expvarTargets[name]
+			expvarMut.Unlock()
+			if target == nil {
+				return map[string]interface{}{}
+			}
+			return target.flatStats()
+		}))
+	}
+	expvarTargets[name] = h
+}
+
+// unpublishExpvar - Stops the expvar variable of the HTTP type from mirroring its stats.
+func (h *HTTP) unpublishExpvar() {
+	expvarMut.Lock()
+	if expvarTargets[h.config.ExpvarName] == h {
+		expvarTargets[h.config.ExpvarName] = nil
+	}
+	expvarMut.Unlock()
+}
+
+// flatStats - Returns every value of the JSON blob keyed by its full dot separated path, excluding
+// values imported from expvar.
+func (h *HTTP) flatStats() map[string]interface{} {
+	h.Lock()
+	defer h.Unlock()
+
+	flat := map[string]interface{}{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			flat[prefix] = v
+			return
+		}
+		for k, child := range obj {
+			path := k
+			if len(prefix) > 0 {
+				path = prefix + "." + k
+			}
+			if path == h.expvarSectionPath() {
+				continue
+			}
+			walk(path, child)
+		}
+	}
+	walk("", h.jsonRoot.Data())
+	return flat
+}
+
+// expvarSectionPath - Returns the full path of the JSON blob that expvar variables are imported
+// into, taking sections into account like set. Must be called whilst holding the lock.
+func (h *HTTP) expvarSectionPath() string {
+	stat := "expvar"
+	if len(h.config.InternalsSection) > 0 {
+		stat = h.config.InternalsSection + ".expvar"
+	}
+	if i := strings.Index(stat, "."); i > 0 {
+		if section, ok := h.sections[stat[:i]]; ok {
+			return section.root + stat[i:]
+		}
+	}
+	return h.pathPrefix + stat
+}
+
+// importExpvars - Copies the expvar variables listed in the config into the JSON blob under the key
+// "expvar" of the internals, where "*" imports every variable other than the one mirroring this
+// HTTP type.
+func (h *HTTP) importExpvars() {
+	h.Lock()
+	names := h.config.ExpvarImport
+	own := h.config.ExpvarName
+	h.Unlock()
+	if len(names) == 0 {
+		return
+	}
+
+	all := false
+	wanted := map[string]struct{}{}
+	for _, name := range names {
+		if name == "*" {
+			all = true
+		}
+		wanted[name] = struct{}{}
+	}
+
+	values := map[string]interface{}{}
+	expvar.Do(func(kv expvar.KeyValue) {
+		if _, ok := wanted[kv.Key]; (!all && !ok) || kv.Key == own {
+			return
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(kv.Value.String()), &v); err == nil {
+			values[kv.Key] = v
+		}
+	})
+
+	h.Lock()
+	var prefix string
+	if len(h.config.InternalsSection) > 0 {
+		prefix = h.config.InternalsSection + "."
+	}
+	for k, v := range values {
+		h.set(prefix+"expvar."+k, v)
+	}
+	h.Unlock()
+}
+
+//--------------------------------------------------------------------------------------------------
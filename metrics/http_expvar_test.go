@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeffail/gabs"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestHTTPExpvarMirror(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+	conf.HTTP.CollectInterval = ""
+	conf.HTTP.ExpvarName = "test_http_mirror"
+
+	h, err := NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Incr("foo.bar", 2)
+	h.Gauge("baz", 5)
+
+	flat := map[string]interface{}{}
+	if err = json.Unmarshal([]byte(expvar.Get("test_http_mirror").String()), &flat); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := float64(2), flat["service.foo.bar"]; exp != act {
+		t.Errorf("Wrong mirrored counter: %v != %v", exp, act)
+	}
+	if exp, act := float64(5), flat["service.baz"]; exp != act {
+		t.Errorf("Wrong mirrored gauge: %v != %v", exp, act)
+	}
+	h.Close()
+
+	// A new HTTP type takes over the variable without a duplicate publish.
+	h, err = NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Incr("foo.bar", 7)
+
+	flat = map[string]interface{}{}
+	json.Unmarshal([]byte(expvar.Get("test_http_mirror").String()), &flat)
+	if exp, act := float64(7), flat["service.foo.bar"]; exp != act {
+		t.Errorf("Wrong mirrored counter after takeover: %v != %v", exp, act)
+	}
+}
+
+func TestHTTPExpvarImport(t *testing.T) {
+	// The variable is published once per process, so that the test can be run repeatedly.
+	imported, ok := expvar.Get("test_http_import").(*expvar.Int)
+	if !ok {
+		imported = expvar.NewInt("test_http_import")
+	}
+	imported.Set(42)
+
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+	conf.HTTP.CollectInterval = ""
+	conf.HTTP.ExpvarName = "test_http_import_mirror"
+	conf.HTTP.ExpvarImport = []string{"test_http_import"}
+
+	h, err := NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	rec := httptest.NewRecorder()
+	h.(*HTTP).JSONHandler()(rec, httptest.NewRequest("GET", "/stats", nil))
+	blob, err := gabs.ParseJSON(rec.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := float64(42), blob.Path("service.expvar.test_http_import").Data(); exp != act {
+		t.Errorf("Wrong imported expvar: %v != %v", exp, act)
+	}
+	if blob.Path("service.expvar.cmdline").Data() != nil {
+		t.Error("Unlisted expvar was imported")
+	}
+
+	flat := h.(*HTTP).flatStats()
+	if _, ok := flat["service.expvar.test_http_import"]; ok {
+		t.Error("Imported expvar was mirrored")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -22,7 +22,16 @@ THE SOFTWARE.
 
 package metrics
 
-import "testing"
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeffail/gabs"
+)
 
 func TestHTTPInterface(t *testing.T) {
 	o := &HTTP{}
@@ -30,3 +39,195 @@ func TestHTTPInterface(t *testing.T) {
 		t.Errorf("Type does not satisfy Type interface.")
 	}
 }
+
+func TestHTTPSections(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+	conf.HTTP.CollectInterval = ""
+	conf.HTTP.InternalsSection = "runtime"
+	conf.HTTP.Sections = map[string]HTTPSectionConfig{
+		"runtime": {Root: "runtime"},
+		"batch":   {Root: "jobs", ResetOnRead: true},
+	}
+
+	h, err := NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Incr("foo.bar", 2)
+	h.Incr("runtime.gc", 1)
+	h.Incr("batch.done", 3)
+
+	read := func() *gabs.Container {
+		rec := httptest.NewRecorder()
+		h.(*HTTP).JSONHandler()(rec, httptest.NewRequest("GET", "/stats", nil))
+		blob, err := gabs.ParseJSON(rec.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blob
+	}
+
+	blob := read()
+	if exp, act := float64(2), blob.Path("service.foo.bar").Data(); exp != act {
+		t.Errorf("Wrong value for service.foo.bar: %v != %v", exp, act)
+	}
+	if exp, act := float64(1), blob.Path("runtime.gc").Data(); exp != act {
+		t.Errorf("Wrong value for runtime.gc: %v != %v", exp, act)
+	}
+	if blob.Path("runtime.goroutines").Data() == nil {
+		t.Error("Internals missing from runtime section")
+	}
+	if exp, act := float64(3), blob.Path("jobs.done").Data(); exp != act {
+		t.Errorf("Wrong value for jobs.done: %v != %v", exp, act)
+	}
+
+	h.Incr("batch.done", 1)
+	blob = read()
+	if exp, act := float64(1), blob.Path("jobs.done").Data(); exp != act {
+		t.Errorf("Section was not reset on read: %v != %v", exp, act)
+	}
+}
+
+func TestHTTPMarshal(t *testing.T) {
+	h := &HTTP{jsonRoot: gabs.New()}
+	h.json = h.jsonRoot
+
+	h.set("b.z", 1)
+	h.set("b.a", 2)
+	h.set("a", 3)
+
+	if exp, act := `{"a":3,"b":{"a":2,"z":1}}`, string(h.marshal(false)); exp != act {
+		t.Errorf("Wrong serialization: %v != %v", exp, act)
+	}
+	if exp, act := "{\n\t\"a\": 3,\n\t\"b\": {\n\t\t\"a\": 2,\n\t\t\"z\": 1\n\t}\n}", string(h.marshal(true)); exp != act {
+		t.Errorf("Wrong pretty serialization: %v != %v", exp, act)
+	}
+}
+
+func TestHTTPSubscribe(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+	conf.HTTP.CollectInterval = "10ms"
+
+	h, err := NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Incr("foo", 5)
+
+	sub := h.(*HTTP).Subscribe()
+	select {
+	case blob := <-sub:
+		snap, err := gabs.ParseJSON(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := float64(5), snap.Path("service.foo").Data(); exp != act {
+			t.Errorf("Wrong value in snapshot: %v != %v", exp, act)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for snapshot")
+	}
+
+	h.Close()
+	if _, open := <-sub; open {
+		t.Error("Subscription was not closed")
+	}
+}
+
+func TestHTTPPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+	conf.HTTP.PersistPath = filepath.Join(dir, "stats.json")
+
+	h, err := NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Incr("foo", 5)
+	h.Decr("bar", 2)
+	h.Close()
+
+	if h, err = NewHTTP(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Incr("foo", 1)
+
+	if exp, act := int64(6), h.(*HTTP).flatMetrics["foo"]; exp != act {
+		t.Errorf("Wrong restored counter: %v != %v", exp, act)
+	}
+	if exp, act := int64(-2), h.(*HTTP).flatMetrics["bar"]; exp != act {
+		t.Errorf("Wrong restored counter: %v != %v", exp, act)
+	}
+}
+
+func TestHTTPPersistOnClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+	conf.HTTP.PersistPath = filepath.Join(dir, "stats.json")
+	conf.HTTP.PersistInterval = "1ms"
+
+	for i := 0; i < 20; i++ {
+		h, err := NewHTTP(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Incr("foo", 1)
+		h.Close()
+
+		if exp, act := int64(i+1), h.(*HTTP).flatMetrics["foo"]; exp != act {
+			t.Fatalf("Wrong restored counter: %v != %v", exp, act)
+		}
+		if _, err = os.Stat(conf.HTTP.PersistPath + ".tmp"); !os.IsNotExist(err) {
+			t.Fatalf("Expected no temporary file after close: %v", err)
+		}
+	}
+}
+
+func TestHTTPCloseTwice(t *testing.T) {
+	conf := NewConfig()
+	conf.HTTP.Address = "localhost:0"
+
+	h, err := NewHTTP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPClampZero(t *testing.T) {
+	h := &HTTP{jsonRoot: gabs.New(), flatMetrics: map[string]int64{}, clampZero: true}
+	h.json = h.jsonRoot
+
+	h.Incr("foo", 1)
+	h.Decr("foo", 3)
+	h.Decr("foo", 1)
+
+	if exp, act := int64(0), h.flatMetrics["foo"]; exp != act {
+		t.Errorf("Counter was not clamped: %v != %v", exp, act)
+	}
+	if exp, act := int64(2), h.flatMetrics[ClampedCountPath]; exp != act {
+		t.Errorf("Wrong clamped count: %v != %v", exp, act)
+	}
+}
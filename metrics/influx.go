@@ -0,0 +1,212 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["influxdb"] = sinkSpec{
+		constructor: NewInfluxSink,
+		description: `
+Writes snapshots to InfluxDB over HTTP using the line protocol. The name of each
+stat is used as the measurement, tags of stats created with metrics.Tagged and
+the tags of the config become Influx tags, and values are written as fields:
+'value' for counters and gauges, and 'count', 'min', 'max', 'mean' and 'p99' for
+timings. Version 1 servers are written to by database, optionally with a username
+and password, and version 2 servers by organisation and bucket with a token.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// InfluxConfig - Config for the InfluxDB sink.
+type InfluxConfig struct {
+	URL     string            `json:"url" yaml:"url"`
+	Version int               `json:"version" yaml:"version"`
+	Timeout string            `json:"timeout" yaml:"timeout"`
+	Tags    map[string]string `json:"tags" yaml:"tags"`
+
+	Database        string `json:"database" yaml:"database"`
+	RetentionPolicy string `json:"retention_policy" yaml:"retention_policy"`
+	Username        string `json:"username" yaml:"username"`
+	Password        string `json:"password" yaml:"password"`
+
+	Organisation string `json:"organisation" yaml:"organisation"`
+	Bucket       string `json:"bucket" yaml:"bucket"`
+	Token        string `json:"token" yaml:"token"`
+}
+
+// NewInfluxConfig - Creates an InfluxConfig struct with default values.
+func NewInfluxConfig() InfluxConfig {
+	return InfluxConfig{
+		URL:     "http://localhost:8086",
+		Version: 1,
+		Timeout: "5s",
+		Tags:    map[string]string{},
+
+		Database:        "benthos",
+		RetentionPolicy: "",
+		Username:        "",
+		Password:        "",
+
+		Organisation: "",
+		Bucket:       "",
+		Token:        "",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// InfluxSink - A Sink that writes snapshots to InfluxDB using the line protocol.
+type InfluxSink struct {
+	writeURL string
+	headers  map[string]string
+	tags     map[string]string
+	sender   func(url string, body []byte, headers map[string]string) error
+}
+
+// NewInfluxSink - Create a new InfluxDB sink.
+func NewInfluxSink(config Config) (Sink, error) {
+	conf := config.Influx
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	writeURL, headers, err := influxWriteURL(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &InfluxSink{
+		writeURL: writeURL,
+		headers:  headers,
+		tags:     conf.Tags,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+// influxWriteURL - Returns the URL and headers for writing points according to the version of the
+// InfluxDB API.
+func influxWriteURL(conf InfluxConfig) (string, map[string]string, error) {
+	base := strings.TrimSuffix(conf.URL, "/")
+	headers := map[string]string{"Content-Type": "text/plain; charset=utf-8"}
+	query := url.Values{}
+	query.Set("precision", "s")
+
+	switch conf.Version {
+	case 1:
+		if len(conf.Database) == 0 {
+			return "", nil, fmt.Errorf("influxdb database must not be empty")
+		}
+		query.Set("db", conf.Database)
+		if len(conf.RetentionPolicy) > 0 {
+			query.Set("rp", conf.RetentionPolicy)
+		}
+		if len(conf.Username) > 0 {
+			query.Set("u", conf.Username)
+			query.Set("p", conf.Password)
+		}
+		return base + "/write?" + query.Encode(), headers, nil
+	case 2:
+		if len(conf.Organisation) == 0 || len(conf.Bucket) == 0 {
+			return "", nil, fmt.Errorf("influxdb organisation and bucket must not be empty")
+		}
+		query.Set("org", conf.Organisation)
+		query.Set("bucket", conf.Bucket)
+		if len(conf.Token) > 0 {
+			headers["Authorization"] = "Token " + conf.Token
+		}
+		return base + "/api/v2/write?" + query.Encode(), headers, nil
+	}
+	return "", nil, fmt.Errorf("influxdb version not recognised: %v", conf.Version)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// influxSeries - Formats the measurement and tags of a point.
+func influxSeries(name string, tags map[string]string) string {
+	series := influxMeasurementEscaper.Replace(name)
+	for _, k := range sortedKeys(tags) {
+		series += "," + influxTagEscaper.Replace(k) + "=" + influxTagEscaper.Replace(tags[k])
+	}
+	return series
+}
+
+// influxLines - Formats a snapshot as lines of the InfluxDB line protocol.
+func (i *InfluxSink) influxLines(snap Snapshot) []byte {
+	ts := " " + strconv.FormatInt(snap.Time.Unix(), 10) + "\n"
+	field := func(k string, v int64) string {
+		return k + "=" + strconv.FormatInt(v, 10) + "i"
+	}
+
+	var buf bytes.Buffer
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		series := influxSeries(name, mergeTags(i.tags, tags))
+
+		if v, ok := snap.Counters[path]; ok {
+			buf.WriteString(series + " " + field("value", v) + ts)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			buf.WriteString(series + " " + field("value", v) + ts)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			fields := []string{}
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				fields = append(fields, field(agg.name, agg.value))
+			}
+			buf.WriteString(series + " " + strings.Join(fields, ",") + ts)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Flush - Writes a snapshot to InfluxDB.
+func (i *InfluxSink) Flush(snap Snapshot) error {
+	body := i.influxLines(snap)
+	if len(body) == 0 {
+		return nil
+	}
+	return i.sender(i.writeURL, body, i.headers)
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (i *InfluxSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,98 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestInfluxSinkLines(t *testing.T) {
+	conf := NewConfig()
+	conf.Influx.Tags = map[string]string{"host": "a b"}
+
+	sink, err := NewInfluxSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := sink.(*InfluxSink)
+
+	var sentURL string
+	var sentBody []byte
+	i.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentBody = url, body
+		return nil
+	}
+
+	requests := Tagged("http.requests", map[string]string{"code": "200"})
+	err = i.Flush(Snapshot{
+		Time:     time.Unix(100, 0),
+		Counters: map[string]int64{requests: 5},
+		Timings:  map[string][]int64{"latency": {1, 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `http.requests,code=200,host=a\ b value=5i 100
+latency,host=a\ b count=2i,min=1i,max=3i,mean=2i,p99=3i 100
+`
+	if act := string(sentBody); exp != act {
+		t.Errorf("Wrong lines:\n%v\n!=\n%v", exp, act)
+	}
+	if exp := "http://localhost:8086/write?db=benthos&precision=s"; exp != sentURL {
+		t.Errorf("Wrong url: %v != %v", exp, sentURL)
+	}
+}
+
+func TestInfluxWriteURL(t *testing.T) {
+	conf := NewInfluxConfig()
+	conf.Version = 2
+	conf.Organisation = "org"
+	conf.Bucket = "bucket"
+	conf.Token = "secret"
+
+	u, headers, err := influxWriteURL(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "http://localhost:8086/api/v2/write?bucket=bucket&org=org&precision=s"; exp != u {
+		t.Errorf("Wrong url: %v != %v", exp, u)
+	}
+	if exp, act := "Token secret", headers["Authorization"]; exp != act {
+		t.Errorf("Wrong authorization: %v != %v", exp, act)
+	}
+
+	conf.Bucket = ""
+	if _, _, err = influxWriteURL(conf); err == nil {
+		t.Error("Expected error from missing bucket")
+	}
+	conf.Version = 3
+	if _, _, err = influxWriteURL(conf); err == nil {
+		t.Error("Expected error from unrecognised version")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
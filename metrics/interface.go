@@ -22,6 +22,8 @@ THE SOFTWARE.
 
 package metrics
 
+import "time"
+
 // Type - An interface for metrics aggregation.
 type Type interface {
 	// Incr - Increment a metric by an amount.
@@ -33,9 +35,27 @@ type Type interface {
 	// Timing - Set a timing metric.
 	Timing(path string, delta int64) error
 
+	// TimingDuration - Set a timing metric from a duration, which is converted into the configured
+	// timing unit.
+	TimingDuration(path string, d time.Duration) error
+
 	// Gauge - Set a gauge metric.
 	Gauge(path string, value int64) error
 
 	// Close - Stop aggregating stats and clean up resources.
 	Close() error
 }
+
+// StringSetter - An optional interface for metric types that are able to hold string values
+// alongside numeric metrics.
+type StringSetter interface {
+	// SetString - Set a metric path to a string value.
+	SetString(path string, value string) error
+}
+
+// Reconfigurable - An optional interface for metric types that are able to apply a new config at
+// runtime without being rebuilt.
+type Reconfigurable interface {
+	// Reconfigure - Apply the runtime adjustable fields of a new config.
+	Reconfigure(conf Config) error
+}
@@ -0,0 +1,213 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["kafka"] = sinkSpec{
+		constructor: NewKafkaSink,
+		description: `
+Publishes each flush as a JSON message to a Kafka topic, where the message holds
+the time and interval of the flush along with the counters, counter deltas,
+gauges and the count, min, max, mean and p99 of timings, keyed by stat path. The
+leader of each partition is discovered from the configured addresses. Messages
+are written to the configured partition, or when the partition is -1 to a
+partition chosen by the hash of the key, or in turn when the key is empty.
+Messages are produced with the native protocol of brokers from Kafka 0.11
+onwards without compression, TLS or SASL.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// KafkaConfig - Config for the Kafka sink.
+type KafkaConfig struct {
+	Addresses []string `json:"addresses" yaml:"addresses"`
+	Topic     string   `json:"topic" yaml:"topic"`
+	ClientID  string   `json:"client_id" yaml:"client_id"`
+	Key       string   `json:"key" yaml:"key"`
+	Partition int32    `json:"partition" yaml:"partition"`
+	Acks      int16    `json:"acks" yaml:"acks"`
+	Timeout   string   `json:"timeout" yaml:"timeout"`
+}
+
+// NewKafkaConfig - Creates a KafkaConfig struct with default values.
+func NewKafkaConfig() KafkaConfig {
+	return KafkaConfig{
+		Addresses: []string{"localhost:9092"},
+		Topic:     "benthos_stats",
+		ClientID:  "benthos",
+		Key:       "",
+		Partition: -1,
+		Acks:      1,
+		Timeout:   "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// KafkaSink - A Sink that publishes snapshots to a Kafka topic.
+type KafkaSink struct {
+	conf    KafkaConfig
+	timeout time.Duration
+
+	meta    *kafkaTopicMetadata
+	brokers map[string]*kafkaBroker
+	next    int
+}
+
+// NewKafkaSink - Create a new Kafka sink.
+func NewKafkaSink(config Config) (Sink, error) {
+	conf := config.Kafka
+	if len(conf.Addresses) == 0 {
+		return nil, fmt.Errorf("kafka addresses must not be empty")
+	}
+	if len(conf.Topic) == 0 {
+		return nil, fmt.Errorf("kafka topic must not be empty")
+	}
+	switch conf.Acks {
+	case -1, 0, 1:
+	default:
+		return nil, fmt.Errorf("kafka acks must be -1, 0 or 1: %v", conf.Acks)
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	return &KafkaSink{
+		conf:    conf,
+		timeout: timeout,
+		brokers: map[string]*kafkaBroker{},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// broker - Returns a connection to a broker, dialing it if necessary.
+func (k *KafkaSink) broker(address string) (*kafkaBroker, error) {
+	if b, ok := k.brokers[address]; ok {
+		return b, nil
+	}
+	b, err := dialKafkaBroker(address, k.conf.ClientID, k.timeout)
+	if err != nil {
+		return nil, err
+	}
+	k.brokers[address] = b
+	return b, nil
+}
+
+// dropBroker - Closes and forgets the connection to a broker.
+func (k *KafkaSink) dropBroker(address string) {
+	if b, ok := k.brokers[address]; ok {
+		b.close()
+		delete(k.brokers, address)
+	}
+}
+
+// metadata - Returns the metadata of the topic, requesting it from each of the configured
+// addresses in turn until one succeeds.
+func (k *KafkaSink) metadata() (kafkaTopicMetadata, error) {
+	if k.meta != nil {
+		return *k.meta, nil
+	}
+	var err error
+	for _, address := range k.conf.Addresses {
+		var b *kafkaBroker
+		if b, err = k.broker(address); err != nil {
+			continue
+		}
+		var meta kafkaTopicMetadata
+		if meta, err = b.metadata(k.conf.Topic); err != nil {
+			k.dropBroker(address)
+			continue
+		}
+		k.meta = &meta
+		return meta, nil
+	}
+	return kafkaTopicMetadata{}, err
+}
+
+// partition - Chooses the partition of the next message.
+func (k *KafkaSink) partition(partitions []int32) int32 {
+	if k.conf.Partition >= 0 {
+		return k.conf.Partition
+	}
+	if len(k.conf.Key) > 0 {
+		return partitions[crc32.ChecksumIEEE([]byte(k.conf.Key))%uint32(len(partitions))]
+	}
+	k.next = (k.next + 1) % len(partitions)
+	return partitions[k.next]
+}
+
+// Flush - Publishes a snapshot to the topic. Any failure drops the connection to the leader and
+// the metadata of the topic so that the next flush discovers the leader again.
+func (k *KafkaSink) Flush(snap Snapshot) error {
+	value, err := marshalSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	message := kafkaMessage{Value: value}
+	if len(k.conf.Key) > 0 {
+		message.Key = []byte(k.conf.Key)
+	}
+
+	meta, err := k.metadata()
+	if err != nil {
+		return err
+	}
+	partition := k.partition(meta.partitions())
+	address, ok := meta.leaders[partition]
+	if !ok {
+		k.meta = nil
+		return fmt.Errorf("kafka partition has no leader: %v", partition)
+	}
+
+	b, err := k.broker(address)
+	if err == nil {
+		batch := encodeKafkaRecordBatch([]kafkaMessage{message}, snap.Time)
+		err = b.produce(k.conf.Topic, partition, k.conf.Acks, batch)
+	}
+	if err != nil {
+		k.dropBroker(address)
+		k.meta = nil
+	}
+	return err
+}
+
+// Close - Closes the connections to all brokers.
+func (k *KafkaSink) Close() error {
+	for address := range k.brokers {
+		k.dropBroker(address)
+	}
+	k.meta = nil
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
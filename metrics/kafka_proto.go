@@ -0,0 +1,429 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+This file implements the small subset of the Kafka protocol needed to produce messages without a
+client library: Metadata (version 1) to find the leader of each partition of a topic, and Produce
+(version 3) with record batches of the version 2 message format, which is supported by brokers from
+Kafka 0.11 onwards. Compression, idempotence, transactions and SASL are not supported.
+*/
+
+// Kafka API keys.
+const (
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+)
+
+// Errors for the Kafka protocol.
+var (
+	ErrKafkaResponse = errors.New("kafka response was malformed")
+)
+
+var kafkaCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+//--------------------------------------------------------------------------------------------------
+
+// kafkaEncoder - Encodes the primitive types of the Kafka protocol.
+type kafkaEncoder struct {
+	bytes.Buffer
+}
+
+func (e *kafkaEncoder) int8(v int8) {
+	e.WriteByte(byte(v))
+}
+
+func (e *kafkaEncoder) int16(v int16) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) int32(v int32) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) int64(v int64) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *kafkaEncoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.Write(buf[:binary.PutVarint(buf[:], v)])
+}
+
+func (e *kafkaEncoder) string(v string) {
+	e.int16(int16(len(v)))
+	e.WriteString(v)
+}
+
+func (e *kafkaEncoder) nullableString(v *string) {
+	if v == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*v)
+}
+
+func (e *kafkaEncoder) bytes(v []byte) {
+	e.int32(int32(len(v)))
+	e.Write(v)
+}
+
+// varintBytes - Encodes bytes with a varint length, where nil is encoded as null.
+func (e *kafkaEncoder) varintBytes(v []byte) {
+	if v == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(v)))
+	e.Write(v)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// kafkaDecoder - Decodes the primitive types of the Kafka protocol, where the first error is
+// retained and all subsequent reads return zero values.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = ErrKafkaResponse
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = ErrKafkaResponse
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+func (d *kafkaDecoder) varintBytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// arrayLen - Reads the length of an array, which must be plausible for the remaining bytes.
+func (d *kafkaDecoder) arrayLen() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	}
+	if n > len(d.buf) {
+		d.err = ErrKafkaResponse
+		return 0
+	}
+	return n
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// kafkaMessage - A message to be produced.
+type kafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// encodeKafkaRecordBatch - Encodes messages as an uncompressed record batch of the version 2
+// message format.
+func encodeKafkaRecordBatch(messages []kafkaMessage, t time.Time) []byte {
+	timestamp := t.UnixNano() / int64(time.Millisecond)
+
+	var records kafkaEncoder
+	for i, m := range messages {
+		var record kafkaEncoder
+		record.int8(0)              // attributes
+		record.varint(0)            // timestamp delta
+		record.varint(int64(i))     // offset delta
+		record.varintBytes(m.Key)   // key
+		record.varintBytes(m.Value) // value
+		record.varint(0)            // headers
+
+		records.varint(int64(record.Len()))
+		records.Write(record.Bytes())
+	}
+
+	var body kafkaEncoder
+	body.int16(0)                        // attributes
+	body.int32(int32(len(messages) - 1)) // last offset delta
+	body.int64(timestamp)                // first timestamp
+	body.int64(timestamp)                // max timestamp
+	body.int64(-1)                       // producer id
+	body.int16(-1)                       // producer epoch
+	body.int32(-1)                       // base sequence
+	body.int32(int32(len(messages)))
+	body.Write(records.Bytes())
+
+	var batch kafkaEncoder
+	batch.int64(0)                             // base offset
+	batch.int32(int32(4 + 1 + 4 + body.Len())) // batch length
+	batch.int32(-1)                            // partition leader epoch
+	batch.int8(2)                              // magic
+	binary.Write(&batch, binary.BigEndian, crc32.Checksum(body.Bytes(), kafkaCRCTable))
+	batch.Write(body.Bytes())
+	return batch.Bytes()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// kafkaBroker - A connection to a single broker. A kafkaBroker is not safe for concurrent use.
+type kafkaBroker struct {
+	conn          net.Conn
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+}
+
+// dialKafkaBroker - Opens a connection to a broker.
+func dialKafkaBroker(address, clientID string, timeout time.Duration) (*kafkaBroker, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaBroker{conn: conn, clientID: clientID, timeout: timeout}, nil
+}
+
+// request - Sends a request and, when expectResponse is true, returns the body of the response
+// following its correlation id.
+func (b *kafkaBroker) request(apiKey, version int16, body []byte, expectResponse bool) ([]byte, error) {
+	b.correlationID++
+
+	var req kafkaEncoder
+	req.int16(apiKey)
+	req.int16(version)
+	req.int32(b.correlationID)
+	req.string(b.clientID)
+	req.Write(body)
+
+	var packet kafkaEncoder
+	packet.bytes(req.Bytes())
+
+	b.conn.SetDeadline(time.Now().Add(b.timeout))
+	if _, err := b.conn.Write(packet.Bytes()); err != nil {
+		return nil, err
+	}
+	if !expectResponse {
+		return nil, nil
+	}
+
+	var size int32
+	if err := binary.Read(b.conn, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 4 || size > 1<<24 {
+		return nil, ErrKafkaResponse
+	}
+	res := make([]byte, size)
+	if _, err := io.ReadFull(b.conn, res); err != nil {
+		return nil, err
+	}
+	if int32(binary.BigEndian.Uint32(res)) != b.correlationID {
+		return nil, ErrKafkaResponse
+	}
+	return res[4:], nil
+}
+
+// close - Closes the connection to the broker.
+func (b *kafkaBroker) close() error {
+	return b.conn.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// kafkaTopicMetadata - The addresses of the brokers leading each partition of a topic.
+type kafkaTopicMetadata struct {
+	leaders map[int32]string
+}
+
+// partitions - Returns the partitions of the topic in ascending order.
+func (m kafkaTopicMetadata) partitions() []int32 {
+	partitions := make([]int32, 0, len(m.leaders))
+	for p := range m.leaders {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
+
+// metadata - Requests the metadata of a topic.
+func (b *kafkaBroker) metadata(topic string) (kafkaTopicMetadata, error) {
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(topic)
+
+	res, err := b.request(kafkaAPIMetadata, 1, req.Bytes(), true)
+	if err != nil {
+		return kafkaTopicMetadata{}, err
+	}
+
+	d := &kafkaDecoder{buf: res}
+	brokers := map[int32]string{}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		nodeID, host, port := d.int32(), d.string(), d.int32()
+		d.string() // rack
+		brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller id
+
+	meta := kafkaTopicMetadata{leaders: map[int32]string{}}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		code, name := d.int16(), d.string()
+		d.int8() // is internal
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int16() // partition error code
+			partition, leader := d.int32(), d.int32()
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // replicas
+			}
+			for k, r := 0, d.arrayLen(); k < r; k++ {
+				d.int32() // isr
+			}
+			if addr, ok := brokers[leader]; ok && name == topic {
+				meta.leaders[partition] = addr
+			}
+		}
+		if name == topic && code != 0 {
+			return kafkaTopicMetadata{}, fmt.Errorf("kafka metadata of topic %v failed with error code: %v", topic, code)
+		}
+	}
+	if d.err != nil {
+		return kafkaTopicMetadata{}, d.err
+	}
+	if len(meta.leaders) == 0 {
+		return kafkaTopicMetadata{}, fmt.Errorf("kafka topic has no partitions with a leader: %v", topic)
+	}
+	return meta, nil
+}
+
+// produce - Produces a record batch to a partition of a topic, where acks of zero does not wait
+// for a response.
+func (b *kafkaBroker) produce(topic string, partition int32, acks int16, batch []byte) error {
+	var req kafkaEncoder
+	req.nullableString(nil) // transactional id
+	req.int16(acks)
+	req.int32(int32(b.timeout / time.Millisecond))
+	req.int32(1)
+	req.string(topic)
+	req.int32(1)
+	req.int32(partition)
+	req.bytes(batch)
+
+	res, err := b.request(kafkaAPIProduce, 3, req.Bytes(), acks != 0)
+	if err != nil || acks == 0 {
+		return err
+	}
+
+	d := &kafkaDecoder{buf: res}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, m := 0, d.arrayLen(); j < m; j++ {
+			d.int32() // partition
+			code := d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+			if d.err == nil && code != 0 {
+				return fmt.Errorf("kafka produce failed with error code: %v", code)
+			}
+		}
+	}
+	return d.err
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,97 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"hash/crc32"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// decodeKafkaRecordBatch - Decodes the messages of a record batch, checking its checksum.
+func decodeKafkaRecordBatch(t *testing.T, batch []byte) []kafkaMessage {
+	d := &kafkaDecoder{buf: batch}
+	d.int64() // base offset
+	if length := int(d.int32()); length != len(d.buf) {
+		t.Fatalf("Wrong batch length: %v != %v", length, len(d.buf))
+	}
+	d.int32() // partition leader epoch
+	if magic := d.int8(); magic != 2 {
+		t.Fatalf("Wrong magic: %v", magic)
+	}
+	crc := uint32(d.int32())
+	if exp := crc32.Checksum(d.buf, kafkaCRCTable); exp != crc {
+		t.Fatalf("Wrong crc: %v != %v", exp, crc)
+	}
+	d.next(2 + 4 + 8 + 8 + 8 + 2 + 4)
+
+	messages := []kafkaMessage{}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		record := &kafkaDecoder{buf: d.next(int(d.varint()))}
+		record.int8()
+		record.varint()
+		if offset := record.varint(); offset != int64(i) {
+			t.Errorf("Wrong offset delta: %v != %v", i, offset)
+		}
+		messages = append(messages, kafkaMessage{Key: record.varintBytes(), Value: record.varintBytes()})
+		if record.varint() != 0 || record.err != nil {
+			t.Errorf("Wrong record trailer: %v", record.err)
+		}
+	}
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	return messages
+}
+
+func TestKafkaRecordBatch(t *testing.T) {
+	batch := encodeKafkaRecordBatch([]kafkaMessage{
+		{Value: []byte("foo")},
+		{Key: []byte("a"), Value: []byte("bar")},
+	}, time.Unix(100, 0))
+
+	messages := decodeKafkaRecordBatch(t, batch)
+	if len(messages) != 2 {
+		t.Fatalf("Wrong count of messages: %v", len(messages))
+	}
+	if messages[0].Key != nil || string(messages[0].Value) != "foo" {
+		t.Errorf("Wrong message: %+v", messages[0])
+	}
+	if string(messages[1].Key) != "a" || string(messages[1].Value) != "bar" {
+		t.Errorf("Wrong message: %+v", messages[1])
+	}
+}
+
+func TestKafkaDecoderMalformed(t *testing.T) {
+	d := &kafkaDecoder{buf: []byte{0, 5, 'a'}}
+	if v := d.string(); v != "" || d.err != ErrKafkaResponse {
+		t.Errorf("Expected malformed error: %v %v", v, d.err)
+	}
+	if v := d.int32(); v != 0 {
+		t.Errorf("Expected zero value after error: %v", v)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
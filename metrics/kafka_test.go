@@ -0,0 +1,188 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// kafkaProduced - A message received by a fake broker.
+type kafkaProduced struct {
+	partition int32
+	message   kafkaMessage
+}
+
+// serveKafka - Runs a fake broker leading partitions of a topic, which answers metadata requests
+// and records produced messages.
+func serveKafka(t *testing.T, topic string, partitions int32) (string, <-chan kafkaProduced) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on tcp: %v", err)
+	}
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	produced := make(chan kafkaProduced, 10)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var size int32
+			if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+				return
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				return
+			}
+			d := &kafkaDecoder{buf: buf}
+			apiKey, _, correlationID := d.int16(), d.int16(), d.int32()
+			d.string() // client id
+
+			var res kafkaEncoder
+			res.int32(correlationID)
+			switch apiKey {
+			case kafkaAPIMetadata:
+				res.int32(1)
+				res.int32(7)
+				res.string(host)
+				res.int32(int32(port))
+				res.int16(-1)
+				res.int32(7)
+				res.int32(1)
+				res.int16(0)
+				res.string(topic)
+				res.int8(0)
+				res.int32(partitions)
+				for p := int32(0); p < partitions; p++ {
+					res.int16(0)
+					res.int32(p)
+					res.int32(7)
+					res.int32(0)
+					res.int32(0)
+				}
+			case kafkaAPIProduce:
+				d.string() // transactional id
+				d.int16()  // acks
+				d.int32()  // timeout
+				d.arrayLen()
+				d.string() // topic
+				d.arrayLen()
+				partition := d.int32()
+				for _, m := range decodeKafkaRecordBatch(t, d.bytes()) {
+					produced <- kafkaProduced{partition, m}
+				}
+				res.int32(1)
+				res.string(topic)
+				res.int32(1)
+				res.int32(partition)
+				res.int16(0)
+				res.int64(0)
+				res.int64(-1)
+				res.int32(0)
+			}
+
+			var packet kafkaEncoder
+			packet.bytes(res.Bytes())
+			conn.Write(packet.Bytes())
+		}
+	}()
+	return ln.Addr().String(), produced
+}
+
+func TestKafkaSinkFlush(t *testing.T) {
+	addr, produced := serveKafka(t, "stats", 3)
+
+	conf := NewConfig()
+	conf.Kafka.Addresses = []string{addr}
+	conf.Kafka.Topic = "stats"
+	conf.Kafka.Key = "foo"
+
+	sink, err := NewKafkaSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 2; i++ {
+		err = sink.Flush(Snapshot{
+			Time:   time.Unix(100, 0),
+			Gauges: map[string]int64{"queue": int64(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expPartition := int32(crc32.ChecksumIEEE([]byte("foo")) % 3)
+	for i := 0; i < 2; i++ {
+		select {
+		case p := <-produced:
+			if p.partition != expPartition {
+				t.Errorf("Wrong partition: %v != %v", expPartition, p.partition)
+			}
+			if string(p.message.Key) != "foo" {
+				t.Errorf("Wrong key: %s", p.message.Key)
+			}
+			var doc snapshotDocument
+			if err := json.Unmarshal(p.message.Value, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if exp, act := int64(i), doc.Gauges["queue"]; exp != act {
+				t.Errorf("Wrong gauge: %v != %v", exp, act)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+	}
+}
+
+func TestKafkaSinkUnreachable(t *testing.T) {
+	conf := NewConfig()
+	conf.Kafka.Addresses = []string{"127.0.0.1:1"}
+	conf.Kafka.Timeout = "100ms"
+
+	sink, err := NewKafkaSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Flush(Snapshot{Gauges: map[string]int64{"a": 1}}); err == nil {
+		t.Error("Expected error from unreachable broker")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,201 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["librato"] = sinkSpec{
+		constructor: NewLibratoSink,
+		description: `
+Posts snapshots to the tagged measurements API of Librato or AppOptics, split
+into batches of at most batch_size measurements per request. Counters are posted
+as the delta of each flush, gauges as their value and timings as summaries of
+their count, sum, min and max. The source of the config is posted as the
+'source' tag of every measurement alongside the tags of the config and the tags
+of stats created with metrics.Tagged. Librato authenticates with an email and
+token, and AppOptics with a token and an empty email.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// LibratoConfig - Config for the Librato and AppOptics measurements sink.
+type LibratoConfig struct {
+	URL       string            `json:"url" yaml:"url"`
+	Email     string            `json:"email" yaml:"email"`
+	Token     string            `json:"token" yaml:"token"`
+	Source    string            `json:"source" yaml:"source"`
+	Tags      map[string]string `json:"tags" yaml:"tags"`
+	BatchSize int               `json:"batch_size" yaml:"batch_size"`
+	Timeout   string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewLibratoConfig - Creates a LibratoConfig struct with default values.
+func NewLibratoConfig() LibratoConfig {
+	return LibratoConfig{
+		URL:       "https://metrics-api.librato.com/v1/measurements",
+		Email:     "",
+		Token:     "",
+		Source:    "",
+		Tags:      map[string]string{},
+		BatchSize: 300,
+		Timeout:   "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// LibratoSink - A Sink that posts snapshots to the Librato or AppOptics measurements API.
+type LibratoSink struct {
+	url       string
+	headers   map[string]string
+	tags      map[string]string
+	batchSize int
+	sender    func(url string, body []byte, headers map[string]string) error
+}
+
+// NewLibratoSink - Create a new Librato or AppOptics sink.
+func NewLibratoSink(config Config) (Sink, error) {
+	conf := config.Librato
+	if len(conf.Token) == 0 {
+		return nil, fmt.Errorf("librato token must not be empty")
+	}
+	if conf.BatchSize <= 0 {
+		return nil, fmt.Errorf("librato batch size must be greater than zero")
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := conf.Tags
+	if len(conf.Source) > 0 {
+		tags = mergeTags(tags, map[string]string{"source": conf.Source})
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(conf.Email + ":" + conf.Token))
+	return &LibratoSink{
+		url: conf.URL,
+		headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "Basic " + auth,
+		},
+		tags:      tags,
+		batchSize: conf.BatchSize,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+type (
+	libratoPayload struct {
+		Time         int64                `json:"time"`
+		Period       int64                `json:"period,omitempty"`
+		Tags         map[string]string    `json:"tags,omitempty"`
+		Measurements []libratoMeasurement `json:"measurements"`
+	}
+	libratoMeasurement struct {
+		Name  string `json:"name"`
+		Value *int64 `json:"value,omitempty"`
+		*libratoSummary
+		Tags map[string]string `json:"tags,omitempty"`
+	}
+	libratoSummary struct {
+		Count int64 `json:"count"`
+		Sum   int64 `json:"sum"`
+		Min   int64 `json:"min"`
+		Max   int64 `json:"max"`
+	}
+)
+
+// libratoMeasurements - Converts a snapshot into Librato measurements.
+func libratoMeasurements(snap Snapshot) []libratoMeasurement {
+	measurements := []libratoMeasurement{}
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		value := func(v int64) {
+			measurements = append(measurements, libratoMeasurement{Name: name, Value: &v, Tags: tags})
+		}
+
+		if delta, ok := snap.CounterDeltas[path]; ok {
+			value(delta)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			value(v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			sum := SummariseTimings(samples)
+			measurements = append(measurements, libratoMeasurement{
+				Name: name,
+				libratoSummary: &libratoSummary{
+					Count: sum.Count,
+					Sum:   sum.Sum,
+					Min:   sum.Min,
+					Max:   sum.Max,
+				},
+				Tags: tags,
+			})
+		}
+	}
+	return measurements
+}
+
+// Flush - Posts a snapshot in batches, stopping at the first batch that fails.
+func (l *LibratoSink) Flush(snap Snapshot) error {
+	measurements := libratoMeasurements(snap)
+	for start := 0; start < len(measurements); start += l.batchSize {
+		end := start + l.batchSize
+		if end > len(measurements) {
+			end = len(measurements)
+		}
+		body, err := json.Marshal(libratoPayload{
+			Time:         snap.Time.Unix(),
+			Period:       int64(snap.Interval.Seconds()),
+			Tags:         l.tags,
+			Measurements: measurements[start:end],
+		})
+		if err != nil {
+			return err
+		}
+		if err = l.sender(l.url, body, l.headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (l *LibratoSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
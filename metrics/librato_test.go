@@ -0,0 +1,79 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestLibratoSinkBatches(t *testing.T) {
+	conf := NewConfig()
+	conf.Librato.Token = "foo"
+	conf.Librato.Source = "a"
+	conf.Librato.BatchSize = 2
+
+	sink, err := NewLibratoSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := sink.(*LibratoSink)
+
+	bodies := []string{}
+	l.sender = func(url string, body []byte, headers map[string]string) error {
+		if !strings.HasPrefix(headers["Authorization"], "Basic ") {
+			t.Errorf("Wrong authorization: %v", headers["Authorization"])
+		}
+		bodies = append(bodies, string(body))
+		return nil
+	}
+
+	requests := Tagged("requests", map[string]string{"code": "200"})
+	err = l.Flush(Snapshot{
+		Time:          time.Unix(100, 0),
+		Interval:      10 * time.Second,
+		Counters:      map[string]int64{requests: 9},
+		CounterDeltas: map[string]int64{requests: 0},
+		Gauges:        map[string]int64{"queue": 3},
+		Timings:       map[string][]int64{"latency": {0, 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`{"time":100,"period":10,"tags":{"source":"a"},"measurements":[` +
+			`{"name":"latency","count":2,"sum":5,"min":0,"max":5},{"name":"queue","value":3}]}`,
+		`{"time":100,"period":10,"tags":{"source":"a"},"measurements":[` +
+			`{"name":"requests","value":0,"tags":{"code":"200"}}]}`,
+	}
+	if act := fmt.Sprintf("%v", bodies); fmt.Sprintf("%v", exp) != act {
+		t.Errorf("Wrong bodies:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Stat paths used by the Limited type.
+const (
+	// OverflowPath - The path under which stats beyond the cardinality limit are aggregated.
+	OverflowPath = "_overflow"
+
+	// OverflowCountPath - The path of a counter incremented each time a stat is redirected to the
+	// overflow path.
+	OverflowCountPath = "_overflow_count"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Limited - Wraps a Type and caps the number of distinct stat paths that are passed through to it.
+Once the cap is reached any stat with a new path is redirected to OverflowPath and the counter at
+OverflowCountPath is incremented. A cap of zero or less disables the limit. This protects against
+unbounded memory growth when dynamic values, such as user IDs, find their way into stat names.
+*/
+type Limited struct {
+	sync.Mutex
+
+	t        Type
+	maxPaths int
+	paths    map[string]struct{}
+}
+
+// NewLimited - Wrap a Type with a cap on the number of distinct stat paths.
+func NewLimited(t Type, maxPaths int) *Limited {
+	return &Limited{
+		t:        t,
+		maxPaths: maxPaths,
+		paths:    map[string]struct{}{},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// resolve - Returns the path a stat should be written to.
+func (l *Limited) resolve(stat string) string {
+	l.Lock()
+	defer l.Unlock()
+
+	if _, exists := l.paths[stat]; exists {
+		return stat
+	}
+	if l.maxPaths <= 0 || len(l.paths) < l.maxPaths {
+		l.paths[stat] = struct{}{}
+		return stat
+	}
+	l.t.Incr(OverflowCountPath, 1)
+	return OverflowPath
+}
+
+// Incr - Increment a stat by a value.
+func (l *Limited) Incr(stat string, value int64) error {
+	return l.t.Incr(l.resolve(stat), value)
+}
+
+// Decr - Decrement a stat by a value.
+func (l *Limited) Decr(stat string, value int64) error {
+	return l.t.Decr(l.resolve(stat), value)
+}
+
+// Timing - Set a stat representing a duration.
+func (l *Limited) Timing(stat string, delta int64) error {
+	return l.t.Timing(l.resolve(stat), delta)
+}
+
+// TimingDuration - Set a stat representing a duration.
+func (l *Limited) TimingDuration(stat string, d time.Duration) error {
+	return l.t.TimingDuration(l.resolve(stat), d)
+}
+
+// Gauge - Set a stat as a gauge value.
+func (l *Limited) Gauge(stat string, value int64) error {
+	return l.t.Gauge(l.resolve(stat), value)
+}
+
+// SetString - Set a stat as a string value if the wrapped Type supports string values.
+func (l *Limited) SetString(stat string, value string) error {
+	if s, ok := l.t.(StringSetter); ok {
+		return s.SetString(l.resolve(stat), value)
+	}
+	return nil
+}
+
+// Reconfigure - Applies the max paths of a new config, and reconfigures the wrapped Type if it
+// supports reconfiguration. Paths that have already been admitted remain after the limit is lowered.
+func (l *Limited) Reconfigure(conf Config) error {
+	if r, ok := l.t.(Reconfigurable); ok {
+		if err := r.Reconfigure(conf); err != nil {
+			return err
+		}
+	}
+	l.Lock()
+	l.maxPaths = conf.MaxPaths
+	l.Unlock()
+	return nil
+}
+
+// Close - Closes the wrapped Type.
+func (l *Limited) Close() error {
+	return l.t.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
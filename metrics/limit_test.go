@@ -0,0 +1,49 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import "testing"
+
+func TestLimited(t *testing.T) {
+	rec := newRecorder()
+	l := NewLimited(rec, 2)
+
+	l.Incr("a", 1)
+	l.Incr("b", 1)
+	l.Incr("c", 1)
+	l.Incr("d", 2)
+	l.Incr("a", 1)
+
+	for stat, exp := range map[string]int64{
+		"a":               2,
+		"b":               1,
+		"c":               0,
+		"d":               0,
+		OverflowPath:      3,
+		OverflowCountPath: 2,
+	} {
+		if act := rec.counts[stat]; exp != act {
+			t.Errorf("Wrong count for %v: %v != %v", stat, exp, act)
+		}
+	}
+}
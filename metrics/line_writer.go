@@ -0,0 +1,109 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+lineWriter - Writes lines of text to a udp or tcp socket for the sinks of line based protocols.
+Lines are grouped into writes of at most maxPacket bytes, which keeps each udp datagram within the
+limits of the network, and a line longer than maxPacket is written on its own. The connection is
+dialed lazily and dropped after a failed write so that the next flush dials again. A lineWriter is
+not safe for concurrent use.
+*/
+type lineWriter struct {
+	network   string
+	address   string
+	timeout   time.Duration
+	maxPacket int
+
+	conn net.Conn
+}
+
+// newLineWriter - Creates a lineWriter, where a maxPacket of zero or less writes all lines at once.
+func newLineWriter(network, address string, timeout time.Duration, maxPacket int) *lineWriter {
+	return &lineWriter{
+		network:   network,
+		address:   address,
+		timeout:   timeout,
+		maxPacket: maxPacket,
+	}
+}
+
+// writeLines - Writes lines, each followed by a newline, to the socket.
+func (w *lineWriter) writeLines(lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	if w.conn == nil {
+		conn, err := net.DialTimeout(w.network, w.address, w.timeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if w.maxPacket > 0 && buf.Len() > 0 && buf.Len()+len(line)+1 > w.maxPacket {
+			if err := w.write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return w.write(buf.Bytes())
+}
+
+// write - Writes a single packet, dropping the connection on failure.
+func (w *lineWriter) write(packet []byte) error {
+	if w.timeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	}
+	if _, err := w.conn.Write(packet); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+// close - Closes the connection if open.
+func (w *lineWriter) close() error {
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
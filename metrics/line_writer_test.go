@@ -0,0 +1,76 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// listenUDP - Listens on a local udp port, skipping the test when unable to.
+func listenUDP(t *testing.T) net.PacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on udp: %v", err)
+	}
+	return pc
+}
+
+// readPackets - Reads n packets from a packet conn.
+func readPackets(t *testing.T, pc net.PacketConn, n int) []string {
+	packets := []string{}
+	buf := make([]byte, 65536)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < n; i++ {
+		size, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, string(buf[:size]))
+	}
+	return packets
+}
+
+func TestLineWriterPackets(t *testing.T) {
+	pc := listenUDP(t)
+	defer pc.Close()
+
+	w := newLineWriter("udp", pc.LocalAddr().String(), time.Second, 10)
+	defer w.close()
+
+	if err := w.writeLines([]string{"aaaa", "bbbb", "cccccccccccc"}); err != nil {
+		t.Fatal(err)
+	}
+	packets := readPackets(t, pc, 2)
+	if exp, act := "aaaa\nbbbb\n", packets[0]; exp != act {
+		t.Errorf("Wrong packet: %q != %q", exp, act)
+	}
+	if exp, act := "cccccccccccc\n", packets[1]; exp != act {
+		t.Errorf("Wrong packet: %q != %q", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,68 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Metric kinds that may be registered as metadata of a stat.
+const (
+	KindCounter = "counter"
+	KindGauge   = "gauge"
+	KindTiming  = "timing"
+)
+
+// Metadata - Describes a stat for sinks that expose descriptions, kinds or units of metrics
+// alongside their values, such as Prometheus. The kind is one of the Kind constants and the unit is
+// free form, e.g. "bytes" or "ms".
+type Metadata struct {
+	Kind        string
+	Description string
+	Unit        string
+}
+
+var (
+	metadataMut sync.RWMutex
+	metadata    = map[string]Metadata{}
+)
+
+// RegisterMetadata - Registers the metadata of a stat path, without any prefix. Registering the same
+// path again replaces the previous metadata.
+func RegisterMetadata(path string, m Metadata) {
+	metadataMut.Lock()
+	metadata[path] = m
+	metadataMut.Unlock()
+}
+
+// lookupMetadata - Returns the metadata registered for a stat path, if any.
+func lookupMetadata(path string) (Metadata, bool) {
+	metadataMut.RLock()
+	defer metadataMut.RUnlock()
+	m, ok := metadata[path]
+	return m, ok
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,180 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["mqtt"] = sinkSpec{
+		constructor: NewMQTTSink,
+		description: `
+Publishes each stat of a flush as a JSON message to an MQTT 3.1.1 broker, which
+suits embedded and edge deployments. The topic of each message is derived from
+the stat with the placeholders '{path}' for the name of the stat, where dots are
+replaced with slashes so that stats map onto the topic hierarchy, and '{type}'
+for one of 'counter', 'gauge' or 'timing'. The placeholders of the prefix of the
+sinks type, such as '{hostname}', may also be used. Messages are published at a
+QoS of 0 or 1, and may be retained so that new subscribers receive the latest
+value of each stat. TLS connections are not supported.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// MQTTConfig - Config for the MQTT sink.
+type MQTTConfig struct {
+	URL      string `json:"url" yaml:"url"`
+	Topic    string `json:"topic" yaml:"topic"`
+	ClientID string `json:"client_id" yaml:"client_id"`
+	QoS      int    `json:"qos" yaml:"qos"`
+	Retain   bool   `json:"retain" yaml:"retain"`
+	Timeout  string `json:"timeout" yaml:"timeout"`
+}
+
+// NewMQTTConfig - Creates an MQTTConfig struct with default values.
+func NewMQTTConfig() MQTTConfig {
+	return MQTTConfig{
+		URL:      "tcp://localhost:1883",
+		Topic:    "benthos/stats/{type}/{path}",
+		ClientID: "",
+		QoS:      0,
+		Retain:   false,
+		Timeout:  "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// MQTTSink - A Sink that publishes stats to an MQTT broker.
+type MQTTSink struct {
+	conf     MQTTConfig
+	address  string
+	clientID string
+	username string
+	password string
+	timeout  time.Duration
+
+	topic string
+	conn  *mqttConn
+}
+
+// NewMQTTSink - Create a new MQTT sink.
+func NewMQTTSink(config Config) (Sink, error) {
+	conf := config.MQTT
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mqtt url: %v", err)
+	}
+	if u.Scheme != "tcp" && u.Scheme != "mqtt" {
+		return nil, fmt.Errorf("mqtt url scheme not supported: %v", u.Scheme)
+	}
+	if conf.QoS != 0 && conf.QoS != 1 {
+		return nil, fmt.Errorf("mqtt qos not supported: %v", conf.QoS)
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	m := &MQTTSink{
+		conf:     conf,
+		address:  u.Host,
+		clientID: expandPrefix(conf.ClientID),
+		timeout:  timeout,
+		topic:    expandPrefix(conf.Topic),
+	}
+	if len(m.clientID) == 0 {
+		m.clientID = "benthos-" + strconv.Itoa(os.Getpid())
+	}
+	if _, _, err := net.SplitHostPort(m.address); err != nil {
+		m.address = net.JoinHostPort(u.Host, "1883")
+	}
+	if u.User != nil {
+		m.username = u.User.Username()
+		m.password, _ = u.User.Password()
+	}
+	return m, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// mqttTopicReplacer - Replaces the dots of a stat with topic levels, and the wildcards that are not
+// permitted within the topic of a published message.
+var mqttTopicReplacer = strings.NewReplacer(".", "/", "+", "_", "#", "_")
+
+// mqttMessages - Converts a snapshot into a message for each stat.
+func (m *MQTTSink) mqttMessages(snap Snapshot) ([]mqttMessage, error) {
+	messages := []mqttMessage{}
+	for _, doc := range metricDocuments(snap) {
+		payload, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		topic := strings.Replace(m.topic, "{type}", doc.Type, -1)
+		topic = strings.Replace(topic, "{path}", mqttTopicReplacer.Replace(doc.Name), -1)
+		messages = append(messages, mqttMessage{topic: topic, payload: payload})
+	}
+	return messages, nil
+}
+
+// Flush - Publishes a snapshot, dropping the connection on failure so that the next flush connects
+// again.
+func (m *MQTTSink) Flush(snap Snapshot) error {
+	messages, err := m.mqttMessages(snap)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+	if m.conn == nil {
+		if m.conn, err = dialMQTT(m.address, m.clientID, m.username, m.password, m.timeout); err != nil {
+			return err
+		}
+	}
+	if err = m.conn.publish(messages, byte(m.conf.QoS), m.conf.Retain); err != nil {
+		m.conn.conn.Close()
+		m.conn = nil
+	}
+	return err
+}
+
+// Close - Disconnects from the broker if connected.
+func (m *MQTTSink) Close() error {
+	if m.conn == nil {
+		return nil
+	}
+	err := m.conn.close()
+	m.conn = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
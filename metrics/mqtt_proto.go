@@ -0,0 +1,250 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+This file implements the subset of MQTT 3.1.1 needed to publish messages without a client library:
+CONNECT with an optional username and password, and PUBLISH at QoS 0 or at QoS 1 where each message
+is acknowledged with a PUBACK. The keep alive is disabled, as connections are only used whilst
+flushing, and subscriptions, QoS 2 and TLS are not supported.
+*/
+
+// MQTT control packet types, shifted into the upper nibble of the fixed header.
+const (
+	mqttConnect    = 1 << 4
+	mqttConnAck    = 2 << 4
+	mqttPublish    = 3 << 4
+	mqttPubAck     = 4 << 4
+	mqttDisconnect = 14 << 4
+)
+
+// Errors for the MQTT protocol.
+var (
+	ErrMQTTPacket = errors.New("mqtt packet was malformed")
+)
+
+// mqttConnAckReasons - The reasons of each refused CONNACK return code.
+var mqttConnAckReasons = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// mqttString - Appends a length prefixed string.
+func mqttString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// mqttPacket - Encodes a control packet with its fixed header.
+func mqttPacket(buf *bytes.Buffer, header byte, body []byte) {
+	buf.WriteByte(header)
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	buf.Write(body)
+}
+
+// mqttConn - A connection to an MQTT broker.
+type mqttConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	// The packet identifier of the last message published at QoS 1.
+	packetID uint16
+}
+
+// dialMQTT - Connects to a broker with a clean session.
+func dialMQTT(address, clientID, username, password string, timeout time.Duration) (*mqttConn, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttConn{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: timeout,
+	}
+	if err = c.connect(clientID, username, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect - Sends CONNECT and waits for the broker to accept it.
+func (c *mqttConn) connect(clientID, username, password string) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	flags := byte(0x02)
+	if len(username) > 0 {
+		flags |= 0x80
+		if len(password) > 0 {
+			flags |= 0x40
+		}
+	}
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 0)
+	body = mqttString(body, clientID)
+	if flags&0x80 != 0 {
+		body = mqttString(body, username)
+	}
+	if flags&0x40 != 0 {
+		body = mqttString(body, password)
+	}
+
+	var buf bytes.Buffer
+	mqttPacket(&buf, mqttConnect, body)
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	header, ack, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if header&0xf0 != mqttConnAck || len(ack) < 2 {
+		return ErrMQTTPacket
+	}
+	if code := ack[1]; code != 0 {
+		reason, ok := mqttConnAckReasons[code]
+		if !ok {
+			reason = fmt.Sprintf("return code %v", code)
+		}
+		return fmt.Errorf("mqtt connection refused: %v", reason)
+	}
+	return nil
+}
+
+// readPacket - Reads a control packet, returning its fixed header byte and body.
+func (c *mqttConn) readPacket() (byte, []byte, error) {
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		if i == 4 {
+			return 0, nil, ErrMQTTPacket
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// mqttMessage - A message to publish.
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+// publish - Publishes messages, and at QoS 1 waits for the broker to acknowledge all of them.
+func (c *mqttConn) publish(messages []mqttMessage, qos byte, retain bool) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	header := byte(mqttPublish) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	pending := map[uint16]struct{}{}
+
+	var buf bytes.Buffer
+	for _, m := range messages {
+		body := mqttString(nil, m.topic)
+		if qos > 0 {
+			if c.packetID++; c.packetID == 0 {
+				c.packetID = 1
+			}
+			pending[c.packetID] = struct{}{}
+			body = append(body, byte(c.packetID>>8), byte(c.packetID))
+		}
+		mqttPacket(&buf, header, append(body, m.payload...))
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for len(pending) > 0 {
+		header, body, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		if header&0xf0 != mqttPubAck {
+			continue
+		}
+		if len(body) < 2 {
+			return ErrMQTTPacket
+		}
+		delete(pending, binary.BigEndian.Uint16(body))
+	}
+	return nil
+}
+
+// close - Sends DISCONNECT and closes the connection.
+func (c *mqttConn) close() error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	c.conn.Write([]byte{mqttDisconnect, 0})
+	return c.conn.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
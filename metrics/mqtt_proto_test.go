@@ -0,0 +1,127 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// mqttReceived - A message published to a fake broker.
+type mqttReceived struct {
+	header  byte
+	topic   string
+	payload []byte
+}
+
+// serveMQTT - Runs a fake broker that records the CONNECT body and published messages, refusing
+// the connection with a return code when refuse is non-zero and acknowledging messages at QoS 1.
+func serveMQTT(t *testing.T, refuse byte) (string, <-chan []byte, <-chan mqttReceived) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on tcp: %v", err)
+	}
+	connects := make(chan []byte, 1)
+	received := make(chan mqttReceived, 10)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		c := &mqttConn{conn: conn, r: bufio.NewReader(conn), timeout: time.Second}
+		for {
+			header, body, err := c.readPacket()
+			if err != nil {
+				return
+			}
+			var buf bytes.Buffer
+			switch header & 0xf0 {
+			case mqttConnect:
+				connects <- body
+				mqttPacket(&buf, mqttConnAck, []byte{0, refuse})
+			case mqttPublish:
+				topicLen := int(binary.BigEndian.Uint16(body))
+				m := mqttReceived{header: header, topic: string(body[2 : 2+topicLen])}
+				body = body[2+topicLen:]
+				if qos := (header >> 1) & 3; qos > 0 {
+					mqttPacket(&buf, mqttPubAck, body[:2])
+					body = body[2:]
+				}
+				m.payload = body
+				received <- m
+			case mqttDisconnect:
+				return
+			}
+			conn.Write(buf.Bytes())
+		}
+	}()
+	return ln.Addr().String(), connects, received
+}
+
+func TestMQTTConnPublish(t *testing.T) {
+	addr, connects, received := serveMQTT(t, 0)
+
+	c, err := dialMQTT(addr, "client", "foo", "bar", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "\x00\x04MQTT\x04\xc2\x00\x00\x00\x06client\x00\x03foo\x00\x03bar"
+	if act := string(<-connects); exp != act {
+		t.Errorf("Wrong connect: %q != %q", exp, act)
+	}
+
+	large := bytes.Repeat([]byte("x"), 20000)
+	if err = c.publish([]mqttMessage{{"a/b", []byte("foo")}, {"c", large}}, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []mqttReceived{
+		{header: mqttPublish | 0x03, topic: "a/b", payload: []byte("foo")},
+		{header: mqttPublish | 0x03, topic: "c", payload: large},
+	} {
+		act := <-received
+		if exp.header != act.header || exp.topic != act.topic || !bytes.Equal(exp.payload, act.payload) {
+			t.Errorf("Wrong message: %x %v != %x %v", exp.header, exp.topic, act.header, act.topic)
+		}
+	}
+	if err = c.close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMQTTConnRefused(t *testing.T) {
+	addr, _, _ := serveMQTT(t, 5)
+	if _, err := dialMQTT(addr, "client", "", "", time.Second); err == nil {
+		t.Error("Expected error from refused connection")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestMQTTSinkFlush(t *testing.T) {
+	addr, connects, received := serveMQTT(t, 0)
+
+	conf := NewConfig()
+	conf.MQTT.URL = "tcp://foo:bar@" + addr
+	conf.MQTT.ClientID = "edge"
+
+	sink, err := NewMQTTSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	err = sink.Flush(Snapshot{
+		Time:    time.Unix(100, 0),
+		Gauges:  map[string]int64{Tagged("sensor.temp", map[string]string{"room": "a"}): 21},
+		Timings: map[string][]int64{"read+latency": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-connects
+
+	for _, exp := range []string{"benthos/stats/timing/read_latency", "benthos/stats/gauge/sensor/temp"} {
+		m := <-received
+		if m.topic != exp || m.header != mqttPublish {
+			t.Errorf("Wrong topic or header: %v %x != %v", m.topic, m.header, exp)
+		}
+		var doc metricDocument
+		if err = json.Unmarshal(m.payload, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Type == KindGauge && (doc.Tags["room"] != "a" || *doc.Value != 21) {
+			t.Errorf("Wrong gauge document: %+v", doc)
+		}
+	}
+}
+
+func TestMQTTSinkBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.MQTT.URL = "ssl://localhost"
+	if _, err := NewMQTTSink(conf); err == nil {
+		t.Error("Expected error from unsupported scheme")
+	}
+
+	conf = NewConfig()
+	conf.MQTT.QoS = 2
+	if _, err := NewMQTTSink(conf); err == nil {
+		t.Error("Expected error from unsupported qos")
+	}
+
+	sink, err := NewMQTTSink(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := sink.(*MQTTSink); m.address != "localhost:1883" || len(m.clientID) == 0 {
+		t.Errorf("Wrong defaults: %v %v", m.address, m.clientID)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
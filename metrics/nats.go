@@ -0,0 +1,325 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["nats"] = sinkSpec{
+		constructor: NewNATSSink,
+		description: `
+Publishes snapshots to NATS subjects with the NATS client protocol. In the mode
+'snapshot' each flush is published to the subject as a JSON message holding the
+counters, counter deltas, gauges and timing aggregates of the flush. In the mode
+'metric' each stat is published as its own JSON message to the subject followed
+by the name of the stat, e.g. 'benthos.stats.http.requests', where the tags of
+stats created with metrics.Tagged are included in the message. A flush waits for
+the server to acknowledge the messages, and credentials may be given in the URL
+or the config.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// NATSConfig - Config for the NATS sink.
+type NATSConfig struct {
+	URL      string `json:"url" yaml:"url"`
+	Subject  string `json:"subject" yaml:"subject"`
+	Mode     string `json:"mode" yaml:"mode"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	Token    string `json:"token" yaml:"token"`
+	Timeout  string `json:"timeout" yaml:"timeout"`
+}
+
+// NewNATSConfig - Creates a NATSConfig struct with default values.
+func NewNATSConfig() NATSConfig {
+	return NATSConfig{
+		URL:      "nats://localhost:4222",
+		Subject:  "benthos.stats",
+		Mode:     "snapshot",
+		Username: "",
+		Password: "",
+		Token:    "",
+		Timeout:  "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the NATS sink.
+var (
+	ErrNATSClosed = errors.New("nats connection was closed")
+)
+
+// natsSubjectReplacer - Replaces the characters that are not valid within the tokens of a subject.
+var natsSubjectReplacer = strings.NewReplacer(" ", "_", "\t", "_", "*", "_", ">", "_")
+
+// NATSSink - A Sink that publishes snapshots to NATS.
+type NATSSink struct {
+	conf    NATSConfig
+	address string
+	timeout time.Duration
+
+	conn *natsConn
+}
+
+// NewNATSSink - Create a new NATS sink.
+func NewNATSSink(config Config) (Sink, error) {
+	conf := config.NATS
+	if len(conf.Subject) == 0 {
+		return nil, fmt.Errorf("nats subject must not be empty")
+	}
+	if conf.Mode != "snapshot" && conf.Mode != "metric" {
+		return nil, fmt.Errorf("nats mode not recognised: %v", conf.Mode)
+	}
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse nats url: %v", err)
+	}
+	if u.User != nil && len(conf.Username) == 0 && len(conf.Token) == 0 {
+		if pass, ok := u.User.Password(); ok {
+			conf.Username, conf.Password = u.User.Username(), pass
+		} else {
+			conf.Token = u.User.Username()
+		}
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	return &NATSSink{
+		conf:    conf,
+		address: u.Host,
+		timeout: timeout,
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// natsMessage - A subject and payload to publish.
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+// natsMessages - Converts a snapshot into messages according to the mode.
+func (n *NATSSink) natsMessages(snap Snapshot) ([]natsMessage, error) {
+	if n.conf.Mode == "snapshot" {
+		payload, err := marshalSnapshot(snap)
+		if err != nil {
+			return nil, err
+		}
+		return []natsMessage{{n.conf.Subject, payload}}, nil
+	}
+
+	messages := []natsMessage{}
+	for _, m := range metricDocuments(snap) {
+		payload, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		subject := n.conf.Subject + "." + natsSubjectReplacer.Replace(m.Name)
+		messages = append(messages, natsMessage{subject, payload})
+	}
+	return messages, nil
+}
+
+// Flush - Publishes a snapshot and waits for the server to process it, dropping the connection on
+// failure so that the next flush connects again.
+func (n *NATSSink) Flush(snap Snapshot) error {
+	messages, err := n.natsMessages(snap)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+	if n.conn == nil {
+		if n.conn, err = dialNATS(n.address, n.conf, n.timeout); err != nil {
+			return err
+		}
+	}
+	if err = n.conn.publish(messages); err != nil {
+		n.conn.close()
+		n.conn = nil
+	}
+	return err
+}
+
+// Close - Closes the connection to NATS if open.
+func (n *NATSSink) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.close()
+	n.conn = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// natsConn - A connection to a NATS server, which answers pings of the server from a reader
+// goroutine and reports acknowledgements and errors of the server on a channel.
+type natsConn struct {
+	conn    net.Conn
+	timeout time.Duration
+
+	writeMut sync.Mutex
+	replies  chan error
+	done     chan struct{}
+}
+
+// dialNATS - Connects to a NATS server and sends the CONNECT message.
+func dialNATS(address string, conf NATSConfig, timeout time.Duration) (*natsConn, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	info, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("nats server sent unexpected greeting: %v", strings.TrimSpace(info))
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	connect, err := json.Marshal(map[string]interface{}{
+		"verbose":    false,
+		"pedantic":   false,
+		"name":       "benthos",
+		"lang":       "go",
+		"user":       conf.Username,
+		"pass":       conf.Password,
+		"auth_token": conf.Token,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &natsConn{
+		conn:    conn,
+		timeout: timeout,
+		replies: make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(r)
+	if err = c.write([]byte("CONNECT " + string(connect) + "\r\nPING\r\n")); err == nil {
+		err = c.await()
+	}
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// readLoop - Reads the messages of the server until the connection is closed.
+func (c *natsConn) readLoop(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			c.write([]byte("PONG\r\n"))
+		case line == "PONG":
+			c.reply(nil)
+		case strings.HasPrefix(line, "-ERR"):
+			c.reply(fmt.Errorf("nats server responded with: %v", strings.TrimSpace(line[4:])))
+		}
+	}
+}
+
+// reply - Reports an acknowledgement or error without blocking, keeping the first reply that has
+// not been awaited.
+func (c *natsConn) reply(err error) {
+	select {
+	case c.replies <- err:
+	default:
+	}
+}
+
+// await - Waits for the server to acknowledge a PING or report an error.
+func (c *natsConn) await() error {
+	select {
+	case err := <-c.replies:
+		return err
+	case <-c.done:
+		return ErrNATSClosed
+	case <-time.After(c.timeout):
+		return fmt.Errorf("nats server did not respond within %v", c.timeout)
+	}
+}
+
+// write - Writes to the connection.
+func (c *natsConn) write(b []byte) error {
+	c.writeMut.Lock()
+	defer c.writeMut.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// publish - Publishes messages followed by a PING, and waits for the server to respond.
+func (c *natsConn) publish(messages []natsMessage) error {
+	var buf []byte
+	for _, m := range messages {
+		buf = append(buf, "PUB "+m.subject+" "+strconv.Itoa(len(m.payload))+"\r\n"...)
+		buf = append(buf, m.payload...)
+		buf = append(buf, "\r\n"...)
+	}
+	buf = append(buf, "PING\r\n"...)
+	if err := c.write(buf); err != nil {
+		return err
+	}
+	return c.await()
+}
+
+// close - Closes the connection and waits for the reader goroutine to stop.
+func (c *natsConn) close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
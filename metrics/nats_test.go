@@ -0,0 +1,180 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// natsPublished - A message received by a fake NATS server.
+type natsPublished struct {
+	subject string
+	payload string
+}
+
+// serveNATS - Runs a fake NATS server that records the CONNECT options and published messages,
+// and responds to PUB messages of the subject errSubject with an error.
+func serveNATS(t *testing.T, errSubject string) (string, <-chan string, <-chan natsPublished) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on tcp: %v", err)
+	}
+	connects := make(chan string, 1)
+	published := make(chan natsPublished, 10)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 0:
+			case fields[0] == "CONNECT":
+				connects <- strings.TrimSpace(line[len("CONNECT "):])
+			case fields[0] == "PING":
+				conn.Write([]byte("PONG\r\n"))
+			case fields[0] == "PUB":
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				io.ReadFull(r, payload)
+				if fields[1] == errSubject {
+					conn.Write([]byte("-ERR 'Permissions Violation'\r\n"))
+					continue
+				}
+				published <- natsPublished{fields[1], string(payload[:size])}
+			}
+		}
+	}()
+	return ln.Addr().String(), connects, published
+}
+
+func TestNATSSinkSnapshot(t *testing.T) {
+	addr, connects, published := serveNATS(t, "")
+
+	conf := NewConfig()
+	conf.NATS.URL = "nats://foo:bar@" + addr
+
+	sink, err := NewNATSSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err = sink.Flush(Snapshot{Time: time.Unix(100, 0), Gauges: map[string]int64{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var connect map[string]interface{}
+	if err = json.Unmarshal([]byte(<-connects), &connect); err != nil {
+		t.Fatal(err)
+	}
+	if connect["user"] != "foo" || connect["pass"] != "bar" {
+		t.Errorf("Wrong credentials: %v", connect)
+	}
+
+	m := <-published
+	if exp, act := "benthos.stats", m.subject; exp != act {
+		t.Errorf("Wrong subject: %v != %v", exp, act)
+	}
+	var doc snapshotDocument
+	if err = json.Unmarshal([]byte(m.payload), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := int64(1), doc.Gauges["a"]; exp != act {
+		t.Errorf("Wrong gauge: %v != %v", exp, act)
+	}
+}
+
+func TestNATSSinkMetric(t *testing.T) {
+	addr, _, published := serveNATS(t, "")
+
+	conf := NewConfig()
+	conf.NATS.URL = "nats://" + addr
+	conf.NATS.Mode = "metric"
+
+	sink, err := NewNATSSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	requests := Tagged("http.requests", map[string]string{"code": "200"})
+	err = sink.Flush(Snapshot{
+		Time:          time.Unix(100, 0),
+		Counters:      map[string]int64{requests: 5},
+		CounterDeltas: map[string]int64{requests: 2},
+		Timings:       map[string][]int64{"latency": {3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []natsPublished{
+		{"benthos.stats.http.requests", `{"time":100,"name":"http.requests","type":"counter","tags":{"code":"200"},"value":5,"delta":2}`},
+		{"benthos.stats.latency", `{"time":100,"name":"latency","type":"timing","stats":{"count":1,"max":3,"mean":3,"min":3,"p99":3}}`},
+	}
+	for _, e := range exp {
+		if act := <-published; e != act {
+			t.Errorf("Wrong message: %v != %v", e, act)
+		}
+	}
+}
+
+func TestNATSSinkError(t *testing.T) {
+	addr, _, _ := serveNATS(t, "benthos.stats")
+
+	conf := NewConfig()
+	conf.NATS.URL = "nats://" + addr
+
+	sink, err := NewNATSSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	err = sink.Flush(Snapshot{Gauges: map[string]int64{"a": 1}})
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Expected permissions error: %v", err)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
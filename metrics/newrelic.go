@@ -0,0 +1,183 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["newrelic"] = sinkSpec{
+		constructor: NewNewRelicSink,
+		description: `
+Posts snapshots to the New Relic Metric API. Counters are posted as counts of the
+delta of each flush over the flush interval, gauges as gauges and timings as
+summaries of their count, sum, min and max. The attributes of the config are
+common to all metrics of a flush, and the tags of stats created with
+metrics.Tagged become attributes of each metric. Accounts in the EU region
+should set the URL to https://metric-api.eu.newrelic.com/metric/v1.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// NewRelicConfig - Config for the New Relic Metric API sink.
+type NewRelicConfig struct {
+	URL        string            `json:"url" yaml:"url"`
+	APIKey     string            `json:"api_key" yaml:"api_key"`
+	Attributes map[string]string `json:"attributes" yaml:"attributes"`
+	Timeout    string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewNewRelicConfig - Creates a NewRelicConfig struct with default values.
+func NewNewRelicConfig() NewRelicConfig {
+	return NewRelicConfig{
+		URL:        "https://metric-api.newrelic.com/metric/v1",
+		APIKey:     "",
+		Attributes: map[string]string{},
+		Timeout:    "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// NewRelicSink - A Sink that posts snapshots to the New Relic Metric API.
+type NewRelicSink struct {
+	url        string
+	headers    map[string]string
+	attributes map[string]string
+	sender     func(url string, body []byte, headers map[string]string) error
+}
+
+// NewNewRelicSink - Create a new New Relic Metric API sink.
+func NewNewRelicSink(config Config) (Sink, error) {
+	conf := config.NewRelic
+	if len(conf.APIKey) == 0 {
+		return nil, fmt.Errorf("newrelic api key must not be empty")
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &NewRelicSink{
+		url: conf.URL,
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"Api-Key":      conf.APIKey,
+		},
+		attributes: conf.Attributes,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+type (
+	newRelicPayload struct {
+		Common  newRelicCommon   `json:"common"`
+		Metrics []newRelicMetric `json:"metrics"`
+	}
+	newRelicCommon struct {
+		Timestamp  int64             `json:"timestamp"`
+		IntervalMs int64             `json:"interval.ms"`
+		Attributes map[string]string `json:"attributes,omitempty"`
+	}
+	newRelicMetric struct {
+		Name       string            `json:"name"`
+		Type       string            `json:"type"`
+		Value      interface{}       `json:"value"`
+		Attributes map[string]string `json:"attributes,omitempty"`
+	}
+	newRelicSummary struct {
+		Count int64 `json:"count"`
+		Sum   int64 `json:"sum"`
+		Min   int64 `json:"min"`
+		Max   int64 `json:"max"`
+	}
+)
+
+// newRelicPayloadFor - Converts a snapshot into a New Relic Metric API payload. The interval of
+// the payload applies to the counts and summaries, and is ignored by New Relic for gauges.
+func (n *NewRelicSink) newRelicPayloadFor(snap Snapshot) newRelicPayload {
+	payload := newRelicPayload{
+		Common: newRelicCommon{
+			Timestamp:  snap.Time.UnixNano() / 1e6,
+			IntervalMs: int64(snap.Interval / 1e6),
+			Attributes: n.attributes,
+		},
+		Metrics: []newRelicMetric{},
+	}
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		add := func(kind string, value interface{}) {
+			payload.Metrics = append(payload.Metrics, newRelicMetric{
+				Name:       name,
+				Type:       kind,
+				Value:      value,
+				Attributes: tags,
+			})
+		}
+
+		if delta, ok := snap.CounterDeltas[path]; ok {
+			add("count", delta)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add("gauge", v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			sum := SummariseTimings(samples)
+			add("summary", newRelicSummary{
+				Count: sum.Count,
+				Sum:   sum.Sum,
+				Min:   sum.Min,
+				Max:   sum.Max,
+			})
+		}
+	}
+	return payload
+}
+
+// Flush - Posts a snapshot to New Relic.
+func (n *NewRelicSink) Flush(snap Snapshot) error {
+	payload := n.newRelicPayloadFor(snap)
+	if len(payload.Metrics) == 0 {
+		return nil
+	}
+	body, err := json.Marshal([]newRelicPayload{payload})
+	if err != nil {
+		return err
+	}
+	return n.sender(n.url, body, n.headers)
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (n *NewRelicSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
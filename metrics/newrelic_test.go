@@ -0,0 +1,75 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestNewRelicSinkPayload(t *testing.T) {
+	conf := NewConfig()
+	conf.NewRelic.APIKey = "foo"
+	conf.NewRelic.Attributes = map[string]string{"service": "bar"}
+
+	sink, err := NewNewRelicSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := sink.(*NewRelicSink)
+
+	var sentBody []byte
+	var sentHeaders map[string]string
+	n.sender = func(url string, body []byte, headers map[string]string) error {
+		sentBody, sentHeaders = body, headers
+		return nil
+	}
+
+	requests := Tagged("requests", map[string]string{"code": "200"})
+	err = n.Flush(Snapshot{
+		Time:          time.Unix(100, 0),
+		Interval:      10 * time.Second,
+		Counters:      map[string]int64{requests: 9},
+		CounterDeltas: map[string]int64{requests: 4},
+		Gauges:        map[string]int64{"queue": 3},
+		Timings:       map[string][]int64{"latency": {1, 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "foo", sentHeaders["Api-Key"]; exp != act {
+		t.Errorf("Wrong api key: %v != %v", exp, act)
+	}
+	exp := `[{"common":{"timestamp":100000,"interval.ms":10000,"attributes":{"service":"bar"}},"metrics":[` +
+		`{"name":"latency","type":"summary","value":{"count":2,"sum":6,"min":1,"max":5}},` +
+		`{"name":"queue","type":"gauge","value":3},` +
+		`{"name":"requests","type":"count","value":4,"attributes":{"code":"200"}}]}]`
+	if act := string(sentBody); exp != act {
+		t.Errorf("Wrong body:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,191 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["opentsdb"] = sinkSpec{
+		constructor: NewOpenTSDBSink,
+		description: `
+Posts snapshots as data points to the /api/put endpoint of OpenTSDB, split into
+batches of at most batch_size data points per request. Counters are posted as
+running totals, gauges as their value and timings as their count, min, max,
+mean and p99. The tags of the config and of stats created with metrics.Tagged
+become OpenTSDB tags, where characters that OpenTSDB does not permit are
+replaced with underscores. As OpenTSDB requires at least one tag, every data
+point also carries a 'host' tag, which defaults to the hostname.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// OpenTSDBConfig - Config for the OpenTSDB HTTP sink.
+type OpenTSDBConfig struct {
+	URL       string            `json:"url" yaml:"url"`
+	Host      string            `json:"host" yaml:"host"`
+	Tags      map[string]string `json:"tags" yaml:"tags"`
+	BatchSize int               `json:"batch_size" yaml:"batch_size"`
+	Timeout   string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewOpenTSDBConfig - Creates an OpenTSDBConfig struct with default values.
+func NewOpenTSDBConfig() OpenTSDBConfig {
+	return OpenTSDBConfig{
+		URL:       "http://localhost:4242",
+		Host:      "",
+		Tags:      map[string]string{},
+		BatchSize: 50,
+		Timeout:   "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// OpenTSDBSink - A Sink that posts snapshots to the OpenTSDB put API.
+type OpenTSDBSink struct {
+	putURL    string
+	headers   map[string]string
+	tags      map[string]string
+	batchSize int
+	sender    func(url string, body []byte, headers map[string]string) error
+}
+
+// NewOpenTSDBSink - Create a new OpenTSDB HTTP sink.
+func NewOpenTSDBSink(config Config) (Sink, error) {
+	conf := config.OpenTSDB
+	if conf.BatchSize <= 0 {
+		return nil, fmt.Errorf("opentsdb batch size must be greater than zero")
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	host := conf.Host
+	if len(host) == 0 {
+		if host, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to resolve hostname for host tag: %v", err)
+		}
+	}
+	return &OpenTSDBSink{
+		putURL:    strings.TrimSuffix(conf.URL, "/") + "/api/put",
+		headers:   map[string]string{"Content-Type": "application/json"},
+		tags:      mergeTags(map[string]string{"host": host}, conf.Tags),
+		batchSize: conf.BatchSize,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// openTSDBPoint - A data point of the OpenTSDB put API.
+type openTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     int64             `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// openTSDBName - Replaces any characters not permitted within OpenTSDB metric names, tag keys and
+// tag values with underscores.
+func openTSDBName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '/') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// openTSDBPoints - Converts a snapshot into OpenTSDB data points.
+func (o *OpenTSDBSink) openTSDBPoints(snap Snapshot) []openTSDBPoint {
+	ts := snap.Time.Unix()
+
+	points := []openTSDBPoint{}
+	for _, path := range snap.Paths() {
+		name, pathTags := splitTags(path)
+		merged := mergeTags(o.tags, pathTags)
+		tags := make(map[string]string, len(merged))
+		for k, v := range merged {
+			tags[openTSDBName(k)] = openTSDBName(v)
+		}
+		add := func(metric string, value int64) {
+			points = append(points, openTSDBPoint{
+				Metric:    openTSDBName(metric),
+				Timestamp: ts,
+				Value:     value,
+				Tags:      tags,
+			})
+		}
+
+		if v, ok := snap.Counters[path]; ok {
+			add(name, v)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add(name, v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				add(name+"."+agg.name, agg.value)
+			}
+		}
+	}
+	return points
+}
+
+// Flush - Posts a snapshot in batches, stopping at the first batch that fails.
+func (o *OpenTSDBSink) Flush(snap Snapshot) error {
+	points := o.openTSDBPoints(snap)
+	for start := 0; start < len(points); start += o.batchSize {
+		end := start + o.batchSize
+		if end > len(points) {
+			end = len(points)
+		}
+		body, err := json.Marshal(points[start:end])
+		if err != nil {
+			return err
+		}
+		if err = o.sender(o.putURL, body, o.headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (o *OpenTSDBSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
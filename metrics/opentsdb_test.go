@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestOpenTSDBSinkBatches(t *testing.T) {
+	conf := NewConfig()
+	conf.OpenTSDB.Host = "web-1"
+	conf.OpenTSDB.Tags = map[string]string{"env": "prod"}
+	conf.OpenTSDB.BatchSize = 4
+
+	sink, err := NewOpenTSDBSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := sink.(*OpenTSDBSink)
+
+	var sentURL string
+	var sent []string
+	o.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL = url
+		sent = append(sent, string(body))
+		return nil
+	}
+
+	requests := Tagged("http requests", map[string]string{"status code": "200 OK"})
+	err = o.Flush(Snapshot{
+		Time:     time.Unix(100, 0),
+		Counters: map[string]int64{requests: 9},
+		Gauges:   map[string]int64{"queue": 3},
+		Timings:  map[string][]int64{"latency": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "http://localhost:4242/api/put", sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	tags := `"tags":{"env":"prod","host":"web-1"}`
+	exp := []string{
+		`[{"metric":"http_requests","timestamp":100,"value":9,"tags":{"env":"prod","host":"web-1","status_code":"200_OK"}},` +
+			`{"metric":"latency.count","timestamp":100,"value":1,` + tags + `},` +
+			`{"metric":"latency.min","timestamp":100,"value":2,` + tags + `},` +
+			`{"metric":"latency.max","timestamp":100,"value":2,` + tags + `}]`,
+		`[{"metric":"latency.mean","timestamp":100,"value":2,` + tags + `},` +
+			`{"metric":"latency.p99","timestamp":100,"value":2,` + tags + `},` +
+			`{"metric":"queue","timestamp":100,"value":3,` + tags + `}]`,
+	}
+	if len(sent) != len(exp) {
+		t.Fatalf("Wrong count of batches: %v != %v", len(exp), len(sent))
+	}
+	for i := range exp {
+		if exp[i] != sent[i] {
+			t.Errorf("Wrong batch %v:\n%v\n!=\n%v", i, exp[i], sent[i])
+		}
+	}
+}
+
+func TestOpenTSDBSinkBatchSize(t *testing.T) {
+	conf := NewConfig()
+	conf.OpenTSDB.BatchSize = 0
+	if _, err := NewOpenTSDBSink(conf); err == nil {
+		t.Error("Expected error from zero batch size")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,317 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["otlp"] = sinkSpec{
+		constructor: NewOTLPSink,
+		description: `
+Exports snapshots to an OpenTelemetry collector using OTLP over HTTP, where the
+protocol is either http/json or http/protobuf. Counters are exported as
+cumulative sums, gauges as gauges and timings as delta histograms with the
+configured bucket boundaries. Tags of stats created with metrics.Tagged become
+data point attributes. OTLP over gRPC is not supported as it would require a gRPC
+dependency, collectors accept both encodings of OTLP over HTTP on port 4318 by
+default.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// OTLPConfig - Config for the OpenTelemetry OTLP sink.
+type OTLPConfig struct {
+	Endpoint           string            `json:"endpoint" yaml:"endpoint"`
+	Protocol           string            `json:"protocol" yaml:"protocol"`
+	Headers            map[string]string `json:"headers" yaml:"headers"`
+	Timeout            string            `json:"timeout" yaml:"timeout"`
+	ServiceName        string            `json:"service_name" yaml:"service_name"`
+	ResourceAttributes map[string]string `json:"resource_attributes" yaml:"resource_attributes"`
+	HistogramBounds    []float64         `json:"histogram_bounds" yaml:"histogram_bounds"`
+}
+
+// NewOTLPConfig - Creates an OTLPConfig struct with default values.
+func NewOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		Endpoint:           "http://localhost:4318/v1/metrics",
+		Protocol:           "http/json",
+		Headers:            map[string]string{},
+		Timeout:            "5s",
+		ServiceName:        "unknown_service",
+		ResourceAttributes: map[string]string{},
+		HistogramBounds:    []float64{1e6, 5e6, 1e7, 5e7, 1e8, 5e8, 1e9, 5e9},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// OTLPSink - A Sink that exports snapshots to an OpenTelemetry collector.
+type OTLPSink struct {
+	endpoint  string
+	protobuf  bool
+	headers   map[string]string
+	resource  []otlpKeyValue
+	bounds    []float64
+	startTime time.Time
+	sender    func(url string, body []byte, headers map[string]string) error
+}
+
+// NewOTLPSink - Create a new OTLP sink.
+func NewOTLPSink(config Config) (Sink, error) {
+	conf := config.OTLP
+	var contentType string
+	switch conf.Protocol {
+	case "http/json":
+		contentType = "application/json"
+	case "http/protobuf":
+		contentType = "application/x-protobuf"
+	default:
+		return nil, fmt.Errorf("otlp protocol not supported: %v", conf.Protocol)
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{"Content-Type": contentType}
+	for k, v := range conf.Headers {
+		headers[k] = v
+	}
+	attributes := mergeTags(conf.ResourceAttributes, map[string]string{"service.name": conf.ServiceName})
+
+	return &OTLPSink{
+		endpoint:  conf.Endpoint,
+		protobuf:  conf.Protocol == "http/protobuf",
+		headers:   headers,
+		resource:  otlpAttributes(attributes),
+		bounds:    conf.HistogramBounds,
+		startTime: time.Now(),
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// The OTLP JSON encoding of the subset of the metrics data model that we export. Integers of 64 bits
+// are encoded as strings as per the protobuf JSON mapping. The same model is encoded as protobuf by
+// encodeOTLPRequest.
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpMetric struct {
+		Name      string         `json:"name"`
+		Sum       *otlpSum       `json:"sum,omitempty"`
+		Gauge     *otlpGauge     `json:"gauge,omitempty"`
+		Histogram *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpNumberPoint `json:"dataPoints"`
+		AggregationTemporality int               `json:"aggregationTemporality"`
+		IsMonotonic            bool              `json:"isMonotonic"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpNumberPoint `json:"dataPoints"`
+	}
+	otlpHistogram struct {
+		DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+		AggregationTemporality int                  `json:"aggregationTemporality"`
+	}
+	otlpNumberPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		AsInt             string         `json:"asInt"`
+	}
+	otlpHistogramPoint struct {
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		TimeUnixNano      string         `json:"timeUnixNano"`
+		Count             string         `json:"count"`
+		Sum               float64        `json:"sum"`
+		Min               float64        `json:"min"`
+		Max               float64        `json:"max"`
+		BucketCounts      []string       `json:"bucketCounts"`
+		ExplicitBounds    []float64      `json:"explicitBounds"`
+	}
+)
+
+// Aggregation temporalities of the OTLP data model.
+const (
+	otlpTemporalityDelta      = 1
+	otlpTemporalityCumulative = 2
+)
+
+// otlpAttributes - Converts tags into OTLP attributes sorted by key.
+func otlpAttributes(tags map[string]string) []otlpKeyValue {
+	if len(tags) == 0 {
+		return nil
+	}
+	attrs := make([]otlpKeyValue, 0, len(tags))
+	for _, k := range sortedKeys(tags) {
+		attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: tags[k]}})
+	}
+	return attrs
+}
+
+// otlpNanos - Formats a time as unix nanoseconds.
+func otlpNanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpRequestFor - Converts a snapshot into an OTLP export request, where stats of the same name
+// with different tags are data points of a single metric.
+func (o *OTLPSink) otlpRequestFor(snap Snapshot) otlpRequest {
+	now, start := otlpNanos(snap.Time), otlpNanos(o.startTime)
+	windowStart := otlpNanos(snap.Time.Add(-snap.Interval))
+
+	metrics := []otlpMetric{}
+	index := map[string]int{}
+	metric := func(name, kind string) *otlpMetric {
+		key := kind + ":" + name
+		if i, ok := index[key]; ok {
+			return &metrics[i]
+		}
+		m := otlpMetric{Name: name}
+		switch kind {
+		case "sum":
+			m.Sum = &otlpSum{AggregationTemporality: otlpTemporalityCumulative}
+		case "gauge":
+			m.Gauge = &otlpGauge{}
+		case "histogram":
+			m.Histogram = &otlpHistogram{AggregationTemporality: otlpTemporalityDelta}
+		}
+		index[key] = len(metrics)
+		metrics = append(metrics, m)
+		return &metrics[len(metrics)-1]
+	}
+
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		attrs := otlpAttributes(tags)
+
+		if v, ok := snap.Counters[path]; ok {
+			m := metric(name, "sum")
+			m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      now,
+				AsInt:             strconv.FormatInt(v, 10),
+			})
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			m := metric(name, "gauge")
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberPoint{
+				Attributes:   attrs,
+				TimeUnixNano: now,
+				AsInt:        strconv.FormatInt(v, 10),
+			})
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			sum := SummariseTimings(samples)
+			buckets := make([]int64, len(o.bounds)+1)
+			for _, s := range samples {
+				i := 0
+				for i < len(o.bounds) && float64(s) > o.bounds[i] {
+					i++
+				}
+				buckets[i]++
+			}
+			counts := make([]string, len(buckets))
+			for i, c := range buckets {
+				counts[i] = strconv.FormatInt(c, 10)
+			}
+			m := metric(name, "histogram")
+			m.Histogram.DataPoints = append(m.Histogram.DataPoints, otlpHistogramPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: windowStart,
+				TimeUnixNano:      now,
+				Count:             strconv.FormatInt(sum.Count, 10),
+				Sum:               float64(sum.Sum),
+				Min:               float64(sum.Min),
+				Max:               float64(sum.Max),
+				BucketCounts:      counts,
+				ExplicitBounds:    o.bounds,
+			})
+		}
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: o.resource},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/jeffail/util/metrics"},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+// Flush - Exports a snapshot to the collector.
+func (o *OTLPSink) Flush(snap Snapshot) error {
+	req := o.otlpRequestFor(snap)
+	if o.protobuf {
+		return o.sender(o.endpoint, encodeOTLPRequest(req), o.headers)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return o.sender(o.endpoint, body, o.headers)
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (o *OTLPSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,156 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+This file implements the protobuf encoding of OTLP export requests without a client library. The
+request is built once as the JSON model of otlp.go and then encoded with the protoBuffer of the
+Riemann protocol, using the field numbers of the opentelemetry-proto metrics messages.
+*/
+
+// otlpFixed64 - Parses a 64 bit integer of the JSON model, which is encoded as a string.
+func otlpFixed64(s string) uint64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return uint64(v)
+}
+
+// appendFixed64 - Appends a fixed width 64 bit value without a field key.
+func appendFixed64(b *protoBuffer, v uint64) {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	*b = append(*b, tmp[:]...)
+}
+
+// fixed64Field - Appends a fixed64 or sfixed64 field.
+func fixed64Field(b *protoBuffer, field int, v uint64) {
+	b.key(field, wireFixed64)
+	appendFixed64(b, v)
+}
+
+// encodeOTLPRequest - Encodes an export request as an ExportMetricsServiceRequest message.
+func encodeOTLPRequest(req otlpRequest) []byte {
+	var msg protoBuffer
+	for _, rm := range req.ResourceMetrics {
+		var resourceMetrics, resource protoBuffer
+		encodeOTLPAttributes(&resource, 1, rm.Resource.Attributes)
+		resourceMetrics.bytesField(1, resource)
+		for _, sm := range rm.ScopeMetrics {
+			var scopeMetrics, scope protoBuffer
+			scope.stringField(1, sm.Scope.Name)
+			scopeMetrics.bytesField(1, scope)
+			for _, m := range sm.Metrics {
+				scopeMetrics.bytesField(2, encodeOTLPMetric(m))
+			}
+			resourceMetrics.bytesField(2, scopeMetrics)
+		}
+		msg.bytesField(1, resourceMetrics)
+	}
+	return msg
+}
+
+// encodeOTLPAttributes - Appends attributes as repeated KeyValue messages.
+func encodeOTLPAttributes(b *protoBuffer, field int, attrs []otlpKeyValue) {
+	for _, a := range attrs {
+		var kv, value protoBuffer
+		kv.stringField(1, a.Key)
+		value.stringField(1, a.Value.StringValue)
+		kv.bytesField(2, value)
+		b.bytesField(field, kv)
+	}
+}
+
+// encodeOTLPMetric - Encodes a Metric message.
+func encodeOTLPMetric(m otlpMetric) []byte {
+	var msg protoBuffer
+	msg.stringField(1, m.Name)
+	switch {
+	case m.Gauge != nil:
+		var gauge protoBuffer
+		for _, p := range m.Gauge.DataPoints {
+			gauge.bytesField(1, encodeOTLPNumberPoint(p))
+		}
+		msg.bytesField(5, gauge)
+	case m.Sum != nil:
+		var sum protoBuffer
+		for _, p := range m.Sum.DataPoints {
+			sum.bytesField(1, encodeOTLPNumberPoint(p))
+		}
+		sum.int64Field(2, int64(m.Sum.AggregationTemporality))
+		if m.Sum.IsMonotonic {
+			sum.int64Field(3, 1)
+		}
+		msg.bytesField(7, sum)
+	case m.Histogram != nil:
+		var hist protoBuffer
+		for _, p := range m.Histogram.DataPoints {
+			hist.bytesField(1, encodeOTLPHistogramPoint(p))
+		}
+		hist.int64Field(2, int64(m.Histogram.AggregationTemporality))
+		msg.bytesField(9, hist)
+	}
+	return msg
+}
+
+// encodeOTLPNumberPoint - Encodes a NumberDataPoint message with an integer value.
+func encodeOTLPNumberPoint(p otlpNumberPoint) []byte {
+	var msg protoBuffer
+	if len(p.StartTimeUnixNano) > 0 {
+		fixed64Field(&msg, 2, otlpFixed64(p.StartTimeUnixNano))
+	}
+	fixed64Field(&msg, 3, otlpFixed64(p.TimeUnixNano))
+	fixed64Field(&msg, 6, otlpFixed64(p.AsInt))
+	encodeOTLPAttributes(&msg, 7, p.Attributes)
+	return msg
+}
+
+// encodeOTLPHistogramPoint - Encodes a HistogramDataPoint message, where bucket counts and bounds
+// are packed repeated fields.
+func encodeOTLPHistogramPoint(p otlpHistogramPoint) []byte {
+	var msg, counts, bounds protoBuffer
+	fixed64Field(&msg, 2, otlpFixed64(p.StartTimeUnixNano))
+	fixed64Field(&msg, 3, otlpFixed64(p.TimeUnixNano))
+	fixed64Field(&msg, 4, otlpFixed64(p.Count))
+	msg.doubleField(5, p.Sum)
+	for _, c := range p.BucketCounts {
+		appendFixed64(&counts, otlpFixed64(c))
+	}
+	msg.bytesField(6, counts)
+	for _, v := range p.ExplicitBounds {
+		appendFixed64(&bounds, math.Float64bits(v))
+	}
+	msg.bytesField(7, bounds)
+	encodeOTLPAttributes(&msg, 9, p.Attributes)
+	msg.doubleField(11, p.Min)
+	msg.doubleField(12, p.Max)
+	return msg
+}
+
+//--------------------------------------------------------------------------------------------------
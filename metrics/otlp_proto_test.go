@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// protoFields - Groups the decoded fields of a protobuf message by field number.
+func protoFields(t *testing.T, b []byte) map[int][]interface{} {
+	fields := map[int][]interface{}{}
+	for _, f := range decodeProto(t, b) {
+		fields[f[0].(int)] = append(fields[f[0].(int)], f[1])
+	}
+	return fields
+}
+
+func TestOTLPSinkProtobuf(t *testing.T) {
+	conf := NewConfig()
+	conf.OTLP.Protocol = "http/protobuf"
+	conf.OTLP.ServiceName = "foo"
+	conf.OTLP.HistogramBounds = []float64{2}
+
+	sink, err := NewOTLPSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := sink.(*OTLPSink)
+
+	var sentBody []byte
+	var sentHeaders map[string]string
+	o.sender = func(url string, body []byte, headers map[string]string) error {
+		sentBody, sentHeaders = body, headers
+		return nil
+	}
+
+	err = o.Flush(Snapshot{
+		Time:     time.Unix(100, 0),
+		Interval: time.Second,
+		Counters: map[string]int64{Tagged("requests", map[string]string{"code": "200"}): 5},
+		Gauges:   map[string]int64{"queue": -3},
+		Timings:  map[string][]int64{"latency": {1, 3, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "application/x-protobuf", sentHeaders["Content-Type"]; exp != act {
+		t.Errorf("Wrong content type: %v != %v", exp, act)
+	}
+
+	resourceMetrics := protoFields(t, protoFields(t, sentBody)[1][0].([]byte))
+	resource := protoFields(t, resourceMetrics[1][0].([]byte))
+	kv := protoFields(t, resource[1][0].([]byte))
+	if exp, act := "service.name", string(kv[1][0].([]byte)); exp != act {
+		t.Errorf("Wrong resource attribute: %v != %v", exp, act)
+	}
+	if exp, act := "foo", string(protoFields(t, kv[2][0].([]byte))[1][0].([]byte)); exp != act {
+		t.Errorf("Wrong service name: %v != %v", exp, act)
+	}
+
+	scopeMetrics := protoFields(t, resourceMetrics[2][0].([]byte))
+	metrics := map[string]map[int][]interface{}{}
+	for _, raw := range scopeMetrics[2] {
+		m := protoFields(t, raw.([]byte))
+		metrics[string(m[1][0].([]byte))] = m
+	}
+
+	sum := protoFields(t, metrics["requests"][7][0].([]byte))
+	if exp, act := uint64(otlpTemporalityCumulative), sum[2][0]; exp != act {
+		t.Errorf("Wrong temporality: %v != %v", exp, act)
+	}
+	point := protoFields(t, sum[1][0].([]byte))
+	if exp, act := uint64(5), point[6][0]; exp != act {
+		t.Errorf("Wrong counter value: %v != %v", exp, act)
+	}
+	if exp, act := uint64(time.Unix(100, 0).UnixNano()), point[3][0]; exp != act {
+		t.Errorf("Wrong time: %v != %v", exp, act)
+	}
+	if exp, act := "code", string(protoFields(t, point[7][0].([]byte))[1][0].([]byte)); exp != act {
+		t.Errorf("Wrong attribute: %v != %v", exp, act)
+	}
+
+	gauge := protoFields(t, metrics["queue"][5][0].([]byte))
+	if exp, act := int64(-3), int64(protoFields(t, gauge[1][0].([]byte))[6][0].(uint64)); exp != act {
+		t.Errorf("Wrong gauge value: %v != %v", exp, act)
+	}
+
+	hist := protoFields(t, metrics["latency"][9][0].([]byte))
+	point = protoFields(t, hist[1][0].([]byte))
+	if exp, act := uint64(3), point[4][0]; exp != act {
+		t.Errorf("Wrong count: %v != %v", exp, act)
+	}
+	if exp, act := 8.0, math.Float64frombits(point[5][0].(uint64)); exp != act {
+		t.Errorf("Wrong sum: %v != %v", exp, act)
+	}
+	var counts []uint64
+	for b := point[6][0].([]byte); len(b) > 0; b = b[8:] {
+		counts = append(counts, binary.LittleEndian.Uint64(b))
+	}
+	if exp := []uint64{1, 2}; !reflect.DeepEqual(exp, counts) {
+		t.Errorf("Wrong bucket counts: %v != %v", exp, counts)
+	}
+	if exp, act := 2.0, math.Float64frombits(binary.LittleEndian.Uint64(point[7][0].([]byte))); exp != act {
+		t.Errorf("Wrong bound: %v != %v", exp, act)
+	}
+	if exp, act := 4.0, math.Float64frombits(point[12][0].(uint64)); exp != act {
+		t.Errorf("Wrong max: %v != %v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
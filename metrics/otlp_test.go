@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestOTLPSinkRequest(t *testing.T) {
+	conf := NewConfig()
+	conf.OTLP.ServiceName = "foo"
+	conf.OTLP.HistogramBounds = []float64{2}
+
+	sink, err := NewOTLPSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := sink.(*OTLPSink)
+
+	var sentBody []byte
+	var sentHeaders map[string]string
+	o.sender = func(url string, body []byte, headers map[string]string) error {
+		sentBody, sentHeaders = body, headers
+		return nil
+	}
+
+	err = o.Flush(Snapshot{
+		Time:     time.Unix(100, 0),
+		Interval: time.Second,
+		Counters: map[string]int64{
+			Tagged("requests", map[string]string{"code": "200"}): 5,
+			Tagged("requests", map[string]string{"code": "500"}): 1,
+		},
+		Gauges:  map[string]int64{"queue": 3},
+		Timings: map[string][]int64{"latency": {1, 3, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "application/json", sentHeaders["Content-Type"]; exp != act {
+		t.Errorf("Wrong content type: %v != %v", exp, act)
+	}
+
+	var req otlpRequest
+	if err = json.Unmarshal(sentBody, &req); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "foo", req.ResourceMetrics[0].Resource.Attributes[0].Value.StringValue; exp != act {
+		t.Errorf("Wrong service name: %v != %v", exp, act)
+	}
+
+	metrics := map[string]otlpMetric{}
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	if m := metrics["requests"]; m.Sum == nil || len(m.Sum.DataPoints) != 2 {
+		t.Errorf("Wrong requests metric: %+v", m)
+	} else if exp, act := "500", m.Sum.DataPoints[1].Attributes[0].Value.StringValue; exp != act {
+		t.Errorf("Wrong attribute: %v != %v", exp, act)
+	}
+	if m := metrics["queue"]; m.Gauge == nil || m.Gauge.DataPoints[0].AsInt != "3" {
+		t.Errorf("Wrong queue metric: %+v", m)
+	}
+	if m := metrics["latency"]; m.Histogram == nil {
+		t.Errorf("Wrong latency metric: %+v", m)
+	} else {
+		p := m.Histogram.DataPoints[0]
+		if p.Count != "3" || p.Sum != 8 || len(p.BucketCounts) != 2 ||
+			p.BucketCounts[0] != "1" || p.BucketCounts[1] != "2" {
+			t.Errorf("Wrong histogram point: %+v", p)
+		}
+		if exp, act := otlpNanos(time.Unix(99, 0)), p.StartTimeUnixNano; exp != act {
+			t.Errorf("Wrong start time: %v != %v", exp, act)
+		}
+	}
+}
+
+func TestOTLPSinkProtocol(t *testing.T) {
+	conf := NewConfig()
+	conf.OTLP.Protocol = "grpc"
+	if _, err := NewOTLPSink(conf); err == nil {
+		t.Error("Expected error for unsupported protocol")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
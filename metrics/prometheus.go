@@ -0,0 +1,257 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["prometheus"] = sinkSpec{
+		constructor: NewPrometheusSink,
+		description: `
+Keeps the latest snapshot for Prometheus to scrape in the text exposition format.
+When an address is configured the snapshot is served at the configured path,
+otherwise the sink can be registered as an http.Handler of an existing server.
+Counters and gauges are exposed as such and timings as summaries of the samples
+recorded since the previous flush. The description and kind of metrics registered
+with metrics.RegisterMetadata are used as the help text and type hint.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// PrometheusConfig - Config for the Prometheus sink.
+type PrometheusConfig struct {
+	Address   string    `json:"address" yaml:"address"`
+	Path      string    `json:"path" yaml:"path"`
+	Quantiles []float64 `json:"quantiles" yaml:"quantiles"`
+}
+
+// NewPrometheusConfig - Creates a PrometheusConfig struct with default values.
+func NewPrometheusConfig() PrometheusConfig {
+	return PrometheusConfig{
+		Address:   "",
+		Path:      "/metrics",
+		Quantiles: []float64{0.5, 0.9, 0.99},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// PrometheusSink - A Sink that serves the latest snapshot in the Prometheus text exposition format.
+type PrometheusSink struct {
+	sync.RWMutex
+
+	quantiles  []float64
+	prefix     string
+	exposition []byte
+	server     *http.Server
+}
+
+// NewPrometheusSink - Create a new Prometheus sink.
+func NewPrometheusSink(config Config) (Sink, error) {
+	return newPrometheusSink(config.Prometheus, expandPrefix(config.Sinks.Prefix)), nil
+}
+
+// newPrometheusSink - Creates a Prometheus sink, serving it when an address is configured. The
+// prefix is that of the Sinks type, which is removed from stat paths to find their metadata.
+func newPrometheusSink(conf PrometheusConfig, prefix string) *PrometheusSink {
+	p := &PrometheusSink{quantiles: conf.Quantiles, prefix: prefix}
+	if len(conf.Address) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle(conf.Path, p)
+		p.server = &http.Server{Addr: conf.Address, Handler: mux}
+		go p.server.ListenAndServe()
+	}
+	return p
+}
+
+// ServeHTTP - Writes the latest snapshot in the Prometheus text exposition format.
+func (p *PrometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.RLock()
+	exposition := p.exposition
+	p.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(exposition)
+}
+
+// Flush - Replaces the snapshot being served.
+func (p *PrometheusSink) Flush(snap Snapshot) error {
+	exposition := renderPrometheus(snap, p.quantiles, p.prefix)
+
+	p.Lock()
+	p.exposition = exposition
+	p.Unlock()
+	return nil
+}
+
+// Close - Stops serving the snapshot when an address was configured.
+func (p *PrometheusSink) Close() error {
+	if p.server != nil {
+		return p.server.Close()
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// prometheusName - Converts a stat path into a valid Prometheus metric name, replacing dots and any
+// other invalid characters with underscores.
+func prometheusName(path string) string {
+	b := []byte(path)
+	for i, c := range b {
+		valid := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// prometheusLabelEscaper - Escapes a label value, where the text format only allows backslashes,
+// double quotes and line feeds to be escaped.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusHelpEscaper - Escapes HELP text, where the text format only allows backslashes and line
+// feeds to be escaped.
+var prometheusHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// prometheusLabels - Formats tags as Prometheus labels, including any extra label pairs.
+func prometheusLabels(tags map[string]string, extra ...string) string {
+	parts := []string{}
+	for _, k := range sortedKeys(tags) {
+		parts = append(parts, prometheusName(k)+`="`+prometheusLabelEscaper.Replace(tags[k])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+prometheusLabelEscaper.Replace(extra[i+1])+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// prometheusKinds - The Prometheus metric type of each kind of registered metadata.
+var prometheusKinds = map[string]string{
+	KindCounter: "counter",
+	KindGauge:   "gauge",
+	KindTiming:  "summary",
+}
+
+// prometheusSeries - The samples of a single metric family, which may span several sets of tags.
+// The source is the kind of stat the family was made from, one of "counter", "gauge" or "summary".
+type prometheusSeries struct {
+	source string
+	kind   string
+	help   string
+	lines  []string
+}
+
+/*
+renderPrometheus - Formats a snapshot in the Prometheus text exposition format, grouping stats with
+the same name and different tags into a single metric family. Timings are summarised at each of
+the quantiles. Metadata is looked up by the stat path without the prefix of the Sinks type, as it
+is registered. A family only holds stats of one kind, so when stats of different kinds share a name
+only those of the kind first seen in the sorted paths are written, in the order counters, gauges
+and then timings for each path, and the others are dropped.
+*/
+func renderPrometheus(snap Snapshot, quantiles []float64, prefix string) []byte {
+	families := map[string]*prometheusSeries{}
+	family := func(path, name, kind string) *prometheusSeries {
+		f, ok := families[name]
+		if ok && f.source != kind {
+			return nil
+		}
+		if !ok {
+			f = &prometheusSeries{source: kind, kind: kind}
+			if m, ok := lookupMetadata(strings.TrimPrefix(path, prefix)); ok {
+				f.help = m.Description
+				if hint, ok := prometheusKinds[m.Kind]; ok {
+					f.kind = hint
+				}
+			}
+			families[name] = f
+		}
+		return f
+	}
+
+	for _, path := range snap.Paths() {
+		stat, tags := splitTags(path)
+		name := prometheusName(stat)
+
+		if v, ok := snap.Counters[path]; ok {
+			if f := family(stat, name, "counter"); f != nil {
+				f.lines = append(f.lines, name+prometheusLabels(tags)+" "+strconv.FormatInt(v, 10))
+			}
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			if f := family(stat, name, "gauge"); f != nil {
+				f.lines = append(f.lines, name+prometheusLabels(tags)+" "+strconv.FormatInt(v, 10))
+			}
+		}
+		if samples, ok := snap.Timings[path]; ok && len(samples) > 0 {
+			if f := family(stat, name, "summary"); f != nil {
+				sum := SummariseTimings(samples)
+				for _, q := range quantiles {
+					label := strconv.FormatFloat(q, 'f', -1, 64)
+					f.lines = append(f.lines, name+prometheusLabels(tags, "quantile", label)+" "+
+						strconv.FormatInt(sum.Quantile(q), 10))
+				}
+				labels := prometheusLabels(tags)
+				f.lines = append(f.lines, name+"_sum"+labels+" "+strconv.FormatInt(sum.Sum, 10))
+				f.lines = append(f.lines, name+"_count"+labels+" "+strconv.FormatInt(sum.Count, 10))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		f := families[name]
+		if len(f.help) > 0 {
+			buf.WriteString("# HELP " + name + " " + prometheusHelpEscaper.Replace(f.help) + "\n")
+		}
+		buf.WriteString("# TYPE " + name + " " + f.kind + "\n")
+		for _, line := range f.lines {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+//--------------------------------------------------------------------------------------------------
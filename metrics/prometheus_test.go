@@ -0,0 +1,146 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestPrometheusSink(t *testing.T) {
+	RegisterMetadata("http.requests", Metadata{Kind: KindCounter, Description: "Requests served."})
+
+	p := newPrometheusSink(NewPrometheusConfig(), "")
+	err := p.Flush(Snapshot{
+		Counters: map[string]int64{
+			Tagged("http.requests", map[string]string{"code": "200"}): 5,
+			Tagged("http.requests", map[string]string{"code": "500"}): 1,
+		},
+		Gauges:  map[string]int64{"queue-depth": 3},
+		Timings: map[string][]int64{"latency": {4, 1, 3, 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	exp := `# HELP http_requests Requests served.
+# TYPE http_requests counter
+http_requests{code="200"} 5
+http_requests{code="500"} 1
+# TYPE latency summary
+latency{quantile="0.5"} 2
+latency{quantile="0.9"} 4
+latency{quantile="0.99"} 4
+latency_sum 10
+latency_count 4
+# TYPE queue_depth gauge
+queue_depth 3
+`
+	if act := rec.Body.String(); exp != act {
+		t.Errorf("Wrong exposition:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+func TestPrometheusSinkPrefix(t *testing.T) {
+	RegisterMetadata("prefixed.requests", Metadata{Kind: KindGauge, Description: "Requests seen."})
+
+	conf := NewConfig()
+	conf.Sinks.Prefix = "svc."
+	conf.Sinks.Outputs = []string{"prometheus"}
+
+	s, err := newSinks(conf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Incr("prefixed.requests", 3)
+	s.flush()
+
+	rec := httptest.NewRecorder()
+	s.sinks[0].(*PrometheusSink).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	exp := `# HELP svc_prefixed_requests Requests seen.
+# TYPE svc_prefixed_requests gauge
+svc_prefixed_requests 3
+`
+	if act := rec.Body.String(); exp != act {
+		t.Errorf("Wrong exposition:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+func TestPrometheusEscaping(t *testing.T) {
+	RegisterMetadata("escaped", Metadata{Kind: KindGauge, Description: "Path of C:\\data\nin bytes."})
+
+	act := string(renderPrometheus(Snapshot{
+		Gauges: map[string]int64{
+			Tagged("escaped", map[string]string{"path": "a\tb\\c\"d\ne\x01é"}): 1,
+		},
+	}, nil, ""))
+
+	exp := `# HELP escaped Path of C:\\data\nin bytes.
+# TYPE escaped gauge
+escaped{path="a` + "\t" + `b\\c\"d\ne` + "\x01" + `é"} 1
+`
+	if exp != act {
+		t.Errorf("Wrong exposition:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+func TestPrometheusKindCollision(t *testing.T) {
+	act := string(renderPrometheus(Snapshot{
+		Counters: map[string]int64{"shared": 1, Tagged("mixed", map[string]string{"a": "2"}): 2},
+		Gauges:   map[string]int64{"shared": 2, Tagged("mixed", map[string]string{"a": "1"}): 1},
+		Timings:  map[string][]int64{"shared": {1}},
+	}, []float64{0.5}, ""))
+
+	exp := `# TYPE mixed gauge
+mixed{a="1"} 1
+# TYPE shared counter
+shared 1
+`
+	if exp != act {
+		t.Errorf("Wrong exposition:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := map[string]string{
+		"foo.bar":     "foo_bar",
+		"foo-bar:baz": "foo_bar:baz",
+		"1foo":        "_foo",
+		"foo1":        "foo1",
+	}
+	for in, exp := range tests {
+		if act := prometheusName(in); exp != act {
+			t.Errorf("Wrong name for %v: %v != %v", in, act, exp)
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,143 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["pushgateway"] = sinkSpec{
+		constructor: NewPushgatewaySink,
+		description: `
+Pushes each snapshot to a Prometheus Pushgateway in the text exposition format,
+replacing all metrics of the configured job and grouping labels. This suits short
+lived batch jobs, where the final snapshot is pushed as the metrics are closed.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// PushgatewayConfig - Config for the Prometheus Pushgateway sink.
+type PushgatewayConfig struct {
+	URL       string            `json:"url" yaml:"url"`
+	Job       string            `json:"job" yaml:"job"`
+	Grouping  map[string]string `json:"grouping" yaml:"grouping"`
+	Timeout   string            `json:"timeout" yaml:"timeout"`
+	Quantiles []float64         `json:"quantiles" yaml:"quantiles"`
+}
+
+// NewPushgatewayConfig - Creates a PushgatewayConfig struct with default values.
+func NewPushgatewayConfig() PushgatewayConfig {
+	return PushgatewayConfig{
+		URL:       "http://localhost:9091",
+		Job:       "benthos",
+		Grouping:  map[string]string{},
+		Timeout:   "5s",
+		Quantiles: []float64{0.5, 0.9, 0.99},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// PushgatewaySink - A Sink that pushes snapshots to a Prometheus Pushgateway.
+type PushgatewaySink struct {
+	sync.Mutex
+
+	url       string
+	quantiles []float64
+	prefix    string
+	client    *http.Client
+
+	pending []byte
+}
+
+// NewPushgatewaySink - Create a new Pushgateway sink.
+func NewPushgatewaySink(config Config) (Sink, error) {
+	conf := config.Pushgateway
+	if len(conf.Job) == 0 {
+		return nil, fmt.Errorf("pushgateway job must not be empty")
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &PushgatewaySink{
+		url:       pushgatewayURL(conf),
+		quantiles: conf.Quantiles,
+		prefix:    expandPrefix(config.Sinks.Prefix),
+		client:    client,
+	}, nil
+}
+
+// pushgatewayURL - Returns the URL that metrics of a job and grouping are pushed to.
+func pushgatewayURL(conf PushgatewayConfig) string {
+	u := strings.TrimSuffix(conf.URL, "/") + "/metrics/job/" + url.PathEscape(conf.Job)
+	for _, k := range sortedKeys(conf.Grouping) {
+		u += "/" + url.PathEscape(k) + "/" + url.PathEscape(conf.Grouping[k])
+	}
+	return u
+}
+
+// Flush - Pushes a snapshot, which replaces any metrics previously pushed for the same grouping.
+func (p *PushgatewaySink) Flush(snap Snapshot) error {
+	body := renderPrometheus(snap, p.quantiles, p.prefix)
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.pending = body
+	if err := p.push(body); err != nil {
+		return err
+	}
+	p.pending = nil
+	return nil
+}
+
+// push - Sends a body to the Pushgateway.
+func (p *PushgatewaySink) push(body []byte) error {
+	return sendHTTP(p.client, "PUT", p.url, body, map[string]string{
+		"Content-Type": "text/plain; version=0.0.4",
+	})
+}
+
+// Close - Retries the push of the final snapshot if it previously failed.
+func (p *PushgatewaySink) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.pending == nil {
+		return nil
+	}
+	err := p.push(p.pending)
+	p.pending = nil
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,84 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestPushgatewaySink(t *testing.T) {
+	var mut sync.Mutex
+	var paths, bodies []string
+	fail := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		if r.Method != "PUT" {
+			t.Errorf("Wrong method: %v", r.Method)
+		}
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.Pushgateway.URL = server.URL
+	conf.Pushgateway.Job = "batch"
+	conf.Pushgateway.Grouping = map[string]string{"instance": "a"}
+
+	sink, err := NewPushgatewaySink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Flush(Snapshot{Gauges: map[string]int64{"done": 1}}); err == nil {
+		t.Error("Expected error from failed push")
+	}
+
+	mut.Lock()
+	fail = false
+	mut.Unlock()
+
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "/metrics/job/batch/instance/a" {
+		t.Errorf("Wrong pushes: %v", paths)
+	}
+	if exp := "# TYPE done gauge\ndone 1\n"; len(bodies) != 1 || bodies[0] != exp {
+		t.Errorf("Wrong bodies: %v", bodies)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,178 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/base64"
+	"sort"
+	"strconv"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["prometheus_remote_write"] = sinkSpec{
+		constructor: NewRemoteWriteSink,
+		description: `
+Pushes each snapshot with the Prometheus remote-write protocol, which is accepted
+directly by receivers such as Thanos, VictoriaMetrics, Cortex and Mimir as well
+as Prometheus itself. Counters and gauges are written as such and timings as
+summaries of the samples recorded since the previous flush. The tags of stats
+created with metrics.Tagged and any external labels become labels of each series.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// RemoteWriteConfig - Config for the Prometheus remote-write sink.
+type RemoteWriteConfig struct {
+	URL            string            `json:"url" yaml:"url"`
+	Headers        map[string]string `json:"headers" yaml:"headers"`
+	Username       string            `json:"username" yaml:"username"`
+	Password       string            `json:"password" yaml:"password"`
+	BearerToken    string            `json:"bearer_token" yaml:"bearer_token"`
+	ExternalLabels map[string]string `json:"external_labels" yaml:"external_labels"`
+	Quantiles      []float64         `json:"quantiles" yaml:"quantiles"`
+	Timeout        string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewRemoteWriteConfig - Creates a RemoteWriteConfig struct with default values.
+func NewRemoteWriteConfig() RemoteWriteConfig {
+	return RemoteWriteConfig{
+		URL:            "http://localhost:9090/api/v1/write",
+		Headers:        map[string]string{},
+		Username:       "",
+		Password:       "",
+		BearerToken:    "",
+		ExternalLabels: map[string]string{},
+		Quantiles:      []float64{0.5, 0.9, 0.99},
+		Timeout:        "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// RemoteWriteSink - A Sink that pushes snapshots with the Prometheus remote-write protocol.
+type RemoteWriteSink struct {
+	url            string
+	headers        map[string]string
+	externalLabels map[string]string
+	quantiles      []float64
+	sender         func(url string, body []byte, headers map[string]string) error
+}
+
+// NewRemoteWriteSink - Create a new Prometheus remote-write sink.
+func NewRemoteWriteSink(config Config) (Sink, error) {
+	conf := config.RemoteWrite
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{}
+	for k, v := range conf.Headers {
+		headers[k] = v
+	}
+	headers["Content-Type"] = "application/x-protobuf"
+	headers["Content-Encoding"] = "snappy"
+	headers["X-Prometheus-Remote-Write-Version"] = "0.1.0"
+	if len(conf.BearerToken) > 0 {
+		headers["Authorization"] = "Bearer " + conf.BearerToken
+	} else if len(conf.Username) > 0 {
+		headers["Authorization"] = "Basic " +
+			base64.StdEncoding.EncodeToString([]byte(conf.Username+":"+conf.Password))
+	}
+	return &RemoteWriteSink{
+		url:            conf.URL,
+		headers:        headers,
+		externalLabels: conf.ExternalLabels,
+		quantiles:      conf.Quantiles,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// remoteWriteLabels - Returns the sorted labels of a series, including its name.
+func remoteWriteLabels(name string, tags map[string]string, extra ...string) []remoteWriteLabel {
+	labels := []remoteWriteLabel{{name: "__name__", value: name}}
+	for k, v := range tags {
+		labels = append(labels, remoteWriteLabel{name: prometheusName(k), value: v})
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels = append(labels, remoteWriteLabel{name: extra[i], value: extra[i+1]})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].name < labels[j].name
+	})
+	return labels
+}
+
+// remoteWriteSeriesFor - Converts a snapshot into remote-write time series.
+func (r *RemoteWriteSink) remoteWriteSeriesFor(snap Snapshot) []remoteWriteSeries {
+	ts := snap.Time.UnixNano() / 1e6
+
+	series := []remoteWriteSeries{}
+	add := func(labels []remoteWriteLabel, value int64) {
+		series = append(series, remoteWriteSeries{labels: labels, value: float64(value), timestamp: ts})
+	}
+	for _, path := range snap.Paths() {
+		stat, pathTags := splitTags(path)
+		tags := mergeTags(r.externalLabels, pathTags)
+		name := prometheusName(stat)
+
+		if v, ok := snap.Counters[path]; ok {
+			add(remoteWriteLabels(name, tags), v)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add(remoteWriteLabels(name, tags), v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			sum := SummariseTimings(samples)
+			for _, q := range r.quantiles {
+				label := strconv.FormatFloat(q, 'f', -1, 64)
+				add(remoteWriteLabels(name, tags, "quantile", label), sum.Quantile(q))
+			}
+			add(remoteWriteLabels(name+"_sum", tags), sum.Sum)
+			add(remoteWriteLabels(name+"_count", tags), sum.Count)
+		}
+	}
+	return series
+}
+
+// Flush - Pushes a snapshot to the remote-write receiver.
+func (r *RemoteWriteSink) Flush(snap Snapshot) error {
+	series := r.remoteWriteSeriesFor(snap)
+	if len(series) == 0 {
+		return nil
+	}
+	return r.sender(r.url, snappyEncode(encodeWriteRequest(series)), r.headers)
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (r *RemoteWriteSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
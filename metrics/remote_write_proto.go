@@ -0,0 +1,148 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import "encoding/binary"
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+This file implements the encoding needed to push to a Prometheus remote-write receiver without a
+client library: the protobuf WriteRequest message of remote-write version 1, which is encoded with
+the protoBuffer of the Riemann protocol, and the block format of snappy compression that the body
+of each request is compressed with.
+*/
+
+// remoteWriteLabel - A label of a remote-write time series.
+type remoteWriteLabel struct {
+	name  string
+	value string
+}
+
+// remoteWriteSeries - A remote-write time series with a single sample, where labels must be sorted
+// by name and include __name__.
+type remoteWriteSeries struct {
+	labels    []remoteWriteLabel
+	value     float64
+	timestamp int64
+}
+
+// encodeWriteRequest - Encodes time series as a remote-write WriteRequest message.
+func encodeWriteRequest(series []remoteWriteSeries) []byte {
+	var req protoBuffer
+	for _, s := range series {
+		var ts protoBuffer
+		for _, l := range s.labels {
+			var label protoBuffer
+			label.stringField(1, l.name)
+			label.stringField(2, l.value)
+			ts.bytesField(1, label)
+		}
+		var sample protoBuffer
+		sample.doubleField(1, s.value)
+		sample.int64Field(2, s.timestamp)
+		ts.bytesField(2, sample)
+		req.bytesField(1, ts)
+	}
+	return req
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Snappy element tags.
+const (
+	snappyLiteral = 0
+	snappyCopy2   = 2
+)
+
+/*
+snappyEncode - Compresses a block with the snappy block format. Matches of at least four bytes are
+found with a hash table of recent positions and written as copies with two byte offsets, which is
+simpler than the reference encoder and compresses a little less, but is fully compatible with any
+snappy decoder.
+*/
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, len(src)/2+16)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+
+	const tableBits = 14
+	var table [1 << tableBits]int32
+
+	lit, i := 0, 0
+	for i+4 <= len(src) {
+		h := (binary.LittleEndian.Uint32(src[i:]) * 0x1e35a7bd) >> (32 - tableBits)
+		cand := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if cand < 0 || i-cand > 0xffff ||
+			binary.LittleEndian.Uint32(src[cand:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+
+		dst = snappyAppendLiteral(dst, src[lit:i])
+		length := 4
+		for i+length < len(src) && src[cand+length] == src[i+length] {
+			length++
+		}
+		dst = snappyAppendCopy(dst, i-cand, length)
+		i += length
+		lit = i
+	}
+	return snappyAppendLiteral(dst, src[lit:])
+}
+
+// snappyAppendLiteral - Appends a literal element.
+func snappyAppendLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := uint32(len(lit) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|snappyLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|snappyLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|snappyLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|snappyLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// snappyAppendCopy - Appends copy elements for a match, each of which is at most 64 bytes long.
+func snappyAppendCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > 64 {
+			n = 64
+		}
+		dst = append(dst, byte(n-1)<<2|snappyCopy2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
+
+//--------------------------------------------------------------------------------------------------
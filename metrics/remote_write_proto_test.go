@@ -0,0 +1,180 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// snappyDecode - Decompresses a snappy block for verifying the encoder.
+func snappyDecode(src []byte) ([]byte, error) {
+	n, l := binary.Uvarint(src)
+	if l <= 0 {
+		return nil, errors.New("bad length")
+	}
+	src = src[l:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case snappyLiteral:
+			length := int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * uint(i))
+				}
+				src = src[extra:]
+			}
+			length++
+			if length > len(src) {
+				return nil, errors.New("literal overflows")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		case snappyCopy2:
+			length := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			src = src[3:]
+			if offset == 0 || offset > len(dst) {
+				return nil, errors.New("bad offset")
+			}
+			for i := 0; i < length; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			return nil, errors.New("unexpected tag")
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, errors.New("wrong length")
+	}
+	return dst, nil
+}
+
+func TestSnappyEncode(t *testing.T) {
+	long := make([]byte, 70000)
+	for i := range long {
+		long[i] = byte(i * 7 % 251)
+	}
+	for _, src := range [][]byte{
+		{},
+		[]byte("abc"),
+		[]byte(strings.Repeat("abcdefgh", 100)),
+		[]byte(strings.Repeat("a", 1000) + "b" + strings.Repeat("a", 1000)),
+		long,
+	} {
+		enc := snappyEncode(src)
+		dec, err := snappyDecode(enc)
+		if err != nil {
+			t.Errorf("Failed to decode %v bytes: %v", len(src), err)
+			continue
+		}
+		if !bytes.Equal(src, dec) {
+			t.Errorf("Wrong round trip of %v bytes", len(src))
+		}
+	}
+	if enc := snappyEncode([]byte(strings.Repeat("abcdefgh", 100))); len(enc) > 64 {
+		t.Errorf("Expected repetitive input to compress: %v", len(enc))
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// decodeProto - Splits a protobuf message into its fields for verifying the encoder, where bytes
+// fields are returned as []byte and all others as uint64.
+func decodeProto(t *testing.T, b []byte) [][2]interface{} {
+	var fields [][2]interface{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			b = b[n:]
+			fields = append(fields, [2]interface{}{field, v})
+		case wireFixed64:
+			fields = append(fields, [2]interface{}{field, binary.LittleEndian.Uint64(b)})
+			b = b[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			b = b[n:]
+			fields = append(fields, [2]interface{}{field, b[:l]})
+			b = b[l:]
+		default:
+			t.Fatalf("Unexpected wire type: %v", key&7)
+		}
+	}
+	return fields
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	req := encodeWriteRequest([]remoteWriteSeries{{
+		labels:    []remoteWriteLabel{{"__name__", "up"}, {"job", "a"}},
+		value:     1.5,
+		timestamp: 1000,
+	}})
+
+	fields := decodeProto(t, req)
+	if len(fields) != 1 || fields[0][0] != 1 {
+		t.Fatalf("Wrong write request: %v", fields)
+	}
+	series := decodeProto(t, fields[0][1].([]byte))
+	if len(series) != 3 {
+		t.Fatalf("Wrong time series: %v", series)
+	}
+
+	var labels [][]interface{}
+	for _, f := range series[:2] {
+		if f[0] != 1 {
+			t.Fatalf("Expected label field: %v", f)
+		}
+		var label []interface{}
+		for _, lf := range decodeProto(t, f[1].([]byte)) {
+			label = append(label, lf[0], string(lf[1].([]byte)))
+		}
+		labels = append(labels, label)
+	}
+	if exp := [][]interface{}{{1, "__name__", 2, "up"}, {1, "job", 2, "a"}}; !reflect.DeepEqual(exp, labels) {
+		t.Errorf("Wrong labels: %v != %v", exp, labels)
+	}
+
+	sample := decodeProto(t, series[2][1].([]byte))
+	exp := [][2]interface{}{{1, math.Float64bits(1.5)}, {2, uint64(1000)}}
+	if series[2][0] != 2 || !reflect.DeepEqual(exp, sample) {
+		t.Errorf("Wrong sample: %v != %v", exp, sample)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestRemoteWriteSinkSeries(t *testing.T) {
+	conf := NewConfig()
+	conf.RemoteWrite.ExternalLabels = map[string]string{"env": "prod"}
+	conf.RemoteWrite.Quantiles = []float64{0.5}
+
+	sink, err := NewRemoteWriteSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := sink.(*RemoteWriteSink)
+
+	requests := Tagged("http.requests", map[string]string{"code": "200"})
+	series := r.remoteWriteSeriesFor(Snapshot{
+		Time:     time.Unix(100, 0),
+		Counters: map[string]int64{requests: 9},
+		Gauges:   map[string]int64{"queue": 3},
+		Timings:  map[string][]int64{"latency": {2, 4}},
+	})
+
+	env := remoteWriteLabel{"env", "prod"}
+	exp := []remoteWriteSeries{
+		{labels: []remoteWriteLabel{{"__name__", "http_requests"}, {"code", "200"}, env}, value: 9, timestamp: 100000},
+		{labels: []remoteWriteLabel{{"__name__", "latency"}, env, {"quantile", "0.5"}}, value: 2, timestamp: 100000},
+		{labels: []remoteWriteLabel{{"__name__", "latency_sum"}, env}, value: 6, timestamp: 100000},
+		{labels: []remoteWriteLabel{{"__name__", "latency_count"}, env}, value: 2, timestamp: 100000},
+		{labels: []remoteWriteLabel{{"__name__", "queue"}, env}, value: 3, timestamp: 100000},
+	}
+	if !reflect.DeepEqual(exp, series) {
+		t.Errorf("Wrong series:\n%v\n!=\n%v", exp, series)
+	}
+}
+
+func TestRemoteWriteSinkFlush(t *testing.T) {
+	conf := NewConfig()
+	conf.RemoteWrite.URL = "http://localhost:8428/api/v1/write"
+	conf.RemoteWrite.Username = "foo"
+	conf.RemoteWrite.Password = "bar"
+	conf.RemoteWrite.Headers = map[string]string{"X-Scope-OrgID": "tenant"}
+
+	sink, err := NewRemoteWriteSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := sink.(*RemoteWriteSink)
+
+	var sentURL string
+	var sentBody []byte
+	var sentHeaders map[string]string
+	r.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentBody, sentHeaders = url, body, headers
+		return nil
+	}
+
+	snap := Snapshot{Time: time.Unix(100, 0), Gauges: map[string]int64{"queue": 3}}
+	if err = r.Flush(snap); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := conf.RemoteWrite.URL, sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	for k, v := range map[string]string{
+		"Content-Encoding": "snappy",
+		"Content-Type":     "application/x-protobuf",
+		"Authorization":    "Basic Zm9vOmJhcg==",
+		"X-Scope-OrgID":    "tenant",
+	} {
+		if act := sentHeaders[k]; v != act {
+			t.Errorf("Wrong %v header: %v != %v", k, v, act)
+		}
+	}
+
+	body, err := snappyDecode(sentBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := encodeWriteRequest(r.remoteWriteSeriesFor(snap)); !reflect.DeepEqual(exp, body) {
+		t.Errorf("Wrong body: %v != %v", exp, body)
+	}
+
+	sentURL = ""
+	if err = r.Flush(Snapshot{}); err != nil || len(sentURL) > 0 {
+		t.Errorf("Expected empty snapshot to be skipped: %v", err)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -23,11 +23,17 @@ THE SOFTWARE.
 package metrics
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
 	"sync"
 	"time"
-
-	"github.com/amir/raidman"
 )
 
 //--------------------------------------------------------------------------------------------------
@@ -46,27 +52,206 @@ with correctly within Riemann.`,
 
 // RiemannConfig - Configuration fields for a riemann service.
 type RiemannConfig struct {
-	Server        string   `json:"server" yaml:"server"`
-	TTL           float32  `json:"ttl" yaml:"ttl"`
-	Tags          []string `json:"tags" yaml:"tags"`
-	FlushInterval string   `json:"flush_interval" yaml:"flush_interval"`
-	Prefix        string   `json:"prefix" yaml:"prefix"`
+	Server      string            `json:"server" yaml:"server"`
+	Servers     []string          `json:"servers" yaml:"servers"`
+	LoadBalance string            `json:"load_balance" yaml:"load_balance"`
+	Network     string            `json:"network" yaml:"network"`
+	Host        string            `json:"host" yaml:"host"`
+	TTL         float32           `json:"ttl" yaml:"ttl"`
+	Tags        []string          `json:"tags" yaml:"tags"`
+	Attributes  map[string]string `json:"attributes" yaml:"attributes"`
+
+	FlushInterval     string `json:"flush_interval" yaml:"flush_interval"`
+	MaxBatchSize      int    `json:"max_batch_size" yaml:"max_batch_size"`
+	Prefix            string `json:"prefix" yaml:"prefix"`
+	HeartbeatInterval string `json:"heartbeat_interval" yaml:"heartbeat_interval"`
+
+	Thresholds []RiemannThresholdConfig `json:"thresholds" yaml:"thresholds"`
+
+	ReconnectBackoff    string `json:"reconnect_backoff" yaml:"reconnect_backoff"`
+	MaxReconnectBackoff string `json:"max_reconnect_backoff" yaml:"max_reconnect_backoff"`
+
+	TLS            RiemannTLSConfig   `json:"tls" yaml:"tls"`
+	CircuitBreaker BreakerConfig      `json:"circuit_breaker" yaml:"circuit_breaker"`
+	Spool          RiemannSpoolConfig `json:"spool" yaml:"spool"`
+}
+
+// RiemannTLSConfig - Configuration fields for connecting to Riemann over TLS. The cert and key files
+// are only required when the server expects client certificates, and the CA file is only required
+// when the server certificate is not signed by a CA trusted by the host.
+type RiemannTLSConfig struct {
+	Enabled            bool   `json:"enabled" yaml:"enabled"`
+	CertFile           string `json:"cert_file" yaml:"cert_file"`
+	KeyFile            string `json:"key_file" yaml:"key_file"`
+	CAFile             string `json:"ca_file" yaml:"ca_file"`
+	ServerName         string `json:"server_name" yaml:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
 }
 
 // NewRiemannConfig - Create a new riemann config with default values.
 func NewRiemannConfig() RiemannConfig {
 	return RiemannConfig{
 		Server:        "",
+		Servers:       []string{},
+		LoadBalance:   "failover",
+		Network:       "tcp",
+		Host:          "",
 		TTL:           5,
 		Tags:          []string{"service", "meter"},
+		Attributes:    map[string]string{},
 		FlushInterval: "2s",
+		MaxBatchSize:  500,
 		Prefix:        "",
+
+		HeartbeatInterval: "",
+
+		Thresholds: []RiemannThresholdConfig{},
+
+		ReconnectBackoff:    "100ms",
+		MaxReconnectBackoff: "30s",
+
+		TLS: RiemannTLSConfig{
+			Enabled:            false,
+			CertFile:           "",
+			KeyFile:            "",
+			CAFile:             "",
+			ServerName:         "",
+			InsecureSkipVerify: false,
+		},
+		CircuitBreaker: NewBreakerConfig(),
+		Spool:          NewRiemannSpoolConfig(),
 	}
 }
 
+// tlsConfig - Builds a TLS config from the configured files and options.
+func (c RiemannTLSConfig) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if len(c.CAFile) > 0 {
+		caBytes, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in CA file: %v", c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+	return conf, nil
+}
+
 //--------------------------------------------------------------------------------------------------
 
-// Riemann - A Riemann client that supports the Type interface.
+// riemannDriver - A connection to Riemann able to send batches of events and query the index.
+type riemannDriver interface {
+	SendMulti(events []*RiemannEvent) error
+	Query(q string) ([]RiemannEvent, error)
+	Close()
+}
+
+// riemannNetwork - Returns the network of a config, which must be either tcp or udp. Events sent
+// over udp are fire and forget, trading delivery guarantees for throughput.
+func riemannNetwork(conf RiemannConfig) (string, error) {
+	switch conf.Network {
+	case "", "tcp":
+		return "tcp", nil
+	case "udp":
+		if conf.TLS.Enabled {
+			return "", errors.New("riemann TLS is not supported over udp")
+		}
+		return "udp", nil
+	}
+	return "", fmt.Errorf("riemann network not recognised: %v", conf.Network)
+}
+
+// dialRiemann - Connects to the Riemann servers of a config, when more than one server is configured
+// the connection is a pool that fails over between them.
+func dialRiemann(conf RiemannConfig, tlsConf *tls.Config, timeout time.Duration) (riemannDriver, error) {
+	addrs, roundRobin, err := riemannEndpoints(conf)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 1 {
+		return dialRiemannServer(conf, addrs[0], tlsConf, timeout)
+	}
+	return dialRiemannPool(addrs, roundRobin, func(addr string) (riemannDriver, error) {
+		return dialRiemannServer(conf, addr, tlsConf, timeout)
+	})
+}
+
+// dialRiemannServer - Connects to a single Riemann server, over tcp events are framed and each batch
+// is acknowledged by the server, whereas over udp each batch is a single unacknowledged datagram.
+func dialRiemannServer(
+	conf RiemannConfig, addr string, tlsConf *tls.Config, timeout time.Duration,
+) (riemannDriver, error) {
+	network, err := riemannNetwork(conf)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if tlsConf != nil {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConf)
+		if err != nil {
+			return nil, err
+		}
+		return &riemannStream{conn: conn, timeout: timeout}, nil
+	}
+	conn, err := dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "udp" {
+		return &riemannDatagram{conn: conn, timeout: timeout}, nil
+	}
+	return &riemannStream{conn: conn, timeout: timeout}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the Riemann type.
+var (
+	ErrRiemannNotConnected     = errors.New("not connected to riemann")
+	ErrRiemannQueryUnsupported = errors.New("riemann queries are not supported over udp")
+	ErrRiemannCircuitOpen      = errors.New("riemann circuit breaker is open")
+	ErrRiemannAckUnsupported   = errors.New("riemann acknowledgements are not supported over udp")
+	ErrRiemannEventDropped     = errors.New("riemann event was dropped by a hook")
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Stat paths of the internal stats tracked by the Riemann type.
+const (
+	riemannReconnectAttemptsPath = "riemann.reconnect.attempts"
+	riemannReconnectFailuresPath = "riemann.reconnect.failures"
+	riemannSendFailuresPath      = "riemann.send.failures"
+	riemannBreakerStatePath      = "riemann.breaker.state"
+	riemannBreakerFailuresPath   = "riemann.breaker.failures"
+	riemannBreakerOpenedPath     = "riemann.breaker.opened"
+	riemannSpoolWrittenPath      = "riemann.spool.written"
+	riemannSpoolFailuresPath     = "riemann.spool.failures"
+)
+
+// RiemannHeartbeatService - The service of heartbeat events, which is prefixed like any other stat.
+const RiemannHeartbeatService = "heartbeat"
+
+//--------------------------------------------------------------------------------------------------
+
+// Riemann - A Riemann client that supports the Type interface. When the connection to Riemann is
+// lost events are kept and the connection is re-established with a jittered exponential backoff.
+// After too many consecutive failures a circuit breaker stops all attempts until a periodic probe
+// succeeds, its state is reported as a gauge where 0 is closed, 1 is open and 2 is half open. When a
+// spool is configured events are written to disk whilst Riemann is unreachable and replayed once the
+// connection is re-established.
 type Riemann struct {
 	sync.Mutex
 
@@ -74,11 +259,73 @@ type Riemann struct {
 
 	flatMetrics map[string]int64
 
-	Client      *raidman.Client
-	eventsCache map[string]*raidman.Event
+	clientMut   sync.Mutex
+	client      riemannDriver
+	breaker     *breaker
+	spool       *riemannSpool
+	tlsConf     *tls.Config
+	eventsCache map[string]*RiemannEvent
+	eventsQueue []*RiemannEvent
+
+	flushInterval     time.Duration
+	heartbeatInterval time.Duration
+	timingUnit        time.Duration
+	clampZero         bool
+	hooks             []RiemannHook
+	health            Type
+	reset             chan struct{}
+	flushNow          chan struct{}
+	quit              chan bool
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	backoff    time.Duration
+	nextDial   time.Time
+}
+
+// RiemannHook - A function that is run over each event before it is sent to Riemann, which may
+// modify the event or return false in order to drop it. Hooks are run whilst the Riemann type is
+// locked and must therefore not call back into it.
+type RiemannHook func(e *RiemannEvent) bool
+
+// parseBackoff - Parses the minimum and maximum reconnect backoff durations of a config.
+func parseBackoff(conf RiemannConfig) (min, max time.Duration, err error) {
+	if min, err = time.ParseDuration(conf.ReconnectBackoff); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse reconnect backoff: %v", err)
+	}
+	if max, err = time.ParseDuration(conf.MaxReconnectBackoff); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse max reconnect backoff: %v", err)
+	}
+	if max < min {
+		max = min
+	}
+	return min, max, nil
+}
+
+// parseHeartbeat - Parses the heartbeat interval of a config, where an empty interval disables
+// heartbeats.
+func parseHeartbeat(conf RiemannConfig) (time.Duration, error) {
+	if len(conf.HeartbeatInterval) == 0 {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(conf.HeartbeatInterval)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse heartbeat interval: %v", err)
+	}
+	if interval <= 0 {
+		return 0, errors.New("heartbeat interval must be positive")
+	}
+	return interval, nil
+}
 
-	flushInterval time.Duration
-	quit          chan bool
+// riemannHost - Returns the host to attach to events, which supports the same placeholders as the
+// prefix and defaults to the hostname of the machine.
+func riemannHost(host string) string {
+	if len(host) == 0 {
+		host, _ = os.Hostname()
+		return host
+	}
+	return expandPrefix(host)
 }
 
 // NewRiemann - Create a new riemann client.
@@ -87,18 +334,70 @@ func NewRiemann(config Config) (Type, error) {
 	if nil != err {
 		return nil, fmt.Errorf("failed to parse flush interval: %v", err)
 	}
+	timingUnit, err := parseTimingUnit(config.TimingUnit)
+	if err != nil {
+		return nil, err
+	}
+	minBackoff, maxBackoff, err := parseBackoff(config.Riemann)
+	if err != nil {
+		return nil, err
+	}
+	heartbeat, err := parseHeartbeat(config.Riemann)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateThresholds(config.Riemann.Thresholds); err != nil {
+		return nil, err
+	}
 
-	client, err := raidman.Dial("tcp", config.Riemann.Server)
+	if _, err = riemannNetwork(config.Riemann); err != nil {
+		return nil, err
+	}
+	if _, _, err = riemannEndpoints(config.Riemann); err != nil {
+		return nil, err
+	}
+	breaker, err := newBreaker(config.Riemann.CircuitBreaker)
+	if err != nil {
+		return nil, err
+	}
+	var spool *riemannSpool
+	if len(config.Riemann.Spool.Path) > 0 {
+		if spool, err = newRiemannSpool(config.Riemann.Spool); err != nil {
+			return nil, err
+		}
+	}
+	var tlsConf *tls.Config
+	if config.Riemann.TLS.Enabled {
+		if tlsConf, err = config.Riemann.TLS.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	config.Riemann.Prefix = expandPrefix(config.Riemann.Prefix)
+	config.Riemann.Host = riemannHost(config.Riemann.Host)
+
+	client, err := dialRiemann(config.Riemann, tlsConf, interval)
 	if err != nil {
 		return nil, err
 	}
 
 	r := &Riemann{
-		config:        config.Riemann,
-		Client:        client,
-		flushInterval: interval,
-		eventsCache:   make(map[string]*raidman.Event),
-		quit:          make(chan bool),
+		config:            config.Riemann,
+		client:            client,
+		breaker:           breaker,
+		spool:             spool,
+		tlsConf:           tlsConf,
+		flushInterval:     interval,
+		heartbeatInterval: heartbeat,
+		timingUnit:        timingUnit,
+		clampZero:         config.ClampZero,
+		flatMetrics:       make(map[string]int64),
+		eventsCache:       make(map[string]*RiemannEvent),
+		reset:             make(chan struct{}, 1),
+		flushNow:          make(chan struct{}, 1),
+		quit:              make(chan bool),
+		minBackoff:        minBackoff,
+		maxBackoff:        maxBackoff,
 	}
 
 	go r.loop()
@@ -113,18 +412,7 @@ func (r *Riemann) Incr(stat string, value int64) error {
 	r.Lock()
 	defer r.Unlock()
 
-	total, _ := r.flatMetrics[stat]
-	total += value
-
-	r.flatMetrics[stat] = total
-
-	service := r.config.Prefix + stat
-	r.eventsCache[service] = &raidman.Event{
-		Ttl:     r.config.TTL,
-		Tags:    r.config.Tags,
-		Metric:  total,
-		Service: service,
-	}
+	r.addCounter(stat, value)
 	return nil
 }
 
@@ -133,19 +421,60 @@ func (r *Riemann) Decr(stat string, value int64) error {
 	r.Lock()
 	defer r.Unlock()
 
-	total, _ := r.flatMetrics[stat]
-	total -= value
+	r.addCounter(stat, negate(value))
+	return nil
+}
 
+// addCounter - Adds a delta to a counter and caches the resulting event, recording when the result
+// is clamped. Must be called whilst holding the lock.
+func (r *Riemann) addCounter(stat string, delta int64) {
+	total, clamped := addCounter(r.flatMetrics[stat], delta, r.clampZero)
 	r.flatMetrics[stat] = total
 
-	service := r.config.Prefix + stat
-	r.eventsCache[service] = &raidman.Event{
-		Ttl:     r.config.TTL,
-		Tags:    r.config.Tags,
-		Metric:  total,
-		Service: service,
+	r.cacheEvent(stat, total)
+
+	if clamped && stat != ClampedCountPath {
+		r.addCounter(ClampedCountPath, 1)
+	}
+}
+
+// cacheEvent - Caches an event for a stat, replacing any previous event for the same stat so that
+// only the latest value of each stat is sent per flush. The state of the event is derived from the
+// first matching threshold, if any. Once the cache holds a full batch of events an early flush is
+// triggered. Must be called whilst holding the lock.
+func (r *Riemann) cacheEvent(stat string, metric interface{}) {
+	r.cache(&RiemannEvent{
+		Time:       time.Now().Unix(),
+		Host:       r.config.Host,
+		State:      thresholdState(r.config.Thresholds, stat, metric),
+		TTL:        r.config.TTL,
+		Tags:       r.config.Tags,
+		Attributes: r.config.Attributes,
+		Metric:     metric,
+		Service:    r.config.Prefix + stat,
+	})
+}
+
+// cache - Runs the hooks over an event and caches it under its service unless it was dropped. Must
+// be called whilst holding the lock.
+func (r *Riemann) cache(e *RiemannEvent) {
+	if !r.applyHooks(e) {
+		return
+	}
+	r.eventsCache[e.Service] = e
+	r.checkBatchSize()
+}
+
+// checkBatchSize - Triggers an early flush once the cache and queue hold a full batch of events.
+// Must be called whilst holding the lock.
+func (r *Riemann) checkBatchSize() {
+	size := len(r.eventsCache) + len(r.eventsQueue)
+	if r.config.MaxBatchSize > 0 && size >= r.config.MaxBatchSize {
+		select {
+		case r.flushNow <- struct{}{}:
+		default:
+		}
 	}
-	return nil
 }
 
 // Timing - Set a stat representing a duration.
@@ -153,28 +482,239 @@ func (r *Riemann) Timing(stat string, delta int64) error {
 	r.Lock()
 	defer r.Unlock()
 
-	service := r.config.Prefix + stat
-	r.eventsCache[service] = &raidman.Event{
-		Ttl:     r.config.TTL,
-		Tags:    r.config.Tags,
-		Metric:  delta,
-		Service: service,
-	}
+	r.cacheEvent(stat, delta)
 	return nil
 }
 
+// TimingDuration - Set a stat representing a duration, in the configured timing unit.
+func (r *Riemann) TimingDuration(stat string, d time.Duration) error {
+	return r.Timing(stat, int64(d/r.timingUnit))
+}
+
 // Gauge - Set a stat as a gauge value.
 func (r *Riemann) Gauge(stat string, value int64) error {
 	r.Lock()
 	defer r.Unlock()
 
-	service := r.config.Prefix + stat
-	r.eventsCache[service] = &raidman.Event{
-		Ttl:     r.config.TTL,
-		Tags:    r.config.Tags,
-		Metric:  value,
-		Service: service,
+	r.cacheEvent(stat, value)
+	return nil
+}
+
+// Reconfigure - Applies the host, TTL, tags, attributes, flush interval, batch size, prefix, clamping,
+// backoff, circuit breaker, heartbeat and thresholds of a new config without reconnecting. Events
+// already cached under the previous prefix are flushed under that prefix.
+func (r *Riemann) Reconfigure(config Config) error {
+	interval, err := time.ParseDuration(config.Riemann.FlushInterval)
+	if nil != err {
+		return fmt.Errorf("failed to parse flush interval: %v", err)
+	}
+	minBackoff, maxBackoff, err := parseBackoff(config.Riemann)
+	if err != nil {
+		return err
+	}
+	heartbeat, err := parseHeartbeat(config.Riemann)
+	if err != nil {
+		return err
+	}
+	if err = validateThresholds(config.Riemann.Thresholds); err != nil {
+		return err
+	}
+
+	r.clientMut.Lock()
+	err = r.breaker.configure(config.Riemann.CircuitBreaker)
+	r.clientMut.Unlock()
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.config.Host = riemannHost(config.Riemann.Host)
+	r.config.TTL = config.Riemann.TTL
+	r.config.Tags = config.Riemann.Tags
+	r.config.Attributes = config.Riemann.Attributes
+	r.config.FlushInterval = config.Riemann.FlushInterval
+	r.config.MaxBatchSize = config.Riemann.MaxBatchSize
+	r.config.Prefix = expandPrefix(config.Riemann.Prefix)
+	r.flushInterval = interval
+	r.config.HeartbeatInterval = config.Riemann.HeartbeatInterval
+	r.config.Thresholds = config.Riemann.Thresholds
+	r.heartbeatInterval = heartbeat
+	r.clampZero = config.ClampZero
+	r.config.ReconnectBackoff = config.Riemann.ReconnectBackoff
+	r.config.MaxReconnectBackoff = config.Riemann.MaxReconnectBackoff
+	r.config.CircuitBreaker = config.Riemann.CircuitBreaker
+	r.minBackoff, r.maxBackoff = minBackoff, maxBackoff
+	r.Unlock()
+
+	select {
+	case r.reset <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Query - Runs a query against the Riemann index, such as `service = "foo" and state = "ok"`, and
+// returns the matching events. Queries are not supported over udp.
+func (r *Riemann) Query(q string) ([]RiemannEvent, error) {
+	if r.config.Network == "udp" {
+		return nil, ErrRiemannQueryUnsupported
+	}
+
+	r.clientMut.Lock()
+	defer r.clientMut.Unlock()
+
+	if !r.breaker.allow(time.Now()) {
+		return nil, ErrRiemannCircuitOpen
+	}
+	if r.client == nil && !r.reconnect() {
+		return nil, ErrRiemannNotConnected
+	}
+	events, err := r.client.Query(q)
+	if err != nil {
+		r.client.Close()
+		r.client = nil
+		r.breakerResult(false)
+		return nil, fmt.Errorf("failed to query riemann: %v", err)
+	}
+	r.breakerResult(true)
+	return events, nil
+}
+
+// AddHook - Adds a hook to run over each event before it is sent, hooks are run in the order they
+// were added and an event dropped by one hook is not seen by those that follow. This can be used to
+// enforce conventions such as default tags or service names, or to sample events.
+func (r *Riemann) AddHook(hook RiemannHook) {
+	r.Lock()
+	r.hooks = append(r.hooks, hook)
+	r.Unlock()
+}
+
+// applyHooks - Runs the hooks over an event, returning false if the event was dropped. The tags and
+// attributes of the event are copied first as they are shared with the config. Must be called whilst
+// holding the lock.
+func (r *Riemann) applyHooks(e *RiemannEvent) bool {
+	if len(r.hooks) == 0 {
+		return true
+	}
+	e.Tags = append([]string(nil), e.Tags...)
+	attributes := make(map[string]string, len(e.Attributes))
+	for k, v := range e.Attributes {
+		attributes[k] = v
+	}
+	e.Attributes = attributes
+	for _, hook := range r.hooks {
+		if !hook(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// withDefaults - Returns a copy of an event with the host, TTL, tags and attributes of the config
+// applied where they are not already set, and the current time when it has none. Must be called
+// whilst holding the lock.
+func (r *Riemann) withDefaults(event *RiemannEvent) RiemannEvent {
+	e := *event
+	if len(e.Host) == 0 {
+		e.Host = r.config.Host
+	}
+	if e.TTL == 0 {
+		e.TTL = r.config.TTL
+	}
+	if e.Tags == nil {
+		e.Tags = r.config.Tags
+	}
+	if e.Attributes == nil {
+		e.Attributes = r.config.Attributes
+	}
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+	return e
+}
+
+/*
+SendEvent - Queues an event to be sent with the next flush without waiting on the network. Unlike
+stats, queued events are not collapsed by service, so every event is sent, although events that
+fail to send are kept like stats and only the latest of each service is retried. The host, TTL, tags
+and attributes of the config are applied to the event where they are not already set, followed by
+the hooks, and ErrRiemannEventDropped is returned when a hook drops the event.
+*/
+func (r *Riemann) SendEvent(event *RiemannEvent) error {
+	r.Lock()
+	defer r.Unlock()
+
+	e := r.withDefaults(event)
+	if !r.applyHooks(&e) {
+		return ErrRiemannEventDropped
 	}
+	r.eventsQueue = append(r.eventsQueue, &e)
+	r.checkBatchSize()
+	return nil
+}
+
+/*
+SendEventSync - Sends an event to Riemann immediately, bypassing the cache, and waits for Riemann to
+acknowledge it. Any error from the transport or from Riemann is returned, as is the error of the
+context if it is done before the event is acknowledged. The host, TTL, tags and attributes of the
+config are applied to the event where they are not already set, followed by the hooks, and
+ErrRiemannEventDropped is returned when a hook drops the event. Acknowledgements are not supported
+over udp.
+*/
+func (r *Riemann) SendEventSync(ctx context.Context, event *RiemannEvent) error {
+	if r.config.Network == "udp" {
+		return ErrRiemannAckUnsupported
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.Lock()
+	e := r.withDefaults(event)
+	keep := r.applyHooks(&e)
+	r.Unlock()
+
+	if !keep {
+		return ErrRiemannEventDropped
+	}
+
+	r.clientMut.Lock()
+	defer r.clientMut.Unlock()
+
+	if !r.breaker.allow(time.Now()) {
+		return ErrRiemannCircuitOpen
+	}
+	if r.client == nil && !r.reconnect() {
+		return ErrRiemannNotConnected
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.send([]*RiemannEvent{&e})
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Closing the client aborts the send, after which the connection is no longer usable.
+		r.client.Close()
+		<-done
+		r.client = nil
+		return ctx.Err()
+	}
+	if err != nil {
+		r.reportError(err)
+		r.client.Close()
+		r.client = nil
+		r.breakerResult(false)
+
+		r.Lock()
+		r.addCounter(riemannSendFailuresPath, 1)
+		r.Unlock()
+		return fmt.Errorf("failed to send riemann event: %v", err)
+	}
+	r.breakerResult(true)
 	return nil
 }
 
@@ -187,39 +727,282 @@ func (r *Riemann) Close() error {
 //--------------------------------------------------------------------------------------------------
 
 func (r *Riemann) loop() {
+	r.Lock()
 	ticker := time.NewTicker(r.flushInterval)
+	heartbeat := newHeartbeatTicker(r.heartbeatInterval)
+	r.Unlock()
 	for {
 		select {
 		case <-ticker.C:
 			r.flushMetrics()
+		case <-heartbeat.C:
+			r.cacheHeartbeat()
+			r.flushMetrics()
+		case <-r.flushNow:
+			r.flushMetrics()
+		case <-r.reset:
+			ticker.Stop()
+			heartbeat.Stop()
+			r.Lock()
+			ticker = time.NewTicker(r.flushInterval)
+			heartbeat = newHeartbeatTicker(r.heartbeatInterval)
+			r.Unlock()
 		case <-r.quit:
-			r.Client.Close()
+			ticker.Stop()
+			heartbeat.Stop()
+			r.clientMut.Lock()
+			if r.client != nil {
+				r.client.Close()
+			}
+			r.clientMut.Unlock()
 			return
 		}
 	}
 }
 
-func (r *Riemann) flushMetrics() {
+// newHeartbeatTicker - Returns a ticker for heartbeats, which never ticks when the interval is zero.
+func newHeartbeatTicker(interval time.Duration) *time.Ticker {
+	if interval <= 0 {
+		return &time.Ticker{}
+	}
+	return time.NewTicker(interval)
+}
+
+// cacheHeartbeat - Caches a heartbeat event with a TTL of one and a half heartbeat intervals, so
+// that Riemann expires the event shortly after a heartbeat is missed.
+func (r *Riemann) cacheHeartbeat() {
 	r.Lock()
 	defer r.Unlock()
 
-	events := make([]*raidman.Event, len(r.eventsCache))
-	i := 0
-	for _, event := range r.eventsCache {
-		events[i] = event
-		i++
+	r.cache(&RiemannEvent{
+		Time:       time.Now().Unix(),
+		Host:       r.config.Host,
+		State:      "ok",
+		TTL:        float32(r.heartbeatInterval.Seconds() * 1.5),
+		Tags:       r.config.Tags,
+		Attributes: r.config.Attributes,
+		Metric:     int64(1),
+		Service:    r.config.Prefix + RiemannHeartbeatService,
+	})
+}
+
+/*
+flushMetrics - Sends all cached events to Riemann in batches of at most the max batch size. The
+cache is swapped out whilst sending so that stats can continue to be written without waiting on the
+network. If a send fails the unsent events are returned to the cache, unless a newer event for the
+same service has arrived in the meantime, and the connection is re-established. The client is
+shared with queries and is guarded by clientMut.
+
+When a spool is configured each flush that cannot reach Riemann writes the cached events to the
+spool instead, which keeps every flush rather than only the latest event of each service, and the
+spool is replayed before any new events are sent.
+*/
+func (r *Riemann) flushMetrics() {
+	r.clientMut.Lock()
+	defer r.clientMut.Unlock()
+	defer r.reportState()
+
+	if !r.breaker.allow(time.Now()) || (r.client == nil && !r.reconnect()) {
+		if r.spool != nil {
+			r.spoolEvents(r.takeEvents())
+		}
+		return
+	}
+
+	if r.spool != nil && !r.spool.empty() {
+		if err := r.spool.replay(r.send); err != nil {
+			r.reportError(err)
+			r.sendFailed(r.takeEvents())
+			return
+		}
+	}
+
+	events := r.takeEvents()
+	if len(events) == 0 {
+		return
+	}
+
+	r.Lock()
+	batchSize := r.config.MaxBatchSize
+	r.Unlock()
+	if batchSize <= 0 {
+		batchSize = len(events)
 	}
 
-	if err := r.Client.SendMulti(events); err == nil {
-		r.eventsCache = make(map[string]*raidman.Event)
+	for len(events) > 0 {
+		n := batchSize
+		if n > len(events) {
+			n = len(events)
+		}
+		if err := r.send(events[:n]); err != nil {
+			r.reportError(err)
+			break
+		}
+		events = events[n:]
+	}
+	if len(events) == 0 {
+		r.breakerResult(true)
+		return
+	}
+	r.sendFailed(events)
+}
+
+// send - Sends a batch of events with the client and publishes the count sent. Must be called whilst
+// holding clientMut.
+func (r *Riemann) send(events []*RiemannEvent) error {
+	if err := r.client.SendMulti(events); err != nil {
+		return err
+	}
+	r.reportSent(len(events))
+	return nil
+}
+
+// takeEvents - Swaps out the cache and queue and returns their events.
+func (r *Riemann) takeEvents() []*RiemannEvent {
+	r.Lock()
+	cache, queue := r.eventsCache, r.eventsQueue
+	r.eventsCache = make(map[string]*RiemannEvent)
+	r.eventsQueue = nil
+	r.Unlock()
+
+	events := make([]*RiemannEvent, 0, len(cache)+len(queue))
+	for _, event := range cache {
+		events = append(events, event)
+	}
+	return append(events, queue...)
+}
+
+// sendFailed - Drops the client after a failed send, keeps the unsent events in either the spool or
+// the cache and attempts to reconnect. Must be called whilst holding clientMut.
+func (r *Riemann) sendFailed(events []*RiemannEvent) {
+	r.client.Close()
+	r.client = nil
+	r.breakerResult(false)
+
+	r.Lock()
+	r.addCounter(riemannSendFailuresPath, 1)
+	r.Unlock()
+
+	if r.spool != nil {
+		r.spoolEvents(events)
 	} else {
-		var newClient *raidman.Client
-		newClient, err = raidman.DialWithTimeout("tcp", r.config.Server, r.flushInterval)
-		if err == nil {
-			r.Client.Close()
-			r.Client = newClient
+		r.requeueEvents(events)
+	}
+
+	if r.breaker.allow(time.Now()) {
+		r.reconnect()
+	}
+}
+
+// requeueEvents - Returns unsent events to the cache unless a newer event for the same service has
+// arrived in the meantime.
+func (r *Riemann) requeueEvents(events []*RiemannEvent) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, event := range events {
+		if _, exists := r.eventsCache[event.Service]; !exists {
+			r.eventsCache[event.Service] = event
 		}
 	}
 }
 
+// spoolEvents - Writes events to the spool, falling back to the cache when the spool is full or
+// cannot be written. Must be called whilst holding clientMut.
+func (r *Riemann) spoolEvents(events []*RiemannEvent) {
+	if len(events) == 0 {
+		return
+	}
+	path := riemannSpoolWrittenPath
+	if err := r.spool.write(events); err != nil {
+		r.requeueEvents(events)
+		path = riemannSpoolFailuresPath
+	}
+	r.Lock()
+	r.addCounter(path, 1)
+	r.Unlock()
+}
+
+// breakerResult - Records the outcome of an attempt with the circuit breaker and reports failures
+// and any change of its state, both as events and to the health Type. Must be called whilst holding
+// clientMut.
+func (r *Riemann) breakerResult(success bool) {
+	prev, opened := r.breaker.state, false
+	if success {
+		r.breaker.success()
+	} else {
+		opened = r.breaker.failure(time.Now())
+	}
+
+	r.Lock()
+	if !success {
+		r.addCounter(riemannBreakerFailuresPath, 1)
+	}
+	if opened {
+		r.addCounter(riemannBreakerOpenedPath, 1)
+	}
+	if r.breaker.state != prev {
+		r.cacheEvent(riemannBreakerStatePath, int64(r.breaker.state))
+	}
+	r.Unlock()
+
+	r.reportBreaker(success, opened, r.breaker.state != prev)
+}
+
+// reconnect - Attempts to dial Riemann unless we are waiting out a backoff period. Each failed
+// attempt doubles the backoff period up to the configured maximum. Returns true if connected. Must be
+// called whilst holding clientMut.
+func (r *Riemann) reconnect() bool {
+	r.Lock()
+	conf, timeout := r.config, r.flushInterval
+	if time.Now().Before(r.nextDial) {
+		r.Unlock()
+		return false
+	}
+	r.addCounter(riemannReconnectAttemptsPath, 1)
+	r.Unlock()
+
+	client, err := dialRiemann(conf, r.tlsConf, timeout)
+	if err != nil {
+		r.reportError(err)
+		r.breakerResult(false)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if err != nil {
+		r.addCounter(riemannReconnectFailuresPath, 1)
+		r.backoff = nextBackoff(r.backoff, r.minBackoff, r.maxBackoff)
+		r.nextDial = time.Now().Add(jitter(r.backoff))
+		return false
+	}
+	r.client = client
+	r.backoff = 0
+	r.nextDial = time.Time{}
+	return true
+}
+
+// nextBackoff - Returns the backoff period following a failed attempt, doubling the current period
+// within the bounds of min and max.
+func nextBackoff(current, min, max time.Duration) time.Duration {
+	if current < min {
+		return min
+	}
+	if current *= 2; current > max || current <= 0 {
+		return max
+	}
+	return current
+}
+
+// jitter - Returns a random duration between half of and the full backoff period, so that many
+// clients that lose their connection at once do not reconnect in lockstep.
+func jitter(backoff time.Duration) time.Duration {
+	if backoff <= 1 {
+		return backoff
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)))
+}
+
 //--------------------------------------------------------------------------------------------------
@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import "time"
+
+//--------------------------------------------------------------------------------------------------
+
+// Stat paths of the health of a Riemann client as published to a health Type.
+const (
+	RiemannHealthConnectedPath     = "riemann.connected"
+	RiemannHealthQueueDepthPath    = "riemann.queue_depth"
+	RiemannHealthSpoolSizePath     = "riemann.spool_size_bytes"
+	RiemannHealthEventsSentPath    = "riemann.events.sent"
+	RiemannHealthSendErrorsPath    = "riemann.send.errors"
+	RiemannHealthLastErrorTimePath = "riemann.last_error_time"
+	RiemannHealthLastErrorPath     = "riemann.last_error"
+
+	RiemannHealthBreakerStatePath               = "riemann.breaker.state"
+	RiemannHealthBreakerConsecutiveFailuresPath = "riemann.breaker.consecutive_failures"
+	RiemannHealthBreakerFailuresPath            = "riemann.breaker.failures"
+	RiemannHealthBreakerOpenedPath              = "riemann.breaker.opened"
+)
+
+/*
+SetHealthStats - Publishes the health of the client into t, which allows monitoring of the
+monitoring path through a separate Type such as an HTTP type. After each flush the connection state
+is set as a gauge of 1 or 0 along with the count of events waiting to be sent, the size of the
+spool, the state of the circuit breaker and its count of consecutive failures. Events sent, errors
+either sending or connecting, and failures and openings of the circuit breaker are counted, and the
+unix time of the most recent error is set as a gauge, with the error itself also set as a string
+when t implements StringSetter. Passing nil stops publishing.
+*/
+func (r *Riemann) SetHealthStats(t Type) {
+	r.Lock()
+	r.health = t
+	r.Unlock()
+}
+
+// healthStats - Returns the Type that health is published to, or nil.
+func (r *Riemann) healthStats() Type {
+	r.Lock()
+	defer r.Unlock()
+	return r.health
+}
+
+// reportSent - Publishes a count of events sent.
+func (r *Riemann) reportSent(n int) {
+	if t := r.healthStats(); t != nil {
+		t.Incr(RiemannHealthEventsSentPath, int64(n))
+	}
+}
+
+// reportError - Publishes an error either sending events or connecting.
+func (r *Riemann) reportError(err error) {
+	t := r.healthStats()
+	if t == nil {
+		return
+	}
+	t.Incr(RiemannHealthSendErrorsPath, 1)
+	t.Gauge(RiemannHealthLastErrorTimePath, time.Now().Unix())
+	if s, ok := t.(StringSetter); ok {
+		s.SetString(RiemannHealthLastErrorPath, err.Error())
+	}
+}
+
+// reportBreaker - Publishes the outcome of an attempt with the circuit breaker, counting failures
+// and times opened, and setting its state when changed. Must be called whilst holding clientMut.
+func (r *Riemann) reportBreaker(success, opened, changed bool) {
+	t := r.healthStats()
+	if t == nil {
+		return
+	}
+	if !success {
+		t.Incr(RiemannHealthBreakerFailuresPath, 1)
+	}
+	if opened {
+		t.Incr(RiemannHealthBreakerOpenedPath, 1)
+	}
+	if changed {
+		t.Gauge(RiemannHealthBreakerStatePath, int64(r.breaker.state))
+	}
+}
+
+// reportState - Publishes the connection state, queue depth and circuit breaker state. Must be
+// called whilst holding clientMut.
+func (r *Riemann) reportState() {
+	t := r.healthStats()
+	if t == nil {
+		return
+	}
+	var connected int64
+	if r.client != nil {
+		connected = 1
+	}
+	r.Lock()
+	depth := int64(len(r.eventsCache) + len(r.eventsQueue))
+	r.Unlock()
+	t.Gauge(RiemannHealthConnectedPath, connected)
+	t.Gauge(RiemannHealthQueueDepthPath, depth)
+	t.Gauge(RiemannHealthBreakerStatePath, int64(r.breaker.state))
+	t.Gauge(RiemannHealthBreakerConsecutiveFailuresPath, int64(r.breaker.failures))
+	if r.spool != nil {
+		t.Gauge(RiemannHealthSpoolSizePath, r.spool.size)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
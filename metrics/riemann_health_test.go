@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestRiemannHealthStats(t *testing.T) {
+	driver := &fakeRiemannDriver{}
+	r := newTestRiemann(NewRiemannConfig(), driver)
+
+	health := newRecorder()
+	r.SetHealthStats(health)
+
+	r.Gauge("a", 1)
+	r.Gauge("b", 1)
+	r.flushMetrics()
+
+	if exp, act := int64(2), health.counts[RiemannHealthEventsSentPath]; exp != act {
+		t.Errorf("Wrong events sent: %v != %v", exp, act)
+	}
+	if exp, act := int64(1), health.gauges[RiemannHealthConnectedPath]; exp != act {
+		t.Errorf("Wrong connected state: %v != %v", exp, act)
+	}
+
+	driver.fail = true
+	r.nextDial = time.Now().Add(time.Hour)
+	r.Gauge("a", 2)
+	r.flushMetrics()
+
+	if exp, act := int64(1), health.counts[RiemannHealthSendErrorsPath]; exp != act {
+		t.Errorf("Wrong send errors: %v != %v", exp, act)
+	}
+	if exp, act := int64(0), health.gauges[RiemannHealthConnectedPath]; exp != act {
+		t.Errorf("Wrong connected state: %v != %v", exp, act)
+	}
+	if depth := health.gauges[RiemannHealthQueueDepthPath]; depth < 1 {
+		t.Errorf("Wrong queue depth: %v", depth)
+	}
+	if health.gauges[RiemannHealthLastErrorTimePath] == 0 {
+		t.Error("Expected last error time to be set")
+	}
+	if exp, act := "fake failure", health.strings[RiemannHealthLastErrorPath]; exp != act {
+		t.Errorf("Wrong last error: %v != %v", exp, act)
+	}
+}
+
+func TestRiemannHealthBreaker(t *testing.T) {
+	driver := &fakeRiemannDriver{fail: true}
+	r := newTestRiemann(NewRiemannConfig(), driver)
+
+	var err error
+	if r.breaker, err = newBreaker(BreakerConfig{Threshold: 1, ProbeInterval: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+
+	health := newRecorder()
+	r.SetHealthStats(health)
+
+	r.Gauge("a", 1)
+	r.flushMetrics()
+
+	if exp, act := int64(BreakerOpen), health.gauges[RiemannHealthBreakerStatePath]; exp != act {
+		t.Errorf("Wrong breaker state: %v != %v", exp, act)
+	}
+	if exp, act := int64(1), health.gauges[RiemannHealthBreakerConsecutiveFailuresPath]; exp != act {
+		t.Errorf("Wrong consecutive failures: %v != %v", exp, act)
+	}
+	if exp, act := int64(1), health.counts[RiemannHealthBreakerFailuresPath]; exp != act {
+		t.Errorf("Wrong breaker failures: %v != %v", exp, act)
+	}
+	if exp, act := int64(1), health.counts[RiemannHealthBreakerOpenedPath]; exp != act {
+		t.Errorf("Wrong breaker opened count: %v != %v", exp, act)
+	}
+
+	if err = r.SendEvent(&RiemannEvent{Service: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	r.flushMetrics()
+
+	if len(r.eventsQueue) != 1 {
+		t.Fatalf("Expected event to remain queued whilst breaker is open: %v", r.eventsQueue)
+	}
+	if exp, act := int64(len(r.eventsCache)+1), health.gauges[RiemannHealthQueueDepthPath]; exp != act {
+		t.Errorf("Wrong queue depth: %v != %v", exp, act)
+	}
+
+	driver.fail = false
+	r.client = driver
+	r.breaker.openedAt = time.Now().Add(-time.Hour)
+	health.gauges[RiemannHealthBreakerStatePath] = -1
+	r.breakerResult(true)
+
+	if exp, act := int64(BreakerClosed), health.gauges[RiemannHealthBreakerStatePath]; exp != act {
+		t.Errorf("Wrong breaker state: %v != %v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
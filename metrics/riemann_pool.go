@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// riemannEndpoints - Returns the addresses of the Riemann servers of a config, the servers list
+// takes precedence over the single server field, and whether the addresses should be balanced in a
+// round robin fashion rather than failed over in order.
+func riemannEndpoints(conf RiemannConfig) ([]string, bool, error) {
+	addrs := conf.Servers
+	if len(addrs) == 0 {
+		addrs = []string{conf.Server}
+	}
+	switch conf.LoadBalance {
+	case "", "failover":
+		return addrs, false, nil
+	case "round_robin":
+		return addrs, true, nil
+	}
+	return nil, false, fmt.Errorf("riemann load balance not recognised: %v", conf.LoadBalance)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+riemannPool - A riemannDriver spread across multiple Riemann servers. In failover mode requests are
+sent to the last server that succeeded, and on failure each of the other servers is tried in order.
+In round robin mode each request starts at the next server along. Servers are dialed lazily and a
+request only fails once every server has failed it.
+*/
+type riemannPool struct {
+	addrs      []string
+	drivers    []riemannDriver
+	dial       func(addr string) (riemannDriver, error)
+	roundRobin bool
+	next       int
+}
+
+// dialRiemannPool - Creates a pool of Riemann servers, returning an error unless at least one of
+// the servers can be dialed.
+func dialRiemannPool(
+	addrs []string, roundRobin bool, dial func(addr string) (riemannDriver, error),
+) (*riemannPool, error) {
+	p := &riemannPool{
+		addrs:      addrs,
+		drivers:    make([]riemannDriver, len(addrs)),
+		dial:       dial,
+		roundRobin: roundRobin,
+	}
+	if err := p.do(func(riemannDriver) error { return nil }); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// do - Runs a request against the servers of the pool until one of them succeeds.
+func (p *riemannPool) do(fn func(d riemannDriver) error) error {
+	if len(p.addrs) == 0 {
+		return errors.New("no riemann servers configured")
+	}
+
+	start := p.next
+	if p.roundRobin {
+		p.next = (p.next + 1) % len(p.addrs)
+	}
+
+	var err error
+	for i := 0; i < len(p.addrs); i++ {
+		index := (start + i) % len(p.addrs)
+		if p.drivers[index] == nil {
+			if p.drivers[index], err = p.dial(p.addrs[index]); err != nil {
+				p.drivers[index] = nil
+				continue
+			}
+		}
+		if err = fn(p.drivers[index]); err != nil {
+			p.drivers[index].Close()
+			p.drivers[index] = nil
+			continue
+		}
+		if !p.roundRobin {
+			p.next = index
+		}
+		return nil
+	}
+	return err
+}
+
+// SendMulti - Sends a batch of events to a server of the pool.
+func (p *riemannPool) SendMulti(events []*RiemannEvent) error {
+	return p.do(func(d riemannDriver) error {
+		return d.SendMulti(events)
+	})
+}
+
+// Query - Runs a query against the index of a server of the pool.
+func (p *riemannPool) Query(q string) (events []RiemannEvent, err error) {
+	err = p.do(func(d riemannDriver) error {
+		events, err = d.Query(q)
+		return err
+	})
+	return events, err
+}
+
+// Close - Closes the connections to all servers of the pool.
+func (p *riemannPool) Close() {
+	for i, d := range p.drivers {
+		if d != nil {
+			d.Close()
+			p.drivers[i] = nil
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,149 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// fakePoolDialer - Returns a dial function for a pool that hands out fake drivers by address.
+func fakePoolDialer(drivers map[string]*fakeRiemannDriver, dialed *[]string) func(string) (riemannDriver, error) {
+	return func(addr string) (riemannDriver, error) {
+		*dialed = append(*dialed, addr)
+		d, ok := drivers[addr]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		d.closed = false
+		return d, nil
+	}
+}
+
+func TestRiemannEndpoints(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.Server = "a:5555"
+
+	addrs, roundRobin, err := riemannEndpoints(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []string{"a:5555"}, addrs; !reflect.DeepEqual(exp, act) || roundRobin {
+		t.Errorf("Wrong endpoints: %v != %v, %v", exp, act, roundRobin)
+	}
+
+	conf.Servers = []string{"b:5555", "c:5555"}
+	conf.LoadBalance = "round_robin"
+	if addrs, roundRobin, err = riemannEndpoints(conf); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := conf.Servers, addrs; !reflect.DeepEqual(exp, act) || !roundRobin {
+		t.Errorf("Wrong endpoints: %v != %v, %v", exp, act, roundRobin)
+	}
+
+	conf.LoadBalance = "random"
+	if _, _, err = riemannEndpoints(conf); err == nil {
+		t.Error("Expected error from unrecognised load balance")
+	}
+}
+
+func TestRiemannPoolFailover(t *testing.T) {
+	a, b := &fakeRiemannDriver{}, &fakeRiemannDriver{}
+	drivers := map[string]*fakeRiemannDriver{"a": a, "b": b}
+	dialed := []string{}
+
+	p, err := dialRiemannPool([]string{"a", "b", "c"}, false, fakePoolDialer(drivers, &dialed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := []*RiemannEvent{{Service: "foo"}}
+	if err = p.SendMulti(event); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.batches) != 1 {
+		t.Errorf("Expected batch on primary server: %v", len(a.batches))
+	}
+
+	a.fail = true
+	if err = p.SendMulti(event); err != nil {
+		t.Fatal(err)
+	}
+	if !a.closed {
+		t.Error("Expected failed server to be closed")
+	}
+	if len(b.batches) != 1 {
+		t.Errorf("Expected batch on secondary server: %v", len(b.batches))
+	}
+
+	a.fail = false
+	if err = p.SendMulti(event); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.batches) != 2 {
+		t.Errorf("Expected pool to stick with secondary server: %v", len(b.batches))
+	}
+
+	b.fail = true
+	if err = p.SendMulti(event); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []string{"a", "b", "c", "a"}, dialed; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong dials: %v != %v", exp, act)
+	}
+
+	a.fail = true
+	if err = p.SendMulti(event); err == nil {
+		t.Error("Expected error when all servers fail")
+	}
+
+	p.Close()
+}
+
+func TestRiemannPoolRoundRobin(t *testing.T) {
+	a, b := &fakeRiemannDriver{}, &fakeRiemannDriver{}
+	drivers := map[string]*fakeRiemannDriver{"a": a, "b": b}
+	dialed := []string{}
+
+	p, err := dialRiemannPool([]string{"a", "b"}, true, fakePoolDialer(drivers, &dialed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err = p.SendMulti([]*RiemannEvent{{Service: "foo"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.batches) != 2 || len(b.batches) != 2 {
+		t.Errorf("Expected batches to be balanced: %v, %v", len(a.batches), len(b.batches))
+	}
+
+	if _, err = dialRiemannPool([]string{"c"}, true, fakePoolDialer(drivers, &dialed)); err == nil {
+		t.Error("Expected error when no server can be dialed")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
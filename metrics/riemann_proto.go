@@ -0,0 +1,514 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the Riemann protocol.
+var (
+	ErrRiemannResponseTooLarge = errors.New("riemann response exceeds maximum size")
+	ErrRiemannDatagramTooLarge = errors.New("riemann batch exceeds maximum datagram size")
+)
+
+// maxRiemannDatagramSize - Upper bound on the size of a batch sent as a single udp datagram, this is
+// the default maximum size accepted by the Riemann udp server.
+const maxRiemannDatagramSize = 16384
+
+// maxRiemannResponseSize - Upper bound on the size of a response we are willing to read.
+const maxRiemannResponseSize = 32 * 1024 * 1024
+
+//--------------------------------------------------------------------------------------------------
+
+// RiemannEvent - An event sent to or read back from Riemann. The metric must be nil or one of int,
+// int32, int64, float32 or float64, events read back from Riemann carry either an int64 or float64.
+type RiemannEvent struct {
+	Time        int64             `json:"time"`
+	State       string            `json:"state"`
+	Service     string            `json:"service"`
+	Host        string            `json:"host"`
+	Description string            `json:"description"`
+	Tags        []string          `json:"tags"`
+	TTL         float32           `json:"ttl"`
+	Attributes  map[string]string `json:"attributes"`
+	Metric      interface{}       `json:"metric"`
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Protobuf wire types used by the Riemann protocol.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoBuffer - Accumulates protobuf encoded fields.
+type protoBuffer []byte
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *protoBuffer) key(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) bytesField(field int, v []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protoBuffer) stringField(field int, v string) {
+	if len(v) > 0 {
+		b.bytesField(field, []byte(v))
+	}
+}
+
+func (b *protoBuffer) int64Field(field int, v int64) {
+	b.key(field, wireVarint)
+	b.varint(uint64(v))
+}
+
+func (b *protoBuffer) sint64Field(field int, v int64) {
+	b.key(field, wireVarint)
+	b.varint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (b *protoBuffer) floatField(field int, v float32) {
+	b.key(field, wireFixed32)
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(v))
+	*b = append(*b, tmp[:]...)
+}
+
+func (b *protoBuffer) doubleField(field int, v float64) {
+	b.key(field, wireFixed64)
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+	*b = append(*b, tmp[:]...)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Field numbers of the Riemann protobuf messages.
+const (
+	riemannMsgOK     = 2
+	riemannMsgError  = 3
+	riemannMsgQuery  = 5
+	riemannMsgEvents = 6
+
+	riemannQueryString = 1
+
+	riemannEventTime        = 1
+	riemannEventState       = 2
+	riemannEventService     = 3
+	riemannEventHost        = 4
+	riemannEventDescription = 5
+	riemannEventTags        = 7
+	riemannEventTTL         = 8
+	riemannEventAttributes  = 9
+	riemannEventMetricSint  = 13
+	riemannEventMetricD     = 14
+	riemannEventMetricF     = 15
+
+	riemannAttributeKey   = 1
+	riemannAttributeValue = 2
+)
+
+// encodeRiemannEvent - Encodes an event as a Riemann protobuf Event message. The host and time of
+// the event default to the hostname of the machine and the current time.
+func encodeRiemannEvent(e *RiemannEvent) ([]byte, error) {
+	var b protoBuffer
+
+	t := e.Time
+	if t == 0 {
+		t = time.Now().Unix()
+	}
+	b.int64Field(riemannEventTime, t)
+	b.stringField(riemannEventState, e.State)
+	b.stringField(riemannEventService, e.Service)
+
+	host := e.Host
+	if len(host) == 0 {
+		host, _ = os.Hostname()
+	}
+	b.stringField(riemannEventHost, host)
+	b.stringField(riemannEventDescription, e.Description)
+	for _, tag := range e.Tags {
+		b.stringField(riemannEventTags, tag)
+	}
+	if e.TTL != 0 {
+		b.floatField(riemannEventTTL, e.TTL)
+	}
+	for k, v := range e.Attributes {
+		var attr protoBuffer
+		attr.stringField(riemannAttributeKey, k)
+		attr.stringField(riemannAttributeValue, v)
+		b.bytesField(riemannEventAttributes, attr)
+	}
+
+	switch m := e.Metric.(type) {
+	case nil:
+	case int:
+		b.sint64Field(riemannEventMetricSint, int64(m))
+	case int32:
+		b.sint64Field(riemannEventMetricSint, int64(m))
+	case int64:
+		b.sint64Field(riemannEventMetricSint, m)
+	case float32:
+		b.floatField(riemannEventMetricF, m)
+	case float64:
+		b.doubleField(riemannEventMetricD, m)
+	default:
+		return nil, fmt.Errorf("metric of invalid type %T", m)
+	}
+	return b, nil
+}
+
+// encodeRiemannEvents - Encodes a batch of events as a Riemann protobuf Msg message.
+func encodeRiemannEvents(events []*RiemannEvent) ([]byte, error) {
+	var b protoBuffer
+	for _, e := range events {
+		eBytes, err := encodeRiemannEvent(e)
+		if err != nil {
+			return nil, err
+		}
+		b.bytesField(riemannMsgEvents, eBytes)
+	}
+	return b, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// protoReader - Iterates the fields of a protobuf encoded message.
+type protoReader struct {
+	data []byte
+	err  error
+}
+
+func (r *protoReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errors.New("malformed varint")
+		r.data = nil
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *protoReader) take(n uint64) []byte {
+	if uint64(len(r.data)) < n {
+		r.err = errors.New("truncated message")
+		r.data = nil
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+// next - Reads the next field, returning its number, wire type, and the raw value for length
+// delimited and fixed width fields, or the decoded value for varint fields. Returns false when the
+// message is exhausted or malformed.
+func (r *protoReader) next() (field int, wireType int, raw []byte, v uint64, ok bool) {
+	if len(r.data) == 0 || r.err != nil {
+		return 0, 0, nil, 0, false
+	}
+	key := r.varint()
+	field, wireType = int(key>>3), int(key&7)
+	switch wireType {
+	case wireVarint:
+		v = r.varint()
+	case wireFixed64:
+		raw = r.take(8)
+	case wireBytes:
+		raw = r.take(r.varint())
+	case wireFixed32:
+		raw = r.take(4)
+	default:
+		r.err = fmt.Errorf("unsupported wire type %v", wireType)
+	}
+	return field, wireType, raw, v, r.err == nil
+}
+
+// decodeRiemannResponse - Decodes a Riemann protobuf Msg message, returning any events it contains
+// or an error if the message does not indicate success.
+func decodeRiemannResponse(data []byte) ([]RiemannEvent, error) {
+	ok, errMsg, events, err := decodeRiemannMsg(data)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if len(errMsg) == 0 {
+			errMsg = "unknown error"
+		}
+		return nil, fmt.Errorf("riemann error: %v", errMsg)
+	}
+	return events, nil
+}
+
+// decodeRiemannEvents - Decodes the events of a Riemann protobuf Msg message.
+func decodeRiemannEvents(data []byte) ([]*RiemannEvent, error) {
+	_, _, events, err := decodeRiemannMsg(data)
+	if err != nil {
+		return nil, err
+	}
+	ptrs := make([]*RiemannEvent, len(events))
+	for i := range events {
+		ptrs[i] = &events[i]
+	}
+	return ptrs, nil
+}
+
+// decodeRiemannMsg - Decodes the fields of a Riemann protobuf Msg message that we care about.
+func decodeRiemannMsg(data []byte) (ok bool, errMsg string, events []RiemannEvent, err error) {
+	r := protoReader{data: data}
+	for {
+		field, _, raw, v, more := r.next()
+		if !more {
+			break
+		}
+		switch field {
+		case riemannMsgOK:
+			ok = v != 0
+		case riemannMsgError:
+			errMsg = string(raw)
+		case riemannMsgEvents:
+			e, err := decodeRiemannEvent(raw)
+			if err != nil {
+				return false, "", nil, err
+			}
+			events = append(events, e)
+		}
+	}
+	if r.err != nil {
+		return false, "", nil, fmt.Errorf("failed to decode riemann response: %v", r.err)
+	}
+	return ok, errMsg, events, nil
+}
+
+// decodeRiemannEvent - Decodes a Riemann protobuf Event message.
+func decodeRiemannEvent(data []byte) (RiemannEvent, error) {
+	var e RiemannEvent
+	r := protoReader{data: data}
+	for {
+		field, _, raw, v, more := r.next()
+		if !more {
+			break
+		}
+		switch field {
+		case riemannEventTime:
+			e.Time = int64(v)
+		case riemannEventState:
+			e.State = string(raw)
+		case riemannEventService:
+			e.Service = string(raw)
+		case riemannEventHost:
+			e.Host = string(raw)
+		case riemannEventDescription:
+			e.Description = string(raw)
+		case riemannEventTags:
+			e.Tags = append(e.Tags, string(raw))
+		case riemannEventTTL:
+			if len(raw) == 4 {
+				e.TTL = math.Float32frombits(binary.LittleEndian.Uint32(raw))
+			}
+		case riemannEventAttributes:
+			k, v, err := decodeRiemannAttribute(raw)
+			if err != nil {
+				return e, err
+			}
+			if e.Attributes == nil {
+				e.Attributes = map[string]string{}
+			}
+			e.Attributes[k] = v
+		case riemannEventMetricSint:
+			e.Metric = int64(v>>1) ^ -int64(v&1)
+		case riemannEventMetricD:
+			if len(raw) == 8 {
+				e.Metric = math.Float64frombits(binary.LittleEndian.Uint64(raw))
+			}
+		case riemannEventMetricF:
+			if len(raw) == 4 {
+				e.Metric = math.Float32frombits(binary.LittleEndian.Uint32(raw))
+			}
+		}
+	}
+	if r.err != nil {
+		return e, fmt.Errorf("failed to decode riemann event: %v", r.err)
+	}
+	return e, nil
+}
+
+// decodeRiemannAttribute - Decodes a Riemann protobuf Attribute message.
+func decodeRiemannAttribute(data []byte) (key, value string, err error) {
+	r := protoReader{data: data}
+	for {
+		field, _, raw, _, more := r.next()
+		if !more {
+			break
+		}
+		switch field {
+		case riemannAttributeKey:
+			key = string(raw)
+		case riemannAttributeValue:
+			value = string(raw)
+		}
+	}
+	if r.err != nil {
+		return "", "", fmt.Errorf("failed to decode riemann attribute: %v", r.err)
+	}
+	return key, value, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// riemannStream - Speaks the Riemann protocol over a stream connection, where each message is
+// prefixed with its length as a four byte big endian integer and is answered with a response.
+type riemannStream struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+// roundTrip - Writes a message and reads the response message.
+func (s *riemannStream) roundTrip(msg []byte) ([]byte, error) {
+	if s.timeout > 0 {
+		s.conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	frame := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[4:], msg)
+	if _, err := s.conn.Write(frame); err != nil {
+		return nil, err
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(s.conn, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxRiemannResponseSize {
+		return nil, ErrRiemannResponseTooLarge
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(s.conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SendMulti - Sends a batch of events and waits for Riemann to acknowledge them.
+func (s *riemannStream) SendMulti(events []*RiemannEvent) error {
+	msg, err := encodeRiemannEvents(events)
+	if err != nil {
+		return err
+	}
+	resp, err := s.roundTrip(msg)
+	if err != nil {
+		return err
+	}
+	_, err = decodeRiemannResponse(resp)
+	return err
+}
+
+// Query - Runs a query against the Riemann index and returns the matching events.
+func (s *riemannStream) Query(q string) ([]RiemannEvent, error) {
+	var query, msg protoBuffer
+	query.stringField(riemannQueryString, q)
+	msg.bytesField(riemannMsgQuery, query)
+
+	resp, err := s.roundTrip(msg)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRiemannResponse(resp)
+}
+
+// Close - Closes the underlying connection.
+func (s *riemannStream) Close() {
+	s.conn.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// riemannDatagram - A riemannDriver that sends each batch of events as a single udp datagram. Riemann
+// does not respond over udp and therefore sends are not acknowledged and queries are unsupported.
+type riemannDatagram struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+// SendMulti - Sends a batch of events without waiting for an acknowledgement. Batches too large for
+// a single datagram are split in half until they fit.
+func (d *riemannDatagram) SendMulti(events []*RiemannEvent) error {
+	msg, err := encodeRiemannEvents(events)
+	if err != nil {
+		return err
+	}
+	if len(msg) > maxRiemannDatagramSize {
+		if len(events) < 2 {
+			return ErrRiemannDatagramTooLarge
+		}
+		half := len(events) / 2
+		if err = d.SendMulti(events[:half]); err != nil {
+			return err
+		}
+		return d.SendMulti(events[half:])
+	}
+	if d.timeout > 0 {
+		d.conn.SetWriteDeadline(time.Now().Add(d.timeout))
+	}
+	_, err = d.conn.Write(msg)
+	return err
+}
+
+// Query - Queries are not supported over udp.
+func (d *riemannDatagram) Query(q string) ([]RiemannEvent, error) {
+	return nil, ErrRiemannQueryUnsupported
+}
+
+// Close - Closes the underlying connection.
+func (d *riemannDatagram) Close() {
+	d.conn.Close()
+}
+
+//--------------------------------------------------------------------------------------------------
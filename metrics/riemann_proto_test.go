@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeRiemannServer - Reads a single framed message from conn, passes it to check and responds
+// with a message containing ok.
+func fakeRiemannServer(t *testing.T, conn net.Conn, check func(msg []byte)) {
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		t.Error(err)
+		return
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		t.Error(err)
+		return
+	}
+	check(msg)
+
+	var resp protoBuffer
+	resp.key(riemannMsgOK, wireVarint)
+	resp.varint(1)
+	binary.BigEndian.PutUint32(header[:], uint32(len(resp)))
+	conn.Write(append(header[:], resp...))
+}
+
+func TestRiemannStreamSend(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fakeRiemannServer(t, server, func(msg []byte) {
+			services := []string{}
+			metrics := []int64{}
+
+			r := protoReader{data: msg}
+			for field, _, raw, _, ok := r.next(); ok; field, _, raw, _, ok = r.next() {
+				if field != riemannMsgEvents {
+					t.Errorf("Unexpected field: %v", field)
+					continue
+				}
+				er := protoReader{data: raw}
+				for f, _, eRaw, v, eOk := er.next(); eOk; f, _, eRaw, v, eOk = er.next() {
+					switch f {
+					case riemannEventService:
+						services = append(services, string(eRaw))
+					case riemannEventMetricSint:
+						metrics = append(metrics, int64(v>>1)^-int64(v&1))
+					}
+				}
+			}
+			if len(services) != 2 || services[0] != "foo" || services[1] != "bar" {
+				t.Errorf("Wrong services: %v", services)
+			}
+			if len(metrics) != 2 || metrics[0] != 5 || metrics[1] != -3 {
+				t.Errorf("Wrong metrics: %v", metrics)
+			}
+		})
+	}()
+
+	s := &riemannStream{conn: client, timeout: time.Second}
+	err := s.SendMulti([]*RiemannEvent{
+		{Service: "foo", Metric: int64(5), Tags: []string{"meter"}, TTL: 5},
+		{Service: "bar", Metric: int64(-3)},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	<-done
+}
+
+func TestRiemannResponseError(t *testing.T) {
+	var resp protoBuffer
+	resp.key(riemannMsgOK, wireVarint)
+	resp.varint(0)
+	resp.stringField(riemannMsgError, "nope")
+
+	if _, err := decodeRiemannResponse(resp); err == nil || err.Error() != "riemann error: nope" {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestRiemannEventRoundTrip(t *testing.T) {
+	in := &RiemannEvent{
+		Time:        1234,
+		State:       "ok",
+		Service:     "foo",
+		Host:        "bar",
+		Description: "baz",
+		Tags:        []string{"a", "b"},
+		TTL:         5,
+		Attributes:  map[string]string{"region": "eu"},
+		Metric:      float64(1.5),
+	}
+	data, err := encodeRiemannEvent(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := decodeRiemannEvent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*in, out) {
+		t.Errorf("Wrong event: %+v != %+v", *in, out)
+	}
+}
+
+func TestRiemannDatagramSplit(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on udp: %v", err)
+	}
+	defer pc.Close()
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &riemannDatagram{conn: conn, timeout: time.Second}
+	defer d.Close()
+
+	events := make([]*RiemannEvent, 4)
+	for i := range events {
+		events[i] = &RiemannEvent{
+			Service:     "foo",
+			Host:        "bar",
+			Description: string(make([]byte, maxRiemannDatagramSize/3)),
+		}
+	}
+	if err = d.SendMulti(events); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, maxRiemannDatagramSize*2)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 2; i++ {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > maxRiemannDatagramSize {
+			t.Errorf("Datagram exceeds maximum size: %v", n)
+		}
+	}
+
+	events[0].Description = string(make([]byte, maxRiemannDatagramSize))
+	if err = d.SendMulti(events[:1]); err != ErrRiemannDatagramTooLarge {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannDatagramTooLarge)
+	}
+}
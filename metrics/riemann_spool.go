@@ -0,0 +1,164 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the Riemann spool.
+var (
+	ErrRiemannSpoolFull = errors.New("riemann spool is full")
+)
+
+// RiemannSpoolConfig - Configuration fields for spooling Riemann events to disk whilst Riemann is
+// unreachable. An empty path disables the spool.
+type RiemannSpoolConfig struct {
+	Path    string `json:"path" yaml:"path"`
+	MaxSize int64  `json:"max_size_bytes" yaml:"max_size_bytes"`
+}
+
+// NewRiemannSpoolConfig - Create a new riemann spool config with default values.
+func NewRiemannSpoolConfig() RiemannSpoolConfig {
+	return RiemannSpoolConfig{
+		Path:    "",
+		MaxSize: 64 * 1024 * 1024,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+riemannSpool - A bounded file of batches of events waiting to be sent to Riemann. Each batch is
+stored as a Riemann protobuf Msg prefixed with its length as a 4 byte big endian integer, the same
+framing used on the wire. A spool is not safe for concurrent use and must be guarded by its owner.
+*/
+type riemannSpool struct {
+	path    string
+	maxSize int64
+	size    int64
+}
+
+// newRiemannSpool - Opens a spool, picking up any batches left over from a previous run.
+func newRiemannSpool(conf RiemannSpoolConfig) (*riemannSpool, error) {
+	s := &riemannSpool{path: conf.Path, maxSize: conf.MaxSize}
+	info, err := os.Stat(s.path)
+	if err == nil {
+		s.size = info.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open riemann spool: %v", err)
+	}
+	return s, nil
+}
+
+// empty - Returns true if there are no batches waiting in the spool.
+func (s *riemannSpool) empty() bool {
+	return s.size == 0
+}
+
+// write - Appends a batch of events to the spool, unless doing so would exceed the max size.
+func (s *riemannSpool) write(events []*RiemannEvent) error {
+	msg, err := encodeRiemannEvents(events)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[4:], msg)
+
+	if s.maxSize > 0 && s.size+int64(len(frame)) > s.maxSize {
+		return ErrRiemannSpoolFull
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(frame)
+	s.size += int64(n)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+/*
+replay - Sends each batch of the spool in the order they were written. When a send fails the
+batches that were not sent are kept in the spool and the error is returned. A spool file that cannot
+be decoded is discarded.
+*/
+func (s *riemannSpool) replay(send func(events []*RiemannEvent) error) error {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.size = 0
+			return nil
+		}
+		return err
+	}
+
+	for len(data) > 0 {
+		if len(data) < 4 || uint32(len(data)-4) < binary.BigEndian.Uint32(data) {
+			break
+		}
+		size := binary.BigEndian.Uint32(data)
+		events, err := decodeRiemannEvents(data[4 : 4+size])
+		if err != nil {
+			break
+		}
+		if err = send(events); err != nil {
+			return s.truncate(data, err)
+		}
+		data = data[4+size:]
+	}
+	return s.truncate(nil, nil)
+}
+
+// truncate - Replaces the spool with the remaining batches, returning cause unless the replacement
+// fails.
+func (s *riemannSpool) truncate(remaining []byte, cause error) error {
+	if len(remaining) == 0 {
+		s.size = 0
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return cause
+	}
+	tmpPath := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, remaining, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	s.size = int64(len(remaining))
+	return cause
+}
+
+//--------------------------------------------------------------------------------------------------
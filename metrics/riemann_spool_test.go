@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestRiemannSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewRiemannSpoolConfig()
+	conf.Path = filepath.Join(dir, "spool")
+
+	s, err := newRiemannSpool(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.empty() {
+		t.Error("Expected new spool to be empty")
+	}
+
+	for _, service := range []string{"a", "b", "c"} {
+		if err = s.write([]*RiemannEvent{{Service: service, Host: "foo", Time: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reopening the spool should pick up the batches written so far.
+	if s, err = newRiemannSpool(conf); err != nil {
+		t.Fatal(err)
+	}
+	if s.empty() {
+		t.Fatal("Expected reopened spool to contain batches")
+	}
+
+	sent := []string{}
+	err = s.replay(func(events []*RiemannEvent) error {
+		if events[0].Service == "b" {
+			return errors.New("fake failure")
+		}
+		sent = append(sent, events[0].Service)
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected error from failed replay")
+	}
+	if len(sent) != 1 || sent[0] != "a" {
+		t.Errorf("Wrong replayed batches: %v", sent)
+	}
+
+	sent = []string{}
+	if err = s.replay(func(events []*RiemannEvent) error {
+		sent = append(sent, events[0].Service)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[0] != "b" || sent[1] != "c" {
+		t.Errorf("Wrong replayed batches: %v", sent)
+	}
+	if !s.empty() {
+		t.Error("Expected spool to be empty after replay")
+	}
+	if _, err = os.Stat(conf.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected spool file to be removed: %v", err)
+	}
+}
+
+func TestRiemannSpoolFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newRiemannSpool(RiemannSpoolConfig{Path: filepath.Join(dir, "spool"), MaxSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := []*RiemannEvent{{Service: "a", Host: "foo", Time: 1}}
+	if err = s.write(event); err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		err = s.write(event)
+	}
+	if err != ErrRiemannSpoolFull {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannSpoolFull)
+	}
+	if s.size > 64 {
+		t.Errorf("Spool exceeds max size: %v", s.size)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
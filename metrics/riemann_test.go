@@ -0,0 +1,522 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// fakeRiemannDriver - A riemannDriver that records batches of events, failing when told to.
+type fakeRiemannDriver struct {
+	sync.Mutex
+	batches [][]*RiemannEvent
+	queries []string
+	results []RiemannEvent
+	fail    bool
+	closed  bool
+}
+
+func (f *fakeRiemannDriver) SendMulti(events []*RiemannEvent) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.fail {
+		return errors.New("fake failure")
+	}
+	f.batches = append(f.batches, append([]*RiemannEvent{}, events...))
+	return nil
+}
+
+func (f *fakeRiemannDriver) Query(q string) ([]RiemannEvent, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.fail {
+		return nil, errors.New("fake failure")
+	}
+	f.queries = append(f.queries, q)
+	return f.results, nil
+}
+
+func (f *fakeRiemannDriver) Close() {
+	f.Lock()
+	f.closed = true
+	f.Unlock()
+}
+
+// newTestRiemann - Creates a Riemann object using a driver, without starting the flush loop.
+func newTestRiemann(conf RiemannConfig, driver riemannDriver) *Riemann {
+	return &Riemann{
+		config:        conf,
+		client:        driver,
+		breaker:       &breaker{},
+		flushInterval: time.Second,
+		timingUnit:    time.Nanosecond,
+		flatMetrics:   map[string]int64{},
+		eventsCache:   map[string]*RiemannEvent{},
+		reset:         make(chan struct{}, 1),
+		flushNow:      make(chan struct{}, 1),
+		quit:          make(chan bool),
+		minBackoff:    time.Hour,
+		maxBackoff:    time.Hour,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+func TestRiemannBackoff(t *testing.T) {
+	min, max := 100*time.Millisecond, time.Second
+
+	var backoff time.Duration
+	for _, exp := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		if backoff = nextBackoff(backoff, min, max); exp != backoff {
+			t.Errorf("Wrong backoff: %v != %v", exp, backoff)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if j := jitter(backoff); j < backoff/2 || j > backoff {
+			t.Errorf("Jitter out of bounds: %v", j)
+		}
+	}
+}
+
+func TestRiemannNetwork(t *testing.T) {
+	conf := NewRiemannConfig()
+	for network, exp := range map[string]string{"": "tcp", "tcp": "tcp", "udp": "udp"} {
+		conf.Network = network
+		if act, err := riemannNetwork(conf); err != nil || exp != act {
+			t.Errorf("Wrong network for %v: %v != %v: %v", network, exp, act, err)
+		}
+	}
+
+	conf.Network = "carrier_pigeon"
+	if _, err := riemannNetwork(conf); err == nil {
+		t.Error("Expected error from unknown network")
+	}
+
+	conf.Network = "udp"
+	conf.TLS.Enabled = true
+	if _, err := riemannNetwork(conf); err == nil {
+		t.Error("Expected error from udp with TLS")
+	}
+}
+
+func TestRiemannBatching(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.MaxBatchSize = 2
+
+	driver := &fakeRiemannDriver{}
+	r := newTestRiemann(conf, driver)
+
+	r.Gauge("a", 1)
+	r.Gauge("a", 2)
+	r.Gauge("b", 1)
+	select {
+	case <-r.flushNow:
+	default:
+		t.Error("Full batch did not trigger an early flush")
+	}
+	r.Gauge("c", 1)
+	r.flushMetrics()
+
+	if exp, act := 2, len(driver.batches); exp != act {
+		t.Fatalf("Wrong number of batches: %v != %v", exp, act)
+	}
+	if exp, act := 3, len(driver.batches[0])+len(driver.batches[1]); exp != act {
+		t.Errorf("Wrong number of events: %v != %v", exp, act)
+	}
+}
+
+func TestRiemannSendFailure(t *testing.T) {
+	driver := &fakeRiemannDriver{fail: true}
+	r := newTestRiemann(NewRiemannConfig(), driver)
+
+	r.Gauge("a", 1)
+	r.flushMetrics()
+
+	if !driver.closed {
+		t.Error("Failed connection was not closed")
+	}
+	if _, exists := r.eventsCache["a"]; !exists {
+		t.Error("Unsent event was dropped")
+	}
+	if exp, act := int64(1), r.flatMetrics[riemannSendFailuresPath]; exp != act {
+		t.Errorf("Wrong send failure count: %v != %v", exp, act)
+	}
+}
+
+func TestRiemannEventFields(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.Host = "foo.{env:METRICS_TEST_HOST}"
+	conf.Attributes = map[string]string{"region": "eu"}
+	conf.Prefix = "svc."
+
+	os.Setenv("METRICS_TEST_HOST", "bar")
+	defer os.Unsetenv("METRICS_TEST_HOST")
+	conf.Host = riemannHost(conf.Host)
+
+	r := newTestRiemann(conf, &fakeRiemannDriver{})
+	r.Gauge("baz", 10)
+
+	e := r.eventsCache["svc.baz"]
+	if e == nil {
+		t.Fatal("Event not cached")
+	}
+	if exp, act := "foo.bar", e.Host; exp != act {
+		t.Errorf("Wrong host: %v != %v", exp, act)
+	}
+	if e.Time == 0 {
+		t.Error("Event time not set")
+	}
+	if exp, act := float32(5), e.TTL; exp != act {
+		t.Errorf("Wrong TTL: %v != %v", exp, act)
+	}
+	if exp, act := "eu", e.Attributes["region"]; exp != act {
+		t.Errorf("Wrong attribute: %v != %v", exp, act)
+	}
+}
+
+func TestRiemannQuery(t *testing.T) {
+	driver := &fakeRiemannDriver{
+		results: []RiemannEvent{{Service: "foo", State: "ok"}},
+	}
+	r := newTestRiemann(NewRiemannConfig(), driver)
+
+	events, err := r.Query(`service = "foo"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(driver.results, events) {
+		t.Errorf("Wrong events: %v != %v", driver.results, events)
+	}
+	if exp, act := []string{`service = "foo"`}, driver.queries; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong queries: %v != %v", exp, act)
+	}
+
+	driver.fail = true
+	if _, err = r.Query(`service = "foo"`); err == nil {
+		t.Error("Expected error from failed query")
+	}
+	if r.client != nil {
+		t.Error("Expected client to be dropped after failed query")
+	}
+	r.nextDial = time.Now().Add(time.Hour)
+	if _, err = r.Query(`service = "foo"`); err != ErrRiemannNotConnected {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannNotConnected)
+	}
+
+	conf := NewRiemannConfig()
+	conf.Network = "udp"
+	r = newTestRiemann(conf, driver)
+	if _, err = r.Query(`true`); err != ErrRiemannQueryUnsupported {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannQueryUnsupported)
+	}
+}
+
+func TestRiemannCircuitBreaker(t *testing.T) {
+	driver := &fakeRiemannDriver{fail: true}
+	r := newTestRiemann(NewRiemannConfig(), driver)
+
+	var err error
+	if r.breaker, err = newBreaker(BreakerConfig{Threshold: 1, ProbeInterval: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Gauge("a", 1)
+	r.flushMetrics()
+
+	if exp, act := BreakerOpen, r.breaker.state; exp != act {
+		t.Fatalf("Wrong breaker state: %v != %v", exp, act)
+	}
+	if r.client != nil {
+		t.Error("Expected client to be dropped after failed send")
+	}
+	if event, ok := r.eventsCache[riemannBreakerStatePath]; !ok || event.Metric != int64(BreakerOpen) {
+		t.Errorf("Wrong breaker state event: %v", event)
+	}
+	if exp, act := int64(1), r.flatMetrics[riemannBreakerOpenedPath]; exp != act {
+		t.Errorf("Wrong opened count: %v != %v", exp, act)
+	}
+
+	r.client = driver
+	r.flushMetrics()
+	if _, ok := r.eventsCache["a"]; !ok {
+		t.Error("Expected events to remain cached whilst breaker is open")
+	}
+	if _, err = r.Query("true"); err != ErrRiemannCircuitOpen {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannCircuitOpen)
+	}
+
+	driver.fail = false
+	r.breaker.openedAt = time.Now().Add(-time.Hour)
+	r.flushMetrics()
+	if exp, act := BreakerClosed, r.breaker.state; exp != act {
+		t.Errorf("Wrong breaker state: %v != %v", exp, act)
+	}
+	if len(driver.batches) != 1 {
+		t.Errorf("Wrong count of batches: %v", len(driver.batches))
+	}
+}
+
+func TestRiemannHeartbeat(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.Prefix = "foo."
+	conf.HeartbeatInterval = "10s"
+
+	interval, err := parseHeartbeat(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestRiemann(conf, &fakeRiemannDriver{})
+	r.heartbeatInterval = interval
+	r.cacheHeartbeat()
+
+	event, ok := r.eventsCache["foo.heartbeat"]
+	if !ok {
+		t.Fatal("Expected heartbeat event")
+	}
+	if exp, act := float32(15), event.TTL; exp != act {
+		t.Errorf("Wrong ttl: %v != %v", exp, act)
+	}
+	if exp, act := "ok", event.State; exp != act {
+		t.Errorf("Wrong state: %v != %v", exp, act)
+	}
+
+	conf.HeartbeatInterval = ""
+	if interval, err = parseHeartbeat(conf); err != nil || interval != 0 {
+		t.Errorf("Expected disabled heartbeat: %v, %v", interval, err)
+	}
+	conf.HeartbeatInterval = "-1s"
+	if _, err = parseHeartbeat(conf); err == nil {
+		t.Error("Expected error from negative heartbeat interval")
+	}
+}
+
+func TestRiemannSpoolOutage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	driver := &fakeRiemannDriver{fail: true}
+	r := newTestRiemann(NewRiemannConfig(), driver)
+	if r.spool, err = newRiemannSpool(RiemannSpoolConfig{Path: filepath.Join(dir, "spool")}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Gauge("a", 1)
+	r.flushMetrics()
+	if r.spool.empty() {
+		t.Fatal("Expected failed send to be spooled")
+	}
+
+	// Whilst disconnected each flush is spooled rather than overwritten in the cache.
+	r.nextDial = time.Now().Add(time.Hour)
+	r.Gauge("a", 2)
+	r.flushMetrics()
+	if _, exists := r.eventsCache["a"]; exists {
+		t.Error("Expected cache to be spooled")
+	}
+
+	driver.fail = false
+	r.client = driver
+	r.Gauge("a", 3)
+	r.flushMetrics()
+
+	values := []interface{}{}
+	for _, batch := range driver.batches {
+		for _, event := range batch {
+			if event.Service == "a" {
+				values = append(values, event.Metric)
+			}
+		}
+	}
+	if exp, act := []interface{}{int64(1), int64(2), int64(3)}, values; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong replayed values: %v != %v", exp, act)
+	}
+	if !r.spool.empty() {
+		t.Error("Expected spool to be empty after replay")
+	}
+}
+
+// blockingRiemannDriver - A riemannDriver whose sends block until it is closed.
+type blockingRiemannDriver struct {
+	closed chan struct{}
+}
+
+func (b *blockingRiemannDriver) SendMulti(events []*RiemannEvent) error {
+	<-b.closed
+	return errors.New("connection closed")
+}
+
+func (b *blockingRiemannDriver) Query(q string) ([]RiemannEvent, error) {
+	return nil, nil
+}
+
+func (b *blockingRiemannDriver) Close() {
+	close(b.closed)
+}
+
+func TestRiemannSendEventSync(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.Host = "foo"
+
+	driver := &fakeRiemannDriver{}
+	r := newTestRiemann(conf, driver)
+
+	if err := r.SendEventSync(context.Background(), &RiemannEvent{Service: "alert"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(driver.batches) != 1 || len(driver.batches[0]) != 1 {
+		t.Fatalf("Wrong batches: %v", driver.batches)
+	}
+	if event := driver.batches[0][0]; event.Host != "foo" || event.TTL != conf.TTL {
+		t.Errorf("Wrong event defaults: %+v", event)
+	}
+	if len(r.eventsCache) != 0 {
+		t.Errorf("Expected event to bypass the cache: %v", r.eventsCache)
+	}
+
+	driver.fail = true
+	if err := r.SendEventSync(context.Background(), &RiemannEvent{Service: "alert"}); err == nil {
+		t.Error("Expected error from failed send")
+	}
+
+	r.client = &blockingRiemannDriver{closed: make(chan struct{})}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := r.SendEventSync(ctx, &RiemannEvent{Service: "alert"}); err != context.DeadlineExceeded {
+		t.Errorf("Wrong error: %v != %v", err, context.DeadlineExceeded)
+	}
+	if r.client != nil {
+		t.Error("Expected client to be dropped after cancelled send")
+	}
+
+	conf.Network = "udp"
+	r = newTestRiemann(conf, driver)
+	if err := r.SendEventSync(context.Background(), &RiemannEvent{}); err != ErrRiemannAckUnsupported {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannAckUnsupported)
+	}
+}
+
+func TestRiemannSendEvent(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.Host = "foo"
+
+	driver := &fakeRiemannDriver{}
+	r := newTestRiemann(conf, driver)
+
+	r.SendEvent(&RiemannEvent{Service: "alert", Description: "first"})
+	r.SendEvent(&RiemannEvent{Service: "alert", Description: "second"})
+	r.Gauge("a", 1)
+	r.flushMetrics()
+
+	if len(driver.batches) != 1 || len(driver.batches[0]) != 3 {
+		t.Fatalf("Wrong batches: %v", driver.batches)
+	}
+	events := driver.batches[0]
+	if events[1].Description != "first" || events[2].Description != "second" {
+		t.Errorf("Wrong queued events: %+v %+v", events[1], events[2])
+	}
+	if events[1].Host != "foo" || events[1].TTL != conf.TTL || events[1].Time == 0 {
+		t.Errorf("Wrong event defaults: %+v", events[1])
+	}
+
+	r.AddHook(func(e *RiemannEvent) bool { return false })
+	if err := r.SendEvent(&RiemannEvent{Service: "alert"}); err != ErrRiemannEventDropped {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannEventDropped)
+	}
+}
+
+func TestRiemannHooks(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.Tags = []string{"meter"}
+	r := newTestRiemann(conf, &fakeRiemannDriver{})
+
+	r.AddHook(func(e *RiemannEvent) bool {
+		e.Tags = append(e.Tags, "team")
+		e.Service = "org." + e.Service
+		return true
+	})
+	r.AddHook(func(e *RiemannEvent) bool {
+		return e.Service != "org.drop"
+	})
+
+	r.Gauge("keep", 1)
+	r.Gauge("drop", 1)
+
+	event, ok := r.eventsCache["org.keep"]
+	if !ok {
+		t.Fatalf("Expected rewritten event: %v", r.eventsCache)
+	}
+	if exp, act := []string{"meter", "team"}, event.Tags; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong tags: %v != %v", exp, act)
+	}
+	if exp, act := []string{"meter"}, r.config.Tags; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Hook modified config tags: %v != %v", exp, act)
+	}
+	if _, ok = r.eventsCache["org.drop"]; ok {
+		t.Error("Expected event to be dropped")
+	}
+
+	if err := r.SendEventSync(context.Background(), &RiemannEvent{Service: "drop"}); err != ErrRiemannEventDropped {
+		t.Errorf("Wrong error: %v != %v", err, ErrRiemannEventDropped)
+	}
+}
+
+func TestRiemannThresholds(t *testing.T) {
+	conf := NewRiemannConfig()
+	conf.Prefix = "foo."
+	conf.Thresholds = []RiemannThresholdConfig{
+		{Pattern: "latency", Warning: 100, Critical: 500},
+	}
+	r := newTestRiemann(conf, &fakeRiemannDriver{})
+
+	r.Timing("latency", 200)
+	r.Gauge("other", 1000)
+
+	if exp, act := RiemannStateWarning, r.eventsCache["foo.latency"].State; exp != act {
+		t.Errorf("Wrong state: %v != %v", exp, act)
+	}
+	if exp, act := "", r.eventsCache["foo.other"].State; exp != act {
+		t.Errorf("Wrong state: %v != %v", exp, act)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"path"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// Riemann event states derived from thresholds.
+const (
+	RiemannStateOK       = "ok"
+	RiemannStateWarning  = "warning"
+	RiemannStateCritical = "critical"
+)
+
+/*
+RiemannThresholdConfig - A rule for deriving the state of events from their metric. The pattern is
+matched against the path of a stat, without the prefix, using the same syntax as filters. A metric at
+or above the critical boundary is critical, at or above the warning boundary is a warning, and
+otherwise ok. When LowerIsWorse is set the comparisons are reversed, which suits stats such as free
+disk space.
+*/
+type RiemannThresholdConfig struct {
+	Pattern      string  `json:"pattern" yaml:"pattern"`
+	Warning      float64 `json:"warning" yaml:"warning"`
+	Critical     float64 `json:"critical" yaml:"critical"`
+	LowerIsWorse bool    `json:"lower_is_worse" yaml:"lower_is_worse"`
+}
+
+// validateThresholds - Returns an error if any threshold pattern is malformed or has boundaries in
+// the wrong order.
+func validateThresholds(thresholds []RiemannThresholdConfig) error {
+	for _, t := range thresholds {
+		if _, err := path.Match(t.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid threshold pattern '%v': %v", t.Pattern, err)
+		}
+		if (!t.LowerIsWorse && t.Critical < t.Warning) || (t.LowerIsWorse && t.Critical > t.Warning) {
+			return fmt.Errorf("threshold '%v' has a critical boundary before its warning boundary", t.Pattern)
+		}
+	}
+	return nil
+}
+
+// thresholdState - Returns the state of a metric according to the first threshold matching the
+// stat, or an empty state if no threshold matches.
+func thresholdState(thresholds []RiemannThresholdConfig, stat string, metric interface{}) string {
+	var value float64
+	switch m := metric.(type) {
+	case int64:
+		value = float64(m)
+	case float64:
+		value = m
+	default:
+		return ""
+	}
+	for _, t := range thresholds {
+		if matched, _ := path.Match(t.Pattern, stat); !matched {
+			continue
+		}
+		worse := func(boundary float64) bool {
+			if t.LowerIsWorse {
+				return value <= boundary
+			}
+			return value >= boundary
+		}
+		switch {
+		case worse(t.Critical):
+			return RiemannStateCritical
+		case worse(t.Warning):
+			return RiemannStateWarning
+		}
+		return RiemannStateOK
+	}
+	return ""
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,68 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import "testing"
+
+//--------------------------------------------------------------------------------------------------
+
+func TestThresholdState(t *testing.T) {
+	thresholds := []RiemannThresholdConfig{
+		{Pattern: "disk.free", Warning: 20, Critical: 10, LowerIsWorse: true},
+		{Pattern: "http.*.latency", Warning: 100, Critical: 500},
+	}
+	if err := validateThresholds(thresholds); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stat   string
+		metric interface{}
+		state  string
+	}{
+		{"disk.free", int64(50), RiemannStateOK},
+		{"disk.free", int64(20), RiemannStateWarning},
+		{"disk.free", float64(5), RiemannStateCritical},
+		{"http.foo.latency", int64(99), RiemannStateOK},
+		{"http.foo.latency", int64(100), RiemannStateWarning},
+		{"http.foo.latency", int64(1000), RiemannStateCritical},
+		{"http.foo.count", int64(1000), ""},
+		{"http.foo.latency", "nope", ""},
+	}
+	for _, test := range tests {
+		if act := thresholdState(thresholds, test.stat, test.metric); act != test.state {
+			t.Errorf("Wrong state for %v %v: %v != %v", test.stat, test.metric, act, test.state)
+		}
+	}
+
+	bad := []RiemannThresholdConfig{{Pattern: "foo", Warning: 10, Critical: 5}}
+	if err := validateThresholds(bad); err == nil {
+		t.Error("Expected error from boundaries in the wrong order")
+	}
+	bad = []RiemannThresholdConfig{{Pattern: "[", Warning: 1, Critical: 2}}
+	if err := validateThresholds(bad); err == nil {
+		t.Error("Expected error from malformed pattern")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
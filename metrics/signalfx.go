@@ -0,0 +1,188 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["signalfx"] = sinkSpec{
+		constructor: NewSignalFxSink,
+		description: `
+Posts snapshots to the SignalFx (Splunk Observability) datapoint ingest API.
+Counters are posted as cumulative counters, gauges as gauges and timings as
+gauges of their count, min, max, mean and p99. The dimensions of the config and
+the tags of stats created with metrics.Tagged become SignalFx dimensions, where
+any characters not permitted within dimension names are replaced with
+underscores.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// SignalFxConfig - Config for the SignalFx ingest API sink.
+type SignalFxConfig struct {
+	URL        string            `json:"url" yaml:"url"`
+	Token      string            `json:"token" yaml:"token"`
+	Dimensions map[string]string `json:"dimensions" yaml:"dimensions"`
+	Timeout    string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewSignalFxConfig - Creates a SignalFxConfig struct with default values.
+func NewSignalFxConfig() SignalFxConfig {
+	return SignalFxConfig{
+		URL:        "https://ingest.us0.signalfx.com",
+		Token:      "",
+		Dimensions: map[string]string{},
+		Timeout:    "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// SignalFxSink - A Sink that posts snapshots to the SignalFx datapoint ingest API.
+type SignalFxSink struct {
+	datapointURL string
+	headers      map[string]string
+	dimensions   map[string]string
+	sender       func(url string, body []byte, headers map[string]string) error
+}
+
+// NewSignalFxSink - Create a new SignalFx ingest API sink.
+func NewSignalFxSink(config Config) (Sink, error) {
+	conf := config.SignalFx
+	if len(conf.Token) == 0 {
+		return nil, fmt.Errorf("signalfx token must not be empty")
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &SignalFxSink{
+		datapointURL: strings.TrimSuffix(conf.URL, "/") + "/v2/datapoint",
+		headers: map[string]string{
+			"Content-Type": "application/json",
+			"X-SF-Token":   conf.Token,
+		},
+		dimensions: conf.Dimensions,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// signalFxDatapoint - A datapoint of the SignalFx ingest API.
+type signalFxDatapoint struct {
+	Metric     string            `json:"metric"`
+	Value      int64             `json:"value"`
+	Timestamp  int64             `json:"timestamp"`
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+}
+
+// signalFxDimension - Converts a tag key into a valid dimension name, which may only contain
+// letters, digits, underscores and hyphens and must not begin with an underscore or "sf_", which
+// are reserved.
+func signalFxDimension(key string) string {
+	b := []byte(key)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			b[i] = '_'
+		}
+	}
+	key = strings.TrimLeft(string(b), "_")
+	if strings.HasPrefix(key, "sf_") {
+		key = "dim_" + key
+	}
+	if len(key) > 128 {
+		key = key[:128]
+	}
+	return key
+}
+
+// signalFxDatapoints - Converts a snapshot into SignalFx datapoints, keyed by their metric type.
+func (s *SignalFxSink) signalFxDatapoints(snap Snapshot) map[string][]signalFxDatapoint {
+	ts := snap.Time.UnixNano() / 1e6
+
+	points := map[string][]signalFxDatapoint{}
+	for _, path := range snap.Paths() {
+		name, pathTags := splitTags(path)
+		tags := mergeTags(s.dimensions, pathTags)
+		var dims map[string]string
+		if len(tags) > 0 {
+			dims = make(map[string]string, len(tags))
+			for k, v := range tags {
+				if dim := signalFxDimension(k); len(dim) > 0 {
+					dims[dim] = v
+				}
+			}
+		}
+		add := func(kind, metric string, value int64) {
+			points[kind] = append(points[kind], signalFxDatapoint{
+				Metric:     metric,
+				Value:      value,
+				Timestamp:  ts,
+				Dimensions: dims,
+			})
+		}
+
+		if v, ok := snap.Counters[path]; ok {
+			add("cumulative_counter", name, v)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add("gauge", name, v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				add("gauge", name+"."+agg.name, agg.value)
+			}
+		}
+	}
+	return points
+}
+
+// Flush - Posts a snapshot to SignalFx.
+func (s *SignalFxSink) Flush(snap Snapshot) error {
+	points := s.signalFxDatapoints(snap)
+	if len(points) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+	return s.sender(s.datapointURL, body, s.headers)
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (s *SignalFxSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
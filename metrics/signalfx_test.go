@@ -0,0 +1,105 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestSignalFxSinkDatapoints(t *testing.T) {
+	conf := NewConfig()
+	conf.SignalFx.Token = "foo"
+	conf.SignalFx.Dimensions = map[string]string{"env": "prod"}
+
+	sink, err := NewSignalFxSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*SignalFxSink)
+
+	var sentURL string
+	var sentBody []byte
+	var sentHeaders map[string]string
+	s.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentBody, sentHeaders = url, body, headers
+		return nil
+	}
+
+	requests := Tagged("requests", map[string]string{"status.code": "200"})
+	err = s.Flush(Snapshot{
+		Time:          time.Unix(100, 0),
+		Counters:      map[string]int64{requests: 9},
+		CounterDeltas: map[string]int64{requests: 4},
+		Gauges:        map[string]int64{"queue": 3},
+		Timings:       map[string][]int64{"latency": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "https://ingest.us0.signalfx.com/v2/datapoint", sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	if exp, act := "foo", sentHeaders["X-SF-Token"]; exp != act {
+		t.Errorf("Wrong token: %v != %v", exp, act)
+	}
+
+	dims := `"dimensions":{"env":"prod"}`
+	exp := `{"cumulative_counter":[` +
+		`{"metric":"requests","value":9,"timestamp":100000,"dimensions":{"env":"prod","status_code":"200"}}],` +
+		`"gauge":[` +
+		`{"metric":"latency.count","value":1,"timestamp":100000,` + dims + `},` +
+		`{"metric":"latency.min","value":2,"timestamp":100000,` + dims + `},` +
+		`{"metric":"latency.max","value":2,"timestamp":100000,` + dims + `},` +
+		`{"metric":"latency.mean","value":2,"timestamp":100000,` + dims + `},` +
+		`{"metric":"latency.p99","value":2,"timestamp":100000,` + dims + `},` +
+		`{"metric":"queue","value":3,"timestamp":100000,` + dims + `}]}`
+	if act := string(sentBody); exp != act {
+		t.Errorf("Wrong body:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+func TestSignalFxDimension(t *testing.T) {
+	for key, exp := range map[string]string{
+		"host":        "host",
+		"status.code": "status_code",
+		"_private":    "private",
+		"sf_metric":   "dim_sf_metric",
+		"a-b_c":       "a-b_c",
+	} {
+		if act := signalFxDimension(key); exp != act {
+			t.Errorf("Wrong dimension for %v: %v != %v", key, exp, act)
+		}
+	}
+}
+
+func TestSignalFxSinkToken(t *testing.T) {
+	if _, err := NewSignalFxSink(NewConfig()); err == nil {
+		t.Error("Expected error from missing token")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,619 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	constructors["sinks"] = typeSpec{
+		constructor: NewSinks,
+		description: `
+Benthos can aggregate metrics locally and periodically flush a snapshot of them
+to any number of sinks, which are listed by name within 'outputs' and configured
+within their own sections of the metrics config.
+
+Sinks can also be listed within 'routes', where each has its own include and
+exclude filter patterns and flush interval, e.g. to send all stats to one sink
+and only latency stats to another at a slower rate.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for sinks.
+var (
+	ErrInvalidSinkType = errors.New("invalid metrics sink type")
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Snapshot - The state of all stats at the time of a flush. Counters hold the running total of each
+counter and CounterDeltas the change of each counter since the previous flush. Gauges hold the
+latest value of each gauge, and Timings hold every timing recorded since the previous flush, up to
+the configured maximum number of samples per path.
+*/
+type Snapshot struct {
+	Time          time.Time
+	Interval      time.Duration
+	Counters      map[string]int64
+	CounterDeltas map[string]int64
+	Gauges        map[string]int64
+	Timings       map[string][]int64
+}
+
+// Paths - Returns the paths of every stat within the snapshot in alphabetical order.
+func (s Snapshot) Paths() []string {
+	seen := map[string]struct{}{}
+	for k := range s.Counters {
+		seen[k] = struct{}{}
+	}
+	for k := range s.Gauges {
+		seen[k] = struct{}{}
+	}
+	for k := range s.Timings {
+		seen[k] = struct{}{}
+	}
+	paths := make([]string, 0, len(seen))
+	for k := range seen {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// TimingSummary - A summary of the timing samples of a stat within a snapshot.
+type TimingSummary struct {
+	Count int64
+	Min   int64
+	Max   int64
+	Sum   int64
+
+	sorted []int64
+}
+
+// SummariseTimings - Summarises timing samples, which must not be empty.
+func SummariseTimings(samples []int64) TimingSummary {
+	sorted := append([]int64(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	s := TimingSummary{
+		Count:  int64(len(sorted)),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		sorted: sorted,
+	}
+	for _, v := range sorted {
+		s.Sum += v
+	}
+	return s
+}
+
+// Mean - Returns the mean of the samples, rounded down.
+func (t TimingSummary) Mean() int64 {
+	return t.Sum / t.Count
+}
+
+// Quantile - Returns the sample at a quantile between 0 and 1 using the nearest rank.
+func (t TimingSummary) Quantile(q float64) int64 {
+	return quantile(t.sorted, q)
+}
+
+// timingAggregate - A named aggregate of timing samples, for sinks that flatten timings.
+type timingAggregate struct {
+	name  string
+	value int64
+}
+
+// aggregates - Returns the count, min, max, mean and 99th percentile of the samples, which is the
+// set of aggregates written by sinks that have no native type for distributions.
+func (t TimingSummary) aggregates() []timingAggregate {
+	return []timingAggregate{
+		{"count", t.Count},
+		{"min", t.Min},
+		{"max", t.Max},
+		{"mean", t.Mean()},
+		{"p99", t.Quantile(0.99)},
+	}
+}
+
+// quantile - Returns the sample at a quantile of sorted samples using the nearest rank.
+func quantile(sorted []int64, q float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// Sink - A destination that snapshots of stats are periodically flushed to. A snapshot is shared
+// between all sinks and must not be modified.
+type Sink interface {
+	// Flush - Send a snapshot of stats to the sink.
+	Flush(s Snapshot) error
+
+	// Close - Stop the sink and clean up resources.
+	Close() error
+}
+
+// mergeSnapshots - Combines a later snapshot into an earlier one as if they had been taken as one,
+// where counters and gauges take their latest values, counter deltas are summed and timings are
+// appended.
+func mergeSnapshots(earlier, later Snapshot) Snapshot {
+	merged := Snapshot{
+		Time:          later.Time,
+		Interval:      earlier.Interval + later.Interval,
+		Counters:      later.Counters,
+		CounterDeltas: make(map[string]int64, len(earlier.CounterDeltas)+len(later.CounterDeltas)),
+		Gauges:        make(map[string]int64, len(earlier.Gauges)+len(later.Gauges)),
+		Timings:       make(map[string][]int64, len(earlier.Timings)+len(later.Timings)),
+	}
+	for _, deltas := range []map[string]int64{earlier.CounterDeltas, later.CounterDeltas} {
+		for k, v := range deltas {
+			merged.CounterDeltas[k], _ = addCounter(merged.CounterDeltas[k], v, false)
+		}
+	}
+	for _, gauges := range []map[string]int64{earlier.Gauges, later.Gauges} {
+		for k, v := range gauges {
+			merged.Gauges[k] = v
+		}
+	}
+	for _, timings := range []map[string][]int64{earlier.Timings, later.Timings} {
+		for k, v := range timings {
+			merged.Timings[k] = append(merged.Timings[k], v...)
+		}
+	}
+	return merged
+}
+
+// snapshotDocument - The JSON document of a snapshot written by sinks that publish each flush as a
+// single message, where timings are summarised by their aggregates.
+type snapshotDocument struct {
+	Time          string                      `json:"time"`
+	IntervalMs    int64                       `json:"interval_ms"`
+	Counters      map[string]int64            `json:"counters"`
+	CounterDeltas map[string]int64            `json:"counter_deltas"`
+	Gauges        map[string]int64            `json:"gauges"`
+	Timings       map[string]map[string]int64 `json:"timings"`
+}
+
+// marshalSnapshot - Encodes a snapshot as a JSON snapshotDocument.
+func marshalSnapshot(snap Snapshot) ([]byte, error) {
+	doc := snapshotDocument{
+		Time:          snap.Time.UTC().Format(time.RFC3339Nano),
+		IntervalMs:    int64(snap.Interval / time.Millisecond),
+		Counters:      snap.Counters,
+		CounterDeltas: snap.CounterDeltas,
+		Gauges:        snap.Gauges,
+		Timings:       make(map[string]map[string]int64, len(snap.Timings)),
+	}
+	for _, m := range []*map[string]int64{&doc.Counters, &doc.CounterDeltas, &doc.Gauges} {
+		if *m == nil {
+			*m = map[string]int64{}
+		}
+	}
+	for path, samples := range snap.Timings {
+		if len(samples) == 0 {
+			continue
+		}
+		aggs := map[string]int64{}
+		for _, agg := range SummariseTimings(samples).aggregates() {
+			aggs[agg.name] = agg.value
+		}
+		doc.Timings[path] = aggs
+	}
+	return json.Marshal(doc)
+}
+
+// metricDocument - The JSON document of a single stat of a snapshot written by sinks that publish
+// each stat as its own message. Counters have a value and delta, gauges a value, and timings the
+// aggregates of their samples as stats.
+type metricDocument struct {
+	Time  int64             `json:"time"`
+	Name  string            `json:"name"`
+	Type  string            `json:"type"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Value *int64            `json:"value,omitempty"`
+	Delta *int64            `json:"delta,omitempty"`
+	Stats map[string]int64  `json:"stats,omitempty"`
+}
+
+// metricDocuments - Splits a snapshot into a metricDocument for each stat in the order of its
+// paths, with the tags of each path split from its name.
+func metricDocuments(snap Snapshot) []metricDocument {
+	docs := []metricDocument{}
+	for _, path := range snap.Paths() {
+		name, tags := splitTags(path)
+		doc := func(kind string) metricDocument {
+			return metricDocument{Time: snap.Time.Unix(), Name: name, Type: kind, Tags: tags}
+		}
+
+		if v, ok := snap.Counters[path]; ok {
+			d, delta := doc(KindCounter), snap.CounterDeltas[path]
+			d.Value, d.Delta = &v, &delta
+			docs = append(docs, d)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			d := doc(KindGauge)
+			d.Value = &v
+			docs = append(docs, d)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			d := doc(KindTiming)
+			d.Stats = map[string]int64{}
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				d.Stats[agg.name] = agg.value
+			}
+			docs = append(docs, d)
+		}
+	}
+	return docs
+}
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+throttledSink - Wraps a sink so that it is flushed at a longer interval than the sinks type, which
+allows a sink to be configured with its own flush interval. Snapshots arriving between flushes are
+merged so that no counter deltas or timings are lost, and any merged snapshot still pending is
+flushed as the sink is closed.
+*/
+type throttledSink struct {
+	sync.Mutex
+	sink     Sink
+	interval time.Duration
+
+	pending   *Snapshot
+	lastFlush time.Time
+}
+
+// newThrottledSink - Wraps a sink with a flush interval, an empty interval leaves the sink as is.
+func newThrottledSink(sink Sink, interval string) (Sink, error) {
+	if len(interval) == 0 {
+		return sink, nil
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush interval: %v", err)
+	}
+	return &throttledSink{sink: sink, interval: d, lastFlush: time.Now()}, nil
+}
+
+// Flush - Merges the snapshot with any pending and flushes once the interval has passed.
+func (t *throttledSink) Flush(s Snapshot) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.pending != nil {
+		s = mergeSnapshots(*t.pending, s)
+	}
+	if s.Time.Sub(t.lastFlush) < t.interval {
+		t.pending = &s
+		return nil
+	}
+	t.pending, t.lastFlush = nil, s.Time
+	return t.sink.Flush(s)
+}
+
+// Close - Flushes any pending snapshot and closes the sink.
+func (t *throttledSink) Close() error {
+	t.Lock()
+	defer t.Unlock()
+
+	var err error
+	if t.pending != nil {
+		err = t.sink.Flush(*t.pending)
+		t.pending = nil
+	}
+	if cerr := t.sink.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// sinkSpec - Constructor and a usage description for each sink type.
+type sinkSpec struct {
+	constructor func(conf Config) (Sink, error)
+	description string
+}
+
+var sinkConstructors = map[string]sinkSpec{}
+
+// NewSink - Create a sink based on its name and a configuration.
+func NewSink(name string, conf Config) (Sink, error) {
+	spec, ok := sinkConstructors[name]
+	if !ok {
+		return nil, ErrInvalidSinkType
+	}
+	return spec.constructor(conf)
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// SinksConfig - Configuration fields for the sinks type.
+type SinksConfig struct {
+	Prefix           string            `json:"prefix" yaml:"prefix"`
+	FlushInterval    string            `json:"flush_interval" yaml:"flush_interval"`
+	MaxTimingSamples int               `json:"max_timing_samples" yaml:"max_timing_samples"`
+	Outputs          []string          `json:"outputs" yaml:"outputs"`
+	Routes           []SinkRouteConfig `json:"routes" yaml:"routes"`
+}
+
+// NewSinksConfig - Create a new sinks config with default values.
+func NewSinksConfig() SinksConfig {
+	return SinksConfig{
+		Prefix:           "",
+		FlushInterval:    "10s",
+		MaxTimingSamples: 1000,
+		Outputs:          []string{},
+		Routes:           []SinkRouteConfig{},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Stat paths of the internal stats tracked by the sinks type.
+const (
+	sinksFlushFailuresPath = "sinks.flush.failures"
+)
+
+/*
+Sinks - A Type that aggregates stats locally and flushes a snapshot of them to each of its sinks
+at a regular interval. Sinks are created from the outputs and routes listed in the config and more
+can be added at runtime with AddSink. A sink that fails to flush is counted at sinks.flush.failures
+and does not prevent the other sinks from being flushed.
+*/
+type Sinks struct {
+	sync.Mutex
+
+	prefix     string
+	maxSamples int
+	timingUnit time.Duration
+	clampZero  bool
+
+	counters map[string]int64
+	deltas   map[string]int64
+	gauges   map[string]int64
+	timings  map[string][]int64
+
+	flushMut sync.Mutex
+	sinks    []Sink
+
+	interval  time.Duration
+	lastFlush time.Time
+	quit      chan struct{}
+	closed    chan struct{}
+}
+
+// NewSinks - Create a new sinks type, along with a sink for each of the configured outputs.
+func NewSinks(config Config) (Type, error) {
+	return newSinks(config, true)
+}
+
+// newSinks - Creates a sinks type, optionally without starting the flush loop.
+func newSinks(config Config, start bool) (*Sinks, error) {
+	interval, err := time.ParseDuration(config.Sinks.FlushInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush interval: %v", err)
+	}
+	timingUnit, err := parseTimingUnit(config.TimingUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sinks{
+		prefix:     expandPrefix(config.Sinks.Prefix),
+		maxSamples: config.Sinks.MaxTimingSamples,
+		timingUnit: timingUnit,
+		clampZero:  config.ClampZero,
+		counters:   map[string]int64{},
+		deltas:     map[string]int64{},
+		gauges:     map[string]int64{},
+		timings:    map[string][]int64{},
+		interval:   interval,
+		lastFlush:  time.Now(),
+		quit:       make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+
+	for _, name := range config.Sinks.Outputs {
+		sink, err := NewSink(name, config)
+		if err != nil {
+			s.closeSinks()
+			return nil, fmt.Errorf("failed to create sink '%v': %v", name, err)
+		}
+		s.sinks = append(s.sinks, sink)
+	}
+	for _, route := range config.Sinks.Routes {
+		sink, err := newRoutedSink(route, config)
+		if err != nil {
+			s.closeSinks()
+			return nil, fmt.Errorf("failed to create sink '%v': %v", route.Type, err)
+		}
+		s.sinks = append(s.sinks, sink)
+	}
+
+	if start {
+		go s.loop()
+	} else {
+		close(s.closed)
+	}
+	return s, nil
+}
+
+// AddSink - Adds a sink to be flushed alongside any others.
+func (s *Sinks) AddSink(sink Sink) {
+	s.flushMut.Lock()
+	s.sinks = append(s.sinks, sink)
+	s.flushMut.Unlock()
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Incr - Increment a stat by a value.
+func (s *Sinks) Incr(stat string, value int64) error {
+	s.Lock()
+	s.addCounter(s.prefix+stat, value)
+	s.Unlock()
+	return nil
+}
+
+// Decr - Decrement a stat by a value.
+func (s *Sinks) Decr(stat string, value int64) error {
+	s.Lock()
+	s.addCounter(s.prefix+stat, negate(value))
+	s.Unlock()
+	return nil
+}
+
+// addCounter - Adds a delta to a counter, recording when the result is clamped. Must be called
+// whilst holding the lock.
+func (s *Sinks) addCounter(path string, delta int64) {
+	prev := s.counters[path]
+	total, clamped := addCounter(prev, delta, s.clampZero)
+	s.counters[path] = total
+	s.deltas[path], _ = addCounter(s.deltas[path], total-prev, false)
+
+	if clamped && path != s.prefix+ClampedCountPath {
+		s.addCounter(s.prefix+ClampedCountPath, 1)
+	}
+}
+
+// Timing - Record a stat representing a duration.
+func (s *Sinks) Timing(stat string, delta int64) error {
+	s.Lock()
+	path := s.prefix + stat
+	if s.maxSamples <= 0 || len(s.timings[path]) < s.maxSamples {
+		s.timings[path] = append(s.timings[path], delta)
+	}
+	s.Unlock()
+	return nil
+}
+
+// TimingDuration - Record a stat representing a duration, in the configured timing unit.
+func (s *Sinks) TimingDuration(stat string, d time.Duration) error {
+	return s.Timing(stat, int64(d/s.timingUnit))
+}
+
+// Gauge - Set a stat as a gauge value.
+func (s *Sinks) Gauge(stat string, value int64) error {
+	s.Lock()
+	s.gauges[s.prefix+stat] = value
+	s.Unlock()
+	return nil
+}
+
+// Close - Stops the flush loop, flushes any remaining stats and closes each sink.
+func (s *Sinks) Close() error {
+	close(s.quit)
+	<-s.closed
+	s.flush()
+	s.flushMut.Lock()
+	s.closeSinks()
+	s.flushMut.Unlock()
+	return nil
+}
+
+// closeSinks - Closes every sink. Must be called whilst holding flushMut.
+func (s *Sinks) closeSinks() {
+	for _, sink := range s.sinks {
+		sink.Close()
+	}
+	s.sinks = nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+func (s *Sinks) loop() {
+	defer close(s.closed)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// snapshot - Takes a snapshot of the stats, resetting the counter deltas and timings.
+func (s *Sinks) snapshot() Snapshot {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	snap := Snapshot{
+		Time:          now,
+		Interval:      now.Sub(s.lastFlush),
+		Counters:      make(map[string]int64, len(s.counters)),
+		CounterDeltas: s.deltas,
+		Gauges:        make(map[string]int64, len(s.gauges)),
+		Timings:       s.timings,
+	}
+	for k, v := range s.counters {
+		snap.Counters[k] = v
+	}
+	for k, v := range s.gauges {
+		snap.Gauges[k] = v
+	}
+	s.deltas = map[string]int64{}
+	s.timings = map[string][]int64{}
+	s.lastFlush = now
+	return snap
+}
+
+// flush - Flushes a snapshot to each sink, counting those that fail.
+func (s *Sinks) flush() {
+	s.flushMut.Lock()
+	defer s.flushMut.Unlock()
+
+	snap := s.snapshot()
+	for _, sink := range s.sinks {
+		if err := sink.Flush(snap); err != nil {
+			s.Incr(sinksFlushFailuresPath, 1)
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,111 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// newSinkHTTPClient - Creates an HTTP client for a sink with a timeout parsed from its config.
+func newSinkHTTPClient(timeout string) (*http.Client, error) {
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	return &http.Client{Timeout: d}, nil
+}
+
+// sendHTTP - Sends a request with a body and headers, returning an error unless the response has
+// a 2xx status. The response body is read and discarded so that the connection can be reused.
+func sendHTTP(client *http.Client, method, url string, body []byte, headers map[string]string) error {
+	_, err := doHTTP(client, method, url, body, headers)
+	return err
+}
+
+// doHTTP - Sends a request like sendHTTP and returns the body of the response.
+func doHTTP(client *http.Client, method, url string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("%v responded with status: %v", req.URL.Host, res.Status)
+	}
+	return resBody, err
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// accessToken - An OAuth access token as returned by a token endpoint, where some endpoints encode
+// the expiry as a string.
+type accessToken struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+}
+
+// tokenSource - Caches the access token of a sink until shortly before it expires.
+type tokenSource struct {
+	sync.Mutex
+	fetch func() (accessToken, error)
+
+	token  string
+	expiry time.Time
+}
+
+// get - Returns a cached token, fetching a new one when it is within a minute of expiring.
+func (s *tokenSource) get(now time.Time) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.token) > 0 && now.Before(s.expiry.Add(-time.Minute)) {
+		return s.token, nil
+	}
+	t, err := s.fetch()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token: %v", err)
+	}
+	expiresIn, _ := t.ExpiresIn.Int64()
+	s.token, s.expiry = t.AccessToken, now.Add(time.Duration(expiresIn)*time.Second)
+	return s.token, nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestTokenSource(t *testing.T) {
+	fetches := 0
+	s := &tokenSource{fetch: func() (accessToken, error) {
+		fetches++
+		return accessToken{AccessToken: "tok", ExpiresIn: "120"}, nil
+	}}
+
+	now := time.Unix(100, 0)
+	for _, d := range []time.Duration{0, 30 * time.Second, 61 * time.Second} {
+		if token, err := s.get(now.Add(d)); err != nil || token != "tok" {
+			t.Errorf("Wrong token: %v %v", token, err)
+		}
+	}
+	if exp, act := 2, fetches; exp != act {
+		t.Errorf("Wrong count of fetches: %v != %v", exp, act)
+	}
+
+	s = &tokenSource{fetch: func() (accessToken, error) {
+		return accessToken{}, errors.New("nope")
+	}}
+	if _, err := s.get(now); err == nil {
+		t.Error("Expected error from failed fetch")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
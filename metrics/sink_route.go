@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+SinkRouteConfig - Attaches a sink to the sinks type with its own filter and flush interval, which
+allows the same stats to be routed differently to each sink, e.g. all stats to prometheus and only
+latency stats to graphite. The sink itself is configured with its usual section of the config, and
+an empty flush interval flushes the sink alongside all others.
+*/
+type SinkRouteConfig struct {
+	Type          string       `json:"type" yaml:"type"`
+	Filter        FilterConfig `json:"filter" yaml:"filter"`
+	FlushInterval string       `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// NewSinkRouteConfig - Returns a route configuration with default values.
+func NewSinkRouteConfig() SinkRouteConfig {
+	return SinkRouteConfig{
+		Type:          "",
+		Filter:        NewFilterConfig(),
+		FlushInterval: "",
+	}
+}
+
+// newRoutedSink - Creates the sink of a route, wrapped according to its filter and flush interval.
+func newRoutedSink(route SinkRouteConfig, conf Config) (Sink, error) {
+	if err := route.Filter.validate(); err != nil {
+		return nil, err
+	}
+	sink, err := NewSink(route.Type, conf)
+	if err != nil {
+		return nil, err
+	}
+	if !route.Filter.IsEmpty() {
+		sink = &filteredSink{sink: sink, filter: route.Filter}
+	}
+	throttled, err := newThrottledSink(sink, route.FlushInterval)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return throttled, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// filteredSink - Wraps a sink so that it is only flushed the stats of a snapshot that pass a filter.
+type filteredSink struct {
+	sink   Sink
+	filter FilterConfig
+}
+
+// NewFilteredSink - Wraps a sink so that stats rejected by a filter are removed from each snapshot
+// before it is flushed.
+func NewFilteredSink(sink Sink, filter FilterConfig) (Sink, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	return &filteredSink{sink: sink, filter: filter}, nil
+}
+
+// Flush - Flushes a copy of the snapshot containing only the stats that pass the filter.
+func (f *filteredSink) Flush(s Snapshot) error {
+	return f.sink.Flush(Snapshot{
+		Time:          s.Time,
+		Interval:      s.Interval,
+		Counters:      f.filterValues(s.Counters),
+		CounterDeltas: f.filterValues(s.CounterDeltas),
+		Gauges:        f.filterValues(s.Gauges),
+		Timings:       f.filterTimings(s.Timings),
+	})
+}
+
+// Close - Closes the wrapped sink.
+func (f *filteredSink) Close() error {
+	return f.sink.Close()
+}
+
+func (f *filteredSink) filterValues(values map[string]int64) map[string]int64 {
+	filtered := make(map[string]int64, len(values))
+	for k, v := range values {
+		if f.filter.Allows(k) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+func (f *filteredSink) filterTimings(timings map[string][]int64) map[string][]int64 {
+	filtered := make(map[string][]int64, len(timings))
+	for k, v := range timings {
+		if f.filter.Allows(k) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,134 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilteredSink(t *testing.T) {
+	inner := &fakeSink{}
+	sink, err := NewFilteredSink(inner, FilterConfig{
+		Include: []string{"latency.*", "requests"},
+		Exclude: []string{"latency.debug"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink.Flush(Snapshot{
+		Counters:      map[string]int64{"requests": 2, "errors": 1},
+		CounterDeltas: map[string]int64{"requests": 1, "errors": 1},
+		Gauges:        map[string]int64{"latency.debug": 4, "memory": 5},
+		Timings:       map[string][]int64{"latency.http": {1, 2}, "other": {3}},
+	})
+	if len(inner.snapshots) != 1 {
+		t.Fatalf("Wrong count of snapshots: %v", len(inner.snapshots))
+	}
+	snap := inner.snapshots[0]
+	if exp, act := map[string]int64{"requests": 2}, snap.Counters; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counters: %v != %v", exp, act)
+	}
+	if exp, act := map[string]int64{"requests": 1}, snap.CounterDeltas; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counter deltas: %v != %v", exp, act)
+	}
+	if exp, act := map[string]int64{}, snap.Gauges; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong gauges: %v != %v", exp, act)
+	}
+	if exp, act := map[string][]int64{"latency.http": {1, 2}}, snap.Timings; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong timings: %v != %v", exp, act)
+	}
+
+	if _, err = NewFilteredSink(inner, FilterConfig{Include: []string{"["}}); err == nil {
+		t.Error("Expected error from bad pattern")
+	}
+}
+
+func TestSinksRoutes(t *testing.T) {
+	all, latency := &fakeSink{}, &fakeSink{}
+	sinkConstructors["test_all"] = sinkSpec{
+		constructor: func(Config) (Sink, error) { return all, nil },
+	}
+	sinkConstructors["test_latency"] = sinkSpec{
+		constructor: func(Config) (Sink, error) { return latency, nil },
+	}
+	defer delete(sinkConstructors, "test_all")
+	defer delete(sinkConstructors, "test_latency")
+
+	latencyRoute := NewSinkRouteConfig()
+	latencyRoute.Type = "test_latency"
+	latencyRoute.Filter.Include = []string{"*.latency"}
+	latencyRoute.FlushInterval = "1h"
+
+	conf := NewConfig()
+	conf.Sinks.Outputs = []string{"test_all"}
+	conf.Sinks.Routes = []SinkRouteConfig{latencyRoute}
+
+	s, err := newSinks(conf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Incr("http.requests", 1)
+	s.Timing("http.latency", 5)
+	s.flush()
+
+	if len(all.snapshots) != 1 {
+		t.Fatalf("Wrong count of snapshots: %v", len(all.snapshots))
+	}
+	if exp, act := []string{"http.latency", "http.requests"}, all.snapshots[0].Paths(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong paths: %v != %v", exp, act)
+	}
+	if len(latency.snapshots) != 0 {
+		t.Error("Expected latency sink to be throttled")
+	}
+
+	s.Timing("http.latency", 6)
+	s.Close()
+	if len(latency.snapshots) != 1 {
+		t.Fatalf("Wrong count of snapshots: %v", len(latency.snapshots))
+	}
+	if exp, act := []string{"http.latency"}, latency.snapshots[0].Paths(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong paths: %v != %v", exp, act)
+	}
+	if exp, act := []int64{5, 6}, latency.snapshots[0].Timings["http.latency"]; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong timings: %v != %v", exp, act)
+	}
+	if !latency.closed {
+		t.Error("Expected latency sink to be closed")
+	}
+}
+
+func TestSinksRouteErrors(t *testing.T) {
+	for _, route := range []SinkRouteConfig{
+		{Type: "not_a_sink"},
+		{Type: "statsd", Filter: FilterConfig{Exclude: []string{"["}}},
+		{Type: "statsd", FlushInterval: "nope"},
+	} {
+		conf := NewConfig()
+		conf.Sinks.Routes = []SinkRouteConfig{route}
+		if _, err := newSinks(conf, false); err == nil {
+			t.Errorf("Expected error from route: %+v", route)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// fakeSink - A Sink that records each snapshot, failing when told to.
+type fakeSink struct {
+	sync.Mutex
+	snapshots []Snapshot
+	fail      bool
+	closed    bool
+}
+
+func (f *fakeSink) Flush(s Snapshot) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.fail {
+		return errors.New("fake failure")
+	}
+	f.snapshots = append(f.snapshots, s)
+	return nil
+}
+
+func (f *fakeSink) Close() error {
+	f.Lock()
+	f.closed = true
+	f.Unlock()
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+func TestSinksSnapshot(t *testing.T) {
+	conf := NewConfig()
+	conf.Sinks.Prefix = "foo."
+	conf.Sinks.MaxTimingSamples = 2
+
+	s, err := newSinks(conf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &fakeSink{}
+	s.AddSink(sink)
+
+	s.Incr("a", 5)
+	s.Decr("a", 2)
+	s.Gauge("b", 10)
+	s.Timing("c", 1)
+	s.Timing("c", 2)
+	s.Timing("c", 3)
+	s.flush()
+
+	s.Incr("a", 1)
+	s.flush()
+
+	if len(sink.snapshots) != 2 {
+		t.Fatalf("Wrong count of snapshots: %v", len(sink.snapshots))
+	}
+	first, second := sink.snapshots[0], sink.snapshots[1]
+
+	if exp, act := map[string]int64{"foo.a": 3}, first.Counters; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counters: %v != %v", exp, act)
+	}
+	if exp, act := map[string]int64{"foo.a": 3}, first.CounterDeltas; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counter deltas: %v != %v", exp, act)
+	}
+	if exp, act := map[string]int64{"foo.b": 10}, first.Gauges; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong gauges: %v != %v", exp, act)
+	}
+	if exp, act := map[string][]int64{"foo.c": {1, 2}}, first.Timings; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong timings: %v != %v", exp, act)
+	}
+	if exp, act := []string{"foo.a", "foo.b", "foo.c"}, first.Paths(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong paths: %v != %v", exp, act)
+	}
+
+	if exp, act := map[string]int64{"foo.a": 4}, second.Counters; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counters: %v != %v", exp, act)
+	}
+	if exp, act := map[string]int64{"foo.a": 1}, second.CounterDeltas; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counter deltas: %v != %v", exp, act)
+	}
+	if len(second.Timings) != 0 {
+		t.Errorf("Expected timings to be reset: %v", second.Timings)
+	}
+
+	s.Close()
+	if !sink.closed {
+		t.Error("Expected sink to be closed")
+	}
+}
+
+func TestSinksFlushFailure(t *testing.T) {
+	s, err := newSinks(NewConfig(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing, working := &fakeSink{fail: true}, &fakeSink{}
+	s.AddSink(failing)
+	s.AddSink(working)
+
+	s.flush()
+	if len(working.snapshots) != 1 {
+		t.Errorf("Expected working sink to be flushed: %v", len(working.snapshots))
+	}
+	if exp, act := int64(1), s.counters[sinksFlushFailuresPath]; exp != act {
+		t.Errorf("Wrong flush failures: %v != %v", exp, act)
+	}
+}
+
+func TestNewSinkInvalid(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "sinks"
+	conf.Sinks.Outputs = []string{"not_a_sink"}
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from unrecognised sink")
+	}
+}
+
+func TestThrottledSink(t *testing.T) {
+	inner := &fakeSink{}
+	sink, err := newThrottledSink(inner, "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttled := sink.(*throttledSink)
+	start := throttled.lastFlush
+
+	sink.Flush(Snapshot{
+		Time:          start.Add(time.Second * 30),
+		Counters:      map[string]int64{"a": 1},
+		CounterDeltas: map[string]int64{"a": 1},
+		Timings:       map[string][]int64{"b": {1}},
+	})
+	if len(inner.snapshots) != 0 {
+		t.Fatal("Expected snapshot to be held back")
+	}
+	sink.Flush(Snapshot{
+		Time:          start.Add(time.Minute),
+		Counters:      map[string]int64{"a": 3},
+		CounterDeltas: map[string]int64{"a": 2},
+		Timings:       map[string][]int64{"b": {2}},
+	})
+	if len(inner.snapshots) != 1 {
+		t.Fatalf("Expected merged snapshot to be flushed: %v", len(inner.snapshots))
+	}
+
+	merged := inner.snapshots[0]
+	if exp, act := map[string]int64{"a": 3}, merged.Counters; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counters: %v != %v", exp, act)
+	}
+	if exp, act := map[string]int64{"a": 3}, merged.CounterDeltas; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong counter deltas: %v != %v", exp, act)
+	}
+	if exp, act := map[string][]int64{"b": {1, 2}}, merged.Timings; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong timings: %v != %v", exp, act)
+	}
+
+	sink.Flush(Snapshot{Time: start.Add(time.Minute + time.Second)})
+	sink.Close()
+	if len(inner.snapshots) != 2 || !inner.closed {
+		t.Errorf("Expected pending snapshot to be flushed on close: %v", len(inner.snapshots))
+	}
+
+	if sink, _ = newThrottledSink(inner, ""); sink != Sink(inner) {
+		t.Error("Expected empty interval to leave sink as is")
+	}
+}
+
+func TestMarshalSnapshot(t *testing.T) {
+	doc, err := marshalSnapshot(Snapshot{
+		Time:     time.Unix(100, 0),
+		Interval: time.Second,
+		Gauges:   map[string]int64{"a": 1},
+		Timings:  map[string][]int64{"b": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"time":"1970-01-01T00:01:40Z","interval_ms":1000,"counters":{},"counter_deltas":{},` +
+		`"gauges":{"a":1},"timings":{"b":{"count":1,"max":2,"mean":2,"min":2,"p99":2}}}`
+	if act := string(doc); exp != act {
+		t.Errorf("Wrong document:\n%v\n!=\n%v", exp, act)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -24,6 +24,7 @@ package metrics
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"gopkg.in/alexcesaro/statsd.v2"
@@ -42,11 +43,12 @@ func init() {
 
 // StatsdConfig - Config for the Statsd metrics type.
 type StatsdConfig struct {
-	Address       string `json:"address" yaml:"address"`
-	FlushPeriod   string `json:"flush_period" yaml:"flush_period"`
-	MaxPacketSize int    `json:"max_packet_size" yaml:"max_packet_size"`
-	Network       string `json:"network" yaml:"network"`
-	Prefix        string `json:"prefix" yaml:"prefix"`
+	Address       string  `json:"address" yaml:"address"`
+	FlushPeriod   string  `json:"flush_period" yaml:"flush_period"`
+	MaxPacketSize int     `json:"max_packet_size" yaml:"max_packet_size"`
+	Network       string  `json:"network" yaml:"network"`
+	Prefix        string  `json:"prefix" yaml:"prefix"`
+	SampleRate    float64 `json:"sample_rate" yaml:"sample_rate"`
 }
 
 // NewStatsdConfig - Creates an StatsdConfig struct with default values.
@@ -57,6 +59,7 @@ func NewStatsdConfig() StatsdConfig {
 		MaxPacketSize: 1440,
 		Network:       "udp",
 		Prefix:        "",
+		SampleRate:    1,
 	}
 }
 
@@ -64,61 +67,114 @@ func NewStatsdConfig() StatsdConfig {
 
 // Statsd - A stats object with capability to hold internal stats as a JSON endpoint.
 type Statsd struct {
-	config Config
-	s      *statsd.Client
+	sync.RWMutex
+
+	config     Config
+	s          *statsd.Client
+	timingUnit time.Duration
 }
 
 // NewStatsd - Create and return a new Statsd object.
 func NewStatsd(config Config) (Type, error) {
+	c, timingUnit, err := newStatsdClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Statsd{
+		config:     config,
+		s:          c,
+		timingUnit: timingUnit,
+	}, nil
+}
+
+// newStatsdClient - Creates a statsd client from a config, along with the parsed timing unit.
+func newStatsdClient(config Config) (*statsd.Client, time.Duration, error) {
 	flushPeriod, err := time.ParseDuration(config.Statsd.FlushPeriod)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse flush period: %s", err)
+		return nil, 0, fmt.Errorf("Failed to parse flush period: %s", err)
+	}
+	timingUnit, err := parseTimingUnit(config.TimingUnit)
+	if err != nil {
+		return nil, 0, err
 	}
 	c, err := statsd.New(
 		statsd.Address(config.Statsd.Address),
 		statsd.FlushPeriod(flushPeriod),
 		statsd.MaxPacketSize(config.Statsd.MaxPacketSize),
 		statsd.Network(config.Statsd.Network),
-		statsd.Prefix(config.Statsd.Prefix),
+		statsd.Prefix(expandPrefix(config.Statsd.Prefix)),
+		statsd.SampleRate(float32(config.Statsd.SampleRate)),
 	)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return &Statsd{
-		config: config,
-		s:      c,
-	}, nil
+	return c, timingUnit, nil
 }
 
 //--------------------------------------------------------------------------------------------------
 
 // Incr - Increment a stat by a value.
 func (h *Statsd) Incr(stat string, value int64) error {
+	h.RLock()
 	h.s.Count(stat, value)
+	h.RUnlock()
 	return nil
 }
 
 // Decr - Decrement a stat by a value.
 func (h *Statsd) Decr(stat string, value int64) error {
+	h.RLock()
 	h.s.Count(stat, -value)
+	h.RUnlock()
 	return nil
 }
 
 // Timing - Set a stat representing a duration.
 func (h *Statsd) Timing(stat string, delta int64) error {
+	h.RLock()
 	h.s.Timing(stat, delta)
+	h.RUnlock()
+	return nil
+}
+
+// TimingDuration - Set a stat representing a duration, in the configured timing unit.
+func (h *Statsd) TimingDuration(stat string, d time.Duration) error {
+	h.RLock()
+	h.s.Timing(stat, int64(d/h.timingUnit))
+	h.RUnlock()
 	return nil
 }
 
 // Gauge - Set a stat as a gauge value.
 func (h *Statsd) Gauge(stat string, value int64) error {
+	h.RLock()
 	h.s.Gauge(stat, value)
+	h.RUnlock()
+	return nil
+}
+
+// Reconfigure - Replaces the underlying statsd client with one built from a new config. Metrics
+// buffered by the previous client are flushed as it is closed.
+func (h *Statsd) Reconfigure(config Config) error {
+	c, timingUnit, err := newStatsdClient(config)
+	if err != nil {
+		return err
+	}
+
+	h.Lock()
+	old := h.s
+	h.config, h.s, h.timingUnit = config, c, timingUnit
+	h.Unlock()
+
+	old.Close()
 	return nil
 }
 
 // Close - Stops the Statsd object from aggregating metrics and cleans up resources.
 func (h *Statsd) Close() error {
+	h.RLock()
 	h.s.Close()
+	h.RUnlock()
 	return nil
 }
 
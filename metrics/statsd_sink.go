@@ -0,0 +1,187 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["statsd"] = sinkSpec{
+		constructor: NewStatsdSink,
+		description: `
+Sends snapshots to a statsd server using the address, network, max packet size,
+prefix and sample rate of the statsd config. Counters are sent as the change
+since the previous flush, and the sample rate applies to timings only as counters
+and gauges are already aggregated.`,
+	}
+	sinkConstructors["dogstatsd"] = sinkSpec{
+		constructor: NewDogStatsdSink,
+		description: `
+Sends snapshots to a DogStatsD agent in the same way as the statsd sink, using
+the dogstatsd config. Tags of stats created with metrics.Tagged, along with the
+tags of the config, are sent with the DogStatsD '|#key:value' extension.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// DogStatsdConfig - Config for the DogStatsD sink.
+type DogStatsdConfig struct {
+	Address       string            `json:"address" yaml:"address"`
+	MaxPacketSize int               `json:"max_packet_size" yaml:"max_packet_size"`
+	Network       string            `json:"network" yaml:"network"`
+	Prefix        string            `json:"prefix" yaml:"prefix"`
+	SampleRate    float64           `json:"sample_rate" yaml:"sample_rate"`
+	Tags          map[string]string `json:"tags" yaml:"tags"`
+}
+
+// NewDogStatsdConfig - Creates a DogStatsdConfig struct with default values.
+func NewDogStatsdConfig() DogStatsdConfig {
+	return DogStatsdConfig{
+		Address:       "localhost:8125",
+		MaxPacketSize: 1440,
+		Network:       "udp",
+		Prefix:        "",
+		SampleRate:    1,
+		Tags:          map[string]string{},
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// StatsdSink - A Sink that writes snapshots using the statsd line protocol, or the DogStatsD dialect
+// of it.
+type StatsdSink struct {
+	prefix     string
+	sampleRate float64
+	dogstatsd  bool
+	tags       map[string]string
+	writer     *lineWriter
+}
+
+// NewStatsdSink - Create a new statsd sink.
+func NewStatsdSink(config Config) (Sink, error) {
+	return newStatsdSink(config.Statsd)
+}
+
+// NewDogStatsdSink - Create a new DogStatsD sink.
+func NewDogStatsdSink(config Config) (Sink, error) {
+	conf := config.DogStatsd
+	s, err := newStatsdSink(StatsdConfig{
+		Address:       conf.Address,
+		MaxPacketSize: conf.MaxPacketSize,
+		Network:       conf.Network,
+		Prefix:        conf.Prefix,
+		SampleRate:    conf.SampleRate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.dogstatsd, s.tags = true, conf.Tags
+	return s, nil
+}
+
+// newStatsdSink - Creates a statsd sink from a statsd config.
+func newStatsdSink(conf StatsdConfig) (*StatsdSink, error) {
+	if conf.SampleRate <= 0 || conf.SampleRate > 1 {
+		return nil, errors.New("statsd sample rate must be greater than 0 and at most 1")
+	}
+	switch conf.Network {
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("statsd network not recognised: %v", conf.Network)
+	}
+	return &StatsdSink{
+		prefix:     expandPrefix(conf.Prefix),
+		sampleRate: conf.SampleRate,
+		writer:     newLineWriter(conf.Network, conf.Address, time.Second*5, conf.MaxPacketSize),
+	}, nil
+}
+
+// dogTags - Formats tags with the DogStatsD extension.
+func dogTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(tags))
+	for _, k := range sortedKeys(tags) {
+		parts = append(parts, k+":"+tags[k])
+	}
+	return "|#" + strings.Join(parts, ",")
+}
+
+// statsdLines - Formats a snapshot as lines of the statsd protocol. Negative gauges are preceded
+// by a zero gauge as statsd otherwise treats a signed gauge value as a change.
+func (s *StatsdSink) statsdLines(snap Snapshot) []string {
+	lines := []string{}
+	for _, path := range snap.Paths() {
+		name, suffix := s.prefix+path, ""
+		if s.dogstatsd {
+			var tags map[string]string
+			name, tags = splitTags(path)
+			name, suffix = s.prefix+name, dogTags(mergeTags(s.tags, tags))
+		}
+
+		if delta, ok := snap.CounterDeltas[path]; ok && delta != 0 {
+			lines = append(lines, name+":"+strconv.FormatInt(delta, 10)+"|c"+suffix)
+		}
+		if value, ok := snap.Gauges[path]; ok {
+			if value < 0 {
+				lines = append(lines, name+":0|g"+suffix)
+			}
+			lines = append(lines, name+":"+strconv.FormatInt(value, 10)+"|g"+suffix)
+		}
+		for _, timing := range snap.Timings[path] {
+			if s.sampleRate < 1 {
+				if rand.Float64() >= s.sampleRate {
+					continue
+				}
+				lines = append(lines, name+":"+strconv.FormatInt(timing, 10)+"|ms|@"+
+					strconv.FormatFloat(s.sampleRate, 'f', -1, 64)+suffix)
+				continue
+			}
+			lines = append(lines, name+":"+strconv.FormatInt(timing, 10)+"|ms"+suffix)
+		}
+	}
+	return lines
+}
+
+// Flush - Writes a snapshot to statsd.
+func (s *StatsdSink) Flush(snap Snapshot) error {
+	return s.writer.writeLines(s.statsdLines(snap))
+}
+
+// Close - Closes the connection to statsd.
+func (s *StatsdSink) Close() error {
+	return s.writer.close()
+}
+
+//--------------------------------------------------------------------------------------------------
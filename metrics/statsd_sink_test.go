@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestStatsdSinkLines(t *testing.T) {
+	conf := NewStatsdConfig()
+	conf.Prefix = "foo."
+
+	s, err := newStatsdSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := s.statsdLines(Snapshot{
+		Counters:      map[string]int64{"a": 10, "b": 3},
+		CounterDeltas: map[string]int64{"a": 2},
+		Gauges:        map[string]int64{"c": -4},
+		Timings:       map[string][]int64{"d": {5, 6}},
+	})
+	exp := []string{
+		"foo.a:2|c",
+		"foo.c:0|g",
+		"foo.c:-4|g",
+		"foo.d:5|ms",
+		"foo.d:6|ms",
+	}
+	if !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong lines: %v != %v", exp, lines)
+	}
+
+	conf.SampleRate = 0.5
+	if s, err = newStatsdSink(conf); err != nil {
+		t.Fatal(err)
+	}
+	lines = s.statsdLines(Snapshot{Timings: map[string][]int64{"d": make([]int64, 100)}})
+	if len(lines) == 0 || len(lines) == 100 {
+		t.Errorf("Expected timings to be sampled: %v", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.HasSuffix(line, "|ms|@0.5") {
+			t.Errorf("Wrong sampled line: %v", line)
+		}
+	}
+
+	conf.SampleRate = 0
+	if _, err = newStatsdSink(conf); err == nil {
+		t.Error("Expected error from zero sample rate")
+	}
+}
+
+func TestStatsdSinkFlush(t *testing.T) {
+	pc := listenUDP(t)
+	defer pc.Close()
+
+	conf := NewStatsdConfig()
+	conf.Address = pc.LocalAddr().String()
+
+	s, err := newStatsdSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err = s.Flush(Snapshot{Gauges: map[string]int64{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []string{"a:1|g\n"}, readPackets(t, pc, 1); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong packets: %v != %v", exp, act)
+	}
+}
+
+func TestDogStatsdSinkLines(t *testing.T) {
+	conf := NewConfig()
+	conf.DogStatsd.Tags = map[string]string{"env": "prod"}
+
+	sink, err := NewDogStatsdSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := Tagged("requests", map[string]string{"code": "200"})
+	lines := sink.(*StatsdSink).statsdLines(Snapshot{
+		Counters:      map[string]int64{requests: 3},
+		CounterDeltas: map[string]int64{requests: 3},
+		Gauges:        map[string]int64{"up": 1},
+	})
+	exp := []string{
+		"requests:3|c|#code:200,env:prod",
+		"up:1|g|#env:prod",
+	}
+	if !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong lines: %v != %v", exp, lines)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"sort"
+	"strings"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+/*
+Tagged - Returns a stat path carrying tags, which sinks with dimensional metrics are able to split
+back out, e.g. Tagged("http.requests", map[string]string{"code": "200"}) returns
+"http.requests;code=200". Tags are sorted by key so that the same tags always produce the same path.
+Types and sinks that are not aware of tags treat the tagged path as the name of the stat.
+*/
+func Tagged(path string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return path
+	}
+	parts := []string{path}
+	for _, k := range sortedKeys(tags) {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ";")
+}
+
+// splitTags - Splits a path created with Tagged into the name of the stat and its tags.
+func splitTags(path string) (string, map[string]string) {
+	if !strings.Contains(path, ";") {
+		return path, nil
+	}
+	parts := strings.Split(path, ";")
+	tags := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if kv := strings.SplitN(part, "=", 2); len(kv) == 2 {
+			tags[kv[0]] = kv[1]
+		}
+	}
+	return parts[0], tags
+}
+
+// mergeTags - Returns the union of two sets of tags, where tags of b take precedence.
+func mergeTags(a, b map[string]string) map[string]string {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// sortedKeys - Returns the keys of a set of tags in alphabetical order.
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//--------------------------------------------------------------------------------------------------
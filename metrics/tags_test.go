@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestTagged(t *testing.T) {
+	path := Tagged("http.requests", map[string]string{"method": "GET", "code": "200"})
+	if exp, act := "http.requests;code=200;method=GET", path; exp != act {
+		t.Errorf("Wrong path: %v != %v", exp, act)
+	}
+
+	name, tags := splitTags(path)
+	if exp, act := "http.requests", name; exp != act {
+		t.Errorf("Wrong name: %v != %v", exp, act)
+	}
+	if exp, act := map[string]string{"method": "GET", "code": "200"}, tags; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong tags: %v != %v", exp, act)
+	}
+
+	if exp, act := "foo", Tagged("foo", nil); exp != act {
+		t.Errorf("Wrong path: %v != %v", exp, act)
+	}
+	if name, tags = splitTags("foo"); name != "foo" || tags != nil {
+		t.Errorf("Wrong split of untagged path: %v, %v", name, tags)
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
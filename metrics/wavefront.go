@@ -0,0 +1,203 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["wavefront"] = sinkSpec{
+		constructor: NewWavefrontSink,
+		description: `
+Sends snapshots in the Wavefront data format, either to a Wavefront proxy over
+tcp when 'mode' is "proxy", or to the direct ingestion API of a Wavefront
+cluster with an API token when 'mode' is "direct". Counters are sent as running
+totals, gauges as they are and timings as a count, min, max, mean and p99. Each
+point is given the configured source, which defaults to the hostname, and the
+tags of the config and of stats created with metrics.Tagged become point tags.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WavefrontConfig - Config for the Wavefront sink.
+type WavefrontConfig struct {
+	Mode    string            `json:"mode" yaml:"mode"`
+	Address string            `json:"address" yaml:"address"`
+	URL     string            `json:"url" yaml:"url"`
+	Token   string            `json:"token" yaml:"token"`
+	Prefix  string            `json:"prefix" yaml:"prefix"`
+	Source  string            `json:"source" yaml:"source"`
+	Tags    map[string]string `json:"tags" yaml:"tags"`
+	Timeout string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewWavefrontConfig - Creates a WavefrontConfig struct with default values.
+func NewWavefrontConfig() WavefrontConfig {
+	return WavefrontConfig{
+		Mode:    "proxy",
+		Address: "localhost:2878",
+		URL:     "",
+		Token:   "",
+		Prefix:  "",
+		Source:  "",
+		Tags:    map[string]string{},
+		Timeout: "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WavefrontSink - A Sink that writes snapshots in the Wavefront data format.
+type WavefrontSink struct {
+	prefix string
+	source string
+	tags   map[string]string
+
+	writer    *lineWriter
+	reportURL string
+	headers   map[string]string
+	sender    func(url string, body []byte, headers map[string]string) error
+}
+
+// NewWavefrontSink - Create a new Wavefront sink.
+func NewWavefrontSink(config Config) (Sink, error) {
+	conf := config.Wavefront
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	source := conf.Source
+	if len(source) == 0 {
+		if source, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to resolve hostname for source: %v", err)
+		}
+	}
+	w := &WavefrontSink{
+		prefix: expandPrefix(conf.Prefix),
+		source: source,
+		tags:   conf.Tags,
+	}
+	switch conf.Mode {
+	case "proxy":
+		w.writer = newLineWriter("tcp", conf.Address, timeout, 0)
+	case "direct":
+		if len(conf.URL) == 0 || len(conf.Token) == 0 {
+			return nil, fmt.Errorf("wavefront url and token must not be empty in direct mode")
+		}
+		client, err := newSinkHTTPClient(conf.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.reportURL = strings.TrimSuffix(conf.URL, "/") + "/report?f=wavefront"
+		w.headers = map[string]string{
+			"Content-Type":  "text/plain",
+			"Authorization": "Bearer " + conf.Token,
+		}
+		w.sender = func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		}
+	default:
+		return nil, fmt.Errorf("wavefront mode not recognised: %v", conf.Mode)
+	}
+	return w, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// wavefrontQuote - Quotes a metric name or tag value, escaping any quotes within it.
+func wavefrontQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+// wavefrontTagKey - Replaces any characters not permitted within point tag keys with underscores.
+func wavefrontTagKey(key string) string {
+	b := []byte(key)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// wavefrontLines - Formats a snapshot as lines of the Wavefront data format.
+func (w *WavefrontSink) wavefrontLines(snap Snapshot) []string {
+	ts := " " + strconv.FormatInt(snap.Time.Unix(), 10)
+
+	lines := []string{}
+	for _, path := range snap.Paths() {
+		name, pathTags := splitTags(path)
+		tags := mergeTags(w.tags, pathTags)
+		suffix := ts + " source=" + wavefrontQuote(w.source)
+		for _, k := range sortedKeys(tags) {
+			suffix += " " + wavefrontTagKey(k) + "=" + wavefrontQuote(tags[k])
+		}
+		line := func(metric string, value int64) string {
+			return wavefrontQuote(w.prefix+metric) + " " + strconv.FormatInt(value, 10) + suffix
+		}
+
+		if v, ok := snap.Counters[path]; ok {
+			lines = append(lines, line(name, v))
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			lines = append(lines, line(name, v))
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				lines = append(lines, line(name+"."+agg.name, agg.value))
+			}
+		}
+	}
+	return lines
+}
+
+// Flush - Writes a snapshot to a Wavefront proxy or posts it to the direct ingestion API.
+func (w *WavefrontSink) Flush(snap Snapshot) error {
+	lines := w.wavefrontLines(snap)
+	if w.writer != nil {
+		return w.writer.writeLines(lines)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return w.sender(w.reportURL, []byte(strings.Join(lines, "\n")+"\n"), w.headers)
+}
+
+// Close - Closes the connection to the Wavefront proxy.
+func (w *WavefrontSink) Close() error {
+	if w.writer != nil {
+		return w.writer.close()
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,162 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestWavefrontSinkLines(t *testing.T) {
+	conf := NewConfig()
+	conf.Wavefront.Prefix = "svc."
+	conf.Wavefront.Source = "web-1"
+	conf.Wavefront.Tags = map[string]string{"env": "prod"}
+
+	sink, err := NewWavefrontSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := sink.(*WavefrontSink)
+
+	requests := Tagged("requests", map[string]string{"status code": `"ok"`})
+	lines := w.wavefrontLines(Snapshot{
+		Time:     time.Unix(100, 0),
+		Counters: map[string]int64{requests: 9},
+		Gauges:   map[string]int64{"queue": 3},
+		Timings:  map[string][]int64{"latency": {2}},
+	})
+
+	tags := ` 100 source="web-1" env="prod"`
+	exp := []string{
+		`"svc.latency.count" 1` + tags,
+		`"svc.latency.min" 2` + tags,
+		`"svc.latency.max" 2` + tags,
+		`"svc.latency.mean" 2` + tags,
+		`"svc.latency.p99" 2` + tags,
+		`"svc.queue" 3` + tags,
+		`"svc.requests" 9` + tags + ` status_code="\"ok\""`,
+	}
+	if !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong lines:\n%v\n!=\n%v", exp, lines)
+	}
+}
+
+func TestWavefrontSinkProxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on tcp: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	conf := NewConfig()
+	conf.Wavefront.Address = ln.Addr().String()
+	conf.Wavefront.Source = "a"
+	sink, err := NewWavefrontSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err = sink.Flush(Snapshot{Time: time.Unix(100, 0), Gauges: map[string]int64{"b": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case line := <-received:
+		if exp := "\"b\" 1 100 source=\"a\"\n"; exp != line {
+			t.Errorf("Wrong line: %q != %q", exp, line)
+		}
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for line")
+	}
+}
+
+func TestWavefrontSinkDirect(t *testing.T) {
+	conf := NewConfig()
+	conf.Wavefront.Mode = "direct"
+	conf.Wavefront.URL = "https://example.wavefront.com/"
+	conf.Wavefront.Token = "foo"
+	conf.Wavefront.Source = "a"
+
+	sink, err := NewWavefrontSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := sink.(*WavefrontSink)
+
+	var sentURL string
+	var sentBody []byte
+	var sentHeaders map[string]string
+	w.sender = func(url string, body []byte, headers map[string]string) error {
+		sentURL, sentBody, sentHeaders = url, body, headers
+		return nil
+	}
+
+	if err = w.Flush(Snapshot{Time: time.Unix(100, 0), Gauges: map[string]int64{"b": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "https://example.wavefront.com/report?f=wavefront", sentURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", exp, act)
+	}
+	if exp, act := "Bearer foo", sentHeaders["Authorization"]; exp != act {
+		t.Errorf("Wrong authorization: %v != %v", exp, act)
+	}
+	if exp, act := "\"b\" 1 100 source=\"a\"\n", string(sentBody); exp != act {
+		t.Errorf("Wrong body: %q != %q", exp, act)
+	}
+
+	sentURL = ""
+	if err = w.Flush(Snapshot{}); err != nil || len(sentURL) > 0 {
+		t.Errorf("Expected empty snapshot to be skipped: %v", err)
+	}
+}
+
+func TestWavefrontSinkBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Wavefront.Mode = "direct"
+	if _, err := NewWavefrontSink(conf); err == nil {
+		t.Error("Expected error from missing url and token")
+	}
+	conf.Wavefront.Mode = "nope"
+	if _, err := NewWavefrontSink(conf); err == nil {
+		t.Error("Expected error from bad mode")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,176 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["webhook"] = sinkSpec{
+		constructor: NewWebhookSink,
+		description: `
+Posts each snapshot as a JSON document to every configured URL, as a catch-all
+integration for bespoke collectors. A failed post is retried up to max_retries
+times with a jittered exponential backoff between backoff and max_backoff, and a
+URL that still fails does not prevent the others from being posted to. When a
+secret is configured each body is signed with HMAC-SHA256, where the hex encoded
+signature is sent as 'sha256=<signature>' in the signature header alongside the
+Unix time of the flush in X-Webhook-Timestamp.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WebhookConfig - Config for the generic webhook sink.
+type WebhookConfig struct {
+	URLs            []string          `json:"urls" yaml:"urls"`
+	Headers         map[string]string `json:"headers" yaml:"headers"`
+	Secret          string            `json:"secret" yaml:"secret"`
+	SignatureHeader string            `json:"signature_header" yaml:"signature_header"`
+	MaxRetries      int               `json:"max_retries" yaml:"max_retries"`
+	Backoff         string            `json:"backoff" yaml:"backoff"`
+	MaxBackoff      string            `json:"max_backoff" yaml:"max_backoff"`
+	Timeout         string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewWebhookConfig - Creates a WebhookConfig struct with default values.
+func NewWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		URLs:            []string{},
+		Headers:         map[string]string{},
+		Secret:          "",
+		SignatureHeader: "X-Webhook-Signature",
+		MaxRetries:      3,
+		Backoff:         "500ms",
+		MaxBackoff:      "10s",
+		Timeout:         "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// WebhookSink - A Sink that posts snapshots as JSON to a list of URLs.
+type WebhookSink struct {
+	conf       WebhookConfig
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	sleep      func(time.Duration)
+	sender     func(url string, body []byte, headers map[string]string) error
+}
+
+// NewWebhookSink - Create a new webhook sink.
+func NewWebhookSink(config Config) (Sink, error) {
+	conf := config.Webhook
+	if len(conf.URLs) == 0 {
+		return nil, fmt.Errorf("webhook urls must not be empty")
+	}
+	minBackoff, err := time.ParseDuration(conf.Backoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse backoff: %v", err)
+	}
+	maxBackoff, err := time.ParseDuration(conf.MaxBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse max backoff: %v", err)
+	}
+	client, err := newSinkHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookSink{
+		conf:       conf,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		sleep:      time.Sleep,
+		sender: func(url string, body []byte, headers map[string]string) error {
+			return sendHTTP(client, "POST", url, body, headers)
+		},
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// headers - Returns the headers of a post, including the signature of the body when a secret is
+// configured.
+func (w *WebhookSink) headers(body []byte, ts time.Time) map[string]string {
+	headers := map[string]string{}
+	for k, v := range w.conf.Headers {
+		headers[k] = v
+	}
+	headers["Content-Type"] = "application/json"
+	if len(w.conf.Secret) > 0 {
+		mac := hmac.New(sha256.New, []byte(w.conf.Secret))
+		mac.Write(body)
+		headers[w.conf.SignatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		headers["X-Webhook-Timestamp"] = strconv.FormatInt(ts.Unix(), 10)
+	}
+	return headers
+}
+
+// post - Posts a body to a URL, retrying with a backoff until it succeeds or the retries are spent.
+func (w *WebhookSink) post(url string, body []byte, headers map[string]string) error {
+	backoff := w.minBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.sender(url, body, headers)
+		if err == nil || attempt >= w.conf.MaxRetries {
+			return err
+		}
+		if backoff > 0 {
+			w.sleep(jitter(backoff))
+		}
+		if backoff *= 2; backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+// Flush - Posts a snapshot to every URL, returning the first error after all have been attempted.
+func (w *WebhookSink) Flush(snap Snapshot) error {
+	body, err := marshalSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	headers := w.headers(body, snap.Time)
+
+	var firstErr error
+	for _, url := range w.conf.URLs {
+		if err = w.post(url, body, headers); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to post to %v: %v", url, err)
+		}
+	}
+	return firstErr
+}
+
+// Close - Does nothing as each flush is a separate request.
+func (w *WebhookSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
@@ -0,0 +1,114 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func TestWebhookSinkFlush(t *testing.T) {
+	conf := NewConfig()
+	conf.Webhook.URLs = []string{"http://a/stats", "http://b/stats"}
+	conf.Webhook.Headers = map[string]string{"X-Source": "benthos"}
+	conf.Webhook.Secret = "shh"
+	conf.Webhook.Backoff = "1s"
+	conf.Webhook.MaxBackoff = "3s"
+
+	sink, err := NewWebhookSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := sink.(*WebhookSink)
+
+	var sleeps []time.Duration
+	w.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+	attempts := map[string]int{}
+	var sentBody []byte
+	var sentHeaders map[string]string
+	w.sender = func(url string, body []byte, headers map[string]string) error {
+		attempts[url]++
+		sentBody, sentHeaders = body, headers
+		if url == "http://a/stats" {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+
+	snap := Snapshot{Time: time.Unix(100, 0), Gauges: map[string]int64{"a": 1}}
+	if err = w.Flush(snap); err == nil {
+		t.Error("Expected error from failing url")
+	}
+	if exp := map[string]int{"http://a/stats": 4, "http://b/stats": 1}; !reflect.DeepEqual(exp, attempts) {
+		t.Errorf("Wrong attempts: %v != %v", exp, attempts)
+	}
+	if len(sleeps) != 3 {
+		t.Fatalf("Wrong count of sleeps: %v", sleeps)
+	}
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		if sleeps[i] < max/2 || sleeps[i] > max {
+			t.Errorf("Wrong backoff %v: %v", i, sleeps[i])
+		}
+	}
+
+	exp, _ := marshalSnapshot(snap)
+	if !reflect.DeepEqual(exp, sentBody) {
+		t.Errorf("Wrong body: %s != %s", exp, sentBody)
+	}
+	mac := hmac.New(sha256.New, []byte("shh"))
+	mac.Write(sentBody)
+	for k, v := range map[string]string{
+		"Content-Type":        "application/json",
+		"X-Source":            "benthos",
+		"X-Webhook-Signature": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+		"X-Webhook-Timestamp": "100",
+	} {
+		if act := sentHeaders[k]; v != act {
+			t.Errorf("Wrong %v header: %v != %v", k, v, act)
+		}
+	}
+}
+
+func TestWebhookSinkUnsigned(t *testing.T) {
+	w := &WebhookSink{}
+	if _, ok := w.headers([]byte("{}"), time.Unix(100, 0))["X-Webhook-Timestamp"]; ok {
+		t.Error("Expected no signature headers without a secret")
+	}
+}
+
+func TestWebhookSinkURLs(t *testing.T) {
+	if _, err := NewWebhookSink(NewConfig()); err == nil {
+		t.Error("Expected error from missing urls")
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
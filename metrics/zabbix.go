@@ -0,0 +1,249 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+func init() {
+	sinkConstructors["zabbix"] = sinkSpec{
+		constructor: NewZabbixSink,
+		description: `
+Sends snapshots to a Zabbix server or proxy using the sender (trapper) protocol,
+where each stat is sent as the value of a trapper item of the configured host.
+The key of an item is the path of the stat, with the tag values of stats created
+with metrics.Tagged as parameters ordered by tag key, e.g. 'http.requests[200]'.
+Counters are sent as running totals, gauges as their value and timings as the
+items '<path>.count', '.min', '.max', '.mean' and '.p99'. A flush fails when the
+server reports that any of the values failed to be processed.`,
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// ZabbixConfig - Config for the Zabbix sender sink.
+type ZabbixConfig struct {
+	Address string `json:"address" yaml:"address"`
+	Host    string `json:"host" yaml:"host"`
+	Timeout string `json:"timeout" yaml:"timeout"`
+}
+
+// NewZabbixConfig - Creates a ZabbixConfig struct with default values.
+func NewZabbixConfig() ZabbixConfig {
+	return ZabbixConfig{
+		Address: "localhost:10051",
+		Host:    "",
+		Timeout: "5s",
+	}
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// Errors for the Zabbix sink.
+var (
+	ErrZabbixResponse = errors.New("zabbix response was not recognised")
+)
+
+// zabbixHeader - The header of every Zabbix protocol packet, followed by the little endian 64 bit
+// length of the data.
+var zabbixHeader = []byte("ZBXD\x01")
+
+// ZabbixSink - A Sink that sends snapshots to Zabbix as trapper items.
+type ZabbixSink struct {
+	address string
+	host    string
+	timeout time.Duration
+}
+
+// NewZabbixSink - Create a new Zabbix sender sink.
+func NewZabbixSink(config Config) (Sink, error) {
+	conf := config.Zabbix
+	if len(conf.Host) == 0 {
+		return nil, fmt.Errorf("zabbix host must not be empty")
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	return &ZabbixSink{
+		address: conf.Address,
+		host:    conf.Host,
+		timeout: timeout,
+	}, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+
+type (
+	zabbixRequest struct {
+		Request string       `json:"request"`
+		Data    []zabbixItem `json:"data"`
+		Clock   int64        `json:"clock"`
+	}
+	zabbixItem struct {
+		Host  string `json:"host"`
+		Key   string `json:"key"`
+		Value string `json:"value"`
+		Clock int64  `json:"clock"`
+	}
+	zabbixResponse struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+)
+
+// zabbixKey - Returns the item key of a stat path, with tag values as key parameters.
+func zabbixKey(path string) string {
+	name, tags := splitTags(path)
+	if len(tags) == 0 {
+		return name
+	}
+	params := []string{}
+	for _, k := range sortedKeys(tags) {
+		v := tags[k]
+		if strings.ContainsAny(v, `,]" `) {
+			v = strconv.Quote(v)
+		}
+		params = append(params, v)
+	}
+	return name + "[" + strings.Join(params, ",") + "]"
+}
+
+// zabbixItems - Converts a snapshot into Zabbix items.
+func (z *ZabbixSink) zabbixItems(snap Snapshot) []zabbixItem {
+	clock := snap.Time.Unix()
+	items := []zabbixItem{}
+	add := func(key string, value int64) {
+		items = append(items, zabbixItem{
+			Host:  z.host,
+			Key:   key,
+			Value: strconv.FormatInt(value, 10),
+			Clock: clock,
+		})
+	}
+	for _, path := range snap.Paths() {
+		if v, ok := snap.Counters[path]; ok {
+			add(zabbixKey(path), v)
+		}
+		if v, ok := snap.Gauges[path]; ok {
+			add(zabbixKey(path), v)
+		}
+		if samples := snap.Timings[path]; len(samples) > 0 {
+			name, tags := splitTags(path)
+			for _, agg := range SummariseTimings(samples).aggregates() {
+				add(zabbixKey(Tagged(name+"."+agg.name, tags)), agg.value)
+			}
+		}
+	}
+	return items
+}
+
+//--------------------------------------------------------------------------------------------------
+
+// zabbixFailed - Parses the count of failed items from the info of a response.
+var zabbixFailed = regexp.MustCompile(`failed: (\d+)`)
+
+// Flush - Sends a snapshot to Zabbix over a new connection and checks the response.
+func (z *ZabbixSink) Flush(snap Snapshot) error {
+	items := z.zabbixItems(snap)
+	if len(items) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(zabbixRequest{
+		Request: "sender data",
+		Data:    items,
+		Clock:   snap.Time.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", z.address, z.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(z.timeout))
+
+	packet := make([]byte, len(zabbixHeader)+8, len(zabbixHeader)+8+len(data))
+	copy(packet, zabbixHeader)
+	binary.LittleEndian.PutUint64(packet[len(zabbixHeader):], uint64(len(data)))
+	if _, err = conn.Write(append(packet, data...)); err != nil {
+		return err
+	}
+
+	res, err := readZabbixPacket(conn)
+	if err != nil {
+		return err
+	}
+	var response zabbixResponse
+	if err = json.Unmarshal(res, &response); err != nil {
+		return ErrZabbixResponse
+	}
+	if response.Response != "success" {
+		return fmt.Errorf("zabbix responded with: %v %v", response.Response, response.Info)
+	}
+	if m := zabbixFailed.FindStringSubmatch(response.Info); len(m) == 2 && m[1] != "0" {
+		return fmt.Errorf("zabbix failed to process items: %v", response.Info)
+	}
+	return nil
+}
+
+// readZabbixPacket - Reads the data of a single Zabbix protocol packet.
+func readZabbixPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(zabbixHeader)]) != string(zabbixHeader) {
+		return nil, ErrZabbixResponse
+	}
+	size := binary.LittleEndian.Uint64(header[len(zabbixHeader):])
+	if size > 1<<20 {
+		return nil, ErrZabbixResponse
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Close - Does nothing as each flush uses a new connection.
+func (z *ZabbixSink) Close() error {
+	return nil
+}
+
+//--------------------------------------------------------------------------------------------------
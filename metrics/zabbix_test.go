@@ -0,0 +1,139 @@
+/*
+Copyright (c) 2014 Ashley Jeffs
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+//--------------------------------------------------------------------------------------------------
+
+// serveZabbix - Accepts a single sender request and responds with info.
+func serveZabbix(t *testing.T, info string) (string, <-chan zabbixRequest) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on tcp: %v", err)
+	}
+	requests := make(chan zabbixRequest, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		data, err := readZabbixPacket(conn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var req zabbixRequest
+		if err = json.Unmarshal(data, &req); err != nil {
+			t.Error(err)
+		}
+		requests <- req
+
+		res, _ := json.Marshal(zabbixResponse{Response: "success", Info: info})
+		header := make([]byte, 8)
+		binary.LittleEndian.PutUint64(header, uint64(len(res)))
+		conn.Write(append(append(append([]byte{}, zabbixHeader...), header...), res...))
+	}()
+	return ln.Addr().String(), requests
+}
+
+func TestZabbixSinkFlush(t *testing.T) {
+	addr, requests := serveZabbix(t, "processed: 7; failed: 0; total: 7; seconds spent: 0.000055")
+
+	conf := NewConfig()
+	conf.Zabbix.Address = addr
+	conf.Zabbix.Host = "foo"
+
+	sink, err := NewZabbixSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Flush(Snapshot{
+		Time:     time.Unix(100, 0),
+		Counters: map[string]int64{Tagged("requests", map[string]string{"code": "200"}): 5},
+		Timings:  map[string][]int64{"latency": {2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if exp, act := "sender data", req.Request; exp != act {
+		t.Errorf("Wrong request: %v != %v", exp, act)
+	}
+	exp := []string{
+		"latency.count=1", "latency.min=2", "latency.max=2", "latency.mean=2", "latency.p99=2",
+		"requests[200]=5",
+	}
+	if len(req.Data) != len(exp) {
+		t.Fatalf("Wrong count of items: %v != %v", len(exp), len(req.Data))
+	}
+	for i, item := range req.Data {
+		if act := item.Key + "=" + item.Value; exp[i] != act {
+			t.Errorf("Wrong item: %v != %v", exp[i], act)
+		}
+		if item.Host != "foo" || item.Clock != 100 {
+			t.Errorf("Wrong item host or clock: %+v", item)
+		}
+	}
+}
+
+func TestZabbixSinkFailedItems(t *testing.T) {
+	addr, _ := serveZabbix(t, "processed: 0; failed: 1; total: 1; seconds spent: 0.000055")
+
+	conf := NewConfig()
+	conf.Zabbix.Address = addr
+	conf.Zabbix.Host = "foo"
+
+	sink, err := NewZabbixSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Flush(Snapshot{Gauges: map[string]int64{"a": 1}}); err == nil {
+		t.Error("Expected error from failed items")
+	}
+}
+
+func TestZabbixKey(t *testing.T) {
+	tests := map[string]string{
+		"a.b": "a.b",
+		Tagged("a", map[string]string{"y": "2", "x": "1"}): "a[1,2]",
+		Tagged("a", map[string]string{"x": "b c"}):         `a["b c"]`,
+	}
+	for path, exp := range tests {
+		if act := zabbixKey(path); exp != act {
+			t.Errorf("Wrong key: %v != %v", exp, act)
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------------------------